@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsdcapture
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/command"
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/impl"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// analyzeParams are the command-line arguments for the analyze subcommand
+type analyzeParams struct {
+	*command.GlobalParams
+
+	path     string
+	mmap     bool
+	nmetrics int
+	ntags    int
+	norigins int
+}
+
+// analyzeCommand returns the 'agent dogstatsd-capture analyze' subcommand,
+// which doesn't need a running agent.
+func analyzeCommand(globalParams *command.GlobalParams) *cobra.Command {
+	params := &analyzeParams{
+		GlobalParams: globalParams,
+	}
+
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze <capture file>",
+		Short: "Analyze the traffic of a dogstatsd capture file",
+		Long:  `Report the top metrics, the tag cardinality of each metric, the packet sizes and the rates of each origin of a capture file.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			params.path = args[0]
+			return fxutil.OneShot(analyzeCapture,
+				fx.Supply(params),
+			)
+		},
+	}
+	analyzeCmd.Flags().BoolVar(&params.mmap, "mmap", true, "Mmap the capture file. Set to false to load the entire file into memory instead")
+	analyzeCmd.Flags().IntVarP(&params.nmetrics, "num-metrics", "m", 10, "number of metrics to show")
+	analyzeCmd.Flags().IntVarP(&params.ntags, "num-tags", "t", 5, "number of tags to show per metric")
+	analyzeCmd.Flags().IntVarP(&params.norigins, "num-origins", "o", 10, "number of origins to show")
+
+	return analyzeCmd
+}
+
+func analyzeCapture(params *analyzeParams) error {
+	reader, err := replay.NewTrafficCaptureReader(params.path, 1, params.mmap)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %v", params.path, err)
+	}
+	defer reader.Close()
+
+	analysis, err := replay.AnalyzeCapture(reader)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", params.path, err)
+	}
+
+	printAnalysis(os.Stdout, analysis, params)
+	return nil
+}
+
+func printAnalysis(w io.Writer, analysis *replay.CaptureAnalysis, params *analyzeParams) {
+	fmt.Fprintf(w, "Packets:         %d over %v (%.2f/s)\n", analysis.Packets, analysis.Duration, analysis.Rate(analysis.Packets))
+	fmt.Fprintf(w, "Bytes:           %d (%.2f/s)\n", analysis.Bytes, analysis.Rate(analysis.Bytes))
+	fmt.Fprintf(w, "Packet sizes:    min %d, p50 %d, p95 %d, p99 %d, max %d\n",
+		analysis.PacketSizePercentile(0),
+		analysis.PacketSizePercentile(50),
+		analysis.PacketSizePercentile(95),
+		analysis.PacketSizePercentile(99),
+		analysis.PacketSizePercentile(100))
+	fmt.Fprintf(w, "Events:          %d\n", analysis.Events)
+	fmt.Fprintf(w, "Service checks:  %d\n", analysis.ServiceChecks)
+	fmt.Fprintf(w, "\n % 10s\t% 10s\t% 10s\t%s\t(%s)\n", "Samples", "Samples/s", "Contexts", "Metric name", "number of unique values for each tag")
+	top, rest := analysis.Metrics, []*replay.MetricAnalysis(nil)
+	// +1 to avoid showing "1 more", just show it.
+	if len(top) > params.nmetrics+1 {
+		top, rest = top[:params.nmetrics], top[params.nmetrics:]
+	}
+	for _, m := range top {
+		fmt.Fprintf(w, " % 10d\t% 10.2f\t% 10d\t%s\t(", m.Samples, analysis.Rate(m.Samples), m.Contexts, m.Name)
+		printTopTags(w, m, params.ntags)
+		fmt.Fprintln(w, ")")
+	}
+	if len(rest) > 0 {
+		var sum int
+		for _, m := range rest {
+			sum += m.Samples
+		}
+		fmt.Fprintf(w, " % 10d\t% 10.2f\t% 10s\t(other %d metrics)\n", sum, analysis.Rate(sum), "", len(rest))
+	}
+
+	fmt.Fprintf(w, "\n % 10s\t% 10s\t% 10s\t% 10s\t%s\n", "Packets", "Packets/s", "Samples/s", "Bytes/s", "Origin")
+	origins := analysis.Origins
+	if len(origins) > params.norigins+1 {
+		origins = origins[:params.norigins]
+	}
+	for _, o := range origins {
+		fmt.Fprintf(w, " % 10d\t% 10.2f\t% 10.2f\t% 10.2f\t%s\n", o.Packets, analysis.Rate(o.Packets), analysis.Rate(o.Samples), analysis.Rate(o.Bytes), o.Origin)
+	}
+	if len(origins) < len(analysis.Origins) {
+		fmt.Fprintf(w, " (other %d origins)\n", len(analysis.Origins)-len(origins))
+	}
+}
+
+func printTopTags(w io.Writer, m *replay.MetricAnalysis, limit int) {
+	ks := make([]string, 0, len(m.TagValues))
+	for k := range m.TagValues {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		n := m.TagValues[ks[i]]
+		o := m.TagValues[ks[j]]
+		if n == o {
+			return ks[i] < ks[j]
+		}
+		return n > o
+	})
+
+	top := ks
+	rest := []string{}
+	// +1 to avoid showing "1 more", just show it.
+	if len(ks) > limit+1 {
+		top = ks[:limit]
+		rest = ks[limit:]
+	}
+
+	for i, k := range top {
+		if i > 0 {
+			fmt.Fprint(w, ", ")
+		}
+		fmt.Fprintf(w, "%s: %d", k, m.TagValues[k])
+	}
+	if len(rest) > 0 {
+		fmt.Fprintf(w, ", %d more", len(rest))
+	}
+}
@@ -40,9 +40,13 @@ const (
 type cliParams struct {
 	*command.GlobalParams
 
-	dsdCaptureDuration   time.Duration
-	dsdCaptureFilePath   string
-	dsdCaptureCompressed bool
+	dsdCaptureDuration     time.Duration
+	dsdCaptureFilePath     string
+	dsdCaptureCompressed   bool
+	dsdCaptureMetricNames  []string
+	dsdCapturePids         []int32
+	dsdCaptureContainerIDs []string
+	dsdCaptureScrubTags    []string
 }
 
 // Commands returns a slice of subcommands for the 'agent' command.
@@ -54,7 +58,10 @@ func Commands(globalParams *command.GlobalParams) []*cobra.Command {
 	dogstatsdCaptureCmd := &cobra.Command{
 		Use:   "dogstatsd-capture",
 		Short: "Start a dogstatsd UDS traffic capture",
-		Long:  ``,
+		Long: `Start a dogstatsd UDS traffic capture.
+
+The captured traffic can be restricted to some metrics and origins, and the values of sensitive tags can be
+scrubbed, before handing the capture file over. Use 'dogstatsd-capture analyze' to inspect a capture file.`,
 		RunE: func(_ *cobra.Command, _ []string) error {
 			return fxutil.OneShot(dogstatsdCapture,
 				fx.Supply(cliParams),
@@ -67,6 +74,12 @@ func Commands(globalParams *command.GlobalParams) []*cobra.Command {
 	dogstatsdCaptureCmd.Flags().DurationVarP(&cliParams.dsdCaptureDuration, "duration", "d", defaultCaptureDuration, "Duration traffic capture should span.")
 	dogstatsdCaptureCmd.Flags().StringVarP(&cliParams.dsdCaptureFilePath, "path", "p", "", "Directory path to write the capture to.")
 	dogstatsdCaptureCmd.Flags().BoolVarP(&cliParams.dsdCaptureCompressed, "compressed", "z", true, "Should capture be zstd compressed.")
+	dogstatsdCaptureCmd.Flags().StringSliceVarP(&cliParams.dsdCaptureMetricNames, "metric", "m", nil, "Only capture the metrics whose name matches one of these glob patterns, events and service checks are dropped.")
+	dogstatsdCaptureCmd.Flags().Int32SliceVar(&cliParams.dsdCapturePids, "pid", nil, "Only capture the traffic sent by these processes.")
+	dogstatsdCaptureCmd.Flags().StringSliceVar(&cliParams.dsdCaptureContainerIDs, "container-id", nil, "Only capture the traffic sent from these containers.")
+	dogstatsdCaptureCmd.Flags().StringSliceVarP(&cliParams.dsdCaptureScrubTags, "scrub-tag", "s", nil, "Replace the values of these tag keys by a hash in the capture.")
+
+	dogstatsdCaptureCmd.AddCommand(analyzeCommand(globalParams))
 
 	// shut up grpc client!
 	grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, io.Discard, io.Discard))
@@ -112,9 +125,13 @@ func dogstatsdCapture(_ log.Component, config config.Component, cliParams *cliPa
 	cli := pb.NewAgentSecureClient(conn)
 
 	resp, err := cli.DogstatsdCaptureTrigger(ctx, &pb.CaptureTriggerRequest{
-		Duration:   cliParams.dsdCaptureDuration.String(),
-		Path:       cliParams.dsdCaptureFilePath,
-		Compressed: cliParams.dsdCaptureCompressed,
+		Duration:     cliParams.dsdCaptureDuration.String(),
+		Path:         cliParams.dsdCaptureFilePath,
+		Compressed:   cliParams.dsdCaptureCompressed,
+		MetricNames:  cliParams.dsdCaptureMetricNames,
+		Pids:         cliParams.dsdCapturePids,
+		ContainerIds: cliParams.dsdCaptureContainerIDs,
+		ScrubTags:    cliParams.dsdCaptureScrubTags,
 	})
 	if err != nil {
 		return err
@@ -6,6 +6,7 @@
 package dogstatsdcapture
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/DataDog/datadog-agent/cmd/agent/command"
 	"github.com/DataDog/datadog-agent/comp/core"
 	"github.com/DataDog/datadog-agent/comp/core/secrets"
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/impl"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 )
 
@@ -28,3 +30,58 @@ func TestCommand(t *testing.T) {
 			require.Equal(t, false, secretParams.Enabled)
 		})
 }
+
+func TestCommandFilters(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		Commands(&command.GlobalParams{}),
+		[]string{"dogstatsd-capture", "-m", "http.*,db.*", "--pid", "42", "--container-id", "abc", "-s", "user_id", "-s", "email"},
+		dogstatsdCapture,
+		func(cliParams *cliParams) {
+			require.Equal(t, []string{"http.*", "db.*"}, cliParams.dsdCaptureMetricNames)
+			require.Equal(t, []int32{42}, cliParams.dsdCapturePids)
+			require.Equal(t, []string{"abc"}, cliParams.dsdCaptureContainerIDs)
+			require.Equal(t, []string{"user_id", "email"}, cliParams.dsdCaptureScrubTags)
+		})
+}
+
+func TestAnalyzeCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		Commands(&command.GlobalParams{}),
+		[]string{"dogstatsd-capture", "analyze", "datadog-capture.dog", "-m", "3", "--mmap=false"},
+		analyzeCapture,
+		func(params *analyzeParams) {
+			require.Equal(t, "datadog-capture.dog", params.path)
+			require.Equal(t, 3, params.nmetrics)
+			require.Equal(t, 5, params.ntags)
+			require.False(t, params.mmap)
+		})
+}
+
+func TestPrintAnalysis(t *testing.T) {
+	analysis := &replay.CaptureAnalysis{
+		Packets:     4,
+		Bytes:       400,
+		Duration:    2 * time.Second,
+		PacketSizes: []int{50, 100, 100, 150},
+		Metrics: []*replay.MetricAnalysis{
+			{Name: "http.requests", Samples: 6, Contexts: 3, TagValues: map[string]int{"env": 1, "user_id": 3}},
+			{Name: "queue.size", Samples: 2, Contexts: 1},
+			{Name: "db.queries", Samples: 1, Contexts: 1},
+		},
+		Origins: []*replay.OriginAnalysis{
+			{Origin: "container_id://abc", Packets: 3, Samples: 8, Bytes: 300},
+			{Origin: "unknown", Packets: 1, Samples: 1, Bytes: 100},
+		},
+	}
+
+	var out bytes.Buffer
+	printAnalysis(&out, analysis, &analyzeParams{nmetrics: 1, ntags: 1, norigins: 10})
+
+	output := out.String()
+	require.Contains(t, output, "Packets:         4 over 2s (2.00/s)")
+	require.Contains(t, output, "min 50, p50 100, p95 100, p99 100, max 150")
+	require.Contains(t, output, "\thttp.requests\t(user_id: 3, env: 1)\n")
+	require.Contains(t, output, "(other 2 metrics)")
+	require.NotContains(t, output, "queue.size")
+	require.Contains(t, output, "      4.00\t    150.00\tcontainer_id://abc\n")
+}
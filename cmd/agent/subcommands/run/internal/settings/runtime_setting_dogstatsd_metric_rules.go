@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/server"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+)
+
+// DsdMetricRulesRuntimeSetting wraps operations to change the dogstatsd metric rules at runtime.
+type DsdMetricRulesRuntimeSetting struct{}
+
+// NewDsdMetricRulesRuntimeSetting creates a new instance of DsdMetricRulesRuntimeSetting
+func NewDsdMetricRulesRuntimeSetting() *DsdMetricRulesRuntimeSetting {
+	return &DsdMetricRulesRuntimeSetting{}
+}
+
+// Description returns the runtime setting's description
+func (s *DsdMetricRulesRuntimeSetting) Description() string {
+	return "Set the rules renaming dogstatsd metrics and filtering their tags. Possible values: a JSON list of rules"
+}
+
+// Hidden returns whether or not this setting is hidden from the list of runtime settings
+func (s *DsdMetricRulesRuntimeSetting) Hidden() bool {
+	return false
+}
+
+// Name returns the name of the runtime setting
+func (s *DsdMetricRulesRuntimeSetting) Name() string {
+	return "dogstatsd_metric_rules"
+}
+
+// Get returns the current value of the runtime setting
+func (s *DsdMetricRulesRuntimeSetting) Get(config config.Component) (interface{}, error) {
+	return config.Get("dogstatsd_metric_rules"), nil
+}
+
+// Set changes the value of the runtime setting
+func (s *DsdMetricRulesRuntimeSetting) Set(config config.Component, v interface{}, source model.Source) error {
+	var raw []byte
+	switch value := v.(type) {
+	case string:
+		raw = []byte(value)
+	case []interface{}:
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return fmt.Errorf("DsdMetricRulesRuntimeSetting: %v", err)
+		}
+	default:
+		return fmt.Errorf("DsdMetricRulesRuntimeSetting: invalid data type %T", v)
+	}
+
+	var configs []server.MetricRuleConfig
+	if err := json.Unmarshal(raw, &configs); err != nil {
+		return fmt.Errorf("DsdMetricRulesRuntimeSetting: could not parse the rules: %v", err)
+	}
+	if err := server.ValidateMetricRules(configs); err != nil {
+		return fmt.Errorf("DsdMetricRulesRuntimeSetting: %v", err)
+	}
+
+	// the rules are stored in the same shape as when they are read from the
+	// configuration file, the dogstatsd server reloads them on update
+	var rules []interface{}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return fmt.Errorf("DsdMetricRulesRuntimeSetting: could not parse the rules: %v", err)
+	}
+	config.Set("dogstatsd_metric_rules", rules, source)
+	return nil
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+// HistogramOverridesRuntimeSetting wraps operations to change the histogram overrides at runtime.
+type HistogramOverridesRuntimeSetting struct{}
+
+// NewHistogramOverridesRuntimeSetting creates a new instance of HistogramOverridesRuntimeSetting
+func NewHistogramOverridesRuntimeSetting() *HistogramOverridesRuntimeSetting {
+	return &HistogramOverridesRuntimeSetting{}
+}
+
+// Description returns the runtime setting's description
+func (s *HistogramOverridesRuntimeSetting) Description() string {
+	return "Set the aggregates and percentiles of the histograms of some metrics. Possible values: a JSON list of overrides"
+}
+
+// Hidden returns whether or not this setting is hidden from the list of runtime settings
+func (s *HistogramOverridesRuntimeSetting) Hidden() bool {
+	return false
+}
+
+// Name returns the name of the runtime setting
+func (s *HistogramOverridesRuntimeSetting) Name() string {
+	return "histogram_overrides"
+}
+
+// Get returns the current value of the runtime setting
+func (s *HistogramOverridesRuntimeSetting) Get(config config.Component) (interface{}, error) {
+	return config.Get("histogram_overrides"), nil
+}
+
+// Set changes the value of the runtime setting
+func (s *HistogramOverridesRuntimeSetting) Set(config config.Component, v interface{}, source model.Source) error {
+	var raw []byte
+	switch value := v.(type) {
+	case string:
+		raw = []byte(value)
+	case []interface{}:
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return fmt.Errorf("HistogramOverridesRuntimeSetting: %v", err)
+		}
+	default:
+		return fmt.Errorf("HistogramOverridesRuntimeSetting: invalid data type %T", v)
+	}
+
+	var configs []metrics.HistogramOverrideConfig
+	if err := json.Unmarshal(raw, &configs); err != nil {
+		return fmt.Errorf("HistogramOverridesRuntimeSetting: could not parse the overrides: %v", err)
+	}
+	if _, err := metrics.NewHistogramOverrides(configs); err != nil {
+		return fmt.Errorf("HistogramOverridesRuntimeSetting: %v", err)
+	}
+
+	// the overrides are stored in the same shape as when they are read from
+	// the configuration file, the aggregator reloads them on update
+	var overrides []interface{}
+	if err := json.Unmarshal(raw, &overrides); err != nil {
+		return fmt.Errorf("HistogramOverridesRuntimeSetting: could not parse the overrides: %v", err)
+	}
+	config.Set("histogram_overrides", overrides, source)
+	return nil
+}
@@ -103,3 +103,61 @@ func TestDogstatsdMetricsStats(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(v, true)
 }
+
+func TestDogstatsdMetricRules(t *testing.T) {
+	cfg := config.NewMock(t)
+	s := NewDsdMetricRulesRuntimeSetting()
+
+	// JSON string, as sent by `agent config set`
+	err := s.Set(cfg, `[{"match": "test.*", "name": "test", "drop_tags": ["user_id"]}]`, model.SourceCLI)
+	assert.NoError(t, err)
+	v, err := s.Get(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"match": "test.*", "name": "test", "drop_tags": []interface{}{"user_id"}},
+	}, v)
+
+	// list of rules
+	err = s.Set(cfg, []interface{}{map[string]interface{}{"match": "other.*"}}, model.SourceCLI)
+	assert.NoError(t, err)
+	v, err = s.Get(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"match": "other.*"}}, v)
+
+	// invalid rules are rejected
+	assert.Error(t, s.Set(cfg, `{"match": "test.*"}`, model.SourceCLI))
+	assert.Error(t, s.Set(cfg, `[{"name": "test"}]`, model.SourceCLI))
+	assert.Error(t, s.Set(cfg, `[{"match": "test.(", "match_type": "regex"}]`, model.SourceCLI))
+	assert.Error(t, s.Set(cfg, 42, model.SourceCLI))
+	v, err = s.Get(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"match": "other.*"}}, v)
+}
+
+func TestHistogramOverrides(t *testing.T) {
+	cfg := config.NewMock(t)
+	s := NewHistogramOverridesRuntimeSetting()
+
+	err := s.Set(cfg, `[{"match": "*.latency", "aggregates": ["max"], "percentiles": [0.99]}]`, model.SourceCLI)
+	assert.NoError(t, err)
+	v, err := s.Get(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"match": "*.latency", "aggregates": []interface{}{"max"}, "percentiles": []interface{}{0.99}},
+	}, v)
+
+	err = s.Set(cfg, []interface{}{map[string]interface{}{"match": "my.*"}}, model.SourceCLI)
+	assert.NoError(t, err)
+	v, err = s.Get(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"match": "my.*"}}, v)
+
+	// invalid overrides are rejected
+	assert.Error(t, s.Set(cfg, `[{"aggregates": ["max"]}]`, model.SourceCLI))
+	assert.Error(t, s.Set(cfg, `[{"match": "*", "aggregates": ["p99"]}]`, model.SourceCLI))
+	assert.Error(t, s.Set(cfg, `[{"match": "*", "percentiles": [99]}]`, model.SourceCLI))
+	assert.Error(t, s.Set(cfg, 42, model.SourceCLI))
+	v, err = s.Get(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"match": "my.*"}}, v)
+}
@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package streammetrics implements 'agent stream-metrics'.
+package streammetrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/command"
+	"github.com/DataDog/datadog-agent/comp/core"
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	log "github.com/DataDog/datadog-agent/comp/core/log/def"
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/api/util"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// cliParams are the command-line arguments for this subcommand
+type cliParams struct {
+	*command.GlobalParams
+
+	filters aggregator.MetricStreamFilters
+
+	// duration represents the duration of the metric stream.
+	duration time.Duration
+}
+
+// Commands returns a slice of subcommands for the 'agent' command.
+func Commands(globalParams *command.GlobalParams) []*cobra.Command {
+	cliParams := &cliParams{
+		GlobalParams: globalParams,
+	}
+	cmd := &cobra.Command{
+		Use:   "stream-metrics",
+		Short: "Stream the metric samples received by the aggregator of a running agent",
+		Long: `Stream the metric samples the aggregator of a running agent receives from the checks and
+DogStatsD, with their resolved tags, or with --series the series and sketches it flushes
+to the serializer.`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return fxutil.OneShot(streamMetrics,
+				fx.Supply(cliParams),
+				fx.Supply(command.GetDefaultCoreBundleParams(cliParams.GlobalParams)),
+				core.Bundle(),
+			)
+		},
+	}
+	cmd.Flags().StringVar(&cliParams.filters.Name, "name", "", "Filter by metric name, glob patterns are supported")
+	cmd.Flags().StringSliceVar(&cliParams.filters.Tags, "tag", nil, "Filter by tag, can be repeated to require several tags")
+	cmd.Flags().StringVar(&cliParams.filters.Origin, "origin", "", "Filter the samples by origin: check ID, container ID or pod UID, glob patterns are supported")
+	cmd.Flags().BoolVar(&cliParams.filters.Series, "series", false, "Stream the flushed series and sketches instead of the received samples")
+	cmd.Flags().DurationVarP(&cliParams.duration, "duration", "d", 0, "Duration of the metric stream (default: 0, infinite)")
+	cmd.PreRunE = func(_ *cobra.Command, _ []string) error {
+		if cliParams.duration < 0 {
+			return fmt.Errorf("duration must be a positive value")
+		}
+		return nil
+	}
+
+	return []*cobra.Command{cmd}
+}
+
+func streamMetrics(_ log.Component, config config.Component, cliParams *cliParams) error {
+	ipcAddress, err := pkgconfigsetup.GetIPCAddress(pkgconfigsetup.Datadog())
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(&cliParams.filters)
+	if err != nil {
+		return err
+	}
+
+	urlstr := fmt.Sprintf("https://%v:%v/agent/stream-metrics", ipcAddress, config.GetInt("cmd_port"))
+	return streamRequest(urlstr, body, cliParams.duration, func(chunk []byte) {
+		fmt.Print(string(chunk))
+	})
+}
+
+func streamRequest(url string, body []byte, duration time.Duration, onChunk func([]byte)) error {
+	var e error
+	c := util.GetClient(false)
+	if duration != 0 {
+		c.Timeout = duration
+	}
+
+	// Set session token
+	e = util.SetAuthToken(pkgconfigsetup.Datadog())
+	if e != nil {
+		return e
+	}
+
+	e = util.DoPostChunked(c, url, "application/json", bytes.NewBuffer(body), onChunk)
+
+	if e == io.EOF {
+		return nil
+	}
+	if e != nil {
+		fmt.Printf("Could not reach agent: %v \nMake sure the agent is running before requesting the metric stream and contact support if you continue having issues. \n", e)
+	}
+	return e
+}
@@ -0,0 +1,31 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package streammetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/command"
+	"github.com/DataDog/datadog-agent/comp/core"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		Commands(&command.GlobalParams{}),
+		[]string{"stream-metrics", "--name", "my.*", "--tag", "env:prod", "--tag", "role:db", "--origin", "cpu*", "--series", "--duration", "10s"},
+		streamMetrics,
+		func(cliParams *cliParams, _ core.BundleParams) {
+			require.Equal(t, "my.*", cliParams.filters.Name)
+			require.Equal(t, []string{"env:prod", "role:db"}, cliParams.filters.Tags)
+			require.Equal(t, "cpu*", cliParams.filters.Origin)
+			require.True(t, cliParams.filters.Series)
+			require.Equal(t, 10*time.Second, cliParams.duration)
+		})
+}
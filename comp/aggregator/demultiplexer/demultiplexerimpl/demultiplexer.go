@@ -8,11 +8,14 @@ package demultiplexerimpl
 
 import (
 	"context"
+	"net/http"
 
 	"go.uber.org/fx"
 
 	demultiplexerComp "github.com/DataDog/datadog-agent/comp/aggregator/demultiplexer"
 	"github.com/DataDog/datadog-agent/comp/aggregator/diagnosesendermanager"
+	api "github.com/DataDog/datadog-agent/comp/api/api/def"
+	streamutils "github.com/DataDog/datadog-agent/comp/api/api/utils/stream"
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	log "github.com/DataDog/datadog-agent/comp/core/log/def"
 	"github.com/DataDog/datadog-agent/comp/core/status"
@@ -69,6 +72,7 @@ type provides struct {
 	SenderManager           sender.SenderManager
 	StatusProvider          status.InformationProvider
 	AggregatorDemultiplexer aggregator.Demultiplexer
+	StreamMetricsEndpoint   api.AgentEndpointProvider
 }
 
 func newDemultiplexer(deps dependencies) (provides, error) {
@@ -109,14 +113,22 @@ func newDemultiplexer(deps dependencies) (provides, error) {
 			Log: deps.Log,
 		}),
 		AggregatorDemultiplexer: demultiplexer,
+		StreamMetricsEndpoint:   api.NewAgentEndpointProvider(streamMetrics(agentDemultiplexer), "/stream-metrics", "POST"),
 	}, nil
 }
 
+func streamMetrics(demux *aggregator.AgentDemultiplexer) http.HandlerFunc {
+	return streamutils.GetFilteredStreamFunc(func() streamutils.FilteredMessageReceiver[aggregator.MetricStreamFilters] {
+		return demux.MetricsTap()
+	}, "metrics", "aggregator")
+}
+
 func createAgentDemultiplexerOptions(config config.Component, params Params) aggregator.AgentDemultiplexerOptions {
 	options := aggregator.DefaultAgentDemultiplexerOptions()
 	if params.useDogstatsdNoAggregationPipelineConfig {
 		options.EnableNoAggregationPipeline = config.GetBool("dogstatsd_no_aggregation_pipeline")
 	}
+	options.UseDogstatsdContextLimiter = config.GetBool("dogstatsd_context_limiter.enabled")
 
 	// Override FlushInterval only if flushInterval is set by the user
 	if v, ok := params.flushInterval.Get(); ok {
@@ -87,7 +87,14 @@ func (s *serverSecure) DogstatsdCaptureTrigger(_ context.Context, req *pb.Captur
 		return &pb.CaptureTriggerResponse{}, err
 	}
 
-	p, err := s.capture.StartCapture(req.GetPath(), d, req.GetCompressed())
+	filter := dsdReplay.CaptureFilter{
+		MetricNames:  req.GetMetricNames(),
+		Pids:         req.GetPids(),
+		ContainerIDs: req.GetContainerIds(),
+		ScrubTags:    req.GetScrubTags(),
+	}
+
+	p, err := s.capture.StartCapture(req.GetPath(), d, req.GetCompressed(), filter)
 	if err != nil {
 		return &pb.CaptureTriggerResponse{}, err
 	}
@@ -24,8 +24,25 @@ type MessageReceiver interface {
 	Filter(filters *diagnostic.Filters, done <-chan struct{}) <-chan string
 }
 
+// FilteredMessageReceiver is a receiver of streamed output filtered by filters of type F
+type FilteredMessageReceiver[F any] interface {
+	SetEnabled(e bool) bool
+	Filter(filters *F, done <-chan struct{}) <-chan string
+}
+
 // GetStreamFunc returns a handlerfunc that handles request to stream output to the desired receiver
 func GetStreamFunc(messageReceiverFunc func() MessageReceiver, streamType, agentType string) func(w http.ResponseWriter, r *http.Request) {
+	return GetFilteredStreamFunc(func() FilteredMessageReceiver[diagnostic.Filters] {
+		if messageReceiver := messageReceiverFunc(); messageReceiver != nil {
+			return messageReceiver
+		}
+		return nil
+	}, streamType, agentType)
+}
+
+// GetFilteredStreamFunc returns a handlerfunc that handles request to stream output to the desired receiver,
+// the request body being the JSON encoded filters of the receiver
+func GetFilteredStreamFunc[F any](messageReceiverFunc func() FilteredMessageReceiver[F], streamType, agentType string) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Infof("Got a request to stream %s.", streamType)
 		w.Header().Set("Transfer-Encoding", "chunked")
@@ -53,7 +70,7 @@ func GetStreamFunc(messageReceiverFunc func() MessageReceiver, streamType, agent
 		}
 		defer messageReceiver.SetEnabled(false)
 
-		var filters diagnostic.Filters
+		var filters F
 
 		if r.Body != http.NoBody {
 			body, err := io.ReadAll(r.Body)
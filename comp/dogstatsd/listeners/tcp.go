@@ -0,0 +1,438 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Framings of the statsd messages sent over TCP.
+const (
+	// TCPFramingNewline is the framing where messages are separated by newlines.
+	TCPFramingNewline = "newline"
+	// TCPFramingLengthPrefixed is the framing where each packet is prefixed by
+	// its length as a 32-bit little-endian integer, as in the UDS stream protocol.
+	TCPFramingLengthPrefixed = "length_prefixed"
+)
+
+// tlsHandshakeTimeout bounds the time a client has to complete the TLS handshake.
+const tlsHandshakeTimeout = 10 * time.Second
+
+var (
+	tcpExpvars             = expvar.NewMap("dogstatsd-tcp")
+	tcpPacketReadingErrors = expvar.Int{}
+	tcpPackets             = expvar.Int{}
+	tcpBytes               = expvar.Int{}
+	tcpConnections         = expvar.Int{}
+)
+
+func init() {
+	tcpExpvars.Set("PacketReadingErrors", &tcpPacketReadingErrors)
+	tcpExpvars.Set("Packets", &tcpPackets)
+	tcpExpvars.Set("Bytes", &tcpBytes)
+	tcpExpvars.Set("Connections", &tcpConnections)
+}
+
+// TCPListener implements the StatsdListener interface for TCP.
+// It accepts connections on a given address, optionally over TLS, and reads
+// statsd packets framed by newlines or prefixed by their length.
+// The packets of a connection can be tagged with the address of the client and,
+// with mutual TLS, the common name of its certificate.
+type TCPListener struct {
+	listener                net.Listener
+	packetOut               chan packets.Packets
+	sharedPacketPoolManager *packets.PoolManager[packets.Packet]
+	connTracker             *ConnectionTracker
+
+	protocol   packets.Protocol
+	transport  string
+	framing    string
+	originTags bool
+
+	packetBufferSize         uint
+	packetBufferFlushTimeout time.Duration
+	telemetryWithListenerID  bool
+
+	listenWg              sync.WaitGroup
+	telemetryStore        *TelemetryStore
+	packetsTelemetryStore *packets.TelemetryStore
+}
+
+// NewTCPListener returns an idle TCP Statsd listener
+func NewTCPListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) (*TCPListener, error) {
+	framing := cfg.GetString("dogstatsd_tcp_framing")
+	if framing != TCPFramingNewline && framing != TCPFramingLengthPrefixed {
+		return nil, fmt.Errorf("invalid dogstatsd_tcp_framing %q, must be %q or %q", framing, TCPFramingNewline, TCPFramingLengthPrefixed)
+	}
+
+	url := tcpListenerURL(cfg, cfg.GetInt("dogstatsd_tcp_port"))
+	var listener net.Listener
+	var err error
+	if cfg.GetBool("dogstatsd_tcp_tls.enabled") {
+		var tlsConfig *tls.Config
+		tlsConfig, err = buildTCPTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		listener, err = tls.Listen("tcp", url, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+
+	l := newTCPListener(listener, packets.DogStatsD, framing, packetOut, sharedPacketPoolManager, cfg, telemetryStore, packetsTelemetryStore)
+	l.originTags = cfg.GetBool("dogstatsd_tcp_origin_tags")
+	return l, nil
+}
+
+// NewProtocolTCPListener returns an idle TCP listener receiving the newline
+// delimited messages of the given protocol, Graphite or InfluxDB line
+// protocol, on the given port.
+func NewProtocolTCPListener(protocol packets.Protocol, port int, packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) (*TCPListener, error) {
+	listener, err := net.Listen("tcp", tcpListenerURL(cfg, port))
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+	return newTCPListener(listener, protocol, TCPFramingNewline, packetOut, sharedPacketPoolManager, cfg, telemetryStore, packetsTelemetryStore), nil
+}
+
+func newTCPListener(listener net.Listener, protocol packets.Protocol, framing string, packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) *TCPListener {
+	// the listeners of the other protocols are told apart in the telemetry
+	transport := "tcp"
+	if protocol != packets.DogStatsD {
+		transport = protocol.String() + "-tcp"
+	}
+
+	l := &TCPListener{
+		listener:                 listener,
+		packetOut:                packetOut,
+		sharedPacketPoolManager:  sharedPacketPoolManager,
+		connTracker:              NewConnectionTracker(transport, 1*time.Second),
+		protocol:                 protocol,
+		transport:                transport,
+		framing:                  framing,
+		packetBufferSize:         uint(cfg.GetInt("dogstatsd_packet_buffer_size")),
+		packetBufferFlushTimeout: cfg.GetDuration("dogstatsd_packet_buffer_flush_timeout"),
+		telemetryWithListenerID:  cfg.GetBool("dogstatsd_telemetry_enabled_listener_id"),
+		telemetryStore:           telemetryStore,
+		packetsTelemetryStore:    packetsTelemetryStore,
+	}
+	log.Debugf("dogstatsd-tcp: %s successfully initialized", listener.Addr())
+	return l
+}
+
+// tcpListenerURL returns the address to listen to for the given port.
+func tcpListenerURL(cfg model.Reader, port int) string {
+	if cfg.GetBool("dogstatsd_non_local_traffic") {
+		// Listen to all network interfaces
+		return fmt.Sprintf(":%d", port)
+	}
+	return net.JoinHostPort(pkgconfigsetup.GetBindHostFromConfig(cfg), strconv.Itoa(port))
+}
+
+// buildTCPTLSConfig returns the TLS configuration of the listener. Client
+// certificates are required and verified when a CA file is configured.
+func buildTCPTLSConfig(cfg model.Reader) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.GetString("dogstatsd_tcp_tls.cert_file"), cfg.GetString("dogstatsd_tcp_tls.key_file"))
+	if err != nil {
+		return nil, fmt.Errorf("could not load the TLS certificate: %s", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile := cfg.GetString("dogstatsd_tcp_tls.ca_file"); caFile != "" {
+		caCert, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the TLS CA file: %s", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificate found in the TLS CA file %s", caFile)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// LocalAddr returns the local network address of the listener.
+func (l *TCPListener) LocalAddr() string {
+	return l.listener.Addr().String()
+}
+
+// Listen runs the intake loop. Should be called in its own goroutine
+func (l *TCPListener) Listen() {
+	l.listenWg.Add(1)
+	go func() {
+		defer l.listenWg.Done()
+		l.listen()
+	}()
+}
+
+func (l *TCPListener) listen() {
+	l.connTracker.Start()
+	log.Infof("dogstatsd-tcp: starting to listen on %s", l.listener.Addr())
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("dogstatsd-tcp: error accepting connection: %v", err)
+			}
+			return
+		}
+
+		l.listenWg.Add(1)
+		go func() {
+			defer l.listenWg.Done()
+			l.connTracker.Track(conn)
+			if err := l.handleConnection(conn); err != nil {
+				log.Errorf("dogstatsd-tcp: error handling connection from %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (l *TCPListener) handleConnection(conn net.Conn) error {
+	listenerID := l.transport + "-" + conn.RemoteAddr().String()
+	tlmListenerID := listenerID
+	if !l.telemetryWithListenerID {
+		// In case we don't want the full listener id, we only keep the transport.
+		tlmListenerID = l.transport
+	}
+
+	packetsBuffer := packets.NewBuffer(
+		l.packetBufferSize,
+		l.packetBufferFlushTimeout,
+		l.packetOut,
+		tlmListenerID,
+		l.packetsTelemetryStore,
+	)
+	tcpConnections.Add(1)
+	l.telemetryStore.tlmTCPConnections.Inc(tlmListenerID)
+	defer func() {
+		l.connTracker.Close(conn)
+		packetsBuffer.Flush()
+		packetsBuffer.Close()
+		tcpConnections.Add(-1)
+		l.telemetryStore.tlmTCPConnections.Dec(tlmListenerID)
+		if l.telemetryWithListenerID {
+			l.clearTelemetry(tlmListenerID)
+		}
+	}()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("TLS handshake failed: %w", err)
+		}
+	}
+
+	r := &tcpConnReader{
+		listener:      l,
+		conn:          conn,
+		packetsBuffer: packetsBuffer,
+		tlmListenerID: tlmListenerID,
+		tags:          l.connectionTags(conn),
+	}
+
+	var err error
+	if l.framing == TCPFramingLengthPrefixed {
+		err = r.readLengthPrefixed()
+	} else {
+		err = r.readNewlineDelimited()
+	}
+	if err == io.EOF || errors.Is(err, net.ErrClosed) {
+		log.Debugf("dogstatsd-tcp: connection from %s closed", conn.RemoteAddr())
+		return nil
+	}
+	return err
+}
+
+// connectionTags returns the tags added to the metrics, events and service
+// checks received on the connection.
+func (l *TCPListener) connectionTags(conn net.Conn) []string {
+	if !l.originTags {
+		return nil
+	}
+
+	var tags []string
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+		tags = append(tags, "dogstatsd_client_ip:"+host)
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 && certs[0].Subject.CommonName != "" {
+			tags = append(tags, "dogstatsd_client_cn:"+certs[0].Subject.CommonName)
+		}
+	}
+	return tags
+}
+
+func (l *TCPListener) clearTelemetry(id string) {
+	// Since the listener id is volatile we need to make sure we clear the telemetry.
+	l.telemetryStore.tlmListener.Delete(id, "tcp", "tcp")
+	l.telemetryStore.tlmTCPConnections.Delete(id)
+	l.telemetryStore.tlmTCPPackets.Delete(id, "error")
+	l.telemetryStore.tlmTCPPackets.Delete(id, "ok")
+	l.telemetryStore.tlmTCPPacketsBytes.Delete(id)
+}
+
+// Stop closes the TCP listener and the connections, and stops listening
+func (l *TCPListener) Stop() {
+	_ = l.listener.Close()
+	l.connTracker.Stop()
+	l.listenWg.Wait()
+}
+
+// tcpConnReader reads the packets of a TCP connection.
+type tcpConnReader struct {
+	listener      *TCPListener
+	conn          net.Conn
+	packetsBuffer *packets.Buffer
+	tlmListenerID string
+	tags          []string
+	lastRead      time.Time
+}
+
+// readNewlineDelimited reads the connection until it is closed, and forwards
+// the complete messages it contains. Messages larger than a packet buffer are
+// dropped.
+func (r *tcpConnReader) readNewlineDelimited() error {
+	poolManager := r.listener.sharedPacketPoolManager
+	packet := poolManager.Get()
+	defer func() {
+		poolManager.Put(packet)
+	}()
+
+	n := 0
+	discarding := false
+	r.lastRead = time.Now()
+	for {
+		r.observeLatency()
+		read, err := r.conn.Read(packet.Buffer[n:])
+		r.lastRead = time.Now()
+
+		if read > 0 {
+			start := n
+			n += read
+			if discarding {
+				// drop the end of the message which didn't fit in the buffer
+				if i := bytes.IndexByte(packet.Buffer[start:n], '\n'); i >= 0 {
+					n = copy(packet.Buffer, packet.Buffer[start+i+1:n])
+					discarding = false
+				} else {
+					n = 0
+				}
+			}
+
+			if i := bytes.LastIndexByte(packet.Buffer[:n], '\n'); i >= 0 {
+				// forward the complete messages and keep the beginning of the
+				// next one for the next packet
+				next := poolManager.Get()
+				rest := copy(next.Buffer, packet.Buffer[i+1:n])
+				r.forward(packet, i)
+				packet, n = next, rest
+			} else if n == len(packet.Buffer) {
+				log.Debugf("dogstatsd-tcp: message larger than %d bytes from %s, dropping it", len(packet.Buffer), r.conn.RemoteAddr())
+				r.readingError()
+				discarding = true
+				n = 0
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF && n > 0 && !discarding {
+				// the last message isn't followed by a newline
+				r.forward(packet, n)
+				packet = poolManager.Get()
+			}
+			return err
+		}
+	}
+}
+
+// readLengthPrefixed reads the connection until it is closed, and forwards each
+// packet, prefixed by its length as a 32-bit little-endian integer.
+func (r *tcpConnReader) readLengthPrefixed() error {
+	poolManager := r.listener.sharedPacketPoolManager
+	r.lastRead = time.Now()
+	for {
+		r.observeLatency()
+
+		var length uint32
+		if err := binary.Read(r.conn, binary.LittleEndian, &length); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return io.EOF
+			}
+			return err
+		}
+
+		packet := poolManager.Get()
+		if length > uint32(len(packet.Buffer)) {
+			poolManager.Put(packet)
+			r.readingError()
+			return fmt.Errorf("packet length %d larger than the buffer size %d, dropping connection", length, len(packet.Buffer))
+		}
+		if _, err := io.ReadFull(r.conn, packet.Buffer[:length]); err != nil {
+			poolManager.Put(packet)
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return io.EOF
+			}
+			return err
+		}
+		r.lastRead = time.Now()
+		r.forward(packet, int(length))
+	}
+}
+
+// forward sends the first n bytes of the packet to the server.
+func (r *tcpConnReader) forward(packet *packets.Packet, n int) {
+	tcpPackets.Add(1)
+	tcpBytes.Add(int64(n))
+	r.listener.telemetryStore.tlmTCPPackets.Inc(r.tlmListenerID, "ok")
+	r.listener.telemetryStore.tlmTCPPacketsBytes.Add(float64(n), r.tlmListenerID)
+
+	packet.Contents = packet.Buffer[:n]
+	packet.Source = packets.TCP
+	packet.Protocol = r.listener.protocol
+	packet.Tags = r.tags
+
+	// packetsBuffer handles the forwarding of the packets to the dogstatsd server intake channel
+	r.packetsBuffer.Append(packet)
+}
+
+func (r *tcpConnReader) readingError() {
+	tcpPacketReadingErrors.Add(1)
+	r.listener.telemetryStore.tlmTCPPackets.Inc(r.tlmListenerID, "error")
+}
+
+// observeLatency records the time spent since the last read.
+func (r *tcpConnReader) observeLatency() {
+	r.listener.telemetryStore.tlmListener.Observe(float64(time.Since(r.lastRead).Nanoseconds()), r.tlmListenerID, "tcp", "tcp")
+}
@@ -0,0 +1,227 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+//go:build !windows
+
+package listeners
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/packets"
+)
+
+func newTestTCPListener(t *testing.T, cfg map[string]interface{}) (*TCPListener, chan packets.Packets) {
+	cfg["dogstatsd_tcp_port"] = 0
+	cfg["dogstatsd_non_local_traffic"] = false
+	cfg["dogstatsd_packet_buffer_flush_timeout"] = 10 * time.Millisecond
+
+	packetsChannel := make(chan packets.Packets, 16)
+	deps := fulfillDepsWithConfig(t, cfg)
+	telemetryStore := NewTelemetryStore(nil, deps.Telemetry)
+	packetsTelemetryStore := packets.NewTelemetryStore(nil, deps.Telemetry)
+	l, err := NewTCPListener(packetsChannel, newPacketPoolManagerUDP(deps.Config, packetsTelemetryStore), deps.Config, telemetryStore, packetsTelemetryStore)
+	require.NoError(t, err)
+	l.Listen()
+	t.Cleanup(l.Stop)
+	return l, packetsChannel
+}
+
+// receivePackets waits for the expected number of packets and returns them.
+func receivePackets(t *testing.T, packetsChannel chan packets.Packets, expected int) []*packets.Packet {
+	var received []*packets.Packet
+	for len(received) < expected {
+		select {
+		case pkts := <-packetsChannel:
+			received = append(received, pkts...)
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "timeout waiting for packets", "received %d packets out of %d", len(received), expected)
+		}
+	}
+	return received
+}
+
+func TestNewTCPListenerInvalidFraming(t *testing.T) {
+	deps := fulfillDepsWithConfig(t, map[string]interface{}{"dogstatsd_tcp_framing": "json"})
+	telemetryStore := NewTelemetryStore(nil, deps.Telemetry)
+	packetsTelemetryStore := packets.NewTelemetryStore(nil, deps.Telemetry)
+	_, err := NewTCPListener(nil, newPacketPoolManagerUDP(deps.Config, packetsTelemetryStore), deps.Config, telemetryStore, packetsTelemetryStore)
+	assert.Error(t, err)
+}
+
+func TestTCPListenerNewlineFraming(t *testing.T) {
+	l, packetsChannel := newTestTCPListener(t, map[string]interface{}{})
+
+	conn, err := net.Dial("tcp", l.LocalAddr())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("daemon:666|g\ndaemon:"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = conn.Write([]byte("999|g\nlast:1|c"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	received := receivePackets(t, packetsChannel, 3)
+	assert.Equal(t, "daemon:666|g", string(received[0].Contents))
+	assert.Equal(t, "daemon:999|g", string(received[1].Contents))
+	assert.Equal(t, "last:1|c", string(received[2].Contents))
+	for _, packet := range received {
+		assert.Equal(t, packets.TCP, packet.Source)
+		assert.Empty(t, packet.Tags)
+	}
+}
+
+func TestTCPListenerDropsOversizedMessages(t *testing.T) {
+	l, packetsChannel := newTestTCPListener(t, map[string]interface{}{"dogstatsd_buffer_size": 16})
+	readingErrors := tcpPacketReadingErrors.Value()
+
+	conn, err := net.Dial("tcp", l.LocalAddr())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("a.very.long.metric.name:1|c\nb:1|c\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	received := receivePackets(t, packetsChannel, 1)
+	assert.Equal(t, "b:1|c", string(received[0].Contents))
+	assert.Equal(t, readingErrors+1, tcpPacketReadingErrors.Value())
+}
+
+func TestTCPListenerLengthPrefixedFraming(t *testing.T) {
+	l, packetsChannel := newTestTCPListener(t, map[string]interface{}{"dogstatsd_tcp_framing": TCPFramingLengthPrefixed})
+
+	conn, err := net.Dial("tcp", l.LocalAddr())
+	require.NoError(t, err)
+	for _, contents := range []string{"daemon:666|g\ndaemon:999|g", "last:1|c"} {
+		require.NoError(t, binary.Write(conn, binary.LittleEndian, uint32(len(contents))))
+		_, err = conn.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, conn.Close())
+
+	received := receivePackets(t, packetsChannel, 2)
+	assert.Equal(t, "daemon:666|g\ndaemon:999|g", string(received[0].Contents))
+	assert.Equal(t, "last:1|c", string(received[1].Contents))
+}
+
+func TestProtocolTCPListener(t *testing.T) {
+	deps := fulfillDepsWithConfig(t, map[string]interface{}{
+		"dogstatsd_non_local_traffic":           false,
+		"dogstatsd_packet_buffer_flush_timeout": 10 * time.Millisecond,
+		// the origin tags are only added by the DogStatsD listener
+		"dogstatsd_tcp_origin_tags": true,
+	})
+	packetsChannel := make(chan packets.Packets, 16)
+	telemetryStore := NewTelemetryStore(nil, deps.Telemetry)
+	packetsTelemetryStore := packets.NewTelemetryStore(nil, deps.Telemetry)
+	l, err := NewProtocolTCPListener(packets.Influx, 0, packetsChannel, newPacketPoolManagerUDP(deps.Config, packetsTelemetryStore), deps.Config, telemetryStore, packetsTelemetryStore)
+	require.NoError(t, err)
+	l.Listen()
+	t.Cleanup(l.Stop)
+
+	conn, err := net.Dial("tcp", l.LocalAddr())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("cpu,host=web01 usage=42\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	received := receivePackets(t, packetsChannel, 1)
+	assert.Equal(t, "cpu,host=web01 usage=42", string(received[0].Contents))
+	assert.Equal(t, packets.TCP, received[0].Source)
+	assert.Equal(t, packets.Influx, received[0].Protocol)
+	assert.Empty(t, received[0].Tags)
+}
+
+func TestTCPListenerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCertificate(t, dir, "ca", nil, nil)
+	writeTestCertificate(t, dir, "server", ca, caKey)
+	writeTestCertificate(t, dir, "client", ca, caKey)
+
+	l, packetsChannel := newTestTCPListener(t, map[string]interface{}{
+		"dogstatsd_tcp_origin_tags":   true,
+		"dogstatsd_tcp_tls.enabled":   true,
+		"dogstatsd_tcp_tls.cert_file": filepath.Join(dir, "server.crt"),
+		"dogstatsd_tcp_tls.key_file":  filepath.Join(dir, "server.key"),
+		"dogstatsd_tcp_tls.ca_file":   filepath.Join(dir, "ca.crt"),
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	// clients without a certificate are rejected
+	conn, err := tls.Dial("tcp", l.LocalAddr(), &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	if err == nil {
+		_, err = conn.Write([]byte("rejected:1|c\n"))
+		if err == nil {
+			_, err = conn.Read(make([]byte, 1))
+		}
+		conn.Close()
+	}
+	assert.Error(t, err)
+
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	require.NoError(t, err)
+	conn, err = tls.Dial("tcp", l.LocalAddr(), &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: []tls.Certificate{clientCert}})
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("daemon:666|g\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	received := receivePackets(t, packetsChannel, 1)
+	assert.Equal(t, "daemon:666|g", string(received[0].Contents))
+	assert.Equal(t, []string{"dogstatsd_client_ip:127.0.0.1", "dogstatsd_client_cn:client"}, received[0].Tags)
+}
+
+// writeTestCertificate writes a certificate and its key to dir, signed by the
+// given CA or self-signed as a CA if it is nil.
+func writeTestCertificate(t *testing.T, dir string, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, signer := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca, caKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
@@ -9,6 +9,7 @@ import (
 	"expvar"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -51,6 +52,7 @@ type UDPListener struct {
 	packetsBuffer   *packets.Buffer
 	packetAssembler *packets.Assembler
 	buffer          []byte
+	listenerID      string
 	trafficCapture  replay.Component // Currently ignored
 	listenWg        sync.WaitGroup
 	telemetryStore  *TelemetryStore
@@ -58,13 +60,22 @@ type UDPListener struct {
 
 // NewUDPListener returns an idle UDP Statsd listener
 func NewUDPListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, capture replay.Component, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) (*UDPListener, error) {
-	var err error
-	var url string
-
 	port := cfg.GetString("dogstatsd_port")
 	if port == RandomPortName {
 		port = "0"
 	}
+	return newUDPListener(port, packets.DogStatsD, packetOut, sharedPacketPoolManager, cfg, capture, telemetryStore, packetsTelemetryStore)
+}
+
+// NewProtocolUDPListener returns an idle UDP listener receiving the messages
+// of the given protocol, Graphite or InfluxDB line protocol, on the given port.
+func NewProtocolUDPListener(protocol packets.Protocol, port int, packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) (*UDPListener, error) {
+	return newUDPListener(strconv.Itoa(port), protocol, packetOut, sharedPacketPoolManager, cfg, nil, telemetryStore, packetsTelemetryStore)
+}
+
+func newUDPListener(port string, protocol packets.Protocol, packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, capture replay.Component, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) (*UDPListener, error) {
+	var err error
+	var url string
 
 	if cfg.GetBool("dogstatsd_non_local_traffic") {
 		// Listen to all network interfaces
@@ -92,15 +103,23 @@ func NewUDPListener(packetOut chan packets.Packets, sharedPacketPoolManager *pac
 	packetsBufferSize := cfg.GetInt("dogstatsd_packet_buffer_size")
 	flushTimeout := cfg.GetDuration("dogstatsd_packet_buffer_flush_timeout")
 
+	// the listeners of the other protocols are told apart in the telemetry
+	listenerID := "udp"
+	if protocol != packets.DogStatsD {
+		listenerID = protocol.String() + "-udp"
+	}
+
 	buffer := make([]byte, bufferSize)
-	packetsBuffer := packets.NewBuffer(uint(packetsBufferSize), flushTimeout, packetOut, "udp", packetsTelemetryStore)
+	packetsBuffer := packets.NewBuffer(uint(packetsBufferSize), flushTimeout, packetOut, listenerID, packetsTelemetryStore)
 	packetAssembler := packets.NewAssembler(flushTimeout, packetsBuffer, sharedPacketPoolManager, packets.UDP)
+	packetAssembler.SetProtocol(protocol)
 
 	listener := &UDPListener{
 		conn:            conn,
 		packetsBuffer:   packetsBuffer,
 		packetAssembler: packetAssembler,
 		buffer:          buffer,
+		listenerID:      listenerID,
 		trafficCapture:  capture,
 		telemetryStore:  telemetryStore,
 	}
@@ -151,7 +170,7 @@ func (l *UDPListener) listen() {
 		}
 
 		t2 = time.Now()
-		l.telemetryStore.tlmListener.Observe(float64(t2.Sub(t1).Nanoseconds()), "udp", "udp", "udp")
+		l.telemetryStore.tlmListener.Observe(float64(t2.Sub(t1).Nanoseconds()), l.listenerID, "udp", "udp")
 	}
 }
 
@@ -156,6 +156,35 @@ func TestUDPReceive(t *testing.T) {
 	}
 }
 
+func TestProtocolUDPReceive(t *testing.T) {
+	var contents = []byte("servers.web01.cpu 42 1700000000")
+	port, err := getAvailableUDPPort()
+	require.Nil(t, err)
+
+	packetChannel := make(chan packets.Packets)
+	deps := fulfillDepsWithConfig(t, map[string]interface{}{})
+	telemetryStore := NewTelemetryStore(nil, deps.Telemetry)
+	packetsTelemetryStore := packets.NewTelemetryStore(nil, deps.Telemetry)
+	s, err := NewProtocolUDPListener(packets.Graphite, port, packetChannel, newPacketPoolManagerUDP(deps.Config, packetsTelemetryStore), deps.Config, telemetryStore, packetsTelemetryStore)
+	require.Nil(t, err)
+	require.NotNil(t, s)
+	mockConn := defaultMConn(s.conn.LocalAddr(), contents)
+	s.conn.Close()
+	s.conn = mockConn
+	s.Listen()
+	defer s.Stop()
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 1)
+		assert.Equal(t, contents, pkts[0].Contents)
+		assert.Equal(t, packets.UDP, pkts[0].Source)
+		assert.Equal(t, packets.Graphite, pkts[0].Protocol)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
 // Reproducer for https://github.com/DataDog/datadog-agent/issues/6803
 func TestNewUDPListenerWhenBusyWithSoRcvBufSet(t *testing.T) {
 	port, err := getAvailableUDPPort()
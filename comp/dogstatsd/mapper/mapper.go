@@ -18,9 +18,13 @@ var (
 	allowedWildcardMatchPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_*.]+$`)
 )
 
+// Match types of the patterns of metric names.
 const (
-	matchTypeWildcard = "wildcard"
-	matchTypeRegex    = "regex"
+	// MatchTypeWildcard matches metric names with patterns where `*` matches a
+	// dot-separated component.
+	MatchTypeWildcard = "wildcard"
+	// MatchTypeRegex matches metric names with regular expressions.
+	MatchTypeRegex = "regex"
 )
 
 //
@@ -91,9 +95,9 @@ func NewMetricMapper(configProfiles []MappingProfileConfig, cacheSize int) (*Met
 		for i, currentMapping := range configProfile.Mappings {
 			matchType := currentMapping.MatchType
 			if matchType == "" {
-				matchType = matchTypeWildcard
+				matchType = MatchTypeWildcard
 			}
-			if matchType != matchTypeWildcard && matchType != matchTypeRegex {
+			if matchType != MatchTypeWildcard && matchType != MatchTypeRegex {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: invalid match type, must be `wildcard` or `regex`", profile.Name, i)
 			}
 			if currentMapping.Name == "" {
@@ -102,7 +106,7 @@ func NewMetricMapper(configProfiles []MappingProfileConfig, cacheSize int) (*Met
 			if currentMapping.Match == "" {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: match is required", profile.Name, i)
 			}
-			regex, err := buildRegex(currentMapping.Match, matchType)
+			regex, err := BuildRegex(currentMapping.Match, matchType)
 			if err != nil {
 				return nil, err
 			}
@@ -117,8 +121,10 @@ func NewMetricMapper(configProfiles []MappingProfileConfig, cacheSize int) (*Met
 	return &MetricMapper{Profiles: profiles, cache: cache}, nil
 }
 
-func buildRegex(matchRe string, matchType string) (*regexp.Regexp, error) {
-	if matchType == matchTypeWildcard {
+// BuildRegex returns the regular expression matching whole metric names with the
+// given pattern. Wildcards are captured as groups.
+func BuildRegex(matchRe string, matchType string) (*regexp.Regexp, error) {
+	if matchType == MatchTypeWildcard {
 		if !allowedWildcardMatchPattern.MatchString(matchRe) {
 			return nil, fmt.Errorf("invalid wildcard match pattern `%s`, it does not match allowed match regex `%s`", matchRe, allowedWildcardMatchPattern)
 		}
@@ -24,6 +24,7 @@ type Assembler struct {
 	flushTimer              *time.Ticker
 	closeChannel            chan struct{}
 	packetSourceType        SourceType
+	packetProtocol          Protocol
 	sync.Mutex
 }
 
@@ -43,6 +44,13 @@ func NewAssembler(flushTimer time.Duration, packetsBuffer *Buffer, sharedPacketP
 	return packetAssembler
 }
 
+// SetProtocol sets the protocol of the assembled packets, DogStatsD by default.
+func (p *Assembler) SetProtocol(protocol Protocol) {
+	p.Lock()
+	p.packetProtocol = protocol
+	p.Unlock()
+}
+
 func (p *Assembler) flushLoop() {
 	for {
 		select {
@@ -78,6 +86,7 @@ func (p *Assembler) flush() {
 	}
 	p.packet.Contents = p.packet.Buffer[:p.packetLength]
 	p.packet.Source = p.packetSourceType
+	p.packet.Protocol = p.packetProtocol
 	p.packetsBuffer.Append(p.packet)
 	// retrieve an available packet from the packet pool,
 	// which will be pushed back by the server when processed.
@@ -70,6 +70,20 @@ func TestPacketBufferMerge(t *testing.T) {
 	assert.Equal(t, []byte("test1\ntest2"), packets[0].Contents)
 }
 
+func TestPacketBufferProtocol(t *testing.T) {
+	telemetryComponent := fxutil.Test[telemetry.Component](t, telemetryimpl.MockModule())
+	packetsTelemetryStore := NewTelemetryStore(nil, telemetryComponent)
+	pb, out := buildPacketAssembler(packetsTelemetryStore)
+	pb.SetProtocol(Graphite)
+
+	pb.AddMessage([]byte("my.metric 1"))
+
+	packets := <-out
+	assert.Len(t, packets, 1)
+	assert.Equal(t, Graphite, packets[0].Protocol)
+	assert.Equal(t, UDP, packets[0].Source)
+}
+
 func TestPacketBufferMergeMaxSize(t *testing.T) {
 	telemetryComponent := fxutil.Test[telemetry.Component](t, telemetryimpl.MockModule())
 	packetsTelemetryStore := NewTelemetryStore(nil, telemetryComponent)
@@ -21,8 +21,33 @@ const (
 	UDS
 	// NamedPipe Windows named pipe listner
 	NamedPipe
+	// TCP listener
+	TCP
 )
 
+// Protocol is the format of the messages of a packet
+type Protocol int
+
+const (
+	// DogStatsD messages
+	DogStatsD Protocol = iota
+	// Graphite plaintext protocol messages
+	Graphite
+	// Influx InfluxDB line protocol messages
+	Influx
+)
+
+// String returns the name of the protocol.
+func (p Protocol) String() string {
+	switch p {
+	case Graphite:
+		return "graphite"
+	case Influx:
+		return "influx"
+	}
+	return "dogstatsd"
+}
+
 // Packet represents a statsd packet ready to process,
 // with its origin metadata if applicable.
 //
@@ -35,6 +60,8 @@ type Packet struct {
 	Origin     string     // Origin container if identified
 	ListenerID string     // Listener ID
 	Source     SourceType // Type of listener that produced the packet
+	Tags       []string   // Tags of the connection the packet was received on, if any
+	Protocol   Protocol   // Format of the messages
 }
 
 // Packets is a slice of packet pointers
@@ -20,8 +20,9 @@ type Component interface {
 	// IsOngoing returns whether a capture is ongoing for this TrafficCapture instance.
 	IsOngoing() bool
 
-	// StartCapture starts a TrafficCapture and returns an error in the event of an issue.
-	StartCapture(p string, d time.Duration, compressed bool) (string, error)
+	// StartCapture starts a TrafficCapture writing the packets selected by the filter, and returns an error
+	// in the event of an issue.
+	StartCapture(p string, d time.Duration, compressed bool, filter CaptureFilter) (string, error)
 
 	// StopCapture stops an ongoing TrafficCapture.
 	StopCapture()
@@ -62,6 +63,20 @@ type CaptureBuffer struct {
 	Buff        *packets.Packet
 }
 
+// CaptureFilter selects the packets written to a capture file and the tags scrubbed from them.
+// The zero value captures all the traffic as is.
+type CaptureFilter struct {
+	// MetricNames are glob patterns, only the metrics whose name matches one of them are captured.
+	// The events and service checks are dropped when set.
+	MetricNames []string
+	// Pids only captures the packets sent by these processes.
+	Pids []int32
+	// ContainerIDs only captures the packets sent from these containers.
+	ContainerIDs []string
+	// ScrubTags are the tag keys whose values are scrubbed from the captured packets.
+	ScrubTags []string
+}
+
 const (
 	// GUID will be used as the GUID during capture replays
 	// This is a magic number chosen for no particular reason other than the fact its
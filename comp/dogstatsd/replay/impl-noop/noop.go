@@ -31,7 +31,7 @@ func (tc *noopTrafficCapture) IsOngoing() bool {
 }
 
 // StartCapture sets isRunning to true
-func (tc *noopTrafficCapture) StartCapture(_ string, _ time.Duration, _ bool) (string, error) {
+func (tc *noopTrafficCapture) StartCapture(_ string, _ time.Duration, _ bool, _ replaydef.CaptureFilter) (string, error) {
 	tc.Lock()
 	defer tc.Unlock()
 	tc.isRunning = true
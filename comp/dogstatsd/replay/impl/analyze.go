@@ -0,0 +1,216 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaptureAnalysis holds the statistics of the traffic of a capture file.
+type CaptureAnalysis struct {
+	// Packets is the number of captured packets, and Bytes the size of their payloads.
+	Packets int
+	Bytes   int
+	// Duration is the time elapsed between the first and the last packets.
+	Duration time.Duration
+	// PacketSizes are the sorted sizes of the payloads of the packets.
+	PacketSizes []int
+
+	// Metrics are the statistics of the metrics, sorted by decreasing number of samples.
+	Metrics       []*MetricAnalysis
+	Events        int
+	ServiceChecks int
+
+	// Origins are the statistics of the senders of the packets, sorted by decreasing number of packets.
+	Origins []*OriginAnalysis
+}
+
+// MetricAnalysis holds the statistics of a metric of a capture file.
+type MetricAnalysis struct {
+	Name    string
+	Samples int
+	// Contexts is the number of distinct tag sets of the metric.
+	Contexts int
+	// TagValues is the number of distinct values of each tag key of the metric.
+	TagValues map[string]int
+
+	contexts  map[string]struct{}
+	tagValues map[string]struct{}
+}
+
+// OriginAnalysis holds the statistics of a sender of a capture file, either a
+// container or a process.
+type OriginAnalysis struct {
+	Origin  string
+	Packets int
+	Samples int
+	Bytes   int
+}
+
+// PacketSizePercentile returns the size of the payloads below which the given
+// percentage of the packets is.
+func (a *CaptureAnalysis) PacketSizePercentile(p float64) int {
+	if len(a.PacketSizes) == 0 {
+		return 0
+	}
+	idx := int(p / 100 * float64(len(a.PacketSizes)-1))
+	return a.PacketSizes[idx]
+}
+
+// Rate returns the rate per second of count over the duration of the capture.
+func (a *CaptureAnalysis) Rate(count int) float64 {
+	if a.Duration <= 0 {
+		return float64(count)
+	}
+	return float64(count) / a.Duration.Seconds()
+}
+
+// AnalyzeCapture reads all the packets of a capture file and returns the
+// statistics of its traffic.
+func AnalyzeCapture(tc *TrafficCaptureReader) (*CaptureAnalysis, error) {
+	// the state is only used to name the origins, the old captures have none
+	pidMap := map[int32]string{}
+	if tc.Version >= minStateVersion {
+		if state, _, err := tc.ReadState(); err == nil && state != nil {
+			pidMap = state
+		}
+	}
+
+	tsResolution := time.Nanosecond
+	if tc.Version < minNanoVersion {
+		tsResolution = time.Second
+	}
+
+	analysis := &CaptureAnalysis{}
+	metrics := make(map[string]*MetricAnalysis)
+	origins := make(map[string]*OriginAnalysis)
+	var first, last int64
+
+	tc.Seek(0)
+	for {
+		msg, err := tc.ReadNext()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if analysis.Packets == 0 {
+			first = msg.Timestamp
+		}
+		last = msg.Timestamp
+
+		payload := msg.Payload[:msg.PayloadSize]
+		analysis.Packets++
+		analysis.Bytes += len(payload)
+		analysis.PacketSizes = append(analysis.PacketSizes, len(payload))
+
+		originName, found := pidMap[msg.Pid]
+		if !found {
+			originName = "unknown"
+			if msg.Pid != 0 {
+				originName = "pid:" + strconv.Itoa(int(msg.Pid))
+			}
+		}
+		origin := origins[originName]
+		if origin == nil {
+			origin = &OriginAnalysis{Origin: originName}
+			origins[originName] = origin
+		}
+		origin.Packets++
+		origin.Bytes += len(payload)
+
+		for len(payload) > 0 {
+			var line []byte
+			line, payload, _ = bytes.Cut(payload, []byte("\n"))
+			switch {
+			case len(line) == 0:
+			case bytes.HasPrefix(line, eventPrefix):
+				analysis.Events++
+			case bytes.HasPrefix(line, serviceCheckPrefix):
+				analysis.ServiceChecks++
+			default:
+				origin.Samples += analyzeMetric(metrics, line)
+			}
+		}
+	}
+
+	analysis.Duration = time.Duration(last-first) * tsResolution
+	sort.Ints(analysis.PacketSizes)
+
+	for _, m := range metrics {
+		m.Contexts = len(m.contexts)
+		m.TagValues = make(map[string]int)
+		for tag := range m.tagValues {
+			key, _, _ := strings.Cut(tag, ":")
+			m.TagValues[key]++
+		}
+		m.contexts, m.tagValues = nil, nil
+		analysis.Metrics = append(analysis.Metrics, m)
+	}
+	sort.Slice(analysis.Metrics, func(i, j int) bool {
+		if analysis.Metrics[i].Samples == analysis.Metrics[j].Samples {
+			return analysis.Metrics[i].Name < analysis.Metrics[j].Name
+		}
+		return analysis.Metrics[i].Samples > analysis.Metrics[j].Samples
+	})
+
+	for _, o := range origins {
+		analysis.Origins = append(analysis.Origins, o)
+	}
+	sort.Slice(analysis.Origins, func(i, j int) bool {
+		if analysis.Origins[i].Packets == analysis.Origins[j].Packets {
+			return analysis.Origins[i].Origin < analysis.Origins[j].Origin
+		}
+		return analysis.Origins[i].Packets > analysis.Origins[j].Packets
+	})
+
+	return analysis, nil
+}
+
+// analyzeMetric records a dogstatsd metric message, and returns its number of
+// samples.
+func analyzeMetric(metrics map[string]*MetricAnalysis, line []byte) int {
+	fields := bytes.Split(line, []byte("|"))
+	name, values, found := bytes.Cut(fields[0], []byte(":"))
+	if !found || len(name) == 0 {
+		return 0
+	}
+
+	m := metrics[string(name)]
+	if m == nil {
+		m = &MetricAnalysis{
+			Name:      string(name),
+			contexts:  make(map[string]struct{}),
+			tagValues: make(map[string]struct{}),
+		}
+		metrics[m.Name] = m
+	}
+
+	// a message may pack several values of the metric
+	samples := bytes.Count(values, []byte(":")) + 1
+	m.Samples += samples
+
+	var tags []string
+	for _, field := range fields[1:] {
+		if len(field) > 0 && field[0] == '#' {
+			tags = strings.Split(string(field[1:]), ",")
+			break
+		}
+	}
+	for _, tag := range tags {
+		m.tagValues[tag] = struct{}{}
+	}
+	sort.Strings(tags)
+	m.contexts[strings.Join(tags, ",")] = struct{}{}
+
+	return samples
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeCapture(t *testing.T) {
+	start := time.Now().UnixNano()
+	reader := writeTestCapture(t, nil,
+		captureBuffer("http.requests:1|c|#env:prod,user_id:1\nhttp.requests:1:2|c|#env:prod,user_id:2", 10, "container_id://abc", start),
+		captureBuffer("http.requests:1|c|#user_id:1,env:prod\n_e{5,4}:title|text", 10, "container_id://abc", start+int64(time.Second)),
+		captureBuffer("queue.size:4|g\n_sc|my.check|0", 42, "", start+int64(2*time.Second)),
+		captureBuffer("queue.size:5|g|#queue:a", 0, "", start+int64(2*time.Second)),
+	)
+
+	analysis, err := AnalyzeCapture(reader)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, analysis.Packets)
+	assert.Equal(t, 2*time.Second, analysis.Duration)
+	assert.Equal(t, 1, analysis.Events)
+	assert.Equal(t, 1, analysis.ServiceChecks)
+	assert.Equal(t, 2.0, analysis.Rate(4))
+
+	require.Len(t, analysis.PacketSizes, 4)
+	assert.Equal(t, 23, analysis.PacketSizes[0])
+	assert.Equal(t, 77, analysis.PacketSizes[3])
+	assert.Equal(t, 77, analysis.PacketSizePercentile(100))
+	assert.Equal(t, 23, analysis.PacketSizePercentile(0))
+	assert.Equal(t, 23+29+56+77, analysis.Bytes)
+
+	require.Len(t, analysis.Metrics, 2)
+	assert.Equal(t, &MetricAnalysis{
+		Name:      "http.requests",
+		Samples:   4,
+		Contexts:  2,
+		TagValues: map[string]int{"env": 1, "user_id": 2},
+	}, analysis.Metrics[0])
+	assert.Equal(t, &MetricAnalysis{
+		Name:      "queue.size",
+		Samples:   2,
+		Contexts:  2,
+		TagValues: map[string]int{"queue": 1},
+	}, analysis.Metrics[1])
+
+	assert.Equal(t, []*OriginAnalysis{
+		{Origin: "container_id://abc", Packets: 2, Samples: 4, Bytes: 77 + 56},
+		{Origin: "pid:42", Packets: 1, Samples: 1, Bytes: 29},
+		{Origin: "unknown", Packets: 1, Samples: 1, Bytes: 23},
+	}, analysis.Origins)
+}
+
+func TestAnalyzeCaptureFile(t *testing.T) {
+	reader, err := NewTrafficCaptureReader("resources/test/datadog-capture.dog", 1, false)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	analysis, err := AnalyzeCapture(reader)
+	require.NoError(t, err)
+	assert.Equal(t, 21, analysis.Packets)
+	assert.NotEmpty(t, analysis.Metrics)
+	assert.NotEmpty(t, analysis.Origins)
+}
@@ -75,18 +75,24 @@ func (tc *trafficCapture) IsOngoing() bool {
 	return tc.writer.IsOngoing()
 }
 
-// StartCapture starts a TrafficCapture and returns an error in the event of an issue.
-func (tc *trafficCapture) StartCapture(p string, d time.Duration, compressed bool) (string, error) {
+// StartCapture starts a TrafficCapture writing the packets selected by the filter, and returns an error
+// in the event of an issue.
+func (tc *trafficCapture) StartCapture(p string, d time.Duration, compressed bool, filter replay.CaptureFilter) (string, error) {
 	if tc.IsOngoing() {
 		return "", fmt.Errorf("Ongoing capture in progress")
 	}
 
+	packetFilter, err := NewPacketFilter(filter)
+	if err != nil {
+		return "", err
+	}
+
 	target, path, err := OpenFile(afero.NewOsFs(), p, tc.defaultlocation())
 	if err != nil {
 		return "", err
 	}
 
-	go tc.writer.Capture(target, d, compressed)
+	go tc.writer.Capture(target, d, compressed, packetFilter)
 
 	return path, nil
 }
@@ -0,0 +1,207 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/def"
+)
+
+var (
+	eventPrefix        = []byte("_e{")
+	serviceCheckPrefix = []byte("_sc|")
+)
+
+// PacketFilter selects the packets written to a capture file, and scrubs the
+// values of some of their tags.
+type PacketFilter struct {
+	metricNames  []string
+	pids         map[int32]struct{}
+	containerIDs map[string]struct{}
+	scrubTags    map[string]struct{}
+
+	// salt of the hashes replacing the scrubbed values, so that they can't be
+	// guessed by hashing well-known values
+	salt []byte
+}
+
+// NewPacketFilter returns the PacketFilter of a capture, or nil if the capture
+// writes all the traffic as is.
+func NewPacketFilter(filter replay.CaptureFilter) (*PacketFilter, error) {
+	if len(filter.MetricNames) == 0 && len(filter.Pids) == 0 && len(filter.ContainerIDs) == 0 && len(filter.ScrubTags) == 0 {
+		return nil, nil
+	}
+
+	for _, pattern := range filter.MetricNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid metric name pattern %q: %v", pattern, err)
+		}
+	}
+
+	f := &PacketFilter{
+		metricNames: filter.MetricNames,
+	}
+	if len(filter.Pids) > 0 || len(filter.ContainerIDs) > 0 {
+		f.pids = make(map[int32]struct{}, len(filter.Pids))
+		for _, pid := range filter.Pids {
+			f.pids[pid] = struct{}{}
+		}
+		f.containerIDs = make(map[string]struct{}, len(filter.ContainerIDs))
+		for _, containerID := range filter.ContainerIDs {
+			f.containerIDs[containerID] = struct{}{}
+		}
+	}
+	if len(filter.ScrubTags) > 0 {
+		f.scrubTags = make(map[string]struct{}, len(filter.ScrubTags))
+		for _, key := range filter.ScrubTags {
+			f.scrubTags[key] = struct{}{}
+		}
+		f.salt = make([]byte, 16)
+		if _, err := rand.Read(f.salt); err != nil {
+			return nil, fmt.Errorf("unable to generate the scrubbing salt: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// apply returns false if the message must not be captured. Otherwise, the
+// payload of the message is replaced by a copy holding only the selected
+// messages with their tags scrubbed, the original payload is still used by the
+// dogstatsd pipeline and must not be modified.
+func (f *PacketFilter) apply(msg *replay.CaptureBuffer) bool {
+	if f.pids != nil && !f.matchOrigin(msg) {
+		return false
+	}
+	if len(f.metricNames) == 0 && f.scrubTags == nil {
+		return true
+	}
+
+	payload := msg.Pb.Payload[:msg.Pb.PayloadSize]
+	filtered := make([]byte, 0, len(payload))
+	for len(payload) > 0 {
+		var line []byte
+		line, payload, _ = bytes.Cut(payload, []byte("\n"))
+		if len(line) == 0 || !f.matchName(line) {
+			continue
+		}
+		if len(filtered) > 0 {
+			filtered = append(filtered, '\n')
+		}
+		filtered = f.appendScrubbed(filtered, line)
+	}
+	if len(filtered) == 0 {
+		return false
+	}
+
+	msg.Pb.Payload = filtered
+	msg.Pb.PayloadSize = int32(len(filtered))
+	return true
+}
+
+// matchOrigin returns whether the message was sent by one of the selected
+// processes or containers.
+func (f *PacketFilter) matchOrigin(msg *replay.CaptureBuffer) bool {
+	if _, found := f.pids[msg.Pid]; found {
+		return true
+	}
+	if msg.ContainerID == "" {
+		return false
+	}
+	// the origin of the packets is an entity ID, e.g. container_id://<id>
+	_, containerID, found := strings.Cut(msg.ContainerID, "://")
+	if !found {
+		containerID = msg.ContainerID
+	}
+	_, found = f.containerIDs[containerID]
+	return found
+}
+
+// matchName returns whether the metric of a dogstatsd message matches one of
+// the name patterns. The events and service checks never match.
+func (f *PacketFilter) matchName(line []byte) bool {
+	if len(f.metricNames) == 0 {
+		return true
+	}
+	if bytes.HasPrefix(line, eventPrefix) || bytes.HasPrefix(line, serviceCheckPrefix) {
+		return false
+	}
+	name, _, found := bytes.Cut(line, []byte(":"))
+	if !found {
+		return false
+	}
+	for _, pattern := range f.metricNames {
+		if matched, _ := path.Match(pattern, string(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// appendScrubbed appends the dogstatsd message to dst, replacing the values of
+// the scrubbed tags by a hash so that their cardinality is preserved.
+func (f *PacketFilter) appendScrubbed(dst []byte, line []byte) []byte {
+	if f.scrubTags == nil {
+		return append(dst, line...)
+	}
+
+	for i, field := range bytes.Split(line, []byte("|")) {
+		if i > 0 {
+			dst = append(dst, '|')
+		}
+		if i == 0 || len(field) == 0 || field[0] != '#' {
+			dst = append(dst, field...)
+			continue
+		}
+
+		dst = append(dst, '#')
+		for j, tag := range bytes.Split(field[1:], []byte(",")) {
+			if j > 0 {
+				dst = append(dst, ',')
+			}
+			key, value, found := bytes.Cut(tag, []byte(":"))
+			if _, scrubbed := f.scrubTags[string(key)]; !found || !scrubbed {
+				dst = append(dst, tag...)
+				continue
+			}
+			dst = append(append(dst, key...), ':')
+			dst = append(dst, f.hash(value)...)
+		}
+	}
+	return dst
+}
+
+// scrubTagList returns the tags of a tagger entity with the values of the
+// scrubbed tags replaced by the same hashes as in the payloads. The tags are
+// returned as is if the filter doesn't scrub any tag.
+func (f *PacketFilter) scrubTagList(tags []string) []string {
+	if f == nil || f.scrubTags == nil {
+		return tags
+	}
+
+	scrubbed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		key, value, found := strings.Cut(tag, ":")
+		if _, scrub := f.scrubTags[key]; found && scrub {
+			tag = key + ":" + f.hash([]byte(value))
+		}
+		scrubbed = append(scrubbed, tag)
+	}
+	return scrubbed
+}
+
+func (f *PacketFilter) hash(value []byte) string {
+	h := sha256.New()
+	h.Write(f.salt)
+	h.Write(value)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,203 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
+	"github.com/DataDog/datadog-agent/comp/core/tagger/mock"
+	"github.com/DataDog/datadog-agent/comp/core/tagger/types"
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/def"
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+)
+
+func captureBuffer(payload string, pid int32, containerID string, ts int64) *replay.CaptureBuffer {
+	msg := &replay.CaptureBuffer{
+		Pid:         pid,
+		ContainerID: containerID,
+	}
+	msg.Pb.Timestamp = ts
+	msg.Pb.Pid = pid
+	msg.Pb.Payload = []byte(payload)
+	msg.Pb.PayloadSize = int32(len(payload))
+	return msg
+}
+
+// writeTestCapture writes the messages selected by the filter to a capture, and
+// returns a reader of it.
+func writeTestCapture(t *testing.T, filter *PacketFilter, msgs ...*replay.CaptureBuffer) *TrafficCaptureReader {
+	return writeTestCaptureWithTagger(t, mock.SetupFakeTagger(t), filter, msgs...)
+}
+
+// writeTestCaptureWithTagger is writeTestCapture, with the tagger whose
+// entities are written to the state of the capture.
+func writeTestCaptureWithTagger(t *testing.T, tagger tagger.Component, filter *PacketFilter, msgs ...*replay.CaptureBuffer) *TrafficCaptureReader {
+	var buf bytes.Buffer
+	writer := NewTrafficCaptureWriter(len(msgs), tagger)
+	writer.writer = bufio.NewWriter(&buf)
+	writer.filter = filter
+
+	require.NoError(t, writer.writeHeader())
+	for _, msg := range msgs {
+		require.NoError(t, writer.processMessage(msg))
+	}
+	_, err := writer.writeState()
+	require.NoError(t, err)
+	require.NoError(t, writer.writer.Flush())
+
+	return &TrafficCaptureReader{
+		Contents: buf.Bytes(),
+		Version:  int(datadogFileVersion),
+		Traffic:  make(chan *pb.UnixDogstatsdMsg, 1),
+	}
+}
+
+func readPayloads(t *testing.T, reader *TrafficCaptureReader) []string {
+	var payloads []string
+	reader.Seek(0)
+	for {
+		msg, err := reader.ReadNext()
+		if err == io.EOF {
+			return payloads
+		}
+		require.NoError(t, err)
+		payloads = append(payloads, string(msg.Payload[:msg.PayloadSize]))
+	}
+}
+
+func TestNewPacketFilter(t *testing.T) {
+	filter, err := NewPacketFilter(replay.CaptureFilter{})
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = NewPacketFilter(replay.CaptureFilter{MetricNames: []string{"my.[metric"}})
+	assert.Error(t, err)
+}
+
+func TestPacketFilterMetricNames(t *testing.T) {
+	filter, err := NewPacketFilter(replay.CaptureFilter{MetricNames: []string{"http.*", "db.queries"}})
+	require.NoError(t, err)
+
+	original := "http.requests:1|c|#env:prod\nother.metric:2|g\n_e{5,4}:title|text\n_sc|my.check|0\ndb.queries:3|c"
+	msg := captureBuffer(original, 0, "", 1)
+	payload := msg.Pb.Payload
+	reader := writeTestCapture(t, filter,
+		msg,
+		captureBuffer("other.metric:1|g", 0, "", 2),
+		captureBuffer("db.queries:4|c", 0, "", 3),
+	)
+
+	assert.Equal(t, []string{
+		"http.requests:1|c|#env:prod\ndb.queries:3|c",
+		"db.queries:4|c",
+	}, readPayloads(t, reader))
+	// the payload used by the dogstatsd pipeline is left untouched
+	assert.Equal(t, original, string(payload))
+}
+
+func TestPacketFilterOrigin(t *testing.T) {
+	filter, err := NewPacketFilter(replay.CaptureFilter{
+		Pids:         []int32{42},
+		ContainerIDs: []string{"abc"},
+	})
+	require.NoError(t, err)
+
+	reader := writeTestCapture(t, filter,
+		captureBuffer("from.pid:1|c", 42, "", 1),
+		captureBuffer("from.container:1|c", 10, "container_id://abc", 2),
+		captureBuffer("from.other.container:1|c", 11, "container_id://def", 3),
+		captureBuffer("from.other.pid:1|c", 12, "", 4),
+	)
+
+	assert.Equal(t, []string{"from.pid:1|c", "from.container:1|c"}, readPayloads(t, reader))
+
+	// only the origins of the captured packets are in the state
+	pidMap, _, err := reader.ReadState()
+	require.NoError(t, err)
+	assert.Equal(t, map[int32]string{10: "container_id://abc"}, pidMap)
+}
+
+func TestPacketFilterScrubTags(t *testing.T) {
+	filter, err := NewPacketFilter(replay.CaptureFilter{ScrubTags: []string{"user_id", "email"}})
+	require.NoError(t, err)
+
+	reader := writeTestCapture(t, filter,
+		captureBuffer("my.metric:1|c|@0.5|#env:prod,user_id:1234,email:a@b.c|T1700000000\n_sc|my.check|0|#user_id:1234", 0, "", 1),
+		captureBuffer("my.metric:1|c|#user_id:5678,env:prod", 0, "", 2),
+		captureBuffer("my.metric:1|c|#user_id:1234", 0, "", 3),
+	)
+
+	payloads := readPayloads(t, reader)
+	require.Len(t, payloads, 3)
+	for _, payload := range payloads {
+		assert.NotContains(t, payload, "1234")
+		assert.NotContains(t, payload, "5678")
+		assert.NotContains(t, payload, "a@b.c")
+		assert.Contains(t, payload, "user_id:")
+	}
+
+	lines := strings.Split(payloads[0], "\n")
+	require.Len(t, lines, 2)
+	fields := strings.Split(lines[0], "|")
+	require.Len(t, fields, 5)
+	assert.Equal(t, []string{"my.metric:1", "c", "@0.5"}, fields[:3])
+	assert.Equal(t, "T1700000000", fields[4])
+	tags := strings.Split(fields[3], ",")
+	require.Len(t, tags, 3)
+	assert.Equal(t, "#env:prod", tags[0])
+
+	// the same values are replaced by the same hashes
+	scrubbed := tags[1]
+	assert.True(t, strings.HasPrefix(lines[1], "_sc|my.check|0|#"))
+	assert.Equal(t, "#"+scrubbed, strings.TrimPrefix(lines[1], "_sc|my.check|0|"))
+	assert.Equal(t, "my.metric:1|c|#"+scrubbed, payloads[2])
+	assert.NotEqual(t, "my.metric:1|c|#"+scrubbed+",env:prod", payloads[1])
+}
+
+func TestPacketFilterScrubStateTags(t *testing.T) {
+	filter, err := NewPacketFilter(replay.CaptureFilter{ScrubTags: []string{"user_id", "pod_name"}})
+	require.NoError(t, err)
+
+	fakeTagger := mock.SetupFakeTagger(t)
+	fakeTagger.SetTags(types.NewEntityID(types.ContainerID, "abc"), "fake",
+		[]string{"env:prod", "user_id:1234"},
+		[]string{"pod_name:my-pod-1234"},
+		[]string{"user_id:5678"},
+		[]string{"service:web"},
+	)
+
+	reader := writeTestCaptureWithTagger(t, fakeTagger, filter,
+		captureBuffer("my.metric:1|c|#user_id:1234", 10, "container_id://abc", 1),
+	)
+
+	payloads := readPayloads(t, reader)
+	require.Len(t, payloads, 1)
+	scrubbed := strings.TrimPrefix(payloads[0], "my.metric:1|c|#")
+
+	_, entityMap, err := reader.ReadState()
+	require.NoError(t, err)
+	require.Contains(t, entityMap, "abc")
+	entity := entityMap["abc"]
+
+	// the tags of the state are scrubbed as in the payloads
+	assert.ElementsMatch(t, []string{"env:prod", scrubbed}, entity.LowCardinalityTags)
+	require.Len(t, entity.OrchestratorCardinalityTags, 1)
+	assert.True(t, strings.HasPrefix(entity.OrchestratorCardinalityTags[0], "pod_name:"))
+	assert.NotContains(t, entity.OrchestratorCardinalityTags[0], "my-pod-1234")
+	require.Len(t, entity.HighCardinalityTags, 1)
+	assert.True(t, strings.HasPrefix(entity.HighCardinalityTags[0], "user_id:"))
+	assert.NotEqual(t, scrubbed, entity.HighCardinalityTags[0])
+	assert.NotContains(t, entity.HighCardinalityTags[0], "5678")
+	assert.Equal(t, []string{"service:web"}, entity.StandardTags)
+}
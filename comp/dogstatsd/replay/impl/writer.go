@@ -65,6 +65,8 @@ type TrafficCaptureWriter struct {
 	taggerState map[int32]string
 	tagger      tagger.Component
 
+	filter *PacketFilter
+
 	// Synchronizes access to ongoing, accepting and closing of Traffic
 	sync.RWMutex
 }
@@ -79,17 +81,20 @@ func NewTrafficCaptureWriter(depth int, tagger tagger.Component) *TrafficCapture
 	}
 }
 
-// processMessage receives a capture buffer and writes it to disk while also tracking
-// the PID map to be persisted to the taggerState. Should not normally be called directly.
+// processMessage receives a capture buffer and writes it to disk, unless it is
+// discarded by the filter of the capture, while also tracking the PID map to be
+// persisted to the taggerState. Should not normally be called directly.
 func (tc *TrafficCaptureWriter) processMessage(msg *replay.CaptureBuffer) error {
-	err := tc.writeNext(msg)
+	if tc.filter == nil || tc.filter.apply(msg) {
+		err := tc.writeNext(msg)
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	if msg.ContainerID != "" {
-		tc.taggerState[msg.Pid] = msg.ContainerID
+		if msg.ContainerID != "" {
+			tc.taggerState[msg.Pid] = msg.ContainerID
+		}
 	}
 
 	if tc.sharedPacketPoolManager != nil {
@@ -153,9 +158,10 @@ func OpenFile(fs afero.Fs, l string, defaultLocation string) (afero.File, string
 	return f, p, err
 }
 
-// Capture start the traffic capture and writes the packets to file at the
-// specified location and for the specified duration.
-func (tc *TrafficCaptureWriter) Capture(target io.WriteCloser, d time.Duration, compressed bool) {
+// Capture start the traffic capture and writes the packets selected by the
+// filter, or all of them if it is nil, to file at the specified location and
+// for the specified duration.
+func (tc *TrafficCaptureWriter) Capture(target io.WriteCloser, d time.Duration, compressed bool, filter *PacketFilter) {
 	defer target.Close()
 	log.Debug("Starting capture...")
 
@@ -173,6 +179,7 @@ func (tc *TrafficCaptureWriter) Capture(target io.WriteCloser, d time.Duration,
 	}
 	tc.ongoing = true
 	tc.accepting = true
+	tc.filter = filter
 	tc.Unlock()
 
 	err := tc.writeHeader()
@@ -338,10 +345,10 @@ func (tc *TrafficCaptureWriter) writeState() (int, error) {
 		entry := pb.Entity{
 			// TODO: Hash:               entity.Hash,
 			Id:                          pbEntityID,
-			HighCardinalityTags:         entity.HighCardinalityTags,
-			OrchestratorCardinalityTags: entity.OrchestratorCardinalityTags,
-			LowCardinalityTags:          entity.LowCardinalityTags,
-			StandardTags:                entity.StandardTags,
+			HighCardinalityTags:         tc.filter.scrubTagList(entity.HighCardinalityTags),
+			OrchestratorCardinalityTags: tc.filter.scrubTagList(entity.OrchestratorCardinalityTags),
+			LowCardinalityTags:          tc.filter.scrubTagList(entity.LowCardinalityTags),
+			StandardTags:                tc.filter.scrubTagList(entity.StandardTags),
 		}
 		pbState.State[id] = &entry
 	}
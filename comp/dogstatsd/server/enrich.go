@@ -7,6 +7,7 @@ package server
 
 import (
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/DataDog/datadog-agent/comp/dogstatsd/constants"
@@ -30,6 +31,7 @@ type enrichConfig struct {
 	metricPrefix              string
 	metricPrefixBlacklist     []string
 	metricBlocklist           blocklist
+	metricRules               *atomic.Pointer[metricRules] // can be updated at runtime
 	defaultHostname           string
 	entityIDPrecedenceEnabled bool
 	serverlessMode            bool
@@ -118,7 +120,11 @@ func enrichMetricSample(dest []metrics.MetricSample, ddSample dogstatsdMetricSam
 	}
 
 	if conf.metricBlocklist.test(metricName) {
-		return []metrics.MetricSample{}
+		return dest
+	}
+
+	if conf.metricRules != nil {
+		metricName, tags = conf.metricRules.Load().apply(metricName, tags)
 	}
 
 	if conf.serverlessMode { // we don't want to set the host while running in serverless mode
@@ -7,6 +7,7 @@ package server
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -1009,6 +1010,37 @@ func TestMetricBlocklistShouldBlock(t *testing.T) {
 	assert.Equal(t, 0, len(samples))
 }
 
+func TestMetricRulesShouldRewriteMetric(t *testing.T) {
+	message := []byte("custom.metric.a:21:22|g|#env:prod,user_id:42,host:my-host")
+	rules, err := newMetricRules([]MetricRuleConfig{{
+		Match:      "custom.metric.*",
+		Name:       "custom.metric",
+		DropTags:   []string{"user_id"},
+		RenameTags: map[string]string{"env": "environment"},
+	}}, 10)
+	require.NoError(t, err)
+	conf := enrichConfig{
+		metricRules:     &atomic.Pointer[metricRules]{},
+		defaultHostname: "default",
+	}
+	conf.metricRules.Store(rules)
+
+	deps := newServerDeps(t)
+	stringInternerTelemetry := newSiTelemetry(false, deps.Telemetry)
+	parser := newParser(deps.Config, newFloat64ListPool(deps.Telemetry), 1, deps.WMeta, stringInternerTelemetry)
+	parsed, err := parser.parseMetricSample(message)
+	assert.NoError(t, err)
+	samples := []metrics.MetricSample{}
+	samples = enrichMetricSample(samples, parsed, "", "", conf)
+
+	require.Equal(t, 2, len(samples))
+	for _, sample := range samples {
+		assert.Equal(t, "custom.metric", sample.Name)
+		assert.Equal(t, []string{"environment:prod"}, sample.Tags)
+		assert.Equal(t, "my-host", sample.Host)
+	}
+}
+
 func TestServerlessModeShouldSetEmptyHostname(t *testing.T) {
 	conf := enrichConfig{
 		serverlessMode:  true,
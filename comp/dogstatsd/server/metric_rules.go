@@ -0,0 +1,231 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	log "github.com/DataDog/datadog-agent/comp/core/log/def"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/mapper"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/config/structure"
+)
+
+// MetricRuleConfig is a rule of the `dogstatsd_metric_rules` setting. It
+// rewrites the name and the tags of the metrics whose name matches its pattern.
+type MetricRuleConfig struct {
+	Match     string `mapstructure:"match" json:"match" yaml:"match"`
+	MatchType string `mapstructure:"match_type" json:"match_type" yaml:"match_type"`
+	// Name is the new name of the matching metrics, it can reference the
+	// groups captured by the pattern.
+	Name string `mapstructure:"name" json:"name" yaml:"name"`
+	// DropTags are the keys of the tags removed from the matching metrics.
+	DropTags []string `mapstructure:"drop_tags" json:"drop_tags" yaml:"drop_tags"`
+	// KeepTags, when not empty, are the only keys of the tags kept on the
+	// matching metrics.
+	KeepTags []string `mapstructure:"keep_tags" json:"keep_tags" yaml:"keep_tags"`
+	// RenameTags maps tag keys to their new name.
+	RenameTags map[string]string `mapstructure:"rename_tags" json:"rename_tags" yaml:"rename_tags"`
+}
+
+type metricRule struct {
+	regex      *regexp.Regexp
+	name       string
+	dropTags   map[string]struct{}
+	keepTags   map[string]struct{}
+	renameTags map[string]string
+}
+
+// metricRuleMatch is the outcome of the rules for a metric name.
+type metricRuleMatch struct {
+	// rule is the first rule matching the metric name, nil if none matches.
+	rule *metricRule
+	name string
+}
+
+// metricRules rewrites the names and filters the tags of the metrics. Rules
+// are evaluated in order, and only the first one matching the name of a metric
+// is applied.
+type metricRules struct {
+	rules []*metricRule
+	cache *lru.Cache[string, metricRuleMatch]
+}
+
+// ValidateMetricRules returns an error if one of the rules is invalid.
+func ValidateMetricRules(configs []MetricRuleConfig) error {
+	_, err := compileMetricRules(configs)
+	return err
+}
+
+func compileMetricRules(configs []MetricRuleConfig) ([]*metricRule, error) {
+	rules := make([]*metricRule, 0, len(configs))
+	for i, config := range configs {
+		matchType := config.MatchType
+		if matchType == "" {
+			matchType = mapper.MatchTypeWildcard
+		}
+		if matchType != mapper.MatchTypeWildcard && matchType != mapper.MatchTypeRegex {
+			return nil, fmt.Errorf("rule num %d: invalid match type, must be `wildcard` or `regex`", i)
+		}
+		if config.Match == "" {
+			return nil, fmt.Errorf("rule num %d: match is required", i)
+		}
+		if len(config.DropTags) > 0 && len(config.KeepTags) > 0 {
+			return nil, fmt.Errorf("rule num %d: drop_tags and keep_tags can't be used together", i)
+		}
+		regex, err := mapper.BuildRegex(config.Match, matchType)
+		if err != nil {
+			return nil, fmt.Errorf("rule num %d: %v", i, err)
+		}
+
+		rule := &metricRule{
+			regex:      regex,
+			name:       config.Name,
+			dropTags:   toSet(config.DropTags),
+			keepTags:   toSet(config.KeepTags),
+			renameTags: config.RenameTags,
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func toSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// newMetricRules returns the metric rules, or nil if there are none.
+func newMetricRules(configs []MetricRuleConfig, cacheSize int) (*metricRules, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	rules, err := compileMetricRules(configs)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := lru.New[string, metricRuleMatch](cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &metricRules{rules: rules, cache: cache}, nil
+}
+
+// getDogstatsdMetricRules returns the metric rules configured in cfg.
+func getDogstatsdMetricRules(cfg model.Reader) (*metricRules, error) {
+	var configs []MetricRuleConfig
+	if cfg.IsSet("dogstatsd_metric_rules") {
+		if err := structure.UnmarshalKey(cfg, "dogstatsd_metric_rules", &configs); err != nil {
+			return nil, fmt.Errorf("Could not parse dogstatsd_metric_rules: %v", err)
+		}
+	}
+	rules, err := newMetricRules(configs, cfg.GetInt("dogstatsd_mapper_cache_size"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid dogstatsd_metric_rules: %v", err)
+	}
+	return rules, nil
+}
+
+// watchDogstatsdMetricRules returns the metric rules configured in cfg, which
+// are reloaded each time the `dogstatsd_metric_rules` setting is updated.
+func watchDogstatsdMetricRules(cfg model.Reader, log log.Component) *atomic.Pointer[metricRules] {
+	current := &atomic.Pointer[metricRules]{}
+	if rules, err := getDogstatsdMetricRules(cfg); err != nil {
+		log.Warn(err)
+	} else {
+		current.Store(rules)
+	}
+
+	cfg.OnUpdate(func(setting string, _, _ any) {
+		if setting != "dogstatsd_metric_rules" {
+			return
+		}
+		rules, err := getDogstatsdMetricRules(cfg)
+		if err != nil {
+			log.Warnf("%v, keeping the previous rules", err)
+			return
+		}
+		current.Store(rules)
+		log.Infof("Dogstatsd: %d metric rules loaded", rules.len())
+	})
+	return current
+}
+
+func (r *metricRules) len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+func (r *metricRules) match(name string) metricRuleMatch {
+	if m, ok := r.cache.Get(name); ok {
+		return m
+	}
+
+	m := metricRuleMatch{name: name}
+	for _, rule := range r.rules {
+		matches := rule.regex.FindStringSubmatchIndex(name)
+		if len(matches) == 0 {
+			continue
+		}
+		m.rule = rule
+		if rule.name != "" {
+			m.name = string(rule.regex.ExpandString(nil, rule.name, name, matches))
+		}
+		break
+	}
+	r.cache.Add(name, m)
+	return m
+}
+
+// apply returns the name and the tags of the metric rewritten by the first
+// matching rule. The tags are filtered in place.
+func (r *metricRules) apply(name string, tags []string) (string, []string) {
+	if r == nil {
+		return name, tags
+	}
+	m := r.match(name)
+	if m.rule == nil {
+		return name, tags
+	}
+	return m.name, m.rule.filterTags(tags)
+}
+
+func (rule *metricRule) filterTags(tags []string) []string {
+	n := 0
+	for _, tag := range tags {
+		key := tag
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			key = tag[:i]
+		}
+		if _, drop := rule.dropTags[key]; drop {
+			continue
+		}
+		if rule.keepTags != nil {
+			if _, keep := rule.keepTags[key]; !keep {
+				continue
+			}
+		}
+		if newKey, ok := rule.renameTags[key]; ok {
+			tag = newKey + tag[len(key):]
+		}
+		tags[n] = tag
+		n++
+	}
+	return tags[:n]
+}
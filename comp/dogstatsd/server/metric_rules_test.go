@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+)
+
+func TestValidateMetricRules(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []MetricRuleConfig
+		valid bool
+	}{
+		{
+			name:  "no rules",
+			valid: true,
+		},
+		{
+			name:  "wildcard",
+			rules: []MetricRuleConfig{{Match: "test.*.duration", Name: "test.duration", DropTags: []string{"user_id"}}},
+			valid: true,
+		},
+		{
+			name:  "regex",
+			rules: []MetricRuleConfig{{Match: `test\.(\w+)`, MatchType: "regex", KeepTags: []string{"env"}}},
+			valid: true,
+		},
+		{
+			name:  "missing match",
+			rules: []MetricRuleConfig{{Name: "test"}},
+		},
+		{
+			name:  "invalid match type",
+			rules: []MetricRuleConfig{{Match: "test", MatchType: "prefix"}},
+		},
+		{
+			name:  "invalid wildcard",
+			rules: []MetricRuleConfig{{Match: "test.**"}},
+		},
+		{
+			name:  "invalid regex",
+			rules: []MetricRuleConfig{{Match: "test.(", MatchType: "regex"}},
+		},
+		{
+			name:  "drop and keep tags",
+			rules: []MetricRuleConfig{{Match: "test", DropTags: []string{"a"}, KeepTags: []string{"b"}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMetricRules(tc.rules)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestMetricRulesApply(t *testing.T) {
+	rules, err := newMetricRules([]MetricRuleConfig{
+		{
+			Match:    "test.job.*.duration",
+			Name:     "test.job.duration",
+			DropTags: []string{"user_id"},
+		},
+		{
+			Match:      `test\.http\.(\w+)`,
+			MatchType:  "regex",
+			Name:       "test.requests.$1",
+			KeepTags:   []string{"env", "status"},
+			RenameTags: map[string]string{"status": "http_status"},
+		},
+		{
+			// never applied, the previous rule matches first
+			Match:     `test\.http\..*`,
+			MatchType: "regex",
+			Name:      "unreachable",
+		},
+		{
+			Match:    "test.keep_name",
+			DropTags: []string{"flag"},
+		},
+	}, 10)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name         string
+		tags         []string
+		expectedName string
+		expectedTags []string
+	}{
+		{
+			name:         "test.job.backup.duration",
+			tags:         []string{"env:prod", "user_id:42", "user_id:43"},
+			expectedName: "test.job.duration",
+			expectedTags: []string{"env:prod"},
+		},
+		{
+			name:         "test.http.get",
+			tags:         []string{"env:prod", "status:200", "path:/", "flag"},
+			expectedName: "test.requests.get",
+			expectedTags: []string{"env:prod", "http_status:200"},
+		},
+		{
+			name:         "test.keep_name",
+			tags:         []string{"flag", "flag:1", "flagged"},
+			expectedName: "test.keep_name",
+			expectedTags: []string{"flagged"},
+		},
+		{
+			name:         "test.other",
+			tags:         []string{"user_id:42"},
+			expectedName: "test.other",
+			expectedTags: []string{"user_id:42"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// the second iteration uses the cached match
+			for i := 0; i < 2; i++ {
+				name, tags := rules.apply(tc.name, append([]string{}, tc.tags...))
+				assert.Equal(t, tc.expectedName, name)
+				assert.Equal(t, tc.expectedTags, tags)
+			}
+		})
+	}
+}
+
+func TestNilMetricRules(t *testing.T) {
+	rules, err := newMetricRules(nil, 10)
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+
+	name, tags := rules.apply("test", []string{"a:b"})
+	assert.Equal(t, "test", name)
+	assert.Equal(t, []string{"a:b"}, tags)
+}
+
+func TestMetricRulesReload(t *testing.T) {
+	deps := fulfillDepsWithConfigYaml(t, `
+dogstatsd_metric_rules:
+  - match: test.a
+    name: test.b
+`)
+	s := deps.Server.(*server)
+	require.Equal(t, 1, s.enrichConfig.metricRules.Load().len())
+
+	deps.Config.Set("dogstatsd_metric_rules", []interface{}{
+		map[string]interface{}{"match": "test.a", "name": "test.b"},
+		map[string]interface{}{"match": "test.c", "drop_tags": []interface{}{"user_id"}},
+	}, model.SourceAgentRuntime)
+	rules := s.enrichConfig.metricRules.Load()
+	assert.Equal(t, 2, rules.len())
+	name, tags := rules.apply("test.c", []string{"user_id:42"})
+	assert.Equal(t, "test.c", name)
+	assert.Empty(t, tags)
+
+	// invalid rules are ignored
+	deps.Config.Set("dogstatsd_metric_rules", []interface{}{
+		map[string]interface{}{"name": "test.b"},
+	}, model.SourceAgentRuntime)
+	assert.Same(t, rules, s.enrichConfig.metricRules.Load())
+
+	deps.Config.Set("dogstatsd_metric_rules", []interface{}{}, model.SourceAgentRuntime)
+	assert.Nil(t, s.enrichConfig.metricRules.Load())
+}
@@ -70,6 +70,10 @@ type parser struct {
 
 	// Generic Metric Provider
 	provider provider.Provider
+
+	// tagBuffer is reused to build the names and the tags of the Graphite and
+	// InfluxDB line protocol messages.
+	tagBuffer []byte
 }
 
 func newParser(cfg model.Reader, float64List *float64ListPool, workerNum int, wmeta optional.Option[workloadmeta.Component], stringInternerTelemetry *stringInternerTelemetry) *parser {
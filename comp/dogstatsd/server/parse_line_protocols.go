@@ -0,0 +1,246 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+var (
+	graphiteTagSeparator = []byte(";")
+	lineProtocolEqual    = []byte("=")
+)
+
+// parseGraphiteMetric parses a message of the Graphite plaintext protocol,
+// `<path>[;<tag>=<value>...] <value> [<timestamp>]`, as a gauge sample.
+func (p *parser) parseGraphiteMetric(message []byte) (dogstatsdMetricSample, error) {
+	fields := bytes.Fields(message)
+	if len(fields) < 2 || len(fields) > 3 {
+		return dogstatsdMetricSample{}, fmt.Errorf("invalid graphite message format")
+	}
+
+	rawPath, rawTags, hasTags := bytes.Cut(fields[0], graphiteTagSeparator)
+	if len(rawPath) == 0 {
+		return dogstatsdMetricSample{}, fmt.Errorf("empty graphite metric path")
+	}
+
+	value, err := parseFloat64(fields[1])
+	if err != nil {
+		return dogstatsdMetricSample{}, fmt.Errorf("could not parse graphite metric value: %v", err)
+	}
+
+	var tags []string
+	if hasTags {
+		for len(rawTags) > 0 {
+			var rawTag []byte
+			rawTag, rawTags, _ = bytes.Cut(rawTags, graphiteTagSeparator)
+			key, tagValue, found := bytes.Cut(rawTag, lineProtocolEqual)
+			if !found || len(key) == 0 {
+				return dogstatsdMetricSample{}, fmt.Errorf("invalid graphite tag %q", rawTag)
+			}
+			tags = append(tags, p.lineProtocolTag(key, tagValue))
+		}
+	}
+
+	// -1 asks to use the reception time, as when the timestamp is omitted
+	var timestamp time.Time
+	if len(fields) == 3 && p.readTimestamps {
+		ts, err := parseFloat64(fields[2])
+		if err != nil {
+			return dogstatsdMetricSample{}, fmt.Errorf("could not parse graphite timestamp %q: %v", fields[2], err)
+		}
+		if ts > 0 {
+			timestamp = time.Unix(int64(ts), 0)
+		}
+	}
+
+	return dogstatsdMetricSample{
+		name:       p.interner.LoadOrStore(rawPath),
+		value:      value,
+		metricType: gaugeType,
+		sampleRate: 1,
+		tags:       tags,
+		ts:         timestamp,
+	}, nil
+}
+
+// parseInfluxMetrics parses a line of the InfluxDB line protocol,
+// `<measurement>[,<tag>=<value>...] <field>=<value>[,...] [<timestamp>]`, and
+// appends a `<measurement>.<field>` gauge sample per numeric or boolean field
+// to samples. The string fields and the comments are ignored.
+func (p *parser) parseInfluxMetrics(samples []dogstatsdMetricSample, message []byte) ([]dogstatsdMetricSample, error) {
+	message = bytes.TrimSpace(message)
+	if len(message) == 0 || message[0] == '#' {
+		return samples, nil
+	}
+
+	sep := indexInfluxUnescaped(message, ' ', false)
+	if sep <= 0 {
+		return samples, fmt.Errorf("invalid influx line protocol message format")
+	}
+	seriesKey, rest := message[:sep], bytes.TrimLeft(message[sep+1:], " ")
+
+	fieldSet, rawTimestamp := rest, []byte(nil)
+	if sep = indexInfluxUnescaped(rest, ' ', true); sep >= 0 {
+		fieldSet, rawTimestamp = rest[:sep], bytes.TrimSpace(rest[sep+1:])
+	}
+	if len(fieldSet) == 0 {
+		return samples, fmt.Errorf("influx line protocol message without field")
+	}
+
+	var timestamp time.Time
+	if len(rawTimestamp) > 0 && p.readTimestamps {
+		ts, err := parseInt64(rawTimestamp)
+		if err != nil {
+			return samples, fmt.Errorf("could not parse influx timestamp %q: %v", rawTimestamp, err)
+		}
+		if ts < 1 {
+			return samples, fmt.Errorf("influx timestamp should be > 0")
+		}
+		timestamp = time.Unix(0, ts)
+	}
+
+	measurement := seriesKey
+	var tags []string
+	if sep = indexInfluxUnescaped(seriesKey, ',', false); sep >= 0 {
+		measurement = seriesKey[:sep]
+		rawTags := seriesKey[sep+1:]
+		for len(rawTags) > 0 {
+			var rawTag []byte
+			rawTag, rawTags = nextInfluxElement(rawTags, false)
+			sep = indexInfluxUnescaped(rawTag, '=', false)
+			if sep <= 0 {
+				return samples, fmt.Errorf("invalid influx tag %q", rawTag)
+			}
+			tags = append(tags, p.lineProtocolTag(unescapeInflux(rawTag[:sep]), unescapeInflux(rawTag[sep+1:])))
+		}
+	}
+	if len(measurement) == 0 {
+		return samples, fmt.Errorf("empty influx measurement")
+	}
+	measurement = unescapeInflux(measurement)
+
+	start := len(samples)
+	for len(fieldSet) > 0 {
+		var rawField []byte
+		rawField, fieldSet = nextInfluxElement(fieldSet, true)
+		sep = indexInfluxUnescaped(rawField, '=', false)
+		if sep <= 0 {
+			return samples[:start], fmt.Errorf("invalid influx field %q", rawField)
+		}
+		value, numeric, err := parseInfluxFieldValue(rawField[sep+1:])
+		if err != nil {
+			return samples[:start], fmt.Errorf("could not parse influx field %q: %v", rawField, err)
+		}
+		if !numeric {
+			continue
+		}
+
+		// the tags of the samples are modified in place while they are
+		// enriched, each sample gets its own copy
+		sampleTags := tags
+		if len(samples) > start {
+			sampleTags = append([]string(nil), tags...)
+		}
+
+		p.tagBuffer = append(append(append(p.tagBuffer[:0], measurement...), '.'), unescapeInflux(rawField[:sep])...)
+		samples = append(samples, dogstatsdMetricSample{
+			name:       p.interner.LoadOrStore(p.tagBuffer),
+			value:      value,
+			metricType: gaugeType,
+			sampleRate: 1,
+			tags:       sampleTags,
+			ts:         timestamp,
+		})
+	}
+	return samples, nil
+}
+
+// lineProtocolTag returns the `<key>:<value>` tag of a Graphite or an InfluxDB
+// line protocol tag.
+func (p *parser) lineProtocolTag(key, value []byte) string {
+	p.tagBuffer = append(append(append(p.tagBuffer[:0], key...), ':'), value...)
+	return p.interner.LoadOrStore(p.tagBuffer)
+}
+
+// indexInfluxUnescaped returns the index of the first occurrence of sep which
+// isn't escaped by a backslash, nor in a double-quoted string when quoted is
+// true, or -1 if there is none.
+func indexInfluxUnescaped(b []byte, sep byte, quoted bool) int {
+	inString := false
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '\\':
+			i++
+		case quoted && c == '"':
+			inString = !inString
+		case !inString && c == sep:
+			return i
+		}
+	}
+	return -1
+}
+
+// nextInfluxElement returns the element found before the first unescaped comma
+// and the remainder.
+func nextInfluxElement(b []byte, quoted bool) ([]byte, []byte) {
+	sep := indexInfluxUnescaped(b, ',', quoted)
+	if sep < 0 {
+		return b, nil
+	}
+	return b[:sep], b[sep+1:]
+}
+
+// unescapeInflux removes the backslashes escaping the commas, equal signs and
+// spaces of the measurements, tags and field keys.
+func unescapeInflux(b []byte) []byte {
+	if bytes.IndexByte(b, '\\') < 0 {
+		return b
+	}
+	unescaped := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\\' && i+1 < len(b) && (b[i+1] == ',' || b[i+1] == '=' || b[i+1] == ' ') {
+			i++
+		}
+		unescaped = append(unescaped, b[i])
+	}
+	return unescaped
+}
+
+// parseInfluxFieldValue parses the value of a field, returning false for the
+// string values which have no numeric value.
+func parseInfluxFieldValue(raw []byte) (float64, bool, error) {
+	if len(raw) == 0 {
+		return 0, false, errors.New("empty value")
+	}
+	if raw[0] == '"' {
+		return 0, false, nil
+	}
+
+	switch string(raw) {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+
+	switch raw[len(raw)-1] {
+	case 'i':
+		value, err := parseInt64(raw[:len(raw)-1])
+		return float64(value), err == nil, err
+	case 'u':
+		rawUint := raw[:len(raw)-1]
+		value, err := strconv.ParseUint(*(*string)(unsafe.Pointer(&rawUint)), 10, 64)
+		return float64(value), err == nil, err
+	}
+	value, err := parseFloat64(raw)
+	return value, err == nil, err
+}
@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+)
+
+func newLineProtocolParser(t *testing.T, overrides map[string]any) *parser {
+	deps := newServerDeps(t, fx.Replace(config.MockParams{Overrides: overrides}))
+	stringInternerTelemetry := newSiTelemetry(false, deps.Telemetry)
+	return newParser(deps.Config, newFloat64ListPool(deps.Telemetry), 1, deps.WMeta, stringInternerTelemetry)
+}
+
+func TestParseGraphiteMetric(t *testing.T) {
+	p := newLineProtocolParser(t, map[string]any{})
+
+	sample, err := p.parseGraphiteMetric([]byte("servers.web01.cpu.usage 42.5 1700000000"))
+	require.NoError(t, err)
+	assert.Equal(t, "servers.web01.cpu.usage", sample.name)
+	assert.Equal(t, 42.5, sample.value)
+	assert.Equal(t, gaugeType, sample.metricType)
+	assert.Equal(t, 1.0, sample.sampleRate)
+	assert.Empty(t, sample.tags)
+	// the timestamps are only read by the no-aggregation pipeline
+	assert.True(t, sample.ts.IsZero())
+
+	sample, err = p.parseGraphiteMetric([]byte("disk.used;datacenter=dc1;host=web01  12"))
+	require.NoError(t, err)
+	assert.Equal(t, "disk.used", sample.name)
+	assert.Equal(t, 12.0, sample.value)
+	assert.Equal(t, []string{"datacenter:dc1", "host:web01"}, sample.tags)
+}
+
+func TestParseGraphiteMetricTimestamp(t *testing.T) {
+	p := newLineProtocolParser(t, map[string]any{"dogstatsd_no_aggregation_pipeline": true})
+
+	sample, err := p.parseGraphiteMetric([]byte("my.metric 1 1700000000"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 0), sample.ts)
+
+	sample, err = p.parseGraphiteMetric([]byte("my.metric 1 -1"))
+	require.NoError(t, err)
+	assert.True(t, sample.ts.IsZero())
+}
+
+func TestParseGraphiteMetricErrors(t *testing.T) {
+	p := newLineProtocolParser(t, map[string]any{"dogstatsd_no_aggregation_pipeline": true})
+
+	for _, message := range []string{
+		"my.metric",
+		"my.metric 1 1700000000 extra",
+		"my.metric abc",
+		";tag=value 1",
+		"my.metric;tag 1",
+		"my.metric 1 abc",
+	} {
+		_, err := p.parseGraphiteMetric([]byte(message))
+		assert.Error(t, err, message)
+	}
+}
+
+func TestParseInfluxMetrics(t *testing.T) {
+	p := newLineProtocolParser(t, map[string]any{})
+
+	samples, err := p.parseInfluxMetrics(nil, []byte(`cpu,host=web01,region=us-east usage_user=12.5,usage_idle=80i,cores=8u,throttled=true,model="Xeon, 8 cores" 1700000000000000000`))
+	require.NoError(t, err)
+	require.Len(t, samples, 4)
+
+	names := make([]string, 0, len(samples))
+	values := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		names = append(names, sample.name)
+		values = append(values, sample.value)
+		assert.Equal(t, gaugeType, sample.metricType)
+		assert.Equal(t, []string{"host:web01", "region:us-east"}, sample.tags)
+		assert.True(t, sample.ts.IsZero())
+	}
+	assert.Equal(t, []string{"cpu.usage_user", "cpu.usage_idle", "cpu.cores", "cpu.throttled"}, names)
+	assert.Equal(t, []float64{12.5, 80, 8, 1}, values)
+
+	// the samples don't share their tags, which are modified in place when
+	// they are enriched
+	samples[0].tags[0] = "modified"
+	assert.Equal(t, "host:web01", samples[1].tags[0])
+}
+
+func TestParseInfluxMetricsEscaping(t *testing.T) {
+	p := newLineProtocolParser(t, map[string]any{"dogstatsd_no_aggregation_pipeline": true})
+
+	samples, err := p.parseInfluxMetrics(nil, []byte(`disk\ io,path=/var\,/tmp,label=a\=b read\ bytes=1,msg="a \"quoted\" value" 1700000000000000000`))
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	assert.Equal(t, "disk io.read bytes", samples[0].name)
+	assert.Equal(t, []string{"path:/var,/tmp", "label:a=b"}, samples[0].tags)
+	assert.Equal(t, time.Unix(1700000000, 0), samples[0].ts)
+
+	// comments and string-only lines don't produce samples
+	samples, err = p.parseInfluxMetrics(nil, []byte("# a comment"))
+	assert.NoError(t, err)
+	assert.Empty(t, samples)
+	samples, err = p.parseInfluxMetrics(nil, []byte(`events message="started"`))
+	assert.NoError(t, err)
+	assert.Empty(t, samples)
+}
+
+func TestParseInfluxMetricsErrors(t *testing.T) {
+	p := newLineProtocolParser(t, map[string]any{"dogstatsd_no_aggregation_pipeline": true})
+
+	for _, message := range []string{
+		"cpu",
+		"cpu ",
+		",host=web01 usage=1",
+		"cpu,host usage=1",
+		"cpu usage",
+		"cpu usage=abc",
+		"cpu usage=1i2i",
+		"cpu usage=1 abc",
+		"cpu usage=1 -1",
+	} {
+		samples, err := p.parseInfluxMetrics(nil, []byte(message))
+		assert.Error(t, err, message)
+		assert.Empty(t, samples, message)
+	}
+}
@@ -223,6 +223,7 @@ func newServerCompat(cfg model.Reader, log log.Component, capture replay.Compone
 		cfg.GetStringSlice("statsd_metric_blocklist"),
 		cfg.GetBool("statsd_metric_blocklist_match_prefix"),
 	)
+	metricRules := watchDogstatsdMetricRules(cfg, log)
 
 	defaultHostname, err := hostname.Get(context.TODO())
 	if err != nil {
@@ -297,6 +298,7 @@ func newServerCompat(cfg model.Reader, log log.Component, capture replay.Compone
 			metricPrefix:              metricPrefix,
 			metricPrefixBlacklist:     metricPrefixBlacklist,
 			metricBlocklist:           metricBlocklist,
+			metricRules:               metricRules,
 			entityIDPrecedenceEnabled: entityIDPrecedenceEnabled,
 			defaultHostname:           defaultHostname,
 			serverlessMode:            serverless,
@@ -401,6 +403,34 @@ func (s *server) start(context.Context) error {
 		}
 	}
 
+	if s.config.GetInt("dogstatsd_tcp_port") > 0 {
+		tcpListener, err := listeners.NewTCPListener(packetsChannel, sharedPacketPoolManager, s.config, s.listernersTelemetry, s.packetsTelemetry)
+		if err != nil {
+			s.log.Errorf("Can't init TCP listener: %s", err.Error())
+		} else {
+			tmpListeners = append(tmpListeners, tcpListener)
+		}
+	}
+
+	for _, protocol := range []packets.Protocol{packets.Graphite, packets.Influx} {
+		if port := s.config.GetInt("dogstatsd_" + protocol.String() + ".udp_port"); port > 0 {
+			udpListener, err := listeners.NewProtocolUDPListener(protocol, port, packetsChannel, sharedPacketPoolManager, s.config, s.listernersTelemetry, s.packetsTelemetry)
+			if err != nil {
+				s.log.Errorf("Can't init %s UDP listener: %s", protocol, err.Error())
+			} else {
+				tmpListeners = append(tmpListeners, udpListener)
+			}
+		}
+		if port := s.config.GetInt("dogstatsd_" + protocol.String() + ".tcp_port"); port > 0 {
+			tcpListener, err := listeners.NewProtocolTCPListener(protocol, port, packetsChannel, sharedPacketPoolManager, s.config, s.listernersTelemetry, s.packetsTelemetry)
+			if err != nil {
+				s.log.Errorf("Can't init %s TCP listener: %s", protocol, err.Error())
+			} else {
+				tmpListeners = append(tmpListeners, tcpListener)
+			}
+		}
+	}
+
 	pipeName := s.config.GetString("dogstatsd_pipe_name")
 	if len(pipeName) > 0 {
 		namedPipeListener, err := listeners.NewNamedPipeListener(pipeName, packetsChannel, sharedPacketPoolManager, s.config, s.tCapture, s.listernersTelemetry, s.packetsTelemetry, s.telemetry)
@@ -539,14 +569,18 @@ func (s *server) forwarder(fcon net.Conn) {
 		select {
 		case <-s.stopChan:
 			return
-		case packets := <-s.captureChan:
-			for _, packet := range packets {
+		case packetBatch := <-s.captureChan:
+			for _, packet := range packetBatch {
+				// only the DogStatsD packets are forwarded to the statsd server
+				if packet.Protocol != packets.DogStatsD {
+					continue
+				}
 				_, err := fcon.Write(packet.Contents)
 				if err != nil {
 					s.log.Warnf("Forwarding packet failed : %s", err)
 				}
 			}
-			s.packetsIn <- packets
+			s.packetsIn <- packetBatch
 		}
 	}
 }
@@ -609,6 +643,15 @@ func nextMessage(packet *[]byte, eolTermination bool) (message []byte) {
 	return message
 }
 
+// appendConnectionTags returns the tags of a message followed by the tags of the
+// connection it was received on, without modifying the tags of the message.
+func appendConnectionTags(tags []string, connectionTags []string) []string {
+	if len(connectionTags) == 0 {
+		return tags
+	}
+	return append(tags[:len(tags):len(tags)], connectionTags...)
+}
+
 func (s *server) UdsListenerRunning() bool {
 	return s.udsListenerRunning
 }
@@ -634,8 +677,8 @@ func (s *server) errLog(format string, params ...interface{}) {
 }
 
 // workers are running this function in their goroutine
-func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packets []*packets.Packet, samples metrics.MetricSampleBatch) metrics.MetricSampleBatch {
-	for _, packet := range packets {
+func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packetBatch []*packets.Packet, samples metrics.MetricSampleBatch) metrics.MetricSampleBatch {
+	for _, packet := range packetBatch {
 		s.log.Tracef("Dogstatsd receive: %q", packet.Contents)
 		for {
 			message := nextMessage(&packet.Contents, s.eolEnabled(packet.Source))
@@ -648,6 +691,21 @@ func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packets
 			if s.Statistics != nil {
 				s.Statistics.StatEvent(1)
 			}
+
+			if packet.Protocol != packets.DogStatsD {
+				var err error
+
+				samples = samples[0:0]
+
+				samples, err = s.parseLineProtocolMessage(samples, parser, message, packet.Protocol, packet.ListenerID)
+				if err != nil {
+					s.errLog("Dogstatsd: error parsing %s message '%q': %s", packet.Protocol, message, err)
+					continue
+				}
+				s.appendMetricSamples(batcher, samples)
+				continue
+			}
+
 			messageType := findMessageType(message)
 
 			switch messageType {
@@ -657,6 +715,7 @@ func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packets
 					s.errLog("Dogstatsd: error parsing service check '%q': %s", message, err)
 					continue
 				}
+				serviceCheck.Tags = appendConnectionTags(serviceCheck.Tags, packet.Tags)
 				batcher.appendServiceCheck(serviceCheck)
 			case eventType:
 				event, err := s.parseEventMessage(parser, message, packet.Origin)
@@ -664,6 +723,7 @@ func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packets
 					s.errLog("Dogstatsd: error parsing event '%q': %s", message, err)
 					continue
 				}
+				event.Tags = appendConnectionTags(event.Tags, packet.Tags)
 				batcher.appendEvent(event)
 			case metricSampleType:
 				var err error
@@ -676,22 +736,14 @@ func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packets
 					continue
 				}
 
-				for idx := range samples {
-					s.Debug.StoreMetricStats(samples[idx])
-
-					if samples[idx].Timestamp > 0.0 {
-						batcher.appendLateSample(samples[idx])
-					} else {
-						batcher.appendSample(samples[idx])
-					}
-
-					if s.histToDist && samples[idx].Mtype == metrics.HistogramType {
-						distSample := samples[idx].Copy()
-						distSample.Name = s.histToDistPrefix + distSample.Name
-						distSample.Mtype = metrics.DistributionType
-						batcher.appendSample(*distSample)
+				if len(packet.Tags) > 0 && len(samples) > 0 {
+					// the samples of a multi-value message share their tags
+					tags := appendConnectionTags(samples[0].Tags, packet.Tags)
+					for idx := range samples {
+						samples[idx].Tags = tags
 					}
 				}
+				s.appendMetricSamples(batcher, samples)
 			}
 		}
 		s.sharedPacketPoolManager.Put(packet)
@@ -700,6 +752,26 @@ func (s *server) parsePackets(batcher dogstatsdBatcher, parser *parser, packets
 	return samples
 }
 
+// appendMetricSamples sends the parsed samples to the batcher.
+func (s *server) appendMetricSamples(batcher dogstatsdBatcher, samples []metrics.MetricSample) {
+	for idx := range samples {
+		s.Debug.StoreMetricStats(samples[idx])
+
+		if samples[idx].Timestamp > 0.0 {
+			batcher.appendLateSample(samples[idx])
+		} else {
+			batcher.appendSample(samples[idx])
+		}
+
+		if s.histToDist && samples[idx].Mtype == metrics.HistogramType {
+			distSample := samples[idx].Copy()
+			distSample.Name = s.histToDistPrefix + distSample.Name
+			distSample.Mtype = metrics.DistributionType
+			batcher.appendSample(*distSample)
+		}
+	}
+}
+
 // getOriginCounter returns a telemetry counter for processed metrics using the given origin as a tag.
 // They are stored in cache to avoid heap escape.
 // Only `maxOriginCounters` are stored to avoid an infinite expansion.
@@ -759,6 +831,39 @@ func (s *server) parseMetricMessage(metricSamples []metrics.MetricSample, parser
 		return metricSamples, err
 	}
 
+	return s.processMetricSample(metricSamples, sample, origin, listenerID, okCnt), nil
+}
+
+// parseLineProtocolMessage parses a message of the Graphite or InfluxDB line
+// protocol, whose samples are then processed like the DogStatsD ones.
+func (s *server) parseLineProtocolMessage(metricSamples []metrics.MetricSample, parser *parser, message []byte, protocol packets.Protocol, listenerID string) ([]metrics.MetricSample, error) {
+	var samples []dogstatsdMetricSample
+	var err error
+	switch protocol {
+	case packets.Graphite:
+		var sample dogstatsdMetricSample
+		sample, err = parser.parseGraphiteMetric(message)
+		samples = append(samples, sample)
+	case packets.Influx:
+		samples, err = parser.parseInfluxMetrics(samples, message)
+	default:
+		err = fmt.Errorf("unsupported protocol %s", protocol)
+	}
+	if err != nil {
+		dogstatsdMetricParseErrors.Add(1)
+		s.tlmProcessedError.Inc()
+		return metricSamples, err
+	}
+
+	for _, sample := range samples {
+		metricSamples = s.processMetricSample(metricSamples, sample, packets.NoOrigin, listenerID, s.tlmProcessedOk)
+	}
+	return metricSamples, nil
+}
+
+// processMetricSample maps and enriches a parsed sample, and appends the
+// resulting metric samples to metricSamples.
+func (s *server) processMetricSample(metricSamples []metrics.MetricSample, sample dogstatsdMetricSample, origin string, listenerID string, okCnt telemetry.SimpleCounter) []metrics.MetricSample {
 	if s.mapper != nil {
 		mapResult := s.mapper.Map(sample.name)
 		if mapResult != nil {
@@ -768,24 +873,25 @@ func (s *server) parseMetricMessage(metricSamples []metrics.MetricSample, parser
 		}
 	}
 
+	start := len(metricSamples)
 	metricSamples = enrichMetricSample(metricSamples, sample, origin, listenerID, s.enrichConfig)
 
 	if len(sample.values) > 0 {
 		s.sharedFloat64List.put(sample.values)
 	}
 
-	for idx := range metricSamples {
+	for idx := start; idx < len(metricSamples); idx++ {
 		// All metricSamples already share the same Tags slice. We can
 		// extends the first one and reuse it for the rest.
-		if idx == 0 {
+		if idx == start {
 			metricSamples[idx].Tags = append(metricSamples[idx].Tags, s.extraTags...)
 		} else {
-			metricSamples[idx].Tags = metricSamples[0].Tags
+			metricSamples[idx].Tags = metricSamples[start].Tags
 		}
 		dogstatsdMetricPackets.Add(1)
 		okCnt.Inc()
 	}
-	return metricSamples, nil
+	return metricSamples
 }
 
 func (s *server) parseEventMessage(parser *parser, message []byte, origin string) (*event.Event, error) {
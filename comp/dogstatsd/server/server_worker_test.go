@@ -15,6 +15,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/comp/core/telemetry"
 	"github.com/DataDog/datadog-agent/comp/dogstatsd/listeners"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/packets"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/metrics/event"
 )
@@ -208,6 +209,44 @@ func TestServiceChecks(t *testing.T) {
 	defaultServiceCheck().testService(t, b.serviceChecks[0])
 }
 
+func TestConnectionTags(t *testing.T) {
+	cfg := make(map[string]interface{})
+	cfg["dogstatsd_port"] = listeners.RandomPortName
+
+	deps := fulfillDepsWithConfigOverride(t, cfg)
+	s := deps.Server.(*server)
+	parser := newParser(deps.Config, s.sharedFloat64List, 1, deps.WMeta, s.stringInternerTelemetry)
+	var b batcherMock
+
+	input := []byte("daemon:666:777|g|#sometag1:somevalue1\n" + string(defaultEventInput) + "\n" + string(defaultServiceInput))
+	packets := genTestPackets(input)
+	packets[0].Tags = []string{"dogstatsd_client_ip:10.0.0.1"}
+	s.parsePackets(&b, parser, packets, metrics.MetricSampleBatch{})
+
+	require.Len(t, b.samples, 2)
+	for _, sample := range b.samples {
+		assert.Contains(t, sample.Tags, "sometag1:somevalue1")
+		assert.Contains(t, sample.Tags, "dogstatsd_client_ip:10.0.0.1")
+	}
+	require.Len(t, b.events, 1)
+	assert.Contains(t, b.events[0].Tags, "dogstatsd_client_ip:10.0.0.1")
+	require.Len(t, b.serviceChecks, 1)
+	assert.Contains(t, b.serviceChecks[0].Tags, "dogstatsd_client_ip:10.0.0.1")
+}
+
+func TestAppendConnectionTags(t *testing.T) {
+	tags := make([]string, 1, 4)
+	tags[0] = "a"
+
+	assert.Equal(t, tags, appendConnectionTags(tags, nil))
+
+	withConnectionTags := appendConnectionTags(tags, []string{"b"})
+	assert.Equal(t, []string{"a", "b"}, withConnectionTags)
+	// the spare capacity of the tags of the message is not reused
+	assert.Equal(t, []string{"a", "c"}, appendConnectionTags(tags, []string{"c"}))
+	assert.Equal(t, []string{"a", "b"}, withConnectionTags)
+}
+
 func TestHistToDist(t *testing.T) {
 	cfg := make(map[string]interface{})
 	cfg["dogstatsd_port"] = listeners.RandomPortName
@@ -374,6 +413,64 @@ dogstatsd_mapper_profiles:
 	}
 }
 
+func TestGraphiteMessages(t *testing.T) {
+	deps := fulfillDepsWithConfigYaml(t, `
+dogstatsd_port: __random__
+dogstatsd_mapper_profiles:
+  - name: servers
+    prefix: 'servers.'
+    mappings:
+      - match: "servers.*.cpu.*"
+        name: "server.cpu"
+        tags:
+          server: "$1"
+          state: "$2"
+`)
+	s := deps.Server.(*server)
+	requireStart(t, s)
+	s.SetExtraTags([]string{"env:prod"})
+
+	parser := newParser(deps.Config, s.sharedFloat64List, 1, deps.WMeta, s.stringInternerTelemetry)
+	var b batcherMock
+	input := genTestPackets([]byte("servers.web01.cpu.user 12.5 1700000000\nnot.mapped;role=db 3\ninvalid"))
+	input[0].Protocol = packets.Graphite
+	s.parsePackets(&b, parser, input, metrics.MetricSampleBatch{})
+
+	require.Len(t, b.samples, 2)
+	assert.Empty(t, b.lateSamples)
+	defaultMetric().withName("server.cpu").withValue(12.5).withTags([]string{"server:web01", "state:user", "env:prod"}).testMetric(t, b.samples[0])
+	defaultMetric().withName("not.mapped").withValue(3).withTags([]string{"role:db", "env:prod"}).testMetric(t, b.samples[1])
+	assert.Equal(t, float64(2), s.tlmProcessedOk.Get())
+	assert.Equal(t, float64(1), s.tlmProcessedError.Get())
+}
+
+func TestInfluxMessages(t *testing.T) {
+	cfg := make(map[string]interface{})
+	cfg["dogstatsd_port"] = listeners.RandomPortName
+
+	deps := fulfillDepsWithConfigOverride(t, cfg)
+	s := deps.Server.(*server)
+	parser := newParser(deps.Config, s.sharedFloat64List, 1, deps.WMeta, s.stringInternerTelemetry)
+	var b batcherMock
+
+	input := genTestPackets([]byte("cpu,host=web01,core=0 user=12.5,idle=80i\nmem,env=prod used=1024u"))
+	input[0].Protocol = packets.Influx
+	s.parsePackets(&b, parser, input, metrics.MetricSampleBatch{})
+
+	require.Len(t, b.samples, 3)
+	expected := []*tMetricSample{
+		defaultMetric().withName("cpu.user").withValue(12.5).withTags([]string{"core:0"}),
+		defaultMetric().withName("cpu.idle").withValue(80).withTags([]string{"core:0"}),
+		defaultMetric().withName("mem.used").withValue(1024).withTags([]string{"env:prod"}),
+	}
+	for idx, sample := range b.samples {
+		expected[idx].testMetric(t, sample)
+	}
+	// the host tag sets the host of the samples
+	assert.Equal(t, "web01", b.samples[0].Host)
+	assert.Equal(t, "web01", b.samples[1].Host)
+}
+
 func TestParseEventMessageTelemetry(t *testing.T) {
 	cfg := make(map[string]interface{})
 
@@ -84,6 +84,7 @@ func (s statusProvider) populateStatus(stats map[string]interface{}) {
 		dogstatsdStatsJSON := []byte(expvar.Get("dogstatsd").String())
 		dogstatsdUdsStatsJSON := []byte(expvar.Get("dogstatsd-uds").String())
 		dogstatsdUDPStatsJSON := []byte(expvar.Get("dogstatsd-udp").String())
+		dogstatsdTCPStatsJSON := []byte(expvar.Get("dogstatsd-tcp").String())
 		dogstatsdStats := make(map[string]interface{})
 		json.Unmarshal(dogstatsdStatsJSON, &dogstatsdStats) //nolint:errcheck
 		dogstatsdUdsStats := make(map[string]interface{})
@@ -96,6 +97,11 @@ func (s statusProvider) populateStatus(stats map[string]interface{}) {
 		for name, value := range dogstatsdUDPStats {
 			dogstatsdStats["Udp"+name] = value
 		}
+		dogstatsdTCPStats := make(map[string]interface{})
+		json.Unmarshal(dogstatsdTCPStatsJSON, &dogstatsdTCPStats) //nolint:errcheck
+		for name, value := range dogstatsdTCPStats {
+			dogstatsdStats["Tcp"+name] = value
+		}
 		stats["dogstatsdStats"] = dogstatsdStats
 	}
 }
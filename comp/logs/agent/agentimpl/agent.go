@@ -31,6 +31,8 @@ import (
 	integrationsimpl "github.com/DataDog/datadog-agent/comp/logs/integrations/impl"
 	"github.com/DataDog/datadog-agent/comp/metadata/inventoryagent"
 	rctypes "github.com/DataDog/datadog-agent/comp/remote-config/rcclient/types"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/sender"
+	checkid "github.com/DataDog/datadog-agent/pkg/collector/check/id"
 	"github.com/DataDog/datadog-agent/pkg/config/model"
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
@@ -38,6 +40,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/launchers"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/processor"
 	"github.com/DataDog/datadog-agent/pkg/logs/schedulers"
 	"github.com/DataDog/datadog-agent/pkg/logs/sds"
 	"github.com/DataDog/datadog-agent/pkg/logs/service"
@@ -55,9 +58,13 @@ import (
 const (
 	// key used to display a warning message on the agent status
 	invalidProcessingRules = "invalid_global_processing_rules"
+	invalidMetricRules     = "invalid_global_metric_rules"
 	invalidEndpoints       = "invalid_endpoints"
 	intakeTrackType        = "logs"
 
+	// metricRulesSenderID is the ID of the sender of the metrics generated from logs
+	metricRulesSenderID checkid.ID = "logs_metric_rules"
+
 	// Log messages
 	multiLineWarning = "multi_line processing rules are not supported as global processing rules."
 
@@ -82,6 +89,9 @@ type dependencies struct {
 	WMeta              optional.Option[workloadmeta.Component]
 	SchedulerProviders []schedulers.Scheduler `group:"log-agent-scheduler"`
 	Tagger             tagger.Component
+	// SenderManager is only provided by the Agents running an aggregator, the
+	// metric rules are ignored without it.
+	SenderManager sender.SenderManager `optional:"true"`
 }
 
 type provides struct {
@@ -103,6 +113,7 @@ type logAgent struct {
 	inventoryAgent inventoryagent.Component
 	hostname       hostname.Component
 	tagger         tagger.Component
+	senderManager  sender.SenderManager
 
 	sources                   *sources.LogSources
 	services                  *service.Services
@@ -112,6 +123,7 @@ type logAgent struct {
 	auditor                   auditor.Auditor
 	destinationsCtx           *client.DestinationsContext
 	pipelineProvider          pipeline.Provider
+	metricGenerator           *processor.MetricGenerator
 	launchers                 *launchers.Launchers
 	health                    *health.Handle
 	diagnosticMessageReceiver *diagnostic.BufferedMessageReceiver
@@ -150,6 +162,7 @@ func newLogsAgent(deps dependencies) provides {
 			schedulerProviders: deps.SchedulerProviders,
 			integrationsLogs:   integrationsLogs,
 			tagger:             deps.Tagger,
+			senderManager:      deps.SenderManager,
 		}
 		deps.Lc.Append(fx.Hook{
 			OnStart: logsAgent.start,
@@ -232,6 +245,15 @@ func (a *logAgent) setupAgent() error {
 		status.AddGlobalWarning(invalidProcessingRules, multiLineWarning)
 	}
 
+	// setup global metric rules
+	metricGenerator, err := a.newMetricGenerator()
+	if err != nil {
+		message := fmt.Sprintf("Invalid metric rules: %v", err)
+		status.AddGlobalError(invalidMetricRules, message)
+		return errors.New(message)
+	}
+	a.metricGenerator = metricGenerator
+
 	if err := sds.ValidateConfigField(a.config); err != nil {
 		a.log.Error(fmt.Errorf("error while reading configuration, will block until the Agents receive an SDS configuration: %v", err))
 	}
@@ -240,6 +262,28 @@ func (a *logAgent) setupAgent() error {
 	return nil
 }
 
+// newMetricGenerator returns the generator of the metrics extracted from logs,
+// or nil when no aggregator is running alongside the logs agent.
+func (a *logAgent) newMetricGenerator() (*processor.MetricGenerator, error) {
+	metricRules, err := config.GlobalMetricRules(a.config)
+	if err != nil {
+		return nil, err
+	}
+	if a.senderManager == nil {
+		if len(metricRules) > 0 {
+			a.log.Warn("metric rules are ignored, no aggregator is running alongside the logs agent")
+		}
+		return nil, nil
+	}
+	// the generator gets its own sender, as it commits it independently of the
+	// other users of the aggregator
+	metricSender, err := a.senderManager.GetSender(metricRulesSenderID)
+	if err != nil {
+		return nil, err
+	}
+	return processor.NewMetricGenerator(metricSender, metricRules), nil
+}
+
 // Start starts all the elements of the data pipeline
 // in the right order to prevent data loss
 func (a *logAgent) startPipeline() {
@@ -325,6 +369,9 @@ func (a *logAgent) stop(context.Context) error {
 			}
 		}
 	}
+	if a.metricGenerator != nil {
+		a.senderManager.DestroySender(metricRulesSenderID)
+	}
 	a.log.Info("logs-agent stopped")
 	return nil
 }
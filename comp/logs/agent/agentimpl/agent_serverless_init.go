@@ -49,7 +49,7 @@ func (a *logAgent) SetupPipeline(
 	destinationsCtx := client.NewDestinationsContext()
 
 	// setup the pipeline provider that provides pairs of processor and sender
-	pipelineProvider := pipeline.NewServerlessProvider(a.config.GetInt("logs_config.pipelines"), a.auditor, diagnosticMessageReceiver, processingRules, a.endpoints, destinationsCtx, NewStatusProvider(), a.hostname, a.config)
+	pipelineProvider := pipeline.NewServerlessProvider(a.config.GetInt("logs_config.pipelines"), a.auditor, diagnosticMessageReceiver, processingRules, nil, a.endpoints, destinationsCtx, NewStatusProvider(), a.hostname, a.config)
 
 	lnchrs := launchers.NewLaunchers(a.sources, pipelineProvider, a.auditor, a.tracker)
 	lnchrs.AddLauncher(channel.NewLauncher())
@@ -64,6 +64,7 @@ func (a *logAgent) SetupPipeline(
 		fileValidatePodContainer,
 		fileScanPeriod,
 		fileWildcardSelectionMode,
+		false,
 		a.flarecontroller,
 		a.tagger))
 	a.schedulers = schedulers.NewSchedulers(a.sources, a.services)
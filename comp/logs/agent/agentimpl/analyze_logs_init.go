@@ -48,6 +48,7 @@ func SetUpLaunchers(conf configComponent.Component, sourceProvider *sources.Conf
 		fileValidatePodContainer,
 		fileScanPeriod,
 		fileWildcardSelectionMode,
+		false,
 		flare.NewFlareController(),
 		nil)
 	tracker := tailers.NewTailerTracker()
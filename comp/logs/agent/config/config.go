@@ -87,6 +87,33 @@ func GlobalProcessingRules(coreConfig pkgconfigmodel.Reader) ([]*ProcessingRule,
 	return rules, nil
 }
 
+// GlobalMetricRules returns the global metric rules generating metrics from all logs.
+func GlobalMetricRules(coreConfig pkgconfigmodel.Reader) ([]*MetricRule, error) {
+	var rules []*MetricRule
+	var err error
+	raw := coreConfig.Get("logs_config.metric_rules")
+	if raw == nil {
+		return rules, nil
+	}
+	if s, ok := raw.(string); ok && s != "" {
+		err = json.Unmarshal([]byte(s), &rules)
+	} else {
+		err = structure.UnmarshalKey(coreConfig, "logs_config.metric_rules", &rules, structure.ConvertEmptyStringToNil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateMetricRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	err = CompileMetricRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
 // HasMultiLineRule returns true if the rule set contains a multi_line rule
 func HasMultiLineRule(rules []*ProcessingRule) bool {
 	for _, rule := range rules {
@@ -158,7 +158,32 @@ func (l *LogsConfigKeys) getAdditionalEndpoints() []unmarshalEndpoint {
 	if err != nil {
 		log.Warnf("Could not parse additional_endpoints for logs: %v", err)
 	}
-	return endpoints
+	validEndpoints := endpoints[:0]
+	for _, e := range endpoints {
+		switch {
+		case e.Type != "" && e.Type != KafkaDestination && !e.IsLocal():
+			log.Warnf("Ignoring additional endpoint for logs: unsupported type %s", e.Type)
+		case e.Type == FileDestination && e.Path == "":
+			log.Warnf("Ignoring additional endpoint for logs: no path provided for a file destination")
+		case e.Type == KafkaDestination && (len(e.Brokers) == 0 || e.Topic == ""):
+			log.Warnf("Ignoring additional endpoint for logs: brokers and topic are required for a kafka destination")
+		case e.Type == KafkaDestination && !isValidKafkaCompression(e.Compression):
+			log.Warnf("Ignoring additional endpoint for logs: unsupported kafka compression %s", e.Compression)
+		case e.Type == KafkaDestination && e.RequiredAcks != "" && e.RequiredAcks != KafkaAcksAll && e.RequiredAcks != KafkaAcksLeader:
+			log.Warnf("Ignoring additional endpoint for logs: unsupported kafka required_acks %s", e.RequiredAcks)
+		default:
+			validEndpoints = append(validEndpoints, e)
+		}
+	}
+	return validEndpoints
+}
+
+func isValidKafkaCompression(compression string) bool {
+	switch compression {
+	case "", KafkaCompressionNone, KafkaCompressionGzip, KafkaCompressionSnappy, KafkaCompressionLz4, KafkaCompressionZstd:
+		return true
+	}
+	return false
 }
 
 func (l *LogsConfigKeys) expectedTagsDuration() time.Duration {
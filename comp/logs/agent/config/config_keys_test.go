@@ -104,3 +104,59 @@ func TestGetAdditionalEndpoints(t *testing.T) {
 	endpoints = l.getAdditionalEndpoints()
 	assert.Equal(t, expected, endpoints)
 }
+
+func TestGetAdditionalLocalEndpoints(t *testing.T) {
+	configMock, l := getLogsConfigKeys(t)
+
+	configMock.SetWithoutSource("logs_config.additional_endpoints", `[
+		{"type": "file", "path": "/var/log/datadog/logs.json", "max_file_size": 1048576, "max_file_rolls": 3},
+		{"type": "stdout", "is_reliable": false},
+		{"type": "file"},
+		{"type": "s3", "Host": "localhost"}
+	]`)
+
+	endpoints := l.getAdditionalEndpoints()
+	assert.Equal(t, []unmarshalEndpoint{
+		{
+			Endpoint: Endpoint{
+				Type:         FileDestination,
+				Path:         "/var/log/datadog/logs.json",
+				MaxFileSize:  1048576,
+				MaxFileRolls: 3,
+			},
+		},
+		{
+			IsReliable: pointer.Ptr(false),
+			Endpoint: Endpoint{
+				Type: StdoutDestination,
+			},
+		},
+	}, endpoints)
+	assert.True(t, endpoints[0].IsLocal())
+}
+
+func TestGetAdditionalKafkaEndpoints(t *testing.T) {
+	configMock, l := getLogsConfigKeys(t)
+
+	configMock.SetWithoutSource("logs_config.additional_endpoints", `[
+		{"type": "kafka", "brokers": ["kafka-1:9092", "kafka-2:9092"], "topic": "logs-{service}", "compression": "zstd", "required_acks": "leader"},
+		{"type": "kafka", "topic": "logs"},
+		{"type": "kafka", "brokers": ["kafka-1:9092"]},
+		{"type": "kafka", "brokers": ["kafka-1:9092"], "topic": "logs", "compression": "brotli"},
+		{"type": "kafka", "brokers": ["kafka-1:9092"], "topic": "logs", "required_acks": "none"}
+	]`)
+
+	endpoints := l.getAdditionalEndpoints()
+	assert.Equal(t, []unmarshalEndpoint{
+		{
+			Endpoint: Endpoint{
+				Type:         KafkaDestination,
+				Brokers:      []string{"kafka-1:9092", "kafka-2:9092"},
+				Topic:        "logs-{service}",
+				Compression:  KafkaCompressionZstd,
+				RequiredAcks: KafkaAcksLeader,
+			},
+		},
+	}, endpoints)
+	assert.False(t, endpoints[0].IsLocal())
+}
@@ -158,6 +158,25 @@ func (suite *ConfigTestSuite) TestGlobalProcessingRulesShouldReturnRulesWithVali
 	suite.NotNil(rule.Regex)
 }
 
+func (suite *ConfigTestSuite) TestGlobalMetricRulesShouldReturnRulesWithValidJSONString() {
+	suite.config.SetWithoutSource("logs_config.metric_rules", `[{"name":"app.request.duration","type":"distribution","pattern":"took (?P<duration>\\d+)ms","value_group":"duration"}]`)
+
+	rules, err := GlobalMetricRules(suite.config)
+	suite.Nil(err)
+	suite.Equal(1, len(rules))
+
+	rule := rules[0]
+	suite.Equal(DistributionMetric, rule.Type)
+	suite.Equal("app.request.duration", rule.Name)
+	suite.Equal("duration", rule.ValueGroup)
+	suite.Equal(1, rule.ValueIndex)
+	suite.NotNil(rule.Regex)
+
+	suite.config.SetWithoutSource("logs_config.metric_rules", `[{"name":"app.request.duration","type":"distribution","pattern":"took \\d+ms"}]`)
+	_, err = GlobalMetricRules(suite.config)
+	suite.NotNil(err)
+}
+
 func (suite *ConfigTestSuite) TestTaggerWarmupDuration() {
 	// assert TaggerWarmupDuration is disabled by default
 	taggerWarmupDuration := TaggerWarmupDuration(suite.config)
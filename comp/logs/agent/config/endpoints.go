@@ -7,6 +7,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
@@ -33,6 +34,30 @@ const (
 	EPIntakeVersion2
 )
 
+// Types of the local destinations, writing logs locally instead of sending them to an intake.
+const (
+	FileDestination   = "file"
+	StdoutDestination = "stdout"
+)
+
+// KafkaDestination is the type of the destinations producing logs to Kafka topics.
+const KafkaDestination = "kafka"
+
+// Compression codecs of the Kafka destinations.
+const (
+	KafkaCompressionNone   = "none"
+	KafkaCompressionGzip   = "gzip"
+	KafkaCompressionSnappy = "snappy"
+	KafkaCompressionLz4    = "lz4"
+	KafkaCompressionZstd   = "zstd"
+)
+
+// Acknowledgements required by the Kafka destinations before a payload is considered sent.
+const (
+	KafkaAcksAll    = "all"
+	KafkaAcksLeader = "leader"
+)
+
 // Endpoint holds all the organization and network parameters to send logs to Datadog.
 type Endpoint struct {
 	apiKeyGetter func() string
@@ -57,6 +82,25 @@ type Endpoint struct {
 	TrackType IntakeTrackType
 	Protocol  IntakeProtocol
 	Origin    IntakeOrigin
+
+	// Type is the type of a local destination, empty for an intake.
+	Type string `mapstructure:"type" json:"type,omitempty"`
+	// Path is the path of the file written by a file destination.
+	Path string `mapstructure:"path" json:"path,omitempty"`
+	// MaxFileSize is the size in bytes at which the file of a file destination is rotated.
+	MaxFileSize int64 `mapstructure:"max_file_size" json:"max_file_size,omitempty"`
+	// MaxFileRolls is the number of rotated files kept by a file destination.
+	MaxFileRolls int `mapstructure:"max_file_rolls" json:"max_file_rolls,omitempty"`
+
+	// Brokers are the addresses of the seed brokers of a Kafka destination.
+	Brokers []string `mapstructure:"brokers" json:"brokers,omitempty"`
+	// Topic is the topic of a Kafka destination. The {source} and {service}
+	// placeholders are replaced by the source and service of each log.
+	Topic string `mapstructure:"topic" json:"topic,omitempty"`
+	// Compression is the codec compressing the batches of a Kafka destination.
+	Compression string `mapstructure:"compression" json:"compression,omitempty"`
+	// RequiredAcks are the acknowledgements a Kafka destination waits for, "all" or "leader".
+	RequiredAcks string `mapstructure:"required_acks" json:"required_acks,omitempty"`
 }
 
 // unmarshalEndpoint is used to load additional endpoints from the configuration which stored as JSON/mapstructure.
@@ -136,10 +180,19 @@ func loadTCPAdditionalEndpoints(main Endpoint, l *LogsConfigKeys) []Endpoint {
 		newE.TrackType = e.TrackType
 		newE.Protocol = e.Protocol
 		newE.Origin = e.Origin
+		newE.Type = e.Type
+		newE.Path = e.Path
+		newE.MaxFileSize = e.MaxFileSize
+		newE.MaxFileRolls = e.MaxFileRolls
+		newE.Brokers = e.Brokers
+		newE.Topic = e.Topic
+		newE.Compression = e.Compression
+		newE.RequiredAcks = e.RequiredAcks
 
 		if e.UseSSL != nil {
 			newE.useSSL = *e.UseSSL
-		} else {
+		} else if e.Type != KafkaDestination {
+			// Kafka brokers don't share the TLS setting of the intake
 			newE.useSSL = main.useSSL
 		}
 		newEndpoints = append(newEndpoints, newE)
@@ -168,10 +221,19 @@ func loadHTTPAdditionalEndpoints(main Endpoint, l *LogsConfigKeys, intakeTrackTy
 		newE.TrackType = e.TrackType
 		newE.Protocol = e.Protocol
 		newE.Origin = e.Origin
+		newE.Type = e.Type
+		newE.Path = e.Path
+		newE.MaxFileSize = e.MaxFileSize
+		newE.MaxFileRolls = e.MaxFileRolls
+		newE.Brokers = e.Brokers
+		newE.Topic = e.Topic
+		newE.Compression = e.Compression
+		newE.RequiredAcks = e.RequiredAcks
 
 		if e.UseSSL != nil {
 			newE.useSSL = *e.UseSSL
-		} else {
+		} else if e.Type != KafkaDestination {
+			// Kafka brokers don't share the TLS setting of the intake
 			newE.useSSL = main.useSSL
 		}
 
@@ -199,8 +261,22 @@ func (e *Endpoint) UseSSL() bool {
 	return e.useSSL
 }
 
+// IsLocal returns true if the endpoint writes logs locally instead of sending them to an intake.
+func (e *Endpoint) IsLocal() bool {
+	return e.Type == FileDestination || e.Type == StdoutDestination
+}
+
 // GetStatus returns the endpoint status
 func (e *Endpoint) GetStatus(prefix string, useHTTP bool) string {
+	switch e.Type {
+	case FileDestination:
+		return fmt.Sprintf("%sWriting logs to %s", prefix, e.Path)
+	case StdoutDestination:
+		return fmt.Sprintf("%sWriting logs to stdout", prefix)
+	case KafkaDestination:
+		return fmt.Sprintf("%sProducing logs to the Kafka topic %s on %s", prefix, e.Topic, strings.Join(e.Brokers, ","))
+	}
+
 	compression := "uncompressed"
 	if e.UseCompression {
 		compression = "compressed"
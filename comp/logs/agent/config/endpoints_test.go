@@ -410,6 +410,73 @@ func (suite *EndpointsTestSuite) TestAdditionalEndpoints() {
 	suite.True(endpoint.UseSSL())
 }
 
+func (suite *EndpointsTestSuite) TestAdditionalLocalEndpoints() {
+	suite.config.SetWithoutSource("logs_config.use_http", true)
+	suite.config.SetWithoutSource("logs_config.additional_endpoints", []map[string]interface{}{
+		{
+			"type":           "file",
+			"path":           "/var/log/datadog/logs-archive.json",
+			"max_file_rolls": 2,
+		},
+		{
+			"type":        "stdout",
+			"is_reliable": false,
+		},
+	})
+
+	endpoints, err := BuildEndpoints(suite.config, HTTPConnectivityFailure, "test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.Len(endpoints.Endpoints, 3)
+
+	file := endpoints.Endpoints[1]
+	suite.True(file.IsLocal())
+	suite.Equal("/var/log/datadog/logs-archive.json", file.Path)
+	suite.Equal(2, file.MaxFileRolls)
+	suite.True(file.IsReliable())
+	suite.Equal("Reliable: Writing logs to /var/log/datadog/logs-archive.json", file.GetStatus("Reliable: ", true))
+
+	stdout := endpoints.Endpoints[2]
+	suite.True(stdout.IsLocal())
+	suite.False(stdout.IsReliable())
+	suite.Equal("Unreliable: Writing logs to stdout", stdout.GetStatus("Unreliable: ", true))
+	suite.False(endpoints.Main.IsLocal())
+}
+
+func (suite *EndpointsTestSuite) TestAdditionalKafkaEndpoints() {
+	suite.config.SetWithoutSource("logs_config.use_http", true)
+	suite.config.SetWithoutSource("logs_config.additional_endpoints", []map[string]interface{}{
+		{
+			"type":        "kafka",
+			"brokers":     []string{"kafka-1:9092", "kafka-2:9092"},
+			"topic":       "logs-{source}",
+			"compression": "lz4",
+		},
+		{
+			"type":    "kafka",
+			"brokers": []string{"kafka-1:9093"},
+			"topic":   "logs",
+			"use_ssl": true,
+		},
+	})
+
+	endpoints, err := BuildEndpoints(suite.config, HTTPConnectivityFailure, "test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.Len(endpoints.Endpoints, 3)
+
+	kafka := endpoints.Endpoints[1]
+	suite.Equal(KafkaDestination, kafka.Type)
+	suite.Equal([]string{"kafka-1:9092", "kafka-2:9092"}, kafka.Brokers)
+	suite.Equal("logs-{source}", kafka.Topic)
+	suite.Equal(KafkaCompressionLz4, kafka.Compression)
+	suite.True(kafka.IsReliable())
+	// the TLS setting of the intake does not apply to the brokers
+	suite.True(endpoints.Main.UseSSL())
+	suite.False(kafka.UseSSL())
+	suite.Equal("Reliable: Producing logs to the Kafka topic logs-{source} on kafka-1:9092,kafka-2:9092", kafka.GetStatus("Reliable: ", true))
+
+	suite.True(endpoints.Endpoints[2].UseSSL())
+}
+
 func (suite *EndpointsTestSuite) TestAdditionalEndpointsMappedCorrectly() {
 	var (
 		endpoints *Endpoints
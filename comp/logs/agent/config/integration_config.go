@@ -25,6 +25,7 @@ const (
 	IntegrationType   = "integration"
 	WindowsEventType  = "windows_event"
 	StringChannelType = "string_channel"
+	SyslogType        = "syslog"
 
 	// UTF16BE for UTF-16 Big endian encoding
 	UTF16BE string = "utf-16-be"
@@ -32,6 +33,18 @@ const (
 	UTF16LE string = "utf-16-le"
 	// SHIFTJIS for Shift JIS (Japanese) encoding
 	SHIFTJIS string = "shift-jis"
+
+	// AutoCompression detects compressed archives from their file extension
+	AutoCompression string = "auto"
+	// GzipCompression for gzip-compressed archives
+	GzipCompression string = "gzip"
+	// ZstdCompression for zstd-compressed archives
+	ZstdCompression string = "zstd"
+
+	// SyslogStructuredDataTags adds the structured data of syslog messages as tags
+	SyslogStructuredDataTags string = "tags"
+	// SyslogStructuredDataAttributes adds the structured data of syslog messages as attributes
+	SyslogStructuredDataAttributes string = "attributes"
 )
 
 // LogsConfig represents a log source config, which can be for instance
@@ -45,9 +58,16 @@ type LogsConfig struct {
 	IdleTimeout string `mapstructure:"idle_timeout" json:"idle_timeout"` // Network
 	Path        string // File, Journald
 
+	Protocol       string `mapstructure:"protocol" json:"protocol"`               // Syslog
+	TLSCertFile    string `mapstructure:"tls_cert_file" json:"tls_cert_file"`     // Syslog
+	TLSKeyFile     string `mapstructure:"tls_key_file" json:"tls_key_file"`       // Syslog
+	TLSCAFile      string `mapstructure:"tls_ca_file" json:"tls_ca_file"`         // Syslog
+	StructuredData string `mapstructure:"structured_data" json:"structured_data"` // Syslog
+
 	Encoding     string   `mapstructure:"encoding" json:"encoding"`             // File
 	ExcludePaths []string `mapstructure:"exclude_paths" json:"exclude_paths"`   // File
 	TailingMode  string   `mapstructure:"start_position" json:"start_position"` // File
+	Compression  string   `mapstructure:"compression" json:"compression"`       // File
 
 	//nolint:revive // TODO(AML) Fix revive linter
 	ConfigId           string   `mapstructure:"config_id" json:"config_id"`                   // Journald
@@ -86,6 +106,7 @@ type LogsConfig struct {
 	SourceCategory  string
 	Tags            []string
 	ProcessingRules []*ProcessingRule `mapstructure:"log_processing_rules" json:"log_processing_rules"`
+	MetricRules     []*MetricRule     `mapstructure:"log_metric_rules" json:"log_metric_rules"`
 	// ProcessRawMessage is used to process the raw message instead of only the content part of the message.
 	ProcessRawMessage *bool `mapstructure:"process_raw_message" json:"process_raw_message"`
 
@@ -123,6 +144,7 @@ func (c *LogsConfig) Dump(multiline bool) string {
 		fmt.Fprintf(&b, ws("Identifier: %#v,"), c.Identifier)
 		fmt.Fprintf(&b, ws("ExcludePaths: %#v,"), c.ExcludePaths)
 		fmt.Fprintf(&b, ws("TailingMode: %#v,"), c.TailingMode)
+		fmt.Fprintf(&b, ws("Compression: %#v,"), c.Compression)
 	case DockerType, ContainerdType:
 		fmt.Fprintf(&b, ws("Image: %#v,"), c.Image)
 		fmt.Fprintf(&b, ws("Label: %#v,"), c.Label)
@@ -139,6 +161,14 @@ func (c *LogsConfig) Dump(multiline bool) string {
 	case WindowsEventType:
 		fmt.Fprintf(&b, ws("ChannelPath: %#v,"), c.ChannelPath)
 		fmt.Fprintf(&b, ws("Query: %#v,"), c.Query)
+	case SyslogType:
+		fmt.Fprintf(&b, ws("Port: %d,"), c.Port)
+		fmt.Fprintf(&b, ws("Protocol: %#v,"), c.Protocol)
+		fmt.Fprintf(&b, ws("IdleTimeout: %#v,"), c.IdleTimeout)
+		fmt.Fprintf(&b, ws("TLSCertFile: %#v,"), c.TLSCertFile)
+		fmt.Fprintf(&b, ws("TLSKeyFile: %#v,"), c.TLSKeyFile)
+		fmt.Fprintf(&b, ws("TLSCAFile: %#v,"), c.TLSCAFile)
+		fmt.Fprintf(&b, ws("StructuredData: %#v,"), c.StructuredData)
 	case StringChannelType:
 		fmt.Fprintf(&b, ws("Channel: %p,"), c.Channel)
 		c.ChannelTagsMutex.Lock()
@@ -150,6 +180,7 @@ func (c *LogsConfig) Dump(multiline bool) string {
 	fmt.Fprintf(&b, ws("SourceCategory: %#v,"), c.SourceCategory)
 	fmt.Fprintf(&b, ws("Tags: %#v,"), c.Tags)
 	fmt.Fprintf(&b, ws("ProcessingRules: %#v,"), c.ProcessingRules)
+	fmt.Fprintf(&b, ws("MetricRules: %#v,"), c.MetricRules)
 	if c.ProcessRawMessage != nil {
 		fmt.Fprintf(&b, ws("ProcessRawMessage: %t,"), *c.ProcessRawMessage)
 	} else {
@@ -173,10 +204,12 @@ func (c *LogsConfig) PublicJSON() ([]byte, error) {
 	return json.Marshal(&struct {
 		Type            string            `json:"type,omitempty"`
 		Port            int               `json:"port,omitempty"`           // Network
+		Protocol        string            `json:"protocol,omitempty"`       // Syslog
 		Path            string            `json:"path,omitempty"`           // File, Journald
 		Encoding        string            `json:"encoding,omitempty"`       // File
 		ExcludePaths    []string          `json:"exclude_paths,omitempty"`  // File
 		TailingMode     string            `json:"start_position,omitempty"` // File
+		Compression     string            `json:"compression,omitempty"`    // File
 		ChannelPath     string            `json:"channel_path,omitempty"`   // Windows Event
 		Service         string            `json:"service,omitempty"`
 		Source          string            `json:"source,omitempty"`
@@ -186,10 +219,12 @@ func (c *LogsConfig) PublicJSON() ([]byte, error) {
 	}{
 		Type:            c.Type,
 		Port:            c.Port,
+		Protocol:        c.Protocol,
 		Path:            c.Path,
 		Encoding:        c.Encoding,
 		ExcludePaths:    c.ExcludePaths,
 		TailingMode:     c.TailingMode,
+		Compression:     c.Compression,
 		ChannelPath:     c.ChannelPath,
 		Service:         c.Service,
 		Source:          c.Source,
@@ -256,16 +291,33 @@ func (c *LogsConfig) Validate() error {
 		if err != nil {
 			return err
 		}
+		err = c.validateCompression()
+		if err != nil {
+			return err
+		}
 	case c.Type == TCPType && c.Port == 0:
 		return fmt.Errorf("tcp source must have a port")
 	case c.Type == UDPType && c.Port == 0:
 		return fmt.Errorf("udp source must have a port")
+	case c.Type == SyslogType:
+		err := c.validateSyslog()
+		if err != nil {
+			return err
+		}
 	}
 	err := ValidateProcessingRules(c.ProcessingRules)
 	if err != nil {
 		return err
 	}
-	return CompileProcessingRules(c.ProcessingRules)
+	err = CompileProcessingRules(c.ProcessingRules)
+	if err != nil {
+		return err
+	}
+	err = ValidateMetricRules(c.MetricRules)
+	if err != nil {
+		return err
+	}
+	return CompileMetricRules(c.MetricRules)
 }
 
 func (c *LogsConfig) validateTailingMode() error {
@@ -279,6 +331,40 @@ func (c *LogsConfig) validateTailingMode() error {
 	return nil
 }
 
+func (c *LogsConfig) validateCompression() error {
+	switch c.Compression {
+	case "", AutoCompression, GzipCompression, ZstdCompression:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression '%v' for %v, must be one of '%v', '%v' or '%v'", c.Compression, c.Path, AutoCompression, GzipCompression, ZstdCompression)
+	}
+}
+
+func (c *LogsConfig) validateSyslog() error {
+	if c.Port == 0 {
+		return fmt.Errorf("syslog source must have a port")
+	}
+	switch c.Protocol {
+	case "", TCPType, UDPType:
+	default:
+		return fmt.Errorf("invalid protocol '%v' for syslog source, must be '%v' or '%v'", c.Protocol, TCPType, UDPType)
+	}
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" || c.TLSCAFile != "" {
+		if c.Protocol == UDPType {
+			return fmt.Errorf("TLS is not supported for syslog source over udp")
+		}
+		if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+			return fmt.Errorf("syslog source with TLS must have both a tls_cert_file and a tls_key_file")
+		}
+	}
+	switch c.StructuredData {
+	case "", SyslogStructuredDataTags, SyslogStructuredDataAttributes:
+	default:
+		return fmt.Errorf("invalid structured_data '%v' for syslog source, must be '%v' or '%v'", c.StructuredData, SyslogStructuredDataTags, SyslogStructuredDataAttributes)
+	}
+	return nil
+}
+
 // AutoMultiLineEnabled determines whether auto multi line detection is enabled for this config,
 // considering both the agent-wide logs_config.auto_multi_line_detection and any config for this
 // particular log source.
@@ -19,8 +19,13 @@ import (
 func TestValidateShouldSucceedWithValidConfigs(t *testing.T) {
 	validConfigs := []*LogsConfig{
 		{Type: FileType, Path: "/var/log/foo.log"},
+		{Type: FileType, Path: "/var/log/foo.log.*.gz", Compression: AutoCompression},
+		{Type: FileType, Path: "/var/log/foo.log.1", Compression: ZstdCompression},
 		{Type: TCPType, Port: 1234},
 		{Type: UDPType, Port: 5678},
+		{Type: SyslogType, Port: 514},
+		{Type: SyslogType, Port: 514, Protocol: UDPType, StructuredData: SyslogStructuredDataAttributes},
+		{Type: SyslogType, Port: 6514, TLSCertFile: "/etc/ssl/cert.pem", TLSKeyFile: "/etc/ssl/key.pem"},
 		{Type: DockerType},
 		{Type: JournaldType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: ExcludeAtMatch, Pattern: ".*"}}},
 	}
@@ -35,8 +40,14 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 	invalidConfigs := []*LogsConfig{
 		{},
 		{Type: FileType},
+		{Type: FileType, Path: "/var/log/foo.log.gz", Compression: "bzip2"},
 		{Type: TCPType},
 		{Type: UDPType},
+		{Type: SyslogType},
+		{Type: SyslogType, Port: 514, Protocol: "sctp"},
+		{Type: SyslogType, Port: 514, Protocol: UDPType, TLSCertFile: "/etc/ssl/cert.pem", TLSKeyFile: "/etc/ssl/key.pem"},
+		{Type: SyslogType, Port: 6514, TLSCertFile: "/etc/ssl/cert.pem"},
+		{Type: SyslogType, Port: 514, StructuredData: "fields"},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Name: "foo"}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: "bar"}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: ExcludeAtMatch}}},
@@ -44,6 +55,7 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Type: ExcludeAtMatch, Pattern: ".*"}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Type: ExcludeAtMatch}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Pattern: ".*"}}},
+		{Type: DockerType, MetricRules: []*MetricRule{{Name: "foo", Type: DistributionMetric, Pattern: ".*"}}},
 	}
 
 	for _, config := range invalidConfigs {
@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Types of the metrics generated from logs
+const (
+	CountMetric        = "count"
+	DistributionMetric = "distribution"
+)
+
+// MetricRule defines a metric generated from the log lines matching a pattern
+type MetricRule struct {
+	// Name is the name of the generated metric.
+	Name    string
+	Type    string
+	Pattern string
+	// ValueGroup is the name of the capture group holding the value of the
+	// metric. It is required for distributions, counts are incremented by one
+	// when it is not set.
+	ValueGroup string `mapstructure:"value_group" json:"value_group,omitempty"`
+	// TagGroups are the names of the capture groups added as tags to the metric,
+	// the capture group name being the tag name.
+	TagGroups []string `mapstructure:"tag_groups" json:"tag_groups,omitempty"`
+	// Tags are static tags added to the metric.
+	Tags []string `mapstructure:"tags" json:"tags,omitempty"`
+	// TODO: should be moved out
+	Regex      *regexp.Regexp
+	ValueIndex int
+	TagIndexes []int
+}
+
+// ValidateMetricRules validates the rules and raises an error if one is misconfigured.
+// Each metric rule must have:
+// - a metric name
+// - a valid type
+// - a valid pattern that compiles
+// - capture groups for its value and tag groups, a value group being required for distributions
+func ValidateMetricRules(rules []*MetricRule) error {
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("all metric rules must have a name")
+		}
+
+		switch rule.Type {
+		case CountMetric, DistributionMetric:
+		case "":
+			return fmt.Errorf("type must be set for metric rule `%s`", rule.Name)
+		default:
+			return fmt.Errorf("type %s is not supported for metric rule `%s`", rule.Type, rule.Name)
+		}
+
+		if rule.Pattern == "" {
+			return fmt.Errorf("no pattern provided for metric rule: %s", rule.Name)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s for metric rule: %s", rule.Pattern, rule.Name)
+		}
+
+		if rule.Type == DistributionMetric && rule.ValueGroup == "" {
+			return fmt.Errorf("no value_group provided for metric rule: %s", rule.Name)
+		}
+		if rule.ValueGroup != "" && re.SubexpIndex(rule.ValueGroup) < 0 {
+			return fmt.Errorf("pattern %s has no capture group named %s for metric rule: %s", rule.Pattern, rule.ValueGroup, rule.Name)
+		}
+		for _, group := range rule.TagGroups {
+			if re.SubexpIndex(group) < 0 {
+				return fmt.Errorf("pattern %s has no capture group named %s for metric rule: %s", rule.Pattern, group, rule.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// CompileMetricRules compiles the regular expressions of the metric rules and
+// resolves the indexes of their capture groups.
+func CompileMetricRules(rules []*MetricRule) error {
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return err
+		}
+		rule.Regex = re
+		rule.ValueIndex = -1
+		if rule.ValueGroup != "" {
+			rule.ValueIndex = re.SubexpIndex(rule.ValueGroup)
+		}
+		rule.TagIndexes = make([]int, 0, len(rule.TagGroups))
+		for _, group := range rule.TagGroups {
+			rule.TagIndexes = append(rule.TagIndexes, re.SubexpIndex(group))
+		}
+	}
+	return nil
+}
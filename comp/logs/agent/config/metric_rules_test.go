@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileMetricRules(t *testing.T) {
+	rules := []*MetricRule{
+		{Name: "app.errors", Type: CountMetric, Pattern: "ERROR"},
+		{Name: "app.latency", Type: DistributionMetric, Pattern: `(?P<method>GET|POST) (?P<path>\S+) took (?P<duration>[\d.]+)ms`, ValueGroup: "duration", TagGroups: []string{"path", "method"}},
+	}
+	assert.Nil(t, ValidateMetricRules(rules))
+	assert.Nil(t, CompileMetricRules(rules))
+
+	assert.True(t, rules[0].Regex.MatchString("ERROR: disk full"))
+	assert.Equal(t, -1, rules[0].ValueIndex)
+	assert.Empty(t, rules[0].TagIndexes)
+	assert.Equal(t, 3, rules[1].ValueIndex)
+	assert.Equal(t, []int{2, 1}, rules[1].TagIndexes)
+}
+
+func TestValidateShouldFailWithInvalidMetricRules(t *testing.T) {
+	invalidRules := []*MetricRule{
+		{Type: CountMetric, Pattern: "ERROR"},
+		{Name: "no_type", Pattern: "ERROR"},
+		{Name: "bad_type", Type: "gauge", Pattern: "ERROR"},
+		{Name: "no_pattern", Type: CountMetric},
+		{Name: "bad_pattern", Type: CountMetric, Pattern: "(?=abf)"},
+		{Name: "no_value_group", Type: DistributionMetric, Pattern: `took (\d+)ms`},
+		{Name: "unknown_value_group", Type: DistributionMetric, Pattern: `took (?P<duration>\d+)ms`, ValueGroup: "latency"},
+		{Name: "unknown_tag_group", Type: CountMetric, Pattern: `(?P<level>ERROR|WARN)`, TagGroups: []string{"status"}},
+	}
+
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateMetricRules([]*MetricRule{rule}), rule.Name)
+	}
+}
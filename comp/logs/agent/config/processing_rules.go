@@ -8,6 +8,7 @@ package config
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // Processing rule types
@@ -16,6 +17,37 @@ const (
 	IncludeAtMatch = "include_at_match"
 	MaskSequences  = "mask_sequences"
 	MultiLine      = "multi_line"
+
+	// Structured processing rule types, applied on the keys of messages
+	// parsed as JSON or logfmt
+	RemoveKeys     = "remove_keys"
+	RenameKey      = "rename_key"
+	HashKeys       = "hash_keys"
+	MaskKeys       = "mask_keys"
+	TagFromKey     = "tag_from_key"
+	StatusFromKey  = "status_from_key"
+	ServiceFromKey = "service_from_key"
+
+	// Processing rule types capping the throughput of a source
+	Sample    = "sample"
+	RateLimit = "rate_limit"
+)
+
+// Modes of the multi_line processing rules
+const (
+	// MultiLineModePattern starts a new message at each line matching the pattern.
+	MultiLineModePattern = "pattern"
+	// MultiLineModeIndentation appends the lines starting with a space or a tab to the previous message.
+	MultiLineModeIndentation = "indentation"
+	// MultiLineModeMarkers aggregates the lines from a line matching the pattern to a line
+	// matching the end pattern, the lines out of such blocks are messages of their own.
+	MultiLineModeMarkers = "markers"
+)
+
+// Formats of the messages structured processing rules apply on
+const (
+	JSONFormat   = "json"
+	LogfmtFormat = "logfmt"
 )
 
 // ProcessingRule defines an exclusion or a masking rule to
@@ -25,9 +57,60 @@ type ProcessingRule struct {
 	Name               string
 	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder"`
 	Pattern            string
+	// Format is the format structured rules parse messages with, JSON by default.
+	Format string `mapstructure:"format" json:"format,omitempty"`
+	// Paths are the dot-separated paths of the keys structured rules apply on.
+	Paths []string `mapstructure:"paths" json:"paths,omitempty"`
+	// Target is the new path of a rename_key rule, or the tag name of a tag_from_key rule.
+	Target string `mapstructure:"target" json:"target,omitempty"`
+	// SampleRate is the ratio of messages kept by a sample rule, in (0, 1].
+	SampleRate float64 `mapstructure:"sample_rate" json:"sample_rate,omitempty"`
+	// MessagesPerSecond is the sustained throughput allowed by a rate_limit rule.
+	MessagesPerSecond float64 `mapstructure:"messages_per_second" json:"messages_per_second,omitempty"`
+	// Burst is the number of messages a rate_limit rule lets through at once,
+	// defaults to the number of messages allowed per second.
+	Burst int `mapstructure:"burst" json:"burst,omitempty"`
+	// Mode is how a multi_line rule aggregates lines, by pattern by default.
+	Mode string `mapstructure:"mode" json:"mode,omitempty"`
+	// EndPattern matches the last line of the messages of a multi_line rule in markers mode.
+	EndPattern string `mapstructure:"end_pattern" json:"end_pattern,omitempty"`
+	// MaxLines is the maximum number of lines aggregated by a multi_line rule, unbounded if 0.
+	MaxLines int `mapstructure:"max_lines" json:"max_lines,omitempty"`
+	// MaxDuration is the maximum time in seconds a multi_line rule aggregates the lines
+	// of a message for, counted from its first line, unbounded if 0.
+	MaxDuration float64 `mapstructure:"max_duration" json:"max_duration,omitempty"`
 	// TODO: should be moved out
 	Regex       *regexp.Regexp
+	EndRegex    *regexp.Regexp
 	Placeholder []byte
+	KeyPaths    [][]string
+	TargetPath  []string
+}
+
+// IsStructured returns true if the rule applies on the keys of messages parsed
+// as JSON or logfmt rather than on their raw content.
+func (r *ProcessingRule) IsStructured() bool {
+	switch r.Type {
+	case RemoveKeys, RenameKey, HashKeys, MaskKeys, TagFromKey, StatusFromKey, ServiceFromKey:
+		return true
+	}
+	return false
+}
+
+// StructuredFormat returns the format structured rules parse messages with.
+func (r *ProcessingRule) StructuredFormat() string {
+	if r.Format == "" {
+		return JSONFormat
+	}
+	return r.Format
+}
+
+// MultiLineMode returns how a multi_line rule aggregates lines.
+func (r *ProcessingRule) MultiLineMode() string {
+	if r.Mode == "" {
+		return MultiLineModePattern
+	}
+	return r.Mode
 }
 
 // ValidateProcessingRules validates the rules and raises an error if one is misconfigured.
@@ -42,8 +125,23 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		}
 
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine:
+		case ExcludeAtMatch, IncludeAtMatch, MaskSequences:
 			break
+		case MultiLine:
+			if err := validateMultiLineProcessingRule(rule); err != nil {
+				return err
+			}
+			continue
+		case RemoveKeys, RenameKey, HashKeys, MaskKeys, TagFromKey, StatusFromKey, ServiceFromKey:
+			if err := validateStructuredProcessingRule(rule); err != nil {
+				return err
+			}
+			continue
+		case Sample, RateLimit:
+			if err := validateThroughputProcessingRule(rule); err != nil {
+				return err
+			}
+			continue
 		case "":
 			return fmt.Errorf("type must be set for processing rule `%s`", rule.Name)
 		default:
@@ -61,9 +159,125 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 	return nil
 }
 
+// validateMultiLineProcessingRule validates a rule aggregating lines. Such a rule must have:
+// - a supported mode
+// - a valid pattern, except in indentation mode
+// - a valid end pattern in markers mode
+// - a non-negative maximum number of lines and duration
+func validateMultiLineProcessingRule(rule *ProcessingRule) error {
+	switch rule.MultiLineMode() {
+	case MultiLineModePattern, MultiLineModeMarkers:
+		if rule.Pattern == "" {
+			return fmt.Errorf("no pattern provided for processing rule: %s", rule.Name)
+		}
+	case MultiLineModeIndentation:
+	default:
+		return fmt.Errorf("mode %s is not supported for processing rule `%s`", rule.Mode, rule.Name)
+	}
+	if rule.MultiLineMode() == MultiLineModeMarkers && rule.EndPattern == "" {
+		return fmt.Errorf("no end_pattern provided for processing rule: %s", rule.Name)
+	}
+
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
+		}
+	}
+	if rule.EndPattern != "" {
+		if _, err := regexp.Compile(rule.EndPattern); err != nil {
+			return fmt.Errorf("invalid end_pattern %s for processing rule: %s", rule.EndPattern, rule.Name)
+		}
+	}
+	if rule.MaxLines < 0 {
+		return fmt.Errorf("max_lines must not be negative for processing rule: %s", rule.Name)
+	}
+	if rule.MaxDuration < 0 {
+		return fmt.Errorf("max_duration must not be negative for processing rule: %s", rule.Name)
+	}
+	return nil
+}
+
+// validateStructuredProcessingRule validates a rule applying on the keys of
+// structured messages. Such a rule must have:
+// - a supported format
+// - at least one non-empty path, or exactly one for rules reading a single key
+// - a target for rename_key and tag_from_key rules
+// - a valid pattern, if any
+func validateStructuredProcessingRule(rule *ProcessingRule) error {
+	switch rule.Format {
+	case "", JSONFormat, LogfmtFormat:
+	default:
+		return fmt.Errorf("format %s is not supported for processing rule `%s`", rule.Format, rule.Name)
+	}
+
+	if len(rule.Paths) == 0 {
+		return fmt.Errorf("no paths provided for processing rule: %s", rule.Name)
+	}
+	for _, path := range rule.Paths {
+		if path == "" {
+			return fmt.Errorf("empty path provided for processing rule: %s", rule.Name)
+		}
+	}
+
+	switch rule.Type {
+	case RenameKey, TagFromKey, StatusFromKey, ServiceFromKey:
+		if len(rule.Paths) != 1 {
+			return fmt.Errorf("exactly one path must be provided for processing rule: %s", rule.Name)
+		}
+	}
+	switch rule.Type {
+	case RenameKey, TagFromKey:
+		if rule.Target == "" {
+			return fmt.Errorf("no target provided for processing rule: %s", rule.Name)
+		}
+	}
+
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
+		}
+	}
+	return nil
+}
+
+// validateThroughputProcessingRule validates a rule capping the throughput of a
+// source. Such a rule must have:
+// - a sample rate greater than 0 and at most 1 for sample rules, a missing rate
+// being 0 would drop all the messages
+// - a positive number of messages per second and a non-negative burst for rate_limit rules
+// - a valid pattern, if any
+func validateThroughputProcessingRule(rule *ProcessingRule) error {
+	switch rule.Type {
+	case Sample:
+		if rule.SampleRate <= 0 || rule.SampleRate > 1 {
+			return fmt.Errorf("sample_rate must be greater than 0 and at most 1 for processing rule: %s", rule.Name)
+		}
+	case RateLimit:
+		if rule.MessagesPerSecond <= 0 {
+			return fmt.Errorf("messages_per_second must be positive for processing rule: %s", rule.Name)
+		}
+		if rule.Burst < 0 {
+			return fmt.Errorf("burst must not be negative for processing rule: %s", rule.Name)
+		}
+	}
+
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
+		}
+	}
+	return nil
+}
+
 // CompileProcessingRules compiles all processing rule regular expressions.
 func CompileProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
+		if rule.IsStructured() {
+			if err := compileStructuredProcessingRule(rule); err != nil {
+				return err
+			}
+			continue
+		}
 		re, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return err
@@ -75,11 +289,64 @@ func CompileProcessingRules(rules []*ProcessingRule) error {
 			rule.Regex = re
 			rule.Placeholder = []byte(rule.ReplacePlaceholder)
 		case MultiLine:
-			rule.Regex, err = regexp.Compile("^" + rule.Pattern)
+			if err := compileMultiLineProcessingRule(rule); err != nil {
+				return err
+			}
+		case Sample, RateLimit:
+			// without a pattern, the rule applies on all messages
+			if rule.Pattern != "" {
+				rule.Regex = re
+			}
+		}
+	}
+	return nil
+}
+
+// compileMultiLineProcessingRule compiles the patterns of a multi_line rule,
+// which match the beginning of the lines.
+func compileMultiLineProcessingRule(rule *ProcessingRule) error {
+	var err error
+	if rule.Pattern != "" {
+		if rule.Regex, err = regexp.Compile("^" + rule.Pattern); err != nil {
+			return err
+		}
+	}
+	if rule.EndPattern != "" {
+		if rule.EndRegex, err = regexp.Compile("^" + rule.EndPattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileStructuredProcessingRule splits the paths of a structured rule and
+// compiles its pattern, if any.
+func compileStructuredProcessingRule(rule *ProcessingRule) error {
+	rule.KeyPaths = make([][]string, 0, len(rule.Paths))
+	for _, path := range rule.Paths {
+		rule.KeyPaths = append(rule.KeyPaths, splitKeyPath(path, rule.StructuredFormat()))
+	}
+	if rule.Type == RenameKey {
+		rule.TargetPath = splitKeyPath(rule.Target, rule.StructuredFormat())
+	}
+	if rule.Type == MaskKeys {
+		rule.Placeholder = []byte(rule.ReplacePlaceholder)
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
 			if err != nil {
 				return err
 			}
+			rule.Regex = re
 		}
 	}
 	return nil
 }
+
+// splitKeyPath splits a dot-separated path into keys. As logfmt messages are
+// flat, their paths are made of a single key.
+func splitKeyPath(path string, format string) []string {
+	if format == LogfmtFormat {
+		return []string{path}
+	}
+	return strings.Split(path, ".")
+}
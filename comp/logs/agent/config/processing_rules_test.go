@@ -33,3 +33,91 @@ func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 		assert.Nil(t, rule.Regex)
 	}
 }
+
+func TestCompileStructuredRules(t *testing.T) {
+	rules := []*ProcessingRule{
+		{Name: "remove", Type: RemoveKeys, Paths: []string{"password", "user.token"}},
+		{Name: "rename", Type: RenameKey, Paths: []string{"user.name"}, Target: "usr.login"},
+		{Name: "logfmt", Type: RenameKey, Format: LogfmtFormat, Paths: []string{"a.b"}, Target: "c.d"},
+		{Name: "mask", Type: MaskKeys, Paths: []string{"card"}, Pattern: "\\d{12}", ReplacePlaceholder: "[masked]"},
+	}
+	assert.Nil(t, ValidateProcessingRules(rules))
+	assert.Nil(t, CompileProcessingRules(rules))
+
+	assert.Equal(t, [][]string{{"password"}, {"user", "token"}}, rules[0].KeyPaths)
+	assert.Nil(t, rules[0].Regex)
+	assert.Equal(t, []string{"usr", "login"}, rules[1].TargetPath)
+	assert.Equal(t, [][]string{{"a.b"}}, rules[2].KeyPaths)
+	assert.Equal(t, []string{"c.d"}, rules[2].TargetPath)
+	assert.True(t, rules[3].Regex.MatchString("432312431234"))
+	assert.Equal(t, []byte("[masked]"), rules[3].Placeholder)
+}
+
+func TestValidateShouldFailWithInvalidStructuredRules(t *testing.T) {
+	invalidRules := []*ProcessingRule{
+		{Name: "no_paths", Type: RemoveKeys},
+		{Name: "empty_path", Type: HashKeys, Paths: []string{""}},
+		{Name: "no_target", Type: RenameKey, Paths: []string{"user"}},
+		{Name: "many_paths", Type: StatusFromKey, Paths: []string{"level", "severity"}},
+		{Name: "bad_format", Type: RemoveKeys, Format: "xml", Paths: []string{"password"}},
+		{Name: "bad_pattern", Type: MaskKeys, Paths: []string{"card"}, Pattern: "(?=abf)"},
+	}
+
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+}
+
+func TestValidateThroughputRules(t *testing.T) {
+	validRules := []*ProcessingRule{
+		{Name: "sample", Type: Sample, SampleRate: 0.1},
+		{Name: "sample_all", Type: Sample, SampleRate: 1},
+		{Name: "sample_by_key", Type: Sample, SampleRate: 0.5, Pattern: "trace_id=(\\w+)"},
+		{Name: "rate_limit", Type: RateLimit, MessagesPerSecond: 100, Burst: 500},
+	}
+	assert.Nil(t, ValidateProcessingRules(validRules))
+	assert.Nil(t, CompileProcessingRules(validRules))
+	assert.Nil(t, validRules[0].Regex)
+	assert.NotNil(t, validRules[1].Regex)
+
+	invalidRules := []*ProcessingRule{
+		{Name: "sample_rate_too_high", Type: Sample, SampleRate: 1.5},
+		{Name: "negative_sample_rate", Type: Sample, SampleRate: -0.1},
+		{Name: "no_sample_rate", Type: Sample},
+		{Name: "no_rate", Type: RateLimit},
+		{Name: "negative_burst", Type: RateLimit, MessagesPerSecond: 10, Burst: -1},
+		{Name: "bad_pattern", Type: Sample, SampleRate: 0.5, Pattern: "(?=abf)"},
+	}
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+}
+
+func TestValidateMultiLineRules(t *testing.T) {
+	validRules := []*ProcessingRule{
+		{Name: "pattern", Type: MultiLine, Pattern: "\\d{4}-\\d{2}-\\d{2}", MaxLines: 500},
+		{Name: "indentation", Type: MultiLine, Mode: MultiLineModeIndentation, MaxDuration: 2.5},
+		{Name: "markers", Type: MultiLine, Mode: MultiLineModeMarkers, Pattern: "BEGIN", EndPattern: "END"},
+	}
+	assert.Nil(t, ValidateProcessingRules(validRules))
+	assert.Nil(t, CompileProcessingRules(validRules))
+	assert.Equal(t, MultiLineModePattern, validRules[0].MultiLineMode())
+	assert.True(t, validRules[0].Regex.MatchString("2024-01-01 started"))
+	assert.False(t, validRules[0].Regex.MatchString("at 2024-01-01"))
+	assert.Nil(t, validRules[1].Regex)
+	assert.Nil(t, validRules[1].EndRegex)
+	assert.True(t, validRules[2].EndRegex.MatchString("END of transaction"))
+	assert.False(t, validRules[2].EndRegex.MatchString("not the END"))
+
+	invalidRules := []*ProcessingRule{
+		{Name: "no_pattern", Type: MultiLine},
+		{Name: "bad_mode", Type: MultiLine, Mode: "json", Pattern: "{"},
+		{Name: "no_end_pattern", Type: MultiLine, Mode: MultiLineModeMarkers, Pattern: "BEGIN"},
+		{Name: "bad_end_pattern", Type: MultiLine, Mode: MultiLineModeMarkers, Pattern: "BEGIN", EndPattern: "(?=abf)"},
+		{Name: "negative_max_lines", Type: MultiLine, Mode: MultiLineModeIndentation, MaxLines: -1},
+		{Name: "negative_max_duration", Type: MultiLine, Mode: MultiLineModeIndentation, MaxDuration: -1},
+	}
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+}
@@ -277,6 +277,8 @@ func (rc rcClient) agentConfigUpdateCallback(updates map[string]state.RawConfig,
 		pkglog.Infof("Using system probe config for remote config")
 		targetCmp = localSysProbeConf
 	}
+
+	histogramErr := rc.applyHistogramOverrides(targetCmp, mergedConfig.HistogramOverrides)
 	// Checks who (the source) is responsible for the last logLevel change
 	source := targetCmp.GetSource("log_level")
 
@@ -314,6 +316,10 @@ func (rc rcClient) agentConfigUpdateCallback(updates map[string]state.RawConfig,
 		err = rc.settingsComponent.SetRuntimeSetting("log_level", mergedConfig.LogLevel, model.SourceRC)
 	}
 
+	if err == nil {
+		err = histogramErr
+	}
+
 	// Apply the new status to all configs
 	for cfgPath := range updates {
 		if err == nil {
@@ -327,6 +333,26 @@ func (rc rcClient) agentConfigUpdateCallback(updates map[string]state.RawConfig,
 	}
 }
 
+// applyHistogramOverrides sets the histogram overrides received through remote config, or removes
+// the ones previously received if they are no longer set.
+func (rc rcClient) applyHistogramOverrides(targetCmp model.ReaderWriter, overrides []interface{}) error {
+	if len(overrides) == 0 {
+		if targetCmp.GetSource("histogram_overrides") == model.SourceRC {
+			targetCmp.UnsetForSource("histogram_overrides", model.SourceRC)
+			pkglog.Infof("Removing remote-config histogram overrides")
+		}
+		return nil
+	}
+
+	pkglog.Infof("Changing the histogram overrides through remote config")
+	err := rc.settingsComponent.SetRuntimeSetting("histogram_overrides", overrides, model.SourceRC)
+	if _, ok := err.(*settings.SettingNotFoundError); ok {
+		// only the agents running an aggregator support histogram overrides
+		return nil
+	}
+	return err
+}
+
 // agentTaskUpdateCallback is the callback function called when there is an AGENT_TASK config update
 // The RCClient can directly call back listeners, because there would be no way to send back
 // RCTE2 configuration applied state to RC backend.
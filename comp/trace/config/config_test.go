@@ -59,6 +59,34 @@ func TestParseRepaceRules(t *testing.T) {
 	}
 }
 
+// TestCompileSpanRules tests the compileSpanRules helper function.
+func TestCompileSpanRules(t *testing.T) {
+	rules := []*traceconfig.SpanRule{
+		{Name: "drop-health", Match: []*traceconfig.SpanRuleCondition{{Key: "resource", Pattern: "^GET /health"}}, Action: "drop"},
+		{Name: "hash-email", Match: []*traceconfig.SpanRuleCondition{{Key: "db.row_count", Op: ">", Number: 10}}, Action: "hash", Keys: []string{"user.email"}},
+		{Name: "set-service", Action: "set", Key: "service", Value: "web"},
+	}
+	require.NoError(t, compileSpanRules(rules))
+	assert.Equal(t, "^GET /health", rules[0].Match[0].Re.String())
+	assert.Nil(t, rules[1].Match[0].Re)
+
+	for _, r := range []*traceconfig.SpanRule{
+		{Action: "drop"},
+		{Name: "a", Action: "explode"},
+		{Name: "a", Action: "drop", Match: []*traceconfig.SpanRuleCondition{{Pattern: "x"}}},
+		{Name: "a", Action: "drop", Match: []*traceconfig.SpanRuleCondition{{Key: "k", Pattern: "("}}},
+		{Name: "a", Action: "drop", Match: []*traceconfig.SpanRuleCondition{{Key: "k", Op: "=~"}}},
+		{Name: "a", Action: "delete"},
+		{Name: "a", Action: "delete", Keys: []string{"resource"}},
+		{Name: "a", Action: "set"},
+		{Name: "a", Action: "rename", From: "a"},
+		{Name: "a", Action: "rename", From: "a", To: "service"},
+	} {
+		assert.Error(t, compileSpanRules([]*traceconfig.SpanRule{r}), "%+v", r)
+	}
+	assert.Error(t, compileSpanRules([]*traceconfig.SpanRule{{Name: "a", Action: "drop"}, {Name: "a", Action: "drop"}}))
+}
+
 // TestSplitTag tests various split-tagging scenarios
 func TestSplitTag(t *testing.T) {
 	for _, tt := range []struct {
@@ -1025,6 +1053,48 @@ func TestLoadEnv(t *testing.T) {
 		assert.Contains(t, cfg.ReplaceTags, rule2)
 	})
 
+	env = "DD_APM_SPAN_RULES"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv(env, `[{"name":"drop-health","match":[{"key":"resource","value":"GET /health"}],"action":"drop"},{"name":"rename","action":"rename","from":"usr.id","to":"user.id"}]`)
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.Equal(t, []*traceconfig.SpanRule{
+			{Name: "drop-health", Match: []*traceconfig.SpanRuleCondition{{Key: "resource", Value: "GET /health"}}, Action: "drop"},
+			{Name: "rename", Action: "rename", From: "usr.id", To: "user.id"},
+		}, cfg.SpanRules)
+	})
+
+	env = "DD_APM_TRACE_SAMPLING_RULES"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv(env, `[{"service":"web-*","resource":"GET /health*","tags":{"env":"prod"},"sample_rate":0.1},{"name":"db.query","sample_rate":1,"rate_limit":10}]`)
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		require.Len(t, cfg.TraceSamplingRules, 2)
+		r := cfg.TraceSamplingRules[0]
+		assert.Equal(t, "web-*", r.Service)
+		assert.Equal(t, map[string]string{"env": "prod"}, r.Tags)
+		assert.Equal(t, 0.1, r.SampleRate)
+		assert.True(t, r.ServiceRe.MatchString("web-store"))
+		assert.True(t, r.ResourceRe.MatchString("GET /healthz"))
+		assert.True(t, r.TagsRe["env"].MatchString("prod"))
+		r = cfg.TraceSamplingRules[1]
+		assert.Equal(t, "db.query", r.Name)
+		assert.Nil(t, r.ServiceRe)
+		assert.Equal(t, 10.0, r.RateLimit)
+	})
+
 	env = "DD_APM_FILTER_TAGS_REQUIRE"
 	t.Run(env, func(t *testing.T) {
 		t.Setenv(env, `important1 important2:value1`)
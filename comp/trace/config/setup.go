@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -269,6 +270,32 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 	if core.IsSet("apm_config.rare_sampler.cardinality") {
 		c.RareSamplerCardinality = core.GetInt("apm_config.rare_sampler.cardinality")
 	}
+	if core.IsSet("apm_config.latency_sampler.enabled") {
+		c.LatencySamplerEnabled = core.GetBool("apm_config.latency_sampler.enabled")
+	}
+	if core.IsSet("apm_config.latency_sampler.tps") {
+		c.LatencySamplerTPS = core.GetFloat64("apm_config.latency_sampler.tps")
+	}
+	if k := "apm_config.latency_sampler.percentile"; core.IsSet(k) {
+		if p := core.GetFloat64(k); p > 0 && p < 100 {
+			c.LatencySamplerPercentile = p
+		} else {
+			log.Warnf("Invalid %s %v, it must be between 0 and 100. Using the default %v instead.", k, p, c.LatencySamplerPercentile)
+		}
+	}
+	if k := "apm_config.trace_sampling_rules"; core.IsSet(k) {
+		rules := make([]*config.TraceSamplingRule, 0)
+		if err := structure.UnmarshalKey(core, k, &rules); err != nil {
+			log.Errorf("Bad format for %q it should be of the form '[{\"service\": \"web-*\",\"resource\":\"GET /health*\",\"sample_rate\":0.1}]', error: %v", k, err)
+		} else {
+			for i, r := range rules {
+				if err := r.Compile(); err != nil {
+					return fmt.Errorf("trace_sampling_rules: rule %d: %s", i, err)
+				}
+			}
+			c.TraceSamplingRules = rules
+		}
+	}
 
 	if core.IsSet("apm_config.probabilistic_sampler.enabled") {
 		c.ProbabilisticSamplerEnabled = core.GetBool("apm_config.probabilistic_sampler.enabled")
@@ -334,6 +361,18 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 		}
 	}
 
+	if k := "apm_config.span_rules"; core.IsSet(k) {
+		rules := make([]*config.SpanRule, 0)
+		if err := structure.UnmarshalKey(core, k, &rules); err != nil {
+			log.Errorf("Bad format for %q it should be of the form '[{\"name\": \"rule_name\",\"match\":[{\"key\":\"resource\",\"pattern\":\"pattern\"}],\"action\":\"drop\"}]', error: %v", k, err)
+		} else {
+			if err := compileSpanRules(rules); err != nil {
+				return fmt.Errorf("span_rules: %s", err)
+			}
+			c.SpanRules = rules
+		}
+	}
+
 	if core.IsSet("bind_host") || core.IsSet("apm_config.apm_non_local_traffic") {
 		if core.IsSet("bind_host") {
 			host := core.GetString("bind_host")
@@ -561,6 +600,15 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 		// Default of 4 was chosen through experimentation, but may not be the optimal value.
 		c.MaxSenderRetries = 4
 	}
+	if core.GetBool("apm_config.payload_spill.enabled") {
+		c.PayloadSpill.Enabled = true
+		c.PayloadSpill.Dir = core.GetString("apm_config.payload_spill.dir")
+		if c.PayloadSpill.Dir == "" {
+			c.PayloadSpill.Dir = filepath.Join(core.GetString("run_path"), "apm-payload-spill")
+		}
+		c.PayloadSpill.MaxSize = core.GetInt64("apm_config.payload_spill.max_size")
+		c.PayloadSpill.MaxAge = getDuration(core.GetInt("apm_config.payload_spill.max_age"))
+	}
 	if core.IsSet("apm_config.sync_flushing") {
 		c.SynchronousFlushing = core.GetBool("apm_config.sync_flushing")
 	}
@@ -740,6 +788,66 @@ func compileReplaceRules(rules []*config.ReplaceRule) error {
 	return nil
 }
 
+// spanRuleReservedKeys holds the span rule keys targeting the span fields
+// rather than its meta or metrics.
+var spanRuleReservedKeys = map[string]bool{"service": true, "name": true, "resource": true, "type": true}
+
+func compileSpanRules(rules []*config.SpanRule) error {
+	names := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.Name == "" {
+			return errors.New(`all rules must have a "name" property`)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		for _, c := range r.Match {
+			if c.Key == "" {
+				return fmt.Errorf("rule %q: all conditions must have a \"key\"", r.Name)
+			}
+			if c.Pattern != "" {
+				re, err := regexp.Compile(c.Pattern)
+				if err != nil {
+					return fmt.Errorf("rule %q: key %q: %s", r.Name, c.Key, err)
+				}
+				c.Re = re
+			}
+			switch c.Op {
+			case "", "==", "!=", "<", "<=", ">", ">=":
+			default:
+				return fmt.Errorf("rule %q: key %q: unknown operator %q", r.Name, c.Key, c.Op)
+			}
+		}
+		switch r.Action {
+		case config.SpanRuleDrop:
+		case config.SpanRuleDelete, config.SpanRuleHash:
+			if len(r.Keys) == 0 {
+				return fmt.Errorf("rule %q: %s rules must have \"keys\"", r.Name, r.Action)
+			}
+			for _, k := range r.Keys {
+				if spanRuleReservedKeys[k] {
+					return fmt.Errorf("rule %q: can't %s the span %s", r.Name, r.Action, k)
+				}
+			}
+		case config.SpanRuleSet:
+			if r.Key == "" {
+				return fmt.Errorf("rule %q: set rules must have a \"key\"", r.Name)
+			}
+		case config.SpanRuleRename:
+			if r.From == "" || r.To == "" {
+				return fmt.Errorf("rule %q: rename rules must have \"from\" and \"to\"", r.Name)
+			}
+			if spanRuleReservedKeys[r.From] || spanRuleReservedKeys[r.To] {
+				return fmt.Errorf("rule %q: can't rename the span service, name, resource or type", r.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: unknown action %q", r.Name, r.Action)
+		}
+	}
+	return nil
+}
+
 // getDuration returns the duration of the provided value in seconds
 func getDuration(seconds int) time.Duration {
 	return time.Duration(seconds) * time.Second
@@ -161,6 +161,8 @@ var (
 		[]string{"shard", "metric_type"}, "Count the number of dogstatsd contexts in the aggregator, by metric type")
 	tlmDogstatsdContextsBytesByMtype = telemetry.NewGauge("aggregator", "dogstatsd_contexts_bytes_by_mtype",
 		[]string{"shard", "metric_type", util.BytesKindTelemetryKey}, "Estimated count of bytes taken by contexts in the aggregator, by metric type")
+	tlmDogstatsdContextsLimited = telemetry.NewCounter("aggregator", "dogstatsd_contexts_limited",
+		[]string{"reason", "fallback"}, "Count the number of new dogstatsd contexts exceeding the limits of the context limiter, by limit and fallback, counting each context once per flush")
 	tlmChecksContexts = telemetry.NewGauge("aggregator", "checks_contexts",
 		[]string{"shard"}, "Count the number of checks contexts in the check aggregator")
 	tlmChecksContextsByMtype = telemetry.NewGauge("aggregator", "checks_contexts_by_mtype",
@@ -224,6 +226,7 @@ func init() {
 	tagsetTlm = newTagsetTelemetry([]uint64{90, 100})
 
 	aggregatorExpvars.Set("MetricTags", expvar.Func(expMetricTags))
+	aggregatorExpvars.Set("DogstatsdContextLimiter", expvar.Func(expContextLimiter))
 }
 
 // BufferedAggregator aggregates metrics in buckets for dogstatsd Metrics
@@ -268,6 +271,7 @@ type BufferedAggregator struct {
 	globalTags                  func(types.TagCardinality) ([]string, error) // This function gets global tags from the tagger when host tags are not available
 	tagger                      tagger.Component
 	flushAndSerializeInParallel FlushAndSerializeInParallel
+	metricsTap                  *MetricsTap // streams the check samples to `agent stream-metrics`, nil when not set up
 }
 
 // FlushAndSerializeInParallel contains options for flushing metrics and serializing in parallel.
@@ -441,6 +445,7 @@ func (agg *BufferedAggregator) handleSenderSample(ss senderMetricSample) {
 			checkSampler.commit(timeNowNano())
 		} else {
 			ss.metricSample.Tags = sort.UniqInPlace(ss.metricSample.Tags)
+			agg.metricsTap.tapCheckSample(ss.metricSample, string(ss.id))
 			checkSampler.addSample(ss.metricSample)
 		}
 	} else {
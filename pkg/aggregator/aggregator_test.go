@@ -744,10 +744,11 @@ func flushSomeSamples(demux *AgentDemultiplexer) map[string]*metrics.Serie {
 
 			if _, found := expectedSeries[name]; !found {
 				expectedSeries[name] = &metrics.Serie{
-					Name:     name,
-					MType:    metrics.APICountType,
-					Interval: int64(10),
-					Tags:     tagset.NewCompositeTags([]string{}, []string{}),
+					Name:        name,
+					MType:       metrics.APICountType,
+					Interval:    int64(10),
+					Tags:        tagset.NewCompositeTags([]string{}, []string{}),
+					SourceMType: metrics.CountType,
 				}
 			}
 			expectedSeries[name].Points = append(expectedSeries[name].Points, metrics.Point{Ts: timestamp, Value: value})
@@ -420,3 +420,65 @@ func testCheckDistribution(t *testing.T, store *tags.Store) {
 func TestCheckDistribution(t *testing.T) {
 	testWithTagsStore(t, testCheckDistribution)
 }
+
+func testCheckRollup(t *testing.T, store *tags.Store) {
+	setRollupRules(t, []RollupRuleConfig{{Match: "my.metric.*", DropTags: []string{"pod"}}})
+
+	taggerComponent := nooptagger.NewComponent()
+	checkSampler := newCheckSampler(1, true, true, 1*time.Second, store, checkid.ID("hello:world:1234"), taggerComponent)
+
+	for i, pod := range []string{"pod:a", "pod:b"} {
+		checkSampler.addSample(&metrics.MetricSample{
+			Name:       "my.metric.count",
+			Value:      float64(i + 1),
+			Mtype:      metrics.CountType,
+			Tags:       []string{"service:web", pod},
+			SampleRate: 1,
+			Timestamp:  12345.0,
+		})
+		// the gauge of the first pod is the last one sampled
+		checkSampler.addSample(&metrics.MetricSample{
+			Name:       "my.metric.gauge",
+			Value:      float64(i + 1),
+			Mtype:      metrics.GaugeType,
+			Tags:       []string{"service:web", pod},
+			SampleRate: 1,
+			Timestamp:  12346.0 - float64(i),
+		})
+		checkSampler.addSample(&metrics.MetricSample{
+			Name:       "other.metric",
+			Value:      1,
+			Mtype:      metrics.GaugeType,
+			Tags:       []string{"service:web", pod},
+			SampleRate: 1,
+			Timestamp:  12345.0,
+		})
+	}
+
+	checkSampler.commit(12349.0)
+	var series metrics.Series
+	var sketches metrics.SketchSeriesList
+	rollupFlush(&series, &sketches, func(seriesSink metrics.SerieSink, _ metrics.SketchesSink) {
+		checkSeries, _ := checkSampler.flush()
+		for _, serie := range checkSeries {
+			seriesSink.Append(serie)
+		}
+	})
+
+	require.Len(t, series, 4)
+	for _, serie := range series[:2] {
+		assert.Equal(t, "other.metric", serie.Name)
+	}
+	rolledUp := sortedSeries(series[2:])
+	assert.Equal(t, "my.metric.count", rolledUp[0].Name)
+	assert.Equal(t, "service:web", rolledUp[0].Tags.Join(","))
+	assert.Equal(t, metrics.APICountType, rolledUp[0].MType)
+	assert.Equal(t, checksSourceTypeName, rolledUp[0].SourceTypeName)
+	assert.Equal(t, []metrics.Point{{Ts: 12349.0, Value: 3}}, rolledUp[0].Points)
+	assert.Equal(t, "my.metric.gauge", rolledUp[1].Name)
+	assert.Equal(t, []metrics.Point{{Ts: 12349.0, Value: 1}}, rolledUp[1].Points)
+}
+
+func TestCheckRollup(t *testing.T) {
+	testWithTagsStore(t, testCheckRollup)
+}
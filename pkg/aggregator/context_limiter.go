@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/limiter"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+)
+
+// contextLimiterTopOffenders is the number of limited metrics reported in the
+// status page.
+const contextLimiterTopOffenders = 10
+
+var contextLimiterOffenders = newLimitedMetricsStats()
+
+// newContextsLimiter returns the limiter of the contexts of one of the
+// pipelinesCount DogStatsD time samplers. Contexts are distributed among the
+// samplers, so the configured limits are split between them.
+func newContextsLimiter(config model.Reader, pipelinesCount int) *limiter.Limiter {
+	perPipeline := func(limit int) int {
+		if limit <= 0 {
+			return 0
+		}
+		return max(1, limit/pipelinesCount)
+	}
+
+	return limiter.New(
+		perPipeline(config.GetInt("dogstatsd_context_limiter.metric_limit")),
+		perPipeline(config.GetInt("dogstatsd_context_limiter.origin_limit")),
+		config.GetStringSlice("dogstatsd_context_limiter.strip_tags"),
+	)
+}
+
+// limitedMetricsStats holds the metrics with the most limited contexts of each
+// time sampler during their last flush.
+type limitedMetricsStats struct {
+	mu        sync.Mutex
+	bySampler map[TimeSamplerID][]limiter.Offender
+}
+
+func newLimitedMetricsStats() *limitedMetricsStats {
+	return &limitedMetricsStats{
+		bySampler: map[TimeSamplerID][]limiter.Offender{},
+	}
+}
+
+func (s *limitedMetricsStats) update(id TimeSamplerID, offenders []limiter.Offender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(offenders) == 0 {
+		delete(s.bySampler, id)
+		return
+	}
+	s.bySampler[id] = offenders
+}
+
+// top returns the metrics with the most limited contexts across all the time
+// samplers.
+func (s *limitedMetricsStats) top(n int) []limiter.Offender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byName := map[string]limiter.Offender{}
+	for _, offenders := range s.bySampler {
+		for _, o := range offenders {
+			merged := byName[o.Name]
+			merged.Name = o.Name
+			merged.Contexts += o.Contexts
+			merged.Limited += o.Limited
+			byName[o.Name] = merged
+		}
+	}
+
+	top := make([]limiter.Offender, 0, len(byName))
+	for _, o := range byName {
+		top = append(top, o)
+	}
+	limiter.SortOffenders(top)
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+func expContextLimiter() interface{} {
+	return contextLimiterOffenders.top(contextLimiterTopOffenders)
+}
@@ -11,6 +11,7 @@ import (
 
 	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/limiter"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/tags"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/tagset"
@@ -27,8 +28,22 @@ type Context struct {
 	metricTags *tags.Entry
 	noIndex    bool
 	source     metrics.MetricSource
+	originKey  ckey.TagsKey
+	// histogramConfig is the configuration of the histograms of the metric,
+	// nil if they use the default one.
+	histogramConfig *metrics.HistogramConfig
 }
 
+// Fallbacks applied to the contexts exceeding the limits of the context limiter.
+const (
+	limiterFallbackStripTags = "strip_tags"
+	limiterFallbackOverflow  = "overflow"
+
+	// overflowTag replaces the metric tags of the contexts folded into the
+	// overflow context of their metric.
+	overflowTag = "context_limiter:overflow"
+)
+
 type resolverEntry struct {
 	lastSeen int64
 	context  *Context
@@ -76,6 +91,13 @@ type contextResolver struct {
 	keyGenerator     *ckey.KeyGenerator
 	taggerBuffer     *tagset.HashingTagsAccumulator
 	metricBuffer     *tagset.HashingTagsAccumulator
+	limiter          *limiter.Limiter
+	// limitedKeys are the keys of the contexts limited since the last flush,
+	// which are counted once per flush.
+	limitedKeys map[ckey.ContextKey]struct{}
+	// histogramOverrides are the overrides the histogram configurations of
+	// the contexts were looked up in.
+	histogramOverrides *metrics.HistogramOverrides
 }
 
 // generateContextKey generates the contextKey associated with the context of the metricSample
@@ -96,6 +118,9 @@ func newContextResolver(tagger tagger.Component, cache *tags.Store, id string) *
 		keyGenerator:     ckey.NewKeyGenerator(),
 		taggerBuffer:     tagset.NewHashingTagsAccumulator(),
 		metricBuffer:     tagset.NewHashingTagsAccumulator(),
+		limitedKeys:      make(map[ckey.ContextKey]struct{}),
+
+		histogramOverrides: metrics.CurrentHistogramOverrides(),
 	}
 }
 
@@ -107,6 +132,16 @@ func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSample
 
 	contextKey, taggerKey, metricKey := cr.generateContextKey(metricSampleContext) // the generator will remove duplicates (and doesn't mind the order)
 
+	if overrides := metrics.CurrentHistogramOverrides(); overrides != cr.histogramOverrides {
+		cr.updateHistogramConfigs(overrides)
+	}
+
+	if _, ok := cr.contextsByKey[contextKey]; !ok {
+		if reason := cr.limiter.Check(metricSampleContext.GetName(), taggerKey); reason != "" {
+			contextKey, taggerKey, metricKey = cr.limitContext(metricSampleContext, contextKey, reason)
+		}
+	}
+
 	if entry, ok := cr.contextsByKey[contextKey]; !ok {
 		mtype := metricSampleContext.GetMetricType()
 		context := &Context{
@@ -117,11 +152,15 @@ func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSample
 			mtype:      mtype,
 			noIndex:    metricSampleContext.IsNoIndex(),
 			source:     metricSampleContext.GetSource(),
+			originKey:  taggerKey,
+
+			histogramConfig: cr.histogramOverrides.Lookup(metricSampleContext.GetName()),
 		}
 		cr.contextsByKey[contextKey] = resolverEntry{
 			lastSeen: timestamp,
 			context:  context,
 		}
+		cr.limiter.Add(context.Name, taggerKey)
 
 		cr.seendByMtype[mtype] = true
 		cr.countsByMtype[mtype]++
@@ -138,6 +177,74 @@ func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSample
 	return contextKey
 }
 
+// limitContext folds a new context exceeding the limits of the limiter into
+// another context of the same metric: the configured high-cardinality tags are
+// stripped from its metric tags. If it has none of them, or if the stripped
+// context is new and still exceeds the limits, its metric tags are replaced by
+// the overflow tag. It returns the keys of the resulting context.
+func (cr *contextResolver) limitContext(metricSampleContext metrics.MetricSampleContext, limitedKey ckey.ContextKey, reason string) (ckey.ContextKey, ckey.TagsKey, ckey.TagsKey) {
+	_, counted := cr.limitedKeys[limitedKey]
+	if !counted {
+		cr.limitedKeys[limitedKey] = struct{}{}
+		cr.limiter.Limited(metricSampleContext.GetName())
+	}
+
+	tags := cr.metricBuffer.Get()
+	kept := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !cr.limiter.ShouldStrip(tag) {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) < len(tags) {
+		cr.metricBuffer.Reset()
+		cr.metricBuffer.Append(kept...)
+		contextKey, taggerKey, metricKey := cr.generateContextKey(metricSampleContext)
+		if _, ok := cr.contextsByKey[contextKey]; ok || cr.limiter.Check(metricSampleContext.GetName(), taggerKey) == "" {
+			if !counted {
+				tlmDogstatsdContextsLimited.Inc(reason, limiterFallbackStripTags)
+			}
+			return contextKey, taggerKey, metricKey
+		}
+	}
+	cr.metricBuffer.Reset()
+	cr.metricBuffer.Append(overflowTag)
+
+	if !counted {
+		tlmDogstatsdContextsLimited.Inc(reason, limiterFallbackOverflow)
+	}
+	return cr.generateContextKey(metricSampleContext)
+}
+
+// resetLimited starts counting the limited contexts of the next flush.
+func (cr *contextResolver) resetLimited() {
+	if len(cr.limitedKeys) > 0 {
+		cr.limitedKeys = make(map[ckey.ContextKey]struct{})
+	}
+	cr.limiter.ResetLimited()
+}
+
+// updateHistogramConfigs looks up the histogram configurations of the tracked
+// contexts in the updated overrides.
+func (cr *contextResolver) updateHistogramConfigs(overrides *metrics.HistogramOverrides) {
+	cr.histogramOverrides = overrides
+	for _, entry := range cr.contextsByKey {
+		entry.context.histogramConfig = overrides.Lookup(entry.context.Name)
+	}
+}
+
+// histogramConfig returns the configuration of the histograms of the context,
+// nil if they use the default one or if mtype isn't a histogram type.
+func (cr *contextResolver) histogramConfig(key ckey.ContextKey, mtype metrics.MetricType) *metrics.HistogramConfig {
+	if mtype != metrics.HistogramType && mtype != metrics.HistorateType {
+		return nil
+	}
+	if context, ok := cr.get(key); ok {
+		return context.histogramConfig
+	}
+	return nil
+}
+
 func (cr *contextResolver) get(key ckey.ContextKey) (*Context, bool) {
 	ctx, found := cr.contextsByKey[key]
 	return ctx.context, found
@@ -152,6 +259,7 @@ func (cr *contextResolver) remove(expiredContextKey ckey.ContextKey) {
 	delete(cr.contextsByKey, expiredContextKey)
 
 	if context != nil {
+		cr.limiter.Remove(context.Name, context.originKey)
 		cr.countsByMtype[context.mtype]--
 		cr.bytesByMtype[context.mtype] -= uint64(context.SizeInBytes())
 		cr.dataBytesByMtype[context.mtype] -= uint64(context.DataSizeInBytes())
@@ -221,9 +329,11 @@ type timestampContextResolver struct {
 	counterExpireTime int64
 }
 
-func newTimestampContextResolver(tagger tagger.Component, cache *tags.Store, id string, contextExpireTime, counterExpireTime int64) *timestampContextResolver {
+func newTimestampContextResolver(tagger tagger.Component, cache *tags.Store, contextsLimiter *limiter.Limiter, id string, contextExpireTime, counterExpireTime int64) *timestampContextResolver {
+	resolver := newContextResolver(tagger, cache, id)
+	resolver.limiter = contextsLimiter
 	return &timestampContextResolver{
-		resolver: newContextResolver(tagger, cache, id),
+		resolver: resolver,
 
 		contextExpireTime: contextExpireTime,
 		counterExpireTime: counterExpireTime,
@@ -248,6 +358,10 @@ func (cr *timestampContextResolver) get(key ckey.ContextKey) (*Context, bool) {
 	return cr.resolver.get(key)
 }
 
+func (cr *timestampContextResolver) histogramConfig(key ckey.ContextKey, mtype metrics.MetricType) *metrics.HistogramConfig {
+	return cr.resolver.histogramConfig(key, mtype)
+}
+
 // expireContexts cleans up the contexts that haven't been tracked since the given timestamp
 func (cr *timestampContextResolver) expireContexts(timestamp int64) {
 	for ck, entry := range cr.resolver.contextsByKey {
@@ -265,6 +379,14 @@ func (cr *timestampContextResolver) sendOriginTelemetry(timestamp float64, serie
 	cr.resolver.sendOriginTelemetry(timestamp, series, hostname, tags)
 }
 
+func (cr *timestampContextResolver) topLimitedMetrics(n int) []limiter.Offender {
+	return cr.resolver.limiter.TopOffenders(n)
+}
+
+func (cr *timestampContextResolver) resetLimited() {
+	cr.resolver.resetLimited()
+}
+
 func (cr *timestampContextResolver) dumpContexts(dest io.Writer) error {
 	return cr.resolver.dumpContexts(dest)
 }
@@ -308,6 +430,10 @@ func (cr *countBasedContextResolver) get(key ckey.ContextKey) (*Context, bool) {
 	return cr.resolver.get(key)
 }
 
+func (cr *countBasedContextResolver) histogramConfig(key ckey.ContextKey, mtype metrics.MetricType) *metrics.HistogramConfig {
+	return cr.resolver.histogramConfig(key, mtype)
+}
+
 // expireContexts cleans up the contexts that haven't been tracked since `expirationCount`
 // call to `expireContexts` and returns the associated contextKeys
 func (cr *countBasedContextResolver) expireContexts() []ckey.ContextKey {
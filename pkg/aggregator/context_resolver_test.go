@@ -15,6 +15,7 @@ import (
 
 	nooptagger "github.com/DataDog/datadog-agent/comp/core/tagger/impl-noop"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/limiter"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/tags"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/tagset"
@@ -98,8 +99,8 @@ func testTrackContext(t *testing.T, store *tags.Store) {
 
 	// If the struct changes it's ok to change these, but be careful if you notice that
 	// the size increases a lot.
-	assert.Equal(t, uint64(0x90), contextResolver.bytesByMtype[metrics.GaugeType])
-	assert.Equal(t, uint64(0x48), contextResolver.bytesByMtype[metrics.CountType])
+	assert.Equal(t, uint64(0xb0), contextResolver.bytesByMtype[metrics.GaugeType])
+	assert.Equal(t, uint64(0x58), contextResolver.bytesByMtype[metrics.CountType])
 	assert.Equal(t, uint64(0), contextResolver.bytesByMtype[metrics.RateType])
 	assert.Equal(t, uint64(0x2b), contextResolver.dataBytesByMtype[metrics.GaugeType])
 	assert.Equal(t, uint64(0x26), contextResolver.dataBytesByMtype[metrics.CountType])
@@ -139,7 +140,7 @@ func testExpireContexts(t *testing.T, store *tags.Store) {
 		Tags:       []string{"foo"},
 		SampleRate: 1,
 	}
-	contextResolver := newTimestampContextResolver(nooptagger.NewComponent(), store, "test", 2, 4)
+	contextResolver := newTimestampContextResolver(nooptagger.NewComponent(), store, nil, "test", 2, 4)
 
 	// Track the 2 contexts
 	contextKey1 := contextResolver.trackContext(&mSample1, 4) // expires after 6
@@ -187,6 +188,92 @@ func TestExpireContexts(t *testing.T) {
 	testWithTagsStore(t, testExpireContexts)
 }
 
+func testContextLimiter(t *testing.T, store *tags.Store) {
+	contextResolver := newTimestampContextResolver(nooptagger.NewComponent(), store, limiter.New(2, 0, []string{"user_id"}), "test", 2, 4)
+	sample := func(name string, tags ...string) *metrics.MetricSample {
+		return &metrics.MetricSample{Name: name, Mtype: metrics.GaugeType, Tags: tags, SampleRate: 1}
+	}
+
+	key1 := contextResolver.trackContext(sample("my.metric", "env:prod"), 4)
+	key2 := contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:2"), 4)
+	assert.NotEqual(t, key1, key2)
+
+	// the high-cardinality tags are stripped from the contexts exceeding the limit
+	assert.Equal(t, key1, contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:3"), 4))
+	assert.Equal(t, key1, contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:4"), 4))
+
+	// the stripped contexts still exceeding the limit are folded into the overflow context
+	key3 := contextResolver.trackContext(sample("my.metric", "env:staging", "user_id:3"), 4)
+	context, ok := contextResolver.get(key3)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"context_limiter:overflow"}, "")
+
+	// as are the contexts without any of them
+	assert.Equal(t, key3, contextResolver.trackContext(sample("my.metric", "env:dev"), 4))
+	assert.Equal(t, 3, contextResolver.length())
+
+	// known contexts are still tracked, and other metrics are not limited
+	assert.Equal(t, key2, contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:2"), 4))
+	contextResolver.trackContext(sample("other.metric", "user_id:1"), 4)
+	assert.Equal(t, 4, contextResolver.length())
+
+	assert.Equal(t, []limiter.Offender{{Name: "my.metric", Contexts: 3, Limited: 4}}, contextResolver.topLimitedMetrics(10))
+
+	// the limited contexts are counted once per flush, not once per sample
+	assert.Equal(t, key1, contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:3"), 4))
+	assert.Equal(t, key3, contextResolver.trackContext(sample("my.metric", "env:dev"), 4))
+	assert.Equal(t, []limiter.Offender{{Name: "my.metric", Contexts: 3, Limited: 4}}, contextResolver.topLimitedMetrics(10))
+	contextResolver.resetLimited()
+	assert.Empty(t, contextResolver.topLimitedMetrics(10))
+	assert.Equal(t, key1, contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:3"), 4))
+	assert.Equal(t, []limiter.Offender{{Name: "my.metric", Contexts: 3, Limited: 1}}, contextResolver.topLimitedMetrics(10))
+
+	// expired contexts make room for new ones
+	contextResolver.expireContexts(7)
+	key5 := contextResolver.trackContext(sample("my.metric", "env:prod", "user_id:5"), 7)
+	context, ok = contextResolver.get(key5)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"env:prod", "user_id:5"}, "")
+	assert.Empty(t, contextResolver.topLimitedMetrics(10))
+}
+
+func TestContextLimiter(t *testing.T) {
+	testWithTagsStore(t, testContextLimiter)
+}
+
+func testHistogramOverrides(t *testing.T, store *tags.Store) {
+	overrides, err := metrics.NewHistogramOverrides([]metrics.HistogramOverrideConfig{
+		{Match: "*.latency", Percentiles: []float64{0.99}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { metrics.SetHistogramOverrides(nil) })
+
+	contextResolver := newContextResolver(nooptagger.NewComponent(), store, "test")
+	latencyKey := contextResolver.trackContext(&metrics.MetricSample{Name: "my.latency", Mtype: metrics.HistogramType}, 0)
+	assert.Nil(t, contextResolver.histogramConfig(latencyKey, metrics.HistogramType))
+
+	// the tracked contexts are updated with the new overrides
+	metrics.SetHistogramOverrides(overrides)
+	otherKey := contextResolver.trackContext(&metrics.MetricSample{Name: "my.other", Mtype: metrics.HistogramType}, 0)
+	assert.Same(t, overrides.Lookup("my.latency"), contextResolver.histogramConfig(latencyKey, metrics.HistogramType))
+	assert.Same(t, overrides.Lookup("my.latency"), contextResolver.histogramConfig(latencyKey, metrics.HistorateType))
+	assert.Nil(t, contextResolver.histogramConfig(latencyKey, metrics.GaugeType))
+	assert.Nil(t, contextResolver.histogramConfig(otherKey, metrics.HistogramType))
+
+	// new contexts look up the overrides
+	newKey := contextResolver.trackContext(&metrics.MetricSample{Name: "my.new.latency", Mtype: metrics.HistogramType}, 0)
+	assert.NotNil(t, contextResolver.histogramConfig(newKey, metrics.HistogramType))
+
+	metrics.SetHistogramOverrides(nil)
+	contextResolver.trackContext(&metrics.MetricSample{Name: "my.other", Mtype: metrics.HistogramType}, 0)
+	assert.Nil(t, contextResolver.histogramConfig(latencyKey, metrics.HistogramType))
+	assert.Nil(t, contextResolver.histogramConfig(newKey, metrics.HistogramType))
+}
+
+func TestHistogramOverrides(t *testing.T) {
+	testWithTagsStore(t, testHistogramOverrides)
+}
+
 func testCountBasedExpireContexts(t *testing.T, store *tags.Store) {
 	mSample1 := metrics.MetricSample{Name: "my.metric.name1"}
 	mSample2 := metrics.MetricSample{Name: "my.metric.name2"}
@@ -96,6 +96,8 @@ func createIterableMetrics(
 	logPayloads bool,
 	isServerless bool,
 	hostTagProvider *HostTagProvider,
+	openMetrics *openMetricsExposition,
+	metricsTap *MetricsTap,
 ) (*metrics.IterableSeries, *metrics.IterableSketches) {
 	var series *metrics.IterableSeries
 	var sketches *metrics.IterableSketches
@@ -110,6 +112,10 @@ func createIterableMetrics(
 				se.Tags = tagset.CombineCompositeTagsAndSlice(se.Tags, hostTagProvider.GetHostTags())
 			}
 			tagsetTlm.updateHugeSerieTelemetry(se)
+			if openMetrics != nil {
+				openMetrics.addSerie(se)
+			}
+			metricsTap.tapSerie(se)
 		}, flushAndSerializeInParallel.BufferSize, flushAndSerializeInParallel.ChannelSize)
 	}
 	if serializer.AreSketchesEnabled() {
@@ -124,6 +130,10 @@ func createIterableMetrics(
 				sketch.Tags = tagset.CombineCompositeTagsAndSlice(sketch.Tags, hostTagProvider.GetHostTags())
 			}
 			tagsetTlm.updateHugeSketchesTelemetry(sketch)
+			if openMetrics != nil {
+				openMetrics.addSketch(sketch)
+			}
+			metricsTap.tapSketch(sketch)
 		}, flushAndSerializeInParallel.BufferSize, flushAndSerializeInParallel.ChannelSize)
 	}
 	return series, sketches
@@ -19,6 +19,7 @@ import (
 	orchestratorforwarder "github.com/DataDog/datadog-agent/comp/forwarder/orchestrator"
 	haagent "github.com/DataDog/datadog-agent/comp/haagent/def"
 	compression "github.com/DataDog/datadog-agent/comp/serializer/compression/def"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/limiter"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/tags"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/sender"
 	checkid "github.com/DataDog/datadog-agent/pkg/collector/check/id"
@@ -67,6 +68,13 @@ type AgentDemultiplexer struct {
 
 	hostTagProvider *HostTagProvider
 
+	// metricsTap streams the received samples or the flushed series to
+	// `agent stream-metrics`
+	metricsTap *MetricsTap
+
+	// promRemoteWrite receives the Prometheus remote-write requests, nil when disabled
+	promRemoteWrite *promRemoteWriteReceiver
+
 	// sharded statsd time samplers
 	statsd
 }
@@ -79,7 +87,7 @@ type AgentDemultiplexerOptions struct {
 
 	DontStartForwarders bool // unit tests don't need the forwarders to be instanciated
 
-	UseDogstatsdContextLimiter bool
+	UseDogstatsdContextLimiter bool // bounds the contexts of the DogStatsD time samplers, see dogstatsd_context_limiter
 	DogstatsdMaxMetricsTags    int
 }
 
@@ -115,6 +123,8 @@ type dataOutputs struct {
 	forwarders       forwarders
 	sharedSerializer serializer.MetricSerializer
 	noAggSerializer  serializer.MetricSerializer
+	// openMetrics exposes the flushed series and sketches, nil when disabled
+	openMetrics *openMetricsExposition
 }
 
 // InitAndStartAgentDemultiplexer creates a new Demultiplexer and runs what's necessary
@@ -161,9 +171,20 @@ func initAgentDemultiplexer(log log.Component,
 
 	agg := NewBufferedAggregator(sharedSerializer, eventPlatformForwarder, haAgent, tagger, hostname, options.FlushInterval)
 
+	metricsTap := newMetricsTap(tagger)
+	agg.metricsTap = metricsTap
+
+	openMetrics, err := newOpenMetricsExposition(pkgconfigsetup.Datadog())
+	if err != nil {
+		log.Errorf("Not exposing the metrics in the OpenMetrics format: %v", err)
+	}
+
 	// statsd samplers
 	// ---------------
 
+	watchHistogramOverrides(pkgconfigsetup.Datadog())
+	watchRollupRules(pkgconfigsetup.Datadog())
+
 	bufferSize := pkgconfigsetup.Datadog().GetInt("aggregator_buffer_size")
 	metricSamplePool := metrics.NewMetricSamplePool(MetricSamplePoolBatchSize, utils.IsTelemetryEnabled(pkgconfigsetup.Datadog()))
 	_, statsdPipelinesCount := GetDogStatsDWorkerAndPipelineCount()
@@ -175,7 +196,12 @@ func initAgentDemultiplexer(log log.Component,
 		// the sampler
 		tagsStore := tags.NewStore(pkgconfigsetup.Datadog().GetBool("aggregator_use_tags_store"), fmt.Sprintf("timesampler #%d", i))
 
-		statsdSampler := NewTimeSampler(TimeSamplerID(i), bucketSize, tagsStore, tagger, agg.hostname)
+		var contextsLimiter *limiter.Limiter
+		if options.UseDogstatsdContextLimiter {
+			contextsLimiter = newContextsLimiter(pkgconfigsetup.Datadog(), statsdPipelinesCount)
+		}
+
+		statsdSampler := NewTimeSampler(TimeSamplerID(i), bucketSize, tagsStore, contextsLimiter, tagger, agg.hostname)
 
 		// its worker (process loop + flush/serialization mechanism)
 
@@ -196,6 +222,11 @@ func initAgentDemultiplexer(log log.Component,
 		)
 	}
 
+	promRemoteWrite, err := newPromRemoteWriteReceiver(pkgconfigsetup.Datadog(), noAggWorker, metricSamplePool, tagger, agg.hostname)
+	if err != nil {
+		log.Errorf("Not receiving the Prometheus remote-write requests: %v", err)
+	}
+
 	// --
 	demux := &AgentDemultiplexer{
 		log:       log,
@@ -212,9 +243,12 @@ func initAgentDemultiplexer(log log.Component,
 
 			sharedSerializer: sharedSerializer,
 			noAggSerializer:  noAggSerializer,
+			openMetrics:      openMetrics,
 		},
 
 		hostTagProvider: NewHostTagProvider(),
+		metricsTap:      metricsTap,
+		promRemoteWrite: promRemoteWrite,
 		senders:         newSenders(agg),
 
 		// statsd time samplers
@@ -287,6 +321,14 @@ func (d *AgentDemultiplexer) run() {
 		go d.noAggStreamWorker.run()
 	}
 
+	if d.promRemoteWrite != nil {
+		d.promRemoteWrite.start()
+	}
+
+	if d.dataOutputs.openMetrics != nil {
+		d.dataOutputs.openMetrics.start()
+	}
+
 	d.flushLoop() // this is the blocking call
 }
 
@@ -321,6 +363,11 @@ func (d *AgentDemultiplexer) flushLoop() {
 func (d *AgentDemultiplexer) Stop(flush bool) {
 	timeout := pkgconfigsetup.Datadog().GetDuration("aggregator_stop_timeout") * time.Second
 
+	// stop receiving the remote-write requests before their worker
+	if d.promRemoteWrite != nil {
+		d.promRemoteWrite.stop()
+	}
+
 	if d.noAggStreamWorker != nil {
 		d.noAggStreamWorker.stop(flush)
 	}
@@ -366,6 +413,10 @@ func (d *AgentDemultiplexer) Stop(flush bool) {
 		}
 	}
 
+	if d.dataOutputs.openMetrics != nil {
+		d.dataOutputs.openMetrics.stop()
+	}
+
 	// misc
 
 	d.dataOutputs.sharedSerializer = nil
@@ -407,46 +458,49 @@ func (d *AgentDemultiplexer) flushToSerializer(start time.Time, waitForSerialize
 	}
 
 	logPayloads := pkgconfigsetup.Datadog().GetBool("log_payloads")
-	series, sketches := createIterableMetrics(d.aggregator.flushAndSerializeInParallel, d.sharedSerializer, logPayloads, false, d.hostTagProvider)
+	series, sketches := createIterableMetrics(d.aggregator.flushAndSerializeInParallel, d.sharedSerializer, logPayloads, false, d.hostTagProvider, d.openMetrics, d.metricsTap)
 	metrics.Serialize(
 		series,
 		sketches,
 		func(seriesSink metrics.SerieSink, sketchesSink metrics.SketchesSink) {
-			// flush DogStatsD pipelines (statsd/time samplers)
-			// ------------------------------------------------
-
-			for _, worker := range d.statsd.workers {
-				// order the flush to the time sampler, and wait, in a different routine
-				t := flushTrigger{
-					trigger: trigger{
-						time:      start,
-						blockChan: make(chan struct{}),
-					},
-					sketchesSink: sketchesSink,
-					seriesSink:   seriesSink,
+			// the series and sketches of all the samplers are rolled up together
+			rollupFlush(seriesSink, sketchesSink, func(seriesSink metrics.SerieSink, sketchesSink metrics.SketchesSink) {
+				// flush DogStatsD pipelines (statsd/time samplers)
+				// ------------------------------------------------
+
+				for _, worker := range d.statsd.workers {
+					// order the flush to the time sampler, and wait, in a different routine
+					t := flushTrigger{
+						trigger: trigger{
+							time:      start,
+							blockChan: make(chan struct{}),
+						},
+						sketchesSink: sketchesSink,
+						seriesSink:   seriesSink,
+					}
+
+					worker.flushChan <- t
+					<-t.trigger.blockChan
 				}
 
-				worker.flushChan <- t
-				<-t.trigger.blockChan
-			}
-
-			// flush the aggregator (check samplers)
-			// -------------------------------------
-
-			if d.aggregator != nil {
-				t := flushTrigger{
-					trigger: trigger{
-						time:              start,
-						blockChan:         make(chan struct{}),
-						waitForSerializer: waitForSerializer,
-					},
-					sketchesSink: sketchesSink,
-					seriesSink:   seriesSink,
+				// flush the aggregator (check samplers)
+				// -------------------------------------
+
+				if d.aggregator != nil {
+					t := flushTrigger{
+						trigger: trigger{
+							time:              start,
+							blockChan:         make(chan struct{}),
+							waitForSerializer: waitForSerializer,
+						},
+						sketchesSink: sketchesSink,
+						seriesSink:   seriesSink,
+					}
+
+					d.aggregator.flushChan <- t
+					<-t.trigger.blockChan
 				}
-
-				d.aggregator.flushChan <- t
-				<-t.trigger.blockChan
-			}
+			})
 		}, func(serieSource metrics.SerieSource) {
 			sendIterableSeries(d.sharedSerializer, start, serieSource)
 		},
@@ -461,6 +515,10 @@ func (d *AgentDemultiplexer) flushToSerializer(start time.Time, waitForSerialize
 			}
 		})
 
+	if d.openMetrics != nil {
+		d.openMetrics.commit()
+	}
+
 	addFlushTime("MainFlushTime", int64(time.Since(start)))
 	aggregatorNumberOfFlush.Add(1)
 }
@@ -487,6 +545,7 @@ func (d *AgentDemultiplexer) SendSamplesWithoutAggregation(samples metrics.Metri
 	}
 
 	tlmProcessed.Add(float64(len(samples)), "", "late_metrics")
+	d.metricsTap.tapDogstatsdSamples(samples)
 	d.statsd.noAggStreamWorker.addSamples(samples)
 }
 
@@ -498,6 +557,7 @@ func (d *AgentDemultiplexer) AggregateSamples(shard TimeSamplerID, samples metri
 	// its buffering + the fact that it is another goroutine processing the samples,
 	// it should get back to the caller as fast as possible once the samples are
 	// in the channel.
+	d.metricsTap.tapDogstatsdSamples(samples)
 	d.statsd.workers[shard].samplesChan <- samples
 }
 
@@ -505,6 +565,7 @@ func (d *AgentDemultiplexer) AggregateSamples(shard TimeSamplerID, samples metri
 func (d *AgentDemultiplexer) AggregateSample(sample metrics.MetricSample) {
 	batch := d.GetMetricSamplePool().GetBatch()
 	batch[0] = sample
+	d.metricsTap.tapDogstatsdSamples(batch[:1])
 	d.statsd.workers[0].samplesChan <- batch[:1]
 }
 
@@ -521,6 +582,11 @@ func (d *AgentDemultiplexer) GetDogStatsDPipelinesCount() int {
 	return d.statsd.pipelinesCount
 }
 
+// MetricsTap returns the tap streaming the metrics to `agent stream-metrics`.
+func (d *AgentDemultiplexer) MetricsTap() *MetricsTap {
+	return d.metricsTap
+}
+
 // Serializer returns a serializer that anyone can use. This method exists
 // to keep compatibility with existing code while introducing the Demultiplexer,
 // however, the plan is to remove it anytime soon.
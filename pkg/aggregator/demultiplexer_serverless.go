@@ -52,8 +52,10 @@ func InitAndStartServerlessDemultiplexer(keysPerDomain map[string][]string, forw
 	serializer := serializer.NewSerializer(forwarder, nil, selector.NewCompressor(pkgconfigsetup.Datadog()), pkgconfigsetup.Datadog(), h)
 	metricSamplePool := metrics.NewMetricSamplePool(MetricSamplePoolBatchSize, utils.IsTelemetryEnabled(pkgconfigsetup.Datadog()))
 	tagsStore := tags.NewStore(pkgconfigsetup.Datadog().GetBool("aggregator_use_tags_store"), "timesampler")
+	watchHistogramOverrides(pkgconfigsetup.Datadog())
+	watchRollupRules(pkgconfigsetup.Datadog())
 
-	statsdSampler := NewTimeSampler(TimeSamplerID(0), bucketSize, tagsStore, tagger, "")
+	statsdSampler := NewTimeSampler(TimeSamplerID(0), bucketSize, tagsStore, nil, tagger, "")
 	flushAndSerializeInParallel := NewFlushAndSerializeInParallel(pkgconfigsetup.Datadog())
 	statsdWorker := newTimeSamplerWorker(statsdSampler, DefaultFlushInterval, bufferSize, metricSamplePool, flushAndSerializeInParallel, tagsStore)
 
@@ -108,24 +110,26 @@ func (d *ServerlessDemultiplexer) ForceFlushToSerializer(start time.Time, waitFo
 	defer d.flushLock.Unlock()
 
 	logPayloads := pkgconfigsetup.Datadog().GetBool("log_payloads")
-	series, sketches := createIterableMetrics(d.flushAndSerializeInParallel, d.serializer, logPayloads, true, d.hostTagProvider)
+	series, sketches := createIterableMetrics(d.flushAndSerializeInParallel, d.serializer, logPayloads, true, d.hostTagProvider, nil, nil)
 
 	metrics.Serialize(
 		series,
 		sketches,
 		func(seriesSink metrics.SerieSink, sketchesSink metrics.SketchesSink) {
-			trigger := flushTrigger{
-				trigger: trigger{
-					time:              start,
-					blockChan:         make(chan struct{}),
-					waitForSerializer: waitForSerializer,
-				},
-				sketchesSink: sketchesSink,
-				seriesSink:   seriesSink,
-			}
-
-			d.statsdWorker.flushChan <- trigger
-			<-trigger.blockChan
+			rollupFlush(seriesSink, sketchesSink, func(seriesSink metrics.SerieSink, sketchesSink metrics.SketchesSink) {
+				trigger := flushTrigger{
+					trigger: trigger{
+						time:              start,
+						blockChan:         make(chan struct{}),
+						waitForSerializer: waitForSerializer,
+					},
+					sketchesSink: sketchesSink,
+					seriesSink:   seriesSink,
+				}
+
+				d.statsdWorker.flushChan <- trigger
+				<-trigger.blockChan
+			})
 		}, func(serieSource metrics.SerieSource) {
 			sendIterableSeries(d.serializer, start, serieSource)
 		}, func(sketches metrics.SketchesSource) {
@@ -0,0 +1,41 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var watchHistogramOverridesOnce sync.Once
+
+// watchHistogramOverrides loads the histogram overrides of the configuration,
+// and reloads them each time the `histogram_overrides` setting is updated, from
+// the settings API or remote config. The context resolvers pick up the new
+// overrides when they track their next context.
+func watchHistogramOverrides(config model.Reader) {
+	loadHistogramOverrides(config)
+
+	watchHistogramOverridesOnce.Do(func() {
+		config.OnUpdate(func(setting string, _, _ any) {
+			if setting == "histogram_overrides" {
+				loadHistogramOverrides(config)
+			}
+		})
+	})
+}
+
+func loadHistogramOverrides(config model.Reader) {
+	overrides, err := metrics.GetHistogramOverrides(config)
+	if err != nil {
+		log.Errorf("Invalid histogram overrides, keeping the previous ones: %v", err)
+		return
+	}
+	metrics.SetHistogramOverrides(overrides)
+}
@@ -0,0 +1,191 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package limiter implements the limits on the number of contexts tracked by
+// the DogStatsD time samplers.
+package limiter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+)
+
+// Reasons for which a new context is limited.
+const (
+	// ReasonMetric is returned when the metric has too many contexts.
+	ReasonMetric = "metric"
+	// ReasonOrigin is returned when the origin has too many contexts.
+	ReasonOrigin = "origin"
+)
+
+type metricStats struct {
+	contexts int
+	limited  uint64
+}
+
+// Offender is a metric whose new contexts have been limited.
+type Offender struct {
+	Name     string
+	Contexts int
+	// Limited is the number of contexts limited since the last flush.
+	Limited uint64
+}
+
+// Limiter tracks the number of contexts by metric name and by origin, and
+// tells whether a new context fits in the limits. The origin of a context is
+// identified by the key of the tags added by the tagger.
+//
+// Limiter is not thread-safe. All the methods can be called on a nil Limiter,
+// which allows every context.
+type Limiter struct {
+	metricLimit int
+	originLimit int
+	stripTags   map[string]struct{}
+
+	byMetric map[string]*metricStats
+	byOrigin map[ckey.TagsKey]int
+}
+
+// New returns a Limiter allowing up to metricLimit contexts per metric name and
+// up to originLimit contexts per origin, a limit of 0 disabling the check. The
+// names of the tags to strip from the contexts exceeding the limits are given
+// by stripTags. It returns nil when both limits are disabled.
+func New(metricLimit, originLimit int, stripTags []string) *Limiter {
+	if metricLimit <= 0 && originLimit <= 0 {
+		return nil
+	}
+
+	l := &Limiter{
+		metricLimit: metricLimit,
+		originLimit: originLimit,
+		stripTags:   make(map[string]struct{}, len(stripTags)),
+		byMetric:    map[string]*metricStats{},
+		byOrigin:    map[ckey.TagsKey]int{},
+	}
+	for _, name := range stripTags {
+		l.stripTags[name] = struct{}{}
+	}
+	return l
+}
+
+// Check returns the limit a new context of the metric sent by the origin would
+// exceed, or an empty string if the context fits in the limits.
+func (l *Limiter) Check(name string, origin ckey.TagsKey) string {
+	if l == nil {
+		return ""
+	}
+	if l.metricLimit > 0 {
+		if stats := l.byMetric[name]; stats != nil && stats.contexts >= l.metricLimit {
+			return ReasonMetric
+		}
+	}
+	if l.originLimit > 0 && l.byOrigin[origin] >= l.originLimit {
+		return ReasonOrigin
+	}
+	return ""
+}
+
+// Add records a new context of the metric sent by the origin.
+func (l *Limiter) Add(name string, origin ckey.TagsKey) {
+	if l == nil {
+		return
+	}
+	stats := l.byMetric[name]
+	if stats == nil {
+		stats = &metricStats{}
+		l.byMetric[name] = stats
+	}
+	stats.contexts++
+	l.byOrigin[origin]++
+}
+
+// Remove records the expiration of a context of the metric sent by the origin.
+func (l *Limiter) Remove(name string, origin ckey.TagsKey) {
+	if l == nil {
+		return
+	}
+	if stats := l.byMetric[name]; stats != nil {
+		stats.contexts--
+		if stats.contexts <= 0 {
+			delete(l.byMetric, name)
+		}
+	}
+	if count := l.byOrigin[origin]; count > 1 {
+		l.byOrigin[origin] = count - 1
+	} else {
+		delete(l.byOrigin, origin)
+	}
+}
+
+// Limited records that a new context of the metric has been limited. The
+// context the limited one is folded into must then be recorded with Add.
+func (l *Limiter) Limited(name string) {
+	if l == nil {
+		return
+	}
+	stats := l.byMetric[name]
+	if stats == nil {
+		stats = &metricStats{}
+		l.byMetric[name] = stats
+	}
+	stats.limited++
+}
+
+// ResetLimited resets the numbers of limited contexts of the metrics, which are
+// counted by flush.
+func (l *Limiter) ResetLimited() {
+	if l == nil {
+		return
+	}
+	for name, stats := range l.byMetric {
+		stats.limited = 0
+		if stats.contexts <= 0 {
+			delete(l.byMetric, name)
+		}
+	}
+}
+
+// ShouldStrip returns true if the tag is one of the tags to strip from the
+// contexts exceeding the limits.
+func (l *Limiter) ShouldStrip(tag string) bool {
+	if l == nil || len(l.stripTags) == 0 {
+		return false
+	}
+	name, _, _ := strings.Cut(tag, ":")
+	_, ok := l.stripTags[name]
+	return ok
+}
+
+// TopOffenders returns up to n metrics with the most limited contexts, sorted
+// by decreasing number of limited contexts.
+func (l *Limiter) TopOffenders(n int) []Offender {
+	if l == nil {
+		return nil
+	}
+	var offenders []Offender
+	for name, stats := range l.byMetric {
+		if stats.limited > 0 {
+			offenders = append(offenders, Offender{Name: name, Contexts: stats.contexts, Limited: stats.limited})
+		}
+	}
+	SortOffenders(offenders)
+	if len(offenders) > n {
+		offenders = offenders[:n]
+	}
+	return offenders
+}
+
+// SortOffenders sorts offenders by decreasing number of limited contexts, then
+// by name.
+func SortOffenders(offenders []Offender) {
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Limited != offenders[j].Limited {
+			return offenders[i].Limited > offenders[j].Limited
+		}
+		return offenders[i].Name < offenders[j].Name
+	})
+}
@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package limiter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+)
+
+func TestNilLimiter(t *testing.T) {
+	l := New(0, 0, []string{"user_id"})
+	assert.Nil(t, l)
+
+	assert.Equal(t, "", l.Check("metric", ckey.TagsKey(1)))
+	l.Add("metric", ckey.TagsKey(1))
+	l.Limited("metric")
+	l.ResetLimited()
+	l.Remove("metric", ckey.TagsKey(1))
+	assert.False(t, l.ShouldStrip("user_id:1"))
+	assert.Empty(t, l.TopOffenders(10))
+}
+
+func TestLimiterMetricLimit(t *testing.T) {
+	l := New(2, 0, nil)
+
+	assert.Equal(t, "", l.Check("a", ckey.TagsKey(1)))
+	l.Add("a", ckey.TagsKey(1))
+	assert.Equal(t, "", l.Check("a", ckey.TagsKey(2)))
+	l.Add("a", ckey.TagsKey(2))
+	assert.Equal(t, ReasonMetric, l.Check("a", ckey.TagsKey(3)))
+
+	// other metrics are not affected
+	assert.Equal(t, "", l.Check("b", ckey.TagsKey(1)))
+
+	// expired contexts make room for new ones
+	l.Remove("a", ckey.TagsKey(1))
+	assert.Equal(t, "", l.Check("a", ckey.TagsKey(3)))
+}
+
+func TestLimiterOriginLimit(t *testing.T) {
+	l := New(0, 2, nil)
+
+	l.Add("a", ckey.TagsKey(1))
+	l.Add("b", ckey.TagsKey(1))
+	assert.Equal(t, ReasonOrigin, l.Check("c", ckey.TagsKey(1)))
+	assert.Equal(t, "", l.Check("c", ckey.TagsKey(2)))
+
+	l.Remove("b", ckey.TagsKey(1))
+	assert.Equal(t, "", l.Check("c", ckey.TagsKey(1)))
+
+	l.Remove("a", ckey.TagsKey(1))
+	assert.Empty(t, l.byOrigin)
+	assert.Empty(t, l.byMetric)
+}
+
+func TestLimiterShouldStrip(t *testing.T) {
+	l := New(1, 0, []string{"user_id", "request_id"})
+
+	assert.True(t, l.ShouldStrip("user_id:42"))
+	assert.True(t, l.ShouldStrip("request_id"))
+	assert.False(t, l.ShouldStrip("env:prod"))
+	assert.False(t, l.ShouldStrip("user:42"))
+}
+
+func TestLimiterTopOffenders(t *testing.T) {
+	l := New(1, 0, nil)
+
+	for _, name := range []string{"a", "b", "c"} {
+		l.Add(name, ckey.TagsKey(1))
+	}
+	l.Limited("a")
+	l.Limited("b")
+	l.Limited("b")
+	l.Limited("c")
+
+	assert.Equal(t, []Offender{
+		{Name: "b", Contexts: 1, Limited: 2},
+		{Name: "a", Contexts: 1, Limited: 1},
+	}, l.TopOffenders(2))
+
+	// the stats of a metric are dropped with its last context
+	l.Remove("b", ckey.TagsKey(1))
+	assert.Equal(t, []Offender{
+		{Name: "a", Contexts: 1, Limited: 1},
+		{Name: "c", Contexts: 1, Limited: 1},
+	}, l.TopOffenders(10))
+
+	// the limited contexts are counted by flush
+	l.ResetLimited()
+	assert.Empty(t, l.TopOffenders(10))
+	l.Limited("c")
+	assert.Equal(t, []Offender{{Name: "c", Contexts: 1, Limited: 1}}, l.TopOffenders(10))
+}
@@ -0,0 +1,264 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// metricsTapChannelSize is the number of formatted metrics buffered for the
+// streaming client, the metrics received when it is full are dropped
+const metricsTapChannelSize = 1000
+
+// MetricStreamFilters filters the metrics streamed by the `agent stream-metrics`
+// command.
+type MetricStreamFilters struct {
+	// Name is a glob pattern the name of the metrics must match
+	Name string `json:"name"`
+	// Tags are tags the metrics must all have
+	Tags []string `json:"tags"`
+	// Origin is a glob pattern the origin of the samples must match: the check
+	// ID for the check samples, the container ID or the pod UID for the DogStatsD
+	// samples.
+	Origin string `json:"origin"`
+	// Series streams the series and sketches flushed to the serializer instead
+	// of the received samples.
+	Series bool `json:"series"`
+}
+
+// MetricsTap streams the metric samples received by the demultiplexer, or the
+// series and sketches it flushes, to a single client at a time. The metrics
+// are only filtered and formatted while a client is streaming.
+type MetricsTap struct {
+	enabled   atomic.Bool
+	filters   atomic.Pointer[MetricStreamFilters]
+	m         sync.Mutex
+	inputChan chan string
+	dropped   atomic.Uint64
+	tagger    tagger.Component
+}
+
+// newMetricsTap returns a disabled MetricsTap, resolving the origin tags of the
+// DogStatsD samples with the tagger.
+func newMetricsTap(tagger tagger.Component) *MetricsTap {
+	return &MetricsTap{
+		inputChan: make(chan string, metricsTapChannelSize),
+		tagger:    tagger,
+	}
+}
+
+// SetEnabled starts or stops streaming the metrics. Returns true if the state
+// was successfully changed.
+func (t *MetricsTap) SetEnabled(e bool) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.enabled.Load() == e {
+		return false
+	}
+
+	t.enabled.Store(e)
+	if !e {
+		t.filters.Store(nil)
+		t.clear()
+	}
+	return true
+}
+
+// IsEnabled returns whether a client is streaming the metrics.
+func (t *MetricsTap) IsEnabled() bool {
+	return t != nil && t.enabled.Load()
+}
+
+func (t *MetricsTap) clear() {
+	l := len(t.inputChan)
+	for i := 0; i < l; i++ {
+		<-t.inputChan
+	}
+	t.dropped.Store(0)
+}
+
+// Filter starts tapping the metrics matching the filters, and writes them
+// formatted as strings to the returned channel until done is closed.
+func (t *MetricsTap) Filter(filters *MetricStreamFilters, done <-chan struct{}) <-chan string {
+	if filters == nil {
+		filters = &MetricStreamFilters{}
+	}
+	t.filters.Store(filters)
+
+	out := make(chan string, metricsTapChannelSize)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case line := <-t.inputChan:
+				if dropped := t.dropped.Swap(0); dropped > 0 {
+					line = fmt.Sprintf("Dropped %d metrics, the client is too slow\n", dropped) + line
+				}
+				select {
+				case out <- line:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// activeFilters returns the filters of the streaming client, or nil if no
+// client is streaming the given kind of metrics.
+func (t *MetricsTap) activeFilters(series bool) *MetricStreamFilters {
+	if !t.IsEnabled() {
+		return nil
+	}
+	filters := t.filters.Load()
+	if filters == nil || filters.Series != series {
+		return nil
+	}
+	return filters
+}
+
+// tapDogstatsdSamples taps the samples received from DogStatsD.
+func (t *MetricsTap) tapDogstatsdSamples(samples metrics.MetricSampleBatch) {
+	filters := t.activeFilters(false)
+	if filters == nil {
+		return
+	}
+	for i := range samples {
+		sample := &samples[i]
+		origin := sample.OriginInfo.ContainerID
+		if origin == "" {
+			origin = sample.OriginInfo.ContainerIDFromSocket
+		}
+		if origin == "" {
+			origin = sample.OriginInfo.PodUID
+		}
+		if !matchesMetricStreamFilters(filters, sample.Name, origin) {
+			continue
+		}
+
+		tb := tagset.NewHashlessTagsAccumulator()
+		tb.Append(sample.Tags...)
+		if t.tagger != nil {
+			t.tagger.EnrichTags(tb, sample.OriginInfo)
+		}
+		t.tapSample(filters, sample, origin, tb.Get())
+	}
+}
+
+// tapCheckSample taps a sample sent by a check.
+func (t *MetricsTap) tapCheckSample(sample *metrics.MetricSample, checkID string) {
+	filters := t.activeFilters(false)
+	if filters == nil || !matchesMetricStreamFilters(filters, sample.Name, checkID) {
+		return
+	}
+	t.tapSample(filters, sample, checkID, sample.Tags)
+}
+
+func (t *MetricsTap) tapSample(filters *MetricStreamFilters, sample *metrics.MetricSample, origin string, tags []string) {
+	if !hasMetricStreamTags(filters, tags) {
+		return
+	}
+	value := sample.RawValue
+	if value == "" {
+		value = strconv.FormatFloat(sample.Value, 'g', -1, 64)
+	}
+	t.send(fmt.Sprintf("Sample: %s | Type: %s | Value: %s | Origin: %s | Source: %s | Host: %s | Tags: %s\n",
+		sample.Name, sample.Mtype, value, origin, sample.Source, sample.Host, strings.Join(tags, ",")))
+}
+
+// tapSerie taps a serie flushed to the serializer.
+func (t *MetricsTap) tapSerie(serie *metrics.Serie) {
+	filters := t.activeFilters(true)
+	if filters == nil || !matchesMetricStreamFilters(filters, serie.Name, "") {
+		return
+	}
+	tags := serie.Tags.UnsafeToReadOnlySliceString()
+	if !hasMetricStreamTags(filters, tags) {
+		return
+	}
+	points := make([]string, 0, len(serie.Points))
+	for _, p := range serie.Points {
+		points = append(points, fmt.Sprintf("[%d, %v]", int64(p.Ts), p.Value))
+	}
+	t.send(fmt.Sprintf("Serie: %s | Type: %s | Points: %s | Interval: %d | Host: %s | Tags: %s\n",
+		serie.Name, serie.MType, strings.Join(points, " "), serie.Interval, serie.Host, strings.Join(tags, ",")))
+}
+
+// tapSketch taps a sketch flushed to the serializer.
+func (t *MetricsTap) tapSketch(sketch *metrics.SketchSeries) {
+	filters := t.activeFilters(true)
+	if filters == nil || !matchesMetricStreamFilters(filters, sketch.Name, "") {
+		return
+	}
+	tags := sketch.Tags.UnsafeToReadOnlySliceString()
+	if !hasMetricStreamTags(filters, tags) {
+		return
+	}
+	points := make([]string, 0, len(sketch.Points))
+	for _, p := range sketch.Points {
+		if p.Sketch == nil {
+			continue
+		}
+		points = append(points, fmt.Sprintf("[%d, count: %d, sum: %v, min: %v, max: %v]",
+			p.Ts, p.Sketch.Basic.Cnt, p.Sketch.Basic.Sum, p.Sketch.Basic.Min, p.Sketch.Basic.Max))
+	}
+	t.send(fmt.Sprintf("Sketch: %s | Points: %s | Interval: %d | Host: %s | Tags: %s\n",
+		sketch.Name, strings.Join(points, " "), sketch.Interval, sketch.Host, strings.Join(tags, ",")))
+}
+
+func (t *MetricsTap) send(line string) {
+	select {
+	case t.inputChan <- line:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// matchesMetricStreamFilters returns whether the name and the origin of a
+// metric match the filters. The series and sketches have no origin, the origin
+// filter only applies to the samples.
+func matchesMetricStreamFilters(filters *MetricStreamFilters, name, origin string) bool {
+	if filters.Name != "" {
+		if matched, _ := path.Match(filters.Name, name); !matched {
+			return false
+		}
+	}
+	if filters.Origin != "" && !filters.Series {
+		if matched, _ := path.Match(filters.Origin, origin); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func hasMetricStreamTags(filters *MetricStreamFilters, tags []string) bool {
+	for _, expected := range filters.Tags {
+		found := false
+		for _, tag := range tags {
+			if tag == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
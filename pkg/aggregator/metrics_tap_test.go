@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	taggertypes "github.com/DataDog/datadog-agent/pkg/tagger/types"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// containerTagger adds a container_id tag to the samples with a container origin
+type containerTagger struct {
+	tagger.Component
+}
+
+func (containerTagger) EnrichTags(tb tagset.TagsAccumulator, originInfo taggertypes.OriginInfo) {
+	if originInfo.ContainerID != "" {
+		tb.Append("container_id:" + originInfo.ContainerID)
+	}
+}
+
+func readTappedMetrics(t *testing.T, out <-chan string, count int) []string {
+	lines := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		select {
+		case line := <-out:
+			lines = append(lines, line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout waiting for the tapped metrics", "got %v", lines)
+		}
+	}
+	select {
+	case line := <-out:
+		require.FailNow(t, "unexpected tapped metric", line)
+	case <-time.After(10 * time.Millisecond):
+	}
+	return lines
+}
+
+func TestMetricsTapDisabled(t *testing.T) {
+	tap := newMetricsTap(containerTagger{})
+	tap.tapDogstatsdSamples(metrics.MetricSampleBatch{{Name: "my.metric", Value: 1}})
+	tap.tapCheckSample(&metrics.MetricSample{Name: "my.metric", Value: 1}, "cpu")
+	assert.Len(t, tap.inputChan, 0)
+
+	var nilTap *MetricsTap
+	nilTap.tapDogstatsdSamples(metrics.MetricSampleBatch{{Name: "my.metric", Value: 1}})
+	nilTap.tapSerie(&metrics.Serie{Name: "my.metric"})
+
+	assert.True(t, tap.SetEnabled(true))
+	assert.False(t, tap.SetEnabled(true))
+	assert.True(t, tap.SetEnabled(false))
+	assert.False(t, tap.SetEnabled(false))
+}
+
+func TestMetricsTapSamples(t *testing.T) {
+	tap := newMetricsTap(containerTagger{})
+	require.True(t, tap.SetEnabled(true))
+	defer tap.SetEnabled(false)
+
+	done := make(chan struct{})
+	defer close(done)
+	out := tap.Filter(&MetricStreamFilters{
+		Name:   "my.*",
+		Tags:   []string{"env:prod", "container_id:abc"},
+		Origin: "abc",
+	}, done)
+
+	tap.tapDogstatsdSamples(metrics.MetricSampleBatch{
+		{Name: "my.metric", Value: 1, Mtype: metrics.GaugeType, Tags: []string{"env:prod"}, OriginInfo: taggertypes.OriginInfo{ContainerID: "abc"}, Source: metrics.MetricSourceDogstatsd},
+		{Name: "my.metric", Value: 2, Mtype: metrics.GaugeType, Tags: []string{"env:staging"}, OriginInfo: taggertypes.OriginInfo{ContainerID: "abc"}},
+		{Name: "my.metric", Value: 3, Mtype: metrics.GaugeType, Tags: []string{"env:prod"}, OriginInfo: taggertypes.OriginInfo{ContainerID: "def"}},
+		{Name: "other.metric", Value: 4, Mtype: metrics.GaugeType, Tags: []string{"env:prod"}, OriginInfo: taggertypes.OriginInfo{ContainerID: "abc"}},
+	})
+	// the series aren't streamed with the samples
+	tap.tapSerie(&metrics.Serie{Name: "my.metric", Tags: tagset.CompositeTagsFromSlice([]string{"env:prod", "container_id:abc"})})
+
+	assert.Equal(t, []string{
+		"Sample: my.metric | Type: Gauge | Value: 1 | Origin: abc | Source: dogstatsd | Host:  | Tags: env:prod,container_id:abc\n",
+	}, readTappedMetrics(t, out, 1))
+}
+
+func TestMetricsTapCheckSamples(t *testing.T) {
+	tap := newMetricsTap(containerTagger{})
+	require.True(t, tap.SetEnabled(true))
+	defer tap.SetEnabled(false)
+
+	done := make(chan struct{})
+	defer close(done)
+	out := tap.Filter(&MetricStreamFilters{Origin: "cpu*"}, done)
+
+	tap.tapCheckSample(&metrics.MetricSample{Name: "system.cpu.user", Value: 12.5, Mtype: metrics.GaugeType, Host: "my-host", Tags: []string{"env:prod"}}, "cpu:1234")
+	tap.tapCheckSample(&metrics.MetricSample{Name: "system.mem.used", Value: 1, Mtype: metrics.GaugeType}, "memory:5678")
+
+	assert.Equal(t, []string{
+		"Sample: system.cpu.user | Type: Gauge | Value: 12.5 | Origin: cpu:1234 | Source: <unknown> | Host: my-host | Tags: env:prod\n",
+	}, readTappedMetrics(t, out, 1))
+}
+
+func TestMetricsTapSeries(t *testing.T) {
+	tap := newMetricsTap(containerTagger{})
+	require.True(t, tap.SetEnabled(true))
+	defer tap.SetEnabled(false)
+
+	done := make(chan struct{})
+	defer close(done)
+	out := tap.Filter(&MetricStreamFilters{Name: "my.*", Series: true}, done)
+
+	tap.tapDogstatsdSamples(metrics.MetricSampleBatch{{Name: "my.metric", Value: 1}})
+	tap.tapSerie(&metrics.Serie{
+		Name:     "my.metric",
+		Points:   []metrics.Point{{Ts: 10, Value: 1}, {Ts: 20, Value: 2.5}},
+		Tags:     tagset.CompositeTagsFromSlice([]string{"env:prod"}),
+		Host:     "my-host",
+		MType:    metrics.APIGaugeType,
+		Interval: 10,
+	})
+	tap.tapSerie(&metrics.Serie{Name: "other.metric", Points: []metrics.Point{{Ts: 10, Value: 1}}})
+
+	assert.Equal(t, []string{
+		"Serie: my.metric | Type: gauge | Points: [10, 1] [20, 2.5] | Interval: 10 | Host: my-host | Tags: env:prod\n",
+	}, readTappedMetrics(t, out, 1))
+}
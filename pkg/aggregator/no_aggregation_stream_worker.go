@@ -19,8 +19,8 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
-// noAggregationStreamWorker is streaming received metrics from the DogStatsD batcher
-// to the serializer.
+// noAggregationStreamWorker is streaming received metrics from the DogStatsD batcher,
+// and the sketches of the Prometheus remote-write receiver, to the serializer.
 //
 // While streaming metrics to the serializer, the serializer should be responsible of sending the payloads
 // to the forwarder once one is generated (because full), even while still receiving metrics.
@@ -46,8 +46,9 @@ type noAggregationStreamWorker struct {
 	taggerBuffer *tagset.HashlessTagsAccumulator
 	metricBuffer *tagset.HashlessTagsAccumulator
 
-	samplesChan chan metrics.MetricSampleBatch
-	stopChan    chan trigger
+	samplesChan  chan metrics.MetricSampleBatch
+	sketchesChan chan metrics.SketchSeriesList
+	stopChan     chan trigger
 
 	hostTagProvider *HostTagProvider
 	tagger          tagger.Component
@@ -96,8 +97,9 @@ func newNoAggregationStreamWorker(maxMetricsPerPayload int, _ *metrics.MetricSam
 		taggerBuffer: tagset.NewHashlessTagsAccumulator(),
 		metricBuffer: tagset.NewHashlessTagsAccumulator(),
 
-		stopChan:    make(chan trigger),
-		samplesChan: make(chan metrics.MetricSampleBatch, pkgconfigsetup.Datadog().GetInt("dogstatsd_queue_size")),
+		stopChan:     make(chan trigger),
+		samplesChan:  make(chan metrics.MetricSampleBatch, pkgconfigsetup.Datadog().GetInt("dogstatsd_queue_size")),
+		sketchesChan: make(chan metrics.SketchSeriesList, pkgconfigsetup.Datadog().GetInt("dogstatsd_queue_size")),
 
 		hostTagProvider: NewHostTagProvider(),
 		// warning for the unsupported metric types should appear maximum 200 times
@@ -116,6 +118,15 @@ func (w *noAggregationStreamWorker) addSamples(samples metrics.MetricSampleBatch
 	w.samplesChan <- samples
 }
 
+// addSketches streams sketches which have already been built, their tags
+// are sent as is.
+func (w *noAggregationStreamWorker) addSketches(sketches metrics.SketchSeriesList) {
+	if len(sketches) == 0 {
+		return
+	}
+	w.sketchesChan <- sketches
+}
+
 func (w *noAggregationStreamWorker) stop(wait bool) {
 	var blockChan chan struct{}
 	if wait {
@@ -152,7 +163,7 @@ func (w *noAggregationStreamWorker) run() {
 	ticker := time.NewTicker(noAggWorkerStreamCheckFrequency)
 	defer ticker.Stop()
 	logPayloads := pkgconfigsetup.Datadog().GetBool("log_payloads")
-	w.seriesSink, w.sketchesSink = createIterableMetrics(w.flushConfig, w.serializer, logPayloads, false, w.hostTagProvider)
+	w.seriesSink, w.sketchesSink = createIterableMetrics(w.flushConfig, w.serializer, logPayloads, false, w.hostTagProvider, nil, nil)
 
 	stopped := false
 	var stopBlockChan chan struct{}
@@ -237,6 +248,27 @@ func (w *noAggregationStreamWorker) run() {
 
 						w.metricSamplePool.PutBatch(samples) // return the sample batch back to the pool for reuse
 
+						if serializedSamples > w.maxMetricsPerPayload {
+							tlmNoAggFlush.Add(1)
+							break mainloop // end `Serialize` call and trigger a flush to the forwarder
+						}
+
+					// receiving sketches
+					case sketches := <-w.sketchesChan:
+						log.Tracef("Streaming %d sketches from the no-aggregation pipeline", len(sketches))
+
+						// the sketches payloads may be disabled
+						if w.sketchesSink != nil {
+							for _, sketch := range sketches {
+								w.sketchesSink.Append(sketch)
+							}
+							serializedSamples += len(sketches)
+							tlmNoAggSamplesProcessedOk.Add(float64(len(sketches)))
+							expvarNoAggSamplesProcessedOk.Add(int64(len(sketches)))
+						}
+
+						lastStream = time.Now()
+
 						if serializedSamples > w.maxMetricsPerPayload {
 							tlmNoAggFlush.Add(1)
 							break mainloop // end `Serialize` call and trigger a flush to the forwarder
@@ -245,15 +277,22 @@ func (w *noAggregationStreamWorker) run() {
 				}
 			}, func(serieSource metrics.SerieSource) {
 				sendIterableSeries(w.serializer, start, serieSource)
-			}, func(_ metrics.SketchesSource) {
-				// noop: we do not support sketches in the no-agg pipeline.
+			}, func(sketches metrics.SketchesSource) {
+				// Don't send empty sketches payloads
+				if sketches.WaitForValue() {
+					err := w.serializer.SendSketch(sketches)
+					sketchesCount := sketches.Count()
+					log.Debugf("Flushing %d sketches from the no-aggregation pipeline to the serializer", sketchesCount)
+					updateSketchTelemetry(start, sketchesCount, err)
+					addFlushCount("Sketches", int64(sketchesCount))
+				}
 			})
 
 		if stopped {
 			break
 		}
 
-		w.seriesSink, w.sketchesSink = createIterableMetrics(w.flushConfig, w.serializer, logPayloads, false, w.hostTagProvider)
+		w.seriesSink, w.sketchesSink = createIterableMetrics(w.flushConfig, w.serializer, logPayloads, false, w.hostTagProvider, nil, nil)
 	}
 
 	if stopBlockChan != nil {
@@ -273,6 +312,10 @@ func metricSampleAPIType(m metrics.MetricSample) (metrics.APIMetricType, bool) {
 		return metrics.APIRateType, true
 	case metrics.RateType:
 		return metrics.APIRateType, true
+	case metrics.MonotonicCountType:
+		// without any state, the monotonic counts must hold the increase since
+		// their previous value, as sent by the Prometheus remote-write receiver
+		return metrics.APICountType, true
 	default:
 		return metrics.APIGaugeType, false
 	}
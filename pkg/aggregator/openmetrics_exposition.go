@@ -0,0 +1,326 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/opentelemetry-mapping-go/pkg/quantile"
+
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// openMetricsQuantiles are the quantiles exposed for the distributions
+var openMetricsQuantiles = []float64{0.5, 0.95, 0.99}
+
+type openMetricsSample struct {
+	suffix string
+	labels string
+	value  float64
+	ts     float64
+}
+
+type openMetricsFamily struct {
+	typ     string
+	samples []openMetricsSample
+}
+
+// openMetricsExposition keeps the series and sketches of the last flush of the
+// demultiplexer and exposes them in the OpenMetrics text format, for a local
+// Prometheus server to scrape the values sent to Datadog.
+type openMetricsExposition struct {
+	addr      string
+	allowlist []string
+	denylist  []string
+
+	m sync.Mutex
+	// families of the flush in progress, indexed by metric name
+	pending map[string]*openMetricsFamily
+	// rendered families of the last flush
+	last []byte
+
+	server *http.Server
+}
+
+// newOpenMetricsExposition returns the exposition configured by the
+// `openmetrics_exposition` settings, or nil if it is disabled.
+func newOpenMetricsExposition(config model.Reader) (*openMetricsExposition, error) {
+	if !config.GetBool("openmetrics_exposition.enabled") {
+		return nil, nil
+	}
+
+	e := &openMetricsExposition{
+		addr:      net.JoinHostPort(config.GetString("openmetrics_exposition.bind_host"), strconv.Itoa(config.GetInt("openmetrics_exposition.port"))),
+		allowlist: config.GetStringSlice("openmetrics_exposition.allowlist"),
+		denylist:  config.GetStringSlice("openmetrics_exposition.denylist"),
+		pending:   make(map[string]*openMetricsFamily),
+		last:      []byte("# EOF\n"),
+	}
+	for _, pattern := range append(append([]string{}, e.allowlist...), e.denylist...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid openmetrics_exposition pattern `%s`: %v", pattern, err)
+		}
+	}
+	return e, nil
+}
+
+// start starts serving the last flushed values on the /metrics path.
+func (e *openMetricsExposition) start() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	e.server = &http.Server{
+		Addr:              e.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := e.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Error creating the OpenMetrics exposition server on %v: %v", e.addr, err)
+		}
+	}()
+	log.Infof("Exposing the flushed metrics in the OpenMetrics format on %v", e.addr)
+}
+
+// stop stops the server started by start.
+func (e *openMetricsExposition) stop() {
+	if e.server == nil {
+		return
+	}
+	if err := e.server.Shutdown(context.Background()); err != nil {
+		log.Errorf("Error shutting down the OpenMetrics exposition server: %v", err)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (e *openMetricsExposition) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.m.Lock()
+	payload := e.last
+	e.m.Unlock()
+
+	w.Header().Set("Content-Type", openMetricsContentType)
+	_, _ = w.Write(payload)
+}
+
+// isExposed returns whether the metric matches the allowlist, when there is
+// one, and none of the patterns of the denylist.
+func (e *openMetricsExposition) isExposed(name string) bool {
+	for _, pattern := range e.denylist {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	if len(e.allowlist) == 0 {
+		return true
+	}
+	for _, pattern := range e.allowlist {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// addSerie records the value of a flushed serie: the sum of its points for
+// counts, the last point for gauges and rates.
+func (e *openMetricsExposition) addSerie(serie *metrics.Serie) {
+	if len(serie.Points) == 0 || !e.isExposed(serie.Name) {
+		return
+	}
+
+	last := serie.Points[len(serie.Points)-1]
+	value := last.Value
+	if serie.MType == metrics.APICountType {
+		value = 0
+		for _, p := range serie.Points {
+			value += p.Value
+		}
+	}
+
+	labels := openMetricsLabels(serie.Host, serie.Tags.ForEach)
+	e.add(serie.Name, "gauge", []openMetricsSample{{labels: labels, value: value, ts: last.Ts}})
+}
+
+// addSketch records a flushed distribution as a summary, merging the sketches
+// of all its points.
+func (e *openMetricsExposition) addSketch(sketch *metrics.SketchSeries) {
+	if len(sketch.Points) == 0 || !e.isExposed(sketch.Name) {
+		return
+	}
+
+	config := quantile.Default()
+	merged := &quantile.Sketch{}
+	for _, p := range sketch.Points {
+		if p.Sketch != nil {
+			merged.Merge(config, p.Sketch)
+		}
+	}
+	ts := float64(sketch.Points[len(sketch.Points)-1].Ts)
+
+	labels := openMetricsLabels(sketch.Host, sketch.Tags.ForEach)
+	samples := make([]openMetricsSample, 0, len(openMetricsQuantiles)+2)
+	for _, q := range openMetricsQuantiles {
+		qLabel := `quantile="` + strconv.FormatFloat(q, 'g', -1, 64) + `"`
+		if labels != "" {
+			qLabel = labels + "," + qLabel
+		}
+		samples = append(samples, openMetricsSample{labels: qLabel, value: merged.Quantile(config, q), ts: ts})
+	}
+	samples = append(samples,
+		openMetricsSample{suffix: "_sum", labels: labels, value: merged.Basic.Sum, ts: ts},
+		openMetricsSample{suffix: "_count", labels: labels, value: float64(merged.Basic.Cnt), ts: ts},
+	)
+	e.add(sketch.Name, "summary", samples)
+}
+
+func (e *openMetricsExposition) add(name string, typ string, samples []openMetricsSample) {
+	name = sanitizeOpenMetricsName(name)
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	family, found := e.pending[name]
+	if !found {
+		family = &openMetricsFamily{typ: typ}
+		e.pending[name] = family
+	} else if family.typ != typ {
+		// two metrics of different types have the same name once sanitized,
+		// only the first one is exposed
+		return
+	}
+	family.samples = append(family.samples, samples...)
+}
+
+// commit replaces the exposed values with the ones of the flush which just
+// ended.
+func (e *openMetricsExposition) commit() {
+	e.m.Lock()
+	pending := e.pending
+	e.pending = make(map[string]*openMetricsFamily, len(pending))
+	e.m.Unlock()
+
+	payload := renderOpenMetrics(pending)
+
+	e.m.Lock()
+	e.last = payload
+	e.m.Unlock()
+}
+
+func renderOpenMetrics(families map[string]*openMetricsFamily) []byte {
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var w bytes.Buffer
+	for _, name := range names {
+		family := families[name]
+		fmt.Fprintf(&w, "# TYPE %s %s\n", name, family.typ)
+		for _, s := range family.samples {
+			w.WriteString(name)
+			w.WriteString(s.suffix)
+			if s.labels != "" {
+				w.WriteString("{")
+				w.WriteString(s.labels)
+				w.WriteString("}")
+			}
+			w.WriteString(" ")
+			w.WriteString(formatOpenMetricsValue(s.value))
+			w.WriteString(" ")
+			w.WriteString(strconv.FormatFloat(s.ts, 'f', -1, 64))
+			w.WriteString("\n")
+		}
+	}
+	w.WriteString("# EOF\n")
+	return w.Bytes()
+}
+
+func formatOpenMetricsValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// openMetricsLabels converts the host and the tags of a metric to sorted
+// labels. The values of the tags sharing a key are joined with commas, and the
+// tags without value get the "true" value.
+func openMetricsLabels(host string, forEachTag func(func(string))) string {
+	values := make(map[string][]string)
+	forEachTag(func(tag string) {
+		key, value, found := strings.Cut(tag, ":")
+		if !found {
+			value = "true"
+		}
+		key = sanitizeOpenMetricsName(key)
+		values[key] = append(values[key], value)
+	})
+	if _, found := values["host"]; !found && host != "" {
+		values["host"] = []string{host}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		tagValues := values[key]
+		sort.Strings(tagValues)
+		b.WriteString(key)
+		b.WriteString(`="`)
+		b.WriteString(escapeOpenMetricsLabelValue(strings.Join(tagValues, ",")))
+		b.WriteString(`"`)
+	}
+	return b.String()
+}
+
+// sanitizeOpenMetricsName replaces the characters not allowed in metric and
+// label names with underscores.
+func sanitizeOpenMetricsName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+var openMetricsLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeOpenMetricsLabelValue(value string) string {
+	return openMetricsLabelValueReplacer.Replace(value)
+}
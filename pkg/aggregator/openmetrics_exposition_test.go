@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/opentelemetry-mapping-go/pkg/quantile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+func newTestOpenMetricsExposition(t *testing.T, allowlist, denylist []string) *openMetricsExposition {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("openmetrics_exposition.enabled", true)
+	cfg.SetWithoutSource("openmetrics_exposition.allowlist", allowlist)
+	cfg.SetWithoutSource("openmetrics_exposition.denylist", denylist)
+	e, err := newOpenMetricsExposition(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	return e
+}
+
+func scrapeOpenMetrics(t *testing.T, e *openMetricsExposition) string {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, openMetricsContentType, rec.Header().Get("Content-Type"))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestOpenMetricsExpositionDisabled(t *testing.T) {
+	e, err := newOpenMetricsExposition(configmock.New(t))
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+}
+
+func TestOpenMetricsExpositionInvalidPattern(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("openmetrics_exposition.enabled", true)
+	cfg.SetWithoutSource("openmetrics_exposition.denylist", []string{"my.[metric"})
+	_, err := newOpenMetricsExposition(cfg)
+	assert.Error(t, err)
+}
+
+func TestOpenMetricsExpositionSeries(t *testing.T) {
+	e := newTestOpenMetricsExposition(t, nil, nil)
+	assert.Equal(t, "# EOF\n", scrapeOpenMetrics(t, e))
+
+	e.addSerie(&metrics.Serie{
+		Name:   "my.gauge",
+		Points: []metrics.Point{{Ts: 10, Value: 1}, {Ts: 20, Value: 2.5}},
+		Tags:   tagset.CompositeTagsFromSlice([]string{"env:prod", "role:db", "role:api", "canary", `path:"/a\b"`}),
+		Host:   "my-host",
+		MType:  metrics.APIGaugeType,
+	})
+	e.addSerie(&metrics.Serie{
+		Name:   "my.count",
+		Points: []metrics.Point{{Ts: 10, Value: 1}, {Ts: 20, Value: 2}},
+		Tags:   tagset.CompositeTagsFromSlice([]string{"host:tagged-host", "1st.tag:a"}),
+		Host:   "my-host",
+		MType:  metrics.APICountType,
+	})
+	// the values are only exposed once the flush ends
+	assert.Equal(t, "# EOF\n", scrapeOpenMetrics(t, e))
+
+	e.commit()
+	assert.Equal(t, `# TYPE my_count gauge
+my_count{_1st_tag="a",host="tagged-host"} 3 20
+# TYPE my_gauge gauge
+my_gauge{canary="true",env="prod",host="my-host",path="\"/a\\b\"",role="api,db"} 2.5 20
+# EOF
+`, scrapeOpenMetrics(t, e))
+
+	// only the values of the last flush are exposed
+	e.addSerie(&metrics.Serie{
+		Name:   "my.rate",
+		Points: []metrics.Point{{Ts: 30, Value: 0.5}},
+		MType:  metrics.APIRateType,
+	})
+	e.commit()
+	assert.Equal(t, "# TYPE my_rate gauge\nmy_rate 0.5 30\n# EOF\n", scrapeOpenMetrics(t, e))
+}
+
+func TestOpenMetricsExpositionSketches(t *testing.T) {
+	e := newTestOpenMetricsExposition(t, nil, nil)
+
+	config := quantile.Default()
+	sketch1, sketch2 := &quantile.Sketch{}, &quantile.Sketch{}
+	sketch1.Insert(config, 1, 2)
+	sketch2.Insert(config, 3)
+	e.addSketch(&metrics.SketchSeries{
+		Name:   "my.distribution",
+		Tags:   tagset.CompositeTagsFromSlice([]string{"env:prod"}),
+		Points: []metrics.SketchPoint{{Sketch: sketch1, Ts: 10}, {Sketch: sketch2, Ts: 20}},
+	})
+	e.commit()
+
+	merged := &quantile.Sketch{}
+	merged.Merge(config, sketch1)
+	merged.Merge(config, sketch2)
+	assert.Equal(t, `# TYPE my_distribution summary
+my_distribution{env="prod",quantile="0.5"} `+formatOpenMetricsValue(merged.Quantile(config, 0.5))+` 20
+my_distribution{env="prod",quantile="0.95"} `+formatOpenMetricsValue(merged.Quantile(config, 0.95))+` 20
+my_distribution{env="prod",quantile="0.99"} `+formatOpenMetricsValue(merged.Quantile(config, 0.99))+` 20
+my_distribution_sum{env="prod"} 6 20
+my_distribution_count{env="prod"} 3 20
+# EOF
+`, scrapeOpenMetrics(t, e))
+}
+
+func TestOpenMetricsExpositionFilters(t *testing.T) {
+	e := newTestOpenMetricsExposition(t, []string{"my.*"}, []string{"my.*.internal"})
+
+	for _, name := range []string{"my.metric", "my.metric.internal", "other.metric"} {
+		e.addSerie(&metrics.Serie{
+			Name:   name,
+			Points: []metrics.Point{{Ts: 10, Value: 1}},
+			MType:  metrics.APIGaugeType,
+		})
+	}
+	e.commit()
+	assert.Equal(t, "# TYPE my_metric gauge\nmy_metric 1 10\n# EOF\n", scrapeOpenMetrics(t, e))
+}
+
+func TestSanitizeOpenMetricsName(t *testing.T) {
+	assert.Equal(t, "my_metric_name", sanitizeOpenMetricsName("my.metric-name"))
+	assert.Equal(t, "_2xx_count", sanitizeOpenMetricsName("2xx.count"))
+	assert.Equal(t, "_", sanitizeOpenMetricsName(""))
+}
@@ -0,0 +1,563 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/opentelemetry-mapping-go/pkg/quantile"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	taggertypes "github.com/DataDog/datadog-agent/pkg/tagger/types"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// promRemoteWritePath is the path Prometheus servers are configured to write to
+	promRemoteWritePath = "/api/v1/write"
+	// promRemoteWriteMaxSize bounds the size of the requests, compressed or not
+	promRemoteWriteMaxSize = 32 * 1024 * 1024
+	// promSeriesTTL is the time after which the last values of a counter or
+	// the counts of a histogram that isn't written anymore are forgotten
+	promSeriesTTL = 10 * time.Minute
+)
+
+// promBucket is a bucket of a classic or native Prometheus histogram.
+type promBucket struct {
+	lower float64
+	upper float64
+	count float64
+}
+
+type promBucketBounds struct {
+	lower float64
+	upper float64
+}
+
+// promHistogramCounts are the cumulative counts of the buckets of the last
+// value written for a histogram.
+type promHistogramCounts struct {
+	schema   int32
+	counts   map[promBucketBounds]float64
+	lastSeen time.Time
+}
+
+// promCounterValue is the last value written for a counter.
+type promCounterValue struct {
+	value    float64
+	lastSeen time.Time
+}
+
+// promClassicHistogram gathers the `_bucket` series of a classic histogram,
+// whose `le` labels are the upper bounds of the buckets.
+type promClassicHistogram struct {
+	name string
+	tags []string
+	// cumulative buckets by timestamp, in milliseconds
+	points map[int64][]promBucket
+}
+
+// promRemoteWriteReceiver receives the samples of the Prometheus servers
+// configured to remote-write to the agent, and streams them to the
+// no-aggregation pipeline: the counters as monotonic counts, the other float
+// samples as gauges, the classic and the native histograms as sketches.
+type promRemoteWriteReceiver struct {
+	addr        string
+	podUIDLabel string
+	hostname    string
+	tagger      tagger.Component
+
+	// the batches of samples are taken from the pool the no-aggregation
+	// pipeline returns them to
+	samplePool   *metrics.MetricSamplePool
+	sendSamples  func(metrics.MetricSampleBatch)
+	sendSketches func(metrics.SketchSeriesList)
+
+	m sync.Mutex
+	// the counters and the histograms are cumulative, their last values are
+	// kept to send the increase since the previous write
+	counters   map[string]*promCounterValue
+	histograms map[string]*promHistogramCounts
+	// counterFamilies holds the names of the metric families reported as
+	// counters by the metadata of the write requests
+	counterFamilies map[string]struct{}
+	lastExpiry      time.Time
+
+	server *http.Server
+}
+
+// newPromRemoteWriteReceiver returns the receiver configured by the
+// `prometheus_remote_write` settings, or nil if it is disabled.
+func newPromRemoteWriteReceiver(config model.Reader, noAggWorker *noAggregationStreamWorker, samplePool *metrics.MetricSamplePool, tagger tagger.Component, hostname string) (*promRemoteWriteReceiver, error) {
+	if !config.GetBool("prometheus_remote_write.enabled") {
+		return nil, nil
+	}
+	if noAggWorker == nil {
+		return nil, errors.New("the Prometheus remote-write receiver requires the no-aggregation pipeline, see `dogstatsd_no_aggregation_pipeline`")
+	}
+
+	return &promRemoteWriteReceiver{
+		addr:            net.JoinHostPort(config.GetString("prometheus_remote_write.bind_host"), strconv.Itoa(config.GetInt("prometheus_remote_write.port"))),
+		podUIDLabel:     config.GetString("prometheus_remote_write.pod_uid_label"),
+		hostname:        hostname,
+		tagger:          tagger,
+		samplePool:      samplePool,
+		sendSamples:     noAggWorker.addSamples,
+		sendSketches:    noAggWorker.addSketches,
+		counters:        make(map[string]*promCounterValue),
+		histograms:      make(map[string]*promHistogramCounts),
+		counterFamilies: make(map[string]struct{}),
+		lastExpiry:      time.Now(),
+	}, nil
+}
+
+// start starts receiving the remote-write requests.
+func (r *promRemoteWriteReceiver) start() {
+	mux := http.NewServeMux()
+	mux.Handle(promRemoteWritePath, r)
+	r.server = &http.Server{
+		Addr:              r.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := r.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Error creating the Prometheus remote-write receiver on %v: %v", r.addr, err)
+		}
+	}()
+	log.Infof("Receiving the Prometheus remote-write requests on %v%s", r.addr, promRemoteWritePath)
+}
+
+// stop stops the server started by start.
+func (r *promRemoteWriteReceiver) stop() {
+	if r.server == nil {
+		return
+	}
+	if err := r.server.Shutdown(context.Background()); err != nil {
+		log.Errorf("Error shutting down the Prometheus remote-write receiver: %v", err)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (r *promRemoteWriteReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	// the 2.0 protocol sends a different message, Prometheus falls back to
+	// the 1.0 one when it is refused
+	if strings.Contains(req.Header.Get("Content-Type"), "io.prometheus.write.v2") {
+		http.Error(w, "only the remote-write 1.0 protocol is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, req.Body, promRemoteWriteMaxSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n, err := snappy.DecodedLen(compressed); err != nil || n > promRemoteWriteMaxSize {
+		http.Error(w, "invalid snappy payload", http.StatusBadRequest)
+		return
+	}
+	payload, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var writeRequest prompb.WriteRequest
+	if err := writeRequest.Unmarshal(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	samples, sketches := r.convert(&writeRequest, time.Now())
+	log.Tracef("Received %d samples and %d sketches from a Prometheus remote-write request", len(samples), len(sketches))
+	r.sendSampleBatches(samples)
+	if len(sketches) > 0 {
+		r.sendSketches(sketches)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendSampleBatches sends the samples in batches of the pool, since the
+// no-aggregation pipeline returns them to it once processed.
+func (r *promRemoteWriteReceiver) sendSampleBatches(samples metrics.MetricSampleBatch) {
+	for len(samples) > 0 {
+		batch := r.samplePool.GetBatch()
+		if batch == nil {
+			batch = make(metrics.MetricSampleBatch, MetricSamplePoolBatchSize)
+		}
+		n := copy(batch, samples)
+		r.sendSamples(batch[:n])
+		samples = samples[n:]
+	}
+}
+
+// convert turns the float samples of a write request into monotonic counts for
+// the counters and gauges for the other series, and its histograms into
+// sketches, keeping their timestamps.
+func (r *promRemoteWriteReceiver) convert(writeRequest *prompb.WriteRequest, now time.Time) (metrics.MetricSampleBatch, metrics.SketchSeriesList) {
+	var samples metrics.MetricSampleBatch
+	var sketches metrics.SketchSeriesList
+	classics := make(map[string]*promClassicHistogram)
+
+	// Prometheus sends the metadata of the series periodically, in requests
+	// of their own
+	if len(writeRequest.Metadata) > 0 {
+		r.m.Lock()
+		for _, metadata := range writeRequest.Metadata {
+			if metadata.Type == prompb.MetricMetadata_COUNTER {
+				r.counterFamilies[metadata.MetricFamilyName] = struct{}{}
+			}
+		}
+		r.m.Unlock()
+	}
+
+	for _, ts := range writeRequest.Timeseries {
+		name, le, tags, key := r.seriesTags(ts.Labels)
+		if name == "" {
+			continue
+		}
+
+		if le != "" && strings.HasSuffix(name, "_bucket") {
+			upper, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				log.Debugf("Discarding the bucket of %s with an invalid `le` label %q", name, le)
+				continue
+			}
+			classic := classics[key]
+			if classic == nil {
+				classic = &promClassicHistogram{
+					name:   strings.TrimSuffix(name, "_bucket"),
+					tags:   tags,
+					points: make(map[int64][]promBucket),
+				}
+				classics[key] = classic
+			}
+			for _, sample := range ts.Samples {
+				classic.points[sample.Timestamp] = append(classic.points[sample.Timestamp], promBucket{upper: upper, count: sample.Value})
+			}
+			continue
+		}
+
+		counter := r.isCounter(name)
+		for _, sample := range ts.Samples {
+			// NaN values include the markers of the stale series
+			if math.IsNaN(sample.Value) {
+				continue
+			}
+			mtype, value := metrics.GaugeType, sample.Value
+			if counter {
+				var ok bool
+				if value, ok = r.counterDelta(key, sample.Value, now); !ok {
+					continue
+				}
+				mtype = metrics.MonotonicCountType
+			}
+			samples = append(samples, metrics.MetricSample{
+				Name:       name,
+				Value:      value,
+				Mtype:      mtype,
+				Tags:       tags,
+				Host:       r.hostname,
+				SampleRate: 1,
+				Timestamp:  float64(sample.Timestamp) / 1000,
+			})
+		}
+
+		for _, histogram := range ts.Histograms {
+			buckets, ok := nativeHistogramBuckets(histogram)
+			if !ok {
+				log.Debugf("Discarding the native histogram %s with the unsupported schema %d", name, histogram.Schema)
+				continue
+			}
+			gauge := histogram.ResetHint == prompb.Histogram_GAUGE
+			reset := histogram.ResetHint == prompb.Histogram_YES
+			if buckets = r.bucketsDelta("native"+key, histogram.Schema, buckets, gauge, reset, now); buckets != nil {
+				if sketch := r.newSketch(name, tags, histogram.Timestamp, buckets); sketch != nil {
+					sketches = append(sketches, sketch)
+				}
+			}
+		}
+	}
+
+	for key, classic := range classics {
+		timestamps := make([]int64, 0, len(classic.points))
+		for ts := range classic.points {
+			timestamps = append(timestamps, ts)
+		}
+		sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+		for _, ts := range timestamps {
+			buckets := classicHistogramBuckets(classic.points[ts])
+			if buckets = r.bucketsDelta("classic"+key, 0, buckets, false, false, now); buckets != nil {
+				if sketch := r.newSketch(classic.name, classic.tags, ts, buckets); sketch != nil {
+					sketches = append(sketches, sketch)
+				}
+			}
+		}
+	}
+
+	r.expireSeries(now)
+	return samples, sketches
+}
+
+// isCounter returns true if the series of the name is a counter, as reported
+// by the metadata of its family or by the `_total` suffix of its name.
+func (r *promRemoteWriteReceiver) isCounter(name string) bool {
+	if strings.HasSuffix(name, "_total") {
+		return true
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	_, ok := r.counterFamilies[name]
+	return ok
+}
+
+// counterDelta returns the increase of a counter since its previous write, or
+// false if it is the first write. The no-aggregation pipeline doesn't keep any
+// state, the monotonic counts it receives hold these increases.
+func (r *promRemoteWriteReceiver) counterDelta(key string, value float64, now time.Time) (float64, bool) {
+	r.m.Lock()
+	last := r.counters[key]
+	r.counters[key] = &promCounterValue{value: value, lastSeen: now}
+	r.m.Unlock()
+
+	// don't send the first cumulative value, it would appear as a spike
+	if last == nil {
+		return 0, false
+	}
+	if value < last.value {
+		// the counter has been reset since the previous write
+		return value, true
+	}
+	return value - last.value, true
+}
+
+// seriesTags returns the name of a series, the value of its `le` label and its
+// other labels as tags, enriched with the tags of its pod if a label holds its
+// UID. The last value identifies the series without its `le` label.
+func (r *promRemoteWriteReceiver) seriesTags(labels []prompb.Label) (string, string, []string, string) {
+	var name, le string
+	var origin taggertypes.OriginInfo
+	var key strings.Builder
+	tb := tagset.NewHashlessTagsAccumulatorFromSlice(make([]string, 0, len(labels)))
+
+	for _, label := range labels {
+		switch label.Name {
+		case "__name__":
+			name = label.Value
+		case "le":
+			le = label.Value
+			continue
+		case r.podUIDLabel:
+			origin.PodUID = label.Value
+		default:
+			tb.Append(label.Name + ":" + label.Value)
+		}
+		key.WriteString(label.Name)
+		key.WriteByte('=')
+		key.WriteString(label.Value)
+		key.WriteByte(0)
+	}
+
+	if origin.PodUID != "" {
+		r.tagger.EnrichTags(tb, origin)
+	}
+	return name, le, tb.Get(), key.String()
+}
+
+// bucketsDelta returns the number of values added to each bucket since the
+// previous write of the histogram, or nil if it is the first write. The counts
+// of the buckets of the gauge histograms are returned as is.
+func (r *promRemoteWriteReceiver) bucketsDelta(key string, schema int32, buckets []promBucket, gauge bool, reset bool, now time.Time) []promBucket {
+	if gauge {
+		return buckets
+	}
+
+	counts := make(map[promBucketBounds]float64, len(buckets))
+	for _, b := range buckets {
+		counts[promBucketBounds{b.lower, b.upper}] = b.count
+	}
+
+	r.m.Lock()
+	last := r.histograms[key]
+	r.histograms[key] = &promHistogramCounts{
+		schema:   schema,
+		counts:   counts,
+		lastSeen: now,
+	}
+	r.m.Unlock()
+
+	// don't send the first cumulative counts, they would appear as a spike
+	if last == nil || last.schema != schema {
+		return nil
+	}
+
+	deltas := make([]promBucket, 0, len(buckets))
+	for _, b := range buckets {
+		delta := b.count - last.counts[promBucketBounds{b.lower, b.upper}]
+		if delta < 0 {
+			// the counter has been reset since the previous write
+			reset = true
+			break
+		}
+		deltas = append(deltas, promBucket{lower: b.lower, upper: b.upper, count: delta})
+	}
+	if reset {
+		return buckets
+	}
+	return deltas
+}
+
+// expireSeries forgets the last values of the counters and the counts of the
+// histograms which haven't been written for promSeriesTTL.
+func (r *promRemoteWriteReceiver) expireSeries(now time.Time) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if now.Sub(r.lastExpiry) < promSeriesTTL {
+		return
+	}
+	for key, last := range r.counters {
+		if now.Sub(last.lastSeen) > promSeriesTTL {
+			delete(r.counters, key)
+		}
+	}
+	for key, counts := range r.histograms {
+		if now.Sub(counts.lastSeen) > promSeriesTTL {
+			delete(r.histograms, key)
+		}
+	}
+	r.lastExpiry = now
+}
+
+// newSketch returns a sketch holding the values of the buckets, interpolated
+// over their ranges, or nil if they are empty.
+func (r *promRemoteWriteReceiver) newSketch(name string, tags []string, tsMillis int64, buckets []promBucket) *metrics.SketchSeries {
+	var agent quantile.Agent
+	for _, b := range buckets {
+		count := uint(math.Round(b.count))
+		if count == 0 {
+			continue
+		}
+		lower, upper := b.lower, b.upper
+		// "if the quantile falls into the highest bucket, the upper bound of the 2nd highest bucket is returned"
+		if math.IsInf(upper, 1) {
+			upper = lower
+		}
+		if math.IsInf(lower, -1) {
+			lower = upper
+		}
+		agent.InsertInterpolate(lower, upper, count)
+	}
+
+	sketch := agent.Finish()
+	if sketch == nil {
+		return nil
+	}
+	return &metrics.SketchSeries{
+		Name:     name,
+		Tags:     tagset.CompositeTagsFromSlice(tags),
+		Host:     r.hostname,
+		Interval: bucketSize,
+		Points:   []metrics.SketchPoint{{Sketch: sketch, Ts: tsMillis / 1000}},
+	}
+}
+
+// classicHistogramBuckets turns the cumulative buckets of a classic histogram,
+// whose lower bounds are unset, into the buckets between consecutive upper
+// bounds.
+func classicHistogramBuckets(cumulative []promBucket) []promBucket {
+	sort.Slice(cumulative, func(i, j int) bool { return cumulative[i].upper < cumulative[j].upper })
+
+	buckets := make([]promBucket, 0, len(cumulative))
+	lower, previous := math.Inf(-1), 0.0
+	for _, b := range cumulative {
+		if math.IsNaN(b.count) {
+			continue
+		}
+		// the values of the first bucket are assumed to be positive
+		if math.IsInf(lower, -1) && b.upper > 0 {
+			lower = 0
+		}
+		buckets = append(buckets, promBucket{lower: lower, upper: b.upper, count: math.Max(b.count-previous, 0)})
+		lower, previous = b.upper, b.count
+	}
+	return buckets
+}
+
+// nativeHistogramBuckets returns the zero bucket and the populated buckets of a
+// native histogram with an exponential schema.
+func nativeHistogramBuckets(h prompb.Histogram) ([]promBucket, bool) {
+	if h.Schema < -4 || h.Schema > 8 {
+		return nil, false
+	}
+	base := math.Pow(2, math.Pow(2, -float64(h.Schema)))
+
+	zeroCount := float64(h.GetZeroCountInt())
+	if h.IsFloatHistogram() {
+		zeroCount = h.GetZeroCountFloat()
+	}
+	buckets := []promBucket{{lower: -h.ZeroThreshold, upper: h.ZeroThreshold, count: zeroCount}}
+
+	buckets = appendNativeBuckets(buckets, base, h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts, false)
+	buckets = appendNativeBuckets(buckets, base, h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts, true)
+	return buckets, true
+}
+
+// appendNativeBuckets appends the buckets described by the spans of a native
+// histogram: the bucket of index i holds the values in (base^(i-1), base^i].
+// The counts are either absolute or, for the integer histograms, deltas from
+// the previous bucket.
+func appendNativeBuckets(buckets []promBucket, base float64, spans []prompb.BucketSpan, deltas []int64, counts []float64, negative bool) []promBucket {
+	var idx int32
+	var pos int
+	var count int64
+	for _, span := range spans {
+		// the offset of the first span is the index of its first bucket, the
+		// other ones are relative to the end of the previous span
+		idx += span.Offset
+		for j := uint32(0); j < span.Length; j++ {
+			b := promBucket{
+				lower: math.Pow(base, float64(idx-1)),
+				upper: math.Pow(base, float64(idx)),
+			}
+			switch {
+			case pos < len(counts):
+				b.count = counts[pos]
+			case pos < len(deltas):
+				count += deltas[pos]
+				b.count = float64(count)
+			default:
+				return buckets
+			}
+			if negative {
+				b.lower, b.upper = -b.upper, -b.lower
+			}
+			buckets = append(buckets, b)
+			idx++
+			pos++
+		}
+	}
+	return buckets
+}
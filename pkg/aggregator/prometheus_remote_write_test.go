@@ -0,0 +1,328 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	taggertypes "github.com/DataDog/datadog-agent/pkg/tagger/types"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// podTagger adds a pod_name tag to the series with a pod origin
+type podTagger struct {
+	tagger.Component
+}
+
+func (podTagger) EnrichTags(tb tagset.TagsAccumulator, originInfo taggertypes.OriginInfo) {
+	if originInfo.PodUID != "" {
+		tb.Append("pod_name:pod-" + originInfo.PodUID)
+	}
+}
+
+func newTestPromRemoteWriteReceiver() (*promRemoteWriteReceiver, *[]metrics.MetricSampleBatch, *metrics.SketchSeriesList) {
+	var batches []metrics.MetricSampleBatch
+	var sketches metrics.SketchSeriesList
+	r := &promRemoteWriteReceiver{
+		podUIDLabel: "pod_uid",
+		hostname:    "my-host",
+		tagger:      podTagger{},
+		samplePool:  metrics.NewMetricSamplePool(MetricSamplePoolBatchSize, false),
+		sendSamples: func(batch metrics.MetricSampleBatch) {
+			batches = append(batches, batch)
+		},
+		sendSketches: func(list metrics.SketchSeriesList) {
+			sketches = append(sketches, list...)
+		},
+		counters:        make(map[string]*promCounterValue),
+		histograms:      make(map[string]*promHistogramCounts),
+		counterFamilies: make(map[string]struct{}),
+		lastExpiry:      time.Now(),
+	}
+	return r, &batches, &sketches
+}
+
+func promLabels(pairs ...string) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		labels = append(labels, prompb.Label{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return labels
+}
+
+func classicHistogramSeries(ts int64, counts map[string]float64) []prompb.TimeSeries {
+	var series []prompb.TimeSeries
+	for le, count := range counts {
+		series = append(series, prompb.TimeSeries{
+			Labels:  promLabels("__name__", "latency_seconds_bucket", "job", "api", "le", le),
+			Samples: []prompb.Sample{{Value: count, Timestamp: ts}},
+		})
+	}
+	return series
+}
+
+func TestPromRemoteWriteReceiverDisabled(t *testing.T) {
+	r, err := newPromRemoteWriteReceiver(configmock.New(t), nil, nil, nil, "")
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("prometheus_remote_write.enabled", true)
+	_, err = newPromRemoteWriteReceiver(cfg, nil, nil, nil, "")
+	assert.Error(t, err)
+}
+
+func TestPromRemoteWriteSamples(t *testing.T) {
+	r, _, _ := newTestPromRemoteWriteReceiver()
+
+	samples, sketches := r.convert(&prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  promLabels("__name__", "http_requests_total", "job", "api", "pod_uid", "1234"),
+				Samples: []prompb.Sample{{Value: 3, Timestamp: 1700000000000}, {Value: math.NaN(), Timestamp: 1700000000250}, {Value: 5, Timestamp: 1700000000500}},
+			},
+			{
+				Labels:  promLabels("__name__", "queue_size"),
+				Samples: []prompb.Sample{{Value: 4, Timestamp: 1700000002000}},
+			},
+			{
+				Labels:  promLabels("job", "api"),
+				Samples: []prompb.Sample{{Value: 5, Timestamp: 1700000002000}},
+			},
+		},
+	}, time.Now())
+
+	assert.Empty(t, sketches)
+	assert.Equal(t, metrics.MetricSampleBatch{
+		{
+			Name:       "http_requests_total",
+			Value:      2,
+			Mtype:      metrics.MonotonicCountType,
+			Tags:       []string{"job:api", "pod_name:pod-1234"},
+			Host:       "my-host",
+			SampleRate: 1,
+			Timestamp:  1700000000.5,
+		},
+		{
+			Name:       "queue_size",
+			Value:      4,
+			Mtype:      metrics.GaugeType,
+			Tags:       []string{},
+			Host:       "my-host",
+			SampleRate: 1,
+			Timestamp:  1700000002,
+		},
+	}, samples)
+}
+
+func TestPromRemoteWriteCounters(t *testing.T) {
+	r, _, _ := newTestPromRemoteWriteReceiver()
+	series := func(name string, value float64) *prompb.WriteRequest {
+		return &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+			Labels:  promLabels("__name__", name, "job", "api"),
+			Samples: []prompb.Sample{{Value: value, Timestamp: 1700000000000}},
+		}}}
+	}
+
+	// the first value of a counter is only kept
+	samples, _ := r.convert(series("requests_total", 10), time.Now())
+	assert.Empty(t, samples)
+	samples, _ = r.convert(series("requests_total", 15), time.Now())
+	require.Len(t, samples, 1)
+	assert.Equal(t, metrics.MonotonicCountType, samples[0].Mtype)
+	assert.Equal(t, 5.0, samples[0].Value)
+
+	// after a reset, the new value is the increase
+	samples, _ = r.convert(series("requests_total", 4), time.Now())
+	require.Len(t, samples, 1)
+	assert.Equal(t, 4.0, samples[0].Value)
+
+	// the families reported as counters by the metadata are counters
+	samples, _ = r.convert(series("requests", 10), time.Now())
+	require.Len(t, samples, 1)
+	assert.Equal(t, metrics.GaugeType, samples[0].Mtype)
+	r.convert(&prompb.WriteRequest{Metadata: []prompb.MetricMetadata{
+		{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "requests"},
+		{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "queue_size"},
+	}}, time.Now())
+	r.convert(series("requests", 10), time.Now())
+	samples, _ = r.convert(series("requests", 12), time.Now())
+	require.Len(t, samples, 1)
+	assert.Equal(t, metrics.MonotonicCountType, samples[0].Mtype)
+	assert.Equal(t, 2.0, samples[0].Value)
+	samples, _ = r.convert(series("queue_size", 12), time.Now())
+	require.Len(t, samples, 1)
+	assert.Equal(t, metrics.GaugeType, samples[0].Mtype)
+}
+
+func TestPromRemoteWriteClassicHistogram(t *testing.T) {
+	r, _, _ := newTestPromRemoteWriteReceiver()
+
+	// the first cumulative counts are only kept
+	_, sketches := r.convert(&prompb.WriteRequest{
+		Timeseries: classicHistogramSeries(1700000000000, map[string]float64{"0.1": 10, "1": 15, "+Inf": 16}),
+	}, time.Now())
+	assert.Empty(t, sketches)
+
+	_, sketches = r.convert(&prompb.WriteRequest{
+		Timeseries: classicHistogramSeries(1700000010000, map[string]float64{"0.1": 30, "1": 40, "+Inf": 46}),
+	}, time.Now())
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "latency_seconds", sketches[0].Name)
+	assert.Equal(t, []string{"job:api"}, sketches[0].Tags.UnsafeToReadOnlySliceString())
+	assert.Equal(t, "my-host", sketches[0].Host)
+	require.Len(t, sketches[0].Points, 1)
+	assert.Equal(t, int64(1700000010), sketches[0].Points[0].Ts)
+	assert.Equal(t, int64(30), sketches[0].Points[0].Sketch.Basic.Cnt)
+	assert.Equal(t, 0.0, sketches[0].Points[0].Sketch.Basic.Min)
+	assert.InDelta(t, 1, sketches[0].Points[0].Sketch.Basic.Max, 0.01)
+
+	// the counts are reset when they decrease
+	_, sketches = r.convert(&prompb.WriteRequest{
+		Timeseries: classicHistogramSeries(1700000020000, map[string]float64{"0.1": 1, "1": 2, "+Inf": 2}),
+	}, time.Now())
+	require.Len(t, sketches, 1)
+	assert.Equal(t, int64(2), sketches[0].Points[0].Sketch.Basic.Cnt)
+}
+
+func TestPromRemoteWriteNativeHistogramBuckets(t *testing.T) {
+	buckets, ok := nativeHistogramBuckets(prompb.Histogram{
+		Schema:        0,
+		ZeroThreshold: 0.001,
+		ZeroCount:     &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+		// buckets 1, 2 and 5
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 1, Length: 2}, {Offset: 2, Length: 1}},
+		PositiveDeltas: []int64{2, 1, -2},
+		// bucket -1
+		NegativeSpans:  []prompb.BucketSpan{{Offset: -1, Length: 1}},
+		NegativeDeltas: []int64{4},
+	})
+	require.True(t, ok)
+	assert.Equal(t, []promBucket{
+		{lower: -0.001, upper: 0.001, count: 1},
+		{lower: 1, upper: 2, count: 2},
+		{lower: 2, upper: 4, count: 3},
+		{lower: 16, upper: 32, count: 1},
+		{lower: -0.5, upper: -0.25, count: 4},
+	}, buckets)
+
+	buckets, ok = nativeHistogramBuckets(prompb.Histogram{
+		Count:          &prompb.Histogram_CountFloat{CountFloat: 3},
+		Schema:         1,
+		ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: 0.5},
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 2, Length: 1}},
+		PositiveCounts: []float64{2.5},
+	})
+	require.True(t, ok)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, 0.5, buckets[0].count)
+	assert.InDelta(t, math.Sqrt2, buckets[1].lower, 1e-9)
+	assert.InDelta(t, 2, buckets[1].upper, 1e-9)
+	assert.Equal(t, 2.5, buckets[1].count)
+
+	_, ok = nativeHistogramBuckets(prompb.Histogram{Schema: -53})
+	assert.False(t, ok)
+}
+
+func TestPromRemoteWriteNativeHistogram(t *testing.T) {
+	r, _, _ := newTestPromRemoteWriteReceiver()
+	series := func(ts int64, count int64, hint prompb.Histogram_ResetHint) *prompb.WriteRequest {
+		return &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+			Labels: promLabels("__name__", "latency_seconds", "pod_uid", "1234"),
+			Histograms: []prompb.Histogram{{
+				Count:          &prompb.Histogram_CountInt{CountInt: uint64(count)},
+				Schema:         0,
+				ZeroCount:      &prompb.Histogram_ZeroCountInt{},
+				PositiveSpans:  []prompb.BucketSpan{{Offset: 1, Length: 1}},
+				PositiveDeltas: []int64{count},
+				ResetHint:      hint,
+				Timestamp:      ts,
+			}},
+		}}}
+	}
+
+	_, sketches := r.convert(series(1700000000000, 10, prompb.Histogram_UNKNOWN), time.Now())
+	assert.Empty(t, sketches)
+
+	_, sketches = r.convert(series(1700000010000, 25, prompb.Histogram_UNKNOWN), time.Now())
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "latency_seconds", sketches[0].Name)
+	assert.Equal(t, []string{"pod_name:pod-1234"}, sketches[0].Tags.UnsafeToReadOnlySliceString())
+	assert.Equal(t, int64(1700000010), sketches[0].Points[0].Ts)
+	assert.Equal(t, int64(15), sketches[0].Points[0].Sketch.Basic.Cnt)
+
+	// the gauge histograms are sent as is
+	_, sketches = r.convert(series(1700000020000, 7, prompb.Histogram_GAUGE), time.Now())
+	require.Len(t, sketches, 1)
+	assert.Equal(t, int64(7), sketches[0].Points[0].Sketch.Basic.Cnt)
+}
+
+func TestPromRemoteWriteExpireSeries(t *testing.T) {
+	r, _, _ := newTestPromRemoteWriteReceiver()
+	now := time.Now()
+
+	writeRequest := &prompb.WriteRequest{
+		Timeseries: classicHistogramSeries(1700000000000, map[string]float64{"1": 1, "+Inf": 1}),
+	}
+	writeRequest.Timeseries = append(writeRequest.Timeseries, prompb.TimeSeries{
+		Labels:  promLabels("__name__", "requests_total"),
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 1700000000000}},
+	})
+	r.convert(writeRequest, now)
+	assert.Len(t, r.histograms, 1)
+	assert.Len(t, r.counters, 1)
+
+	r.convert(&prompb.WriteRequest{}, now.Add(promSeriesTTL+time.Second))
+	assert.Empty(t, r.histograms)
+	assert.Empty(t, r.counters)
+}
+
+func TestPromRemoteWriteServeHTTP(t *testing.T) {
+	r, batches, _ := newTestPromRemoteWriteReceiver()
+
+	// the samples are sent in batches of the pool
+	samples := make([]prompb.Sample, 0, MetricSamplePoolBatchSize+1)
+	for i := 0; i <= MetricSamplePoolBatchSize; i++ {
+		samples = append(samples, prompb.Sample{Value: float64(i), Timestamp: 1700000000000 + int64(i)})
+	}
+	writeRequest := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels:  promLabels("__name__", "up", "job", "api"),
+		Samples: samples,
+	}}}
+	payload, err := writeRequest.Marshal()
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, promRemoteWritePath, bytes.NewReader(snappy.Encode(nil, payload))))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	require.Len(t, *batches, 2)
+	assert.Len(t, (*batches)[0], MetricSamplePoolBatchSize)
+	assert.Equal(t, MetricSamplePoolBatchSize, cap((*batches)[0]))
+	require.Len(t, (*batches)[1], 1)
+	assert.Equal(t, "up", (*batches)[1][0].Name)
+	assert.Equal(t, float64(MetricSamplePoolBatchSize), (*batches)[1][0].Value)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, promRemoteWritePath, bytes.NewReader(payload)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, promRemoteWritePath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
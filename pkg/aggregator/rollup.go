@@ -0,0 +1,411 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DataDog/opentelemetry-mapping-go/pkg/quantile"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/config/structure"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Aggregations of the gauges of a rollup rule.
+const (
+	rollupGaugeLast = "last"
+	rollupGaugeMax  = "max"
+)
+
+// RollupRuleConfig is an entry of the `metric_rollups` setting. The series and
+// sketches whose name matches its glob pattern are re-aggregated at flush time
+// without the tags it drops.
+type RollupRuleConfig struct {
+	Match            string   `mapstructure:"match" json:"match" yaml:"match"`
+	DropTags         []string `mapstructure:"drop_tags" json:"drop_tags" yaml:"drop_tags"`
+	GaugeAggregation string   `mapstructure:"gauge_aggregation" json:"gauge_aggregation" yaml:"gauge_aggregation"`
+	KeepOriginal     bool     `mapstructure:"keep_original" json:"keep_original" yaml:"keep_original"`
+	NameSuffix       string   `mapstructure:"name_suffix" json:"name_suffix" yaml:"name_suffix"`
+}
+
+type rollupRule struct {
+	match            string
+	dropTags         map[string]struct{}
+	gaugeAggregation string
+	keepOriginal     bool
+	nameSuffix       string
+}
+
+// rollupRules are the rollup rules of the configuration, the first rule whose
+// pattern matches the name of a metric applies.
+type rollupRules struct {
+	rules []rollupRule
+}
+
+var (
+	currentRollupRules   atomic.Pointer[rollupRules]
+	watchRollupRulesOnce sync.Once
+)
+
+// newRollupRules returns the rollup rules, or an error if one of them is invalid.
+func newRollupRules(configs []RollupRuleConfig) (*rollupRules, error) {
+	r := &rollupRules{
+		rules: make([]rollupRule, 0, len(configs)),
+	}
+	for i, c := range configs {
+		if c.Match == "" {
+			return nil, fmt.Errorf("rollup num %d: match is required", i)
+		}
+		if _, err := path.Match(c.Match, ""); err != nil {
+			return nil, fmt.Errorf("rollup num %d: invalid match pattern `%s`: %v", i, c.Match, err)
+		}
+		if len(c.DropTags) == 0 {
+			return nil, fmt.Errorf("rollup num %d: drop_tags is required", i)
+		}
+
+		rule := rollupRule{
+			match:            c.Match,
+			dropTags:         make(map[string]struct{}, len(c.DropTags)),
+			gaugeAggregation: c.GaugeAggregation,
+			keepOriginal:     c.KeepOriginal,
+			nameSuffix:       c.NameSuffix,
+		}
+		switch rule.gaugeAggregation {
+		case "":
+			rule.gaugeAggregation = rollupGaugeLast
+		case rollupGaugeLast, rollupGaugeMax:
+		default:
+			return nil, fmt.Errorf("rollup num %d: unknown gauge_aggregation '%s', must be '%s' or '%s'", i, c.GaugeAggregation, rollupGaugeLast, rollupGaugeMax)
+		}
+		for _, key := range c.DropTags {
+			rule.dropTags[key] = struct{}{}
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+// getRollupRules returns the rollup rules of the configuration, or nil if
+// there is none.
+func getRollupRules(config model.Reader) (*rollupRules, error) {
+	var configs []RollupRuleConfig
+	if config.IsSet("metric_rollups") {
+		if err := structure.UnmarshalKey(config, "metric_rollups", &configs); err != nil {
+			return nil, fmt.Errorf("could not parse metric_rollups: %v", err)
+		}
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return newRollupRules(configs)
+}
+
+// watchRollupRules loads the rollup rules of the configuration, and reloads
+// them each time the `metric_rollups` setting is updated. The new rules apply
+// from the next flush.
+func watchRollupRules(config model.Reader) {
+	loadRollupRules(config)
+
+	watchRollupRulesOnce.Do(func() {
+		config.OnUpdate(func(setting string, _, _ any) {
+			if setting == "metric_rollups" {
+				loadRollupRules(config)
+			}
+		})
+	})
+}
+
+func loadRollupRules(config model.Reader) {
+	rules, err := getRollupRules(config)
+	if err != nil {
+		log.Errorf("Invalid metric rollups, keeping the previous ones: %v", err)
+		return
+	}
+	currentRollupRules.Store(rules)
+}
+
+// lookup returns the rule applying to the metric, or nil if there is none.
+func (r *rollupRules) lookup(name string) *rollupRule {
+	for i := range r.rules {
+		if matched, _ := path.Match(r.rules[i].match, name); matched {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+type rolledUpSerie struct {
+	serie  *metrics.Serie
+	rule   *rollupRule
+	summed bool
+	// sampleTimestamps holds the timestamp of the last sample of each point
+	sampleTimestamps []float64
+}
+
+// rollupAggregator re-aggregates the series and sketches of a flush matching a
+// rollup rule without the tags dropped by the rule: the points of the counts
+// and rates are summed, the ones of the gauges take the value of the last sample
+// or the highest value, and the sketches are merged.
+type rollupAggregator struct {
+	rules    *rollupRules
+	byName   map[string]*rollupRule
+	series   map[string]*rolledUpSerie
+	sketches map[string]*metrics.SketchSeries
+	keyGen   *ckey.KeyGenerator
+	tagsBuf  *tagset.HashingTagsAccumulator
+}
+
+// newRollupAggregator returns an aggregator applying the rules, or nil if
+// there is no rule.
+func newRollupAggregator(rules *rollupRules) *rollupAggregator {
+	if rules == nil || len(rules.rules) == 0 {
+		return nil
+	}
+	return &rollupAggregator{
+		rules:    rules,
+		byName:   make(map[string]*rollupRule),
+		series:   make(map[string]*rolledUpSerie),
+		sketches: make(map[string]*metrics.SketchSeries),
+		keyGen:   ckey.NewKeyGenerator(),
+		tagsBuf:  tagset.NewHashingTagsAccumulator(),
+	}
+}
+
+func (r *rollupAggregator) lookup(name string) *rollupRule {
+	rule, found := r.byName[name]
+	if !found {
+		rule = r.rules.lookup(name)
+		r.byName[name] = rule
+	}
+	return rule
+}
+
+// rollupTags returns the sorted tags kept by the rule, and the key of the
+// rolled up metric.
+func (r *rollupAggregator) rollupTags(rule *rollupRule, name, host, device, mtype string, tags tagset.CompositeTags) ([]string, string) {
+	kept := make([]string, 0, tags.Len())
+	tags.ForEach(func(tag string) {
+		key, _, _ := strings.Cut(tag, ":")
+		if _, dropped := rule.dropTags[key]; !dropped {
+			kept = append(kept, tag)
+		}
+	})
+	sort.Strings(kept)
+	kept = slices.Compact(kept)
+	return kept, name + "\x00" + host + "\x00" + device + "\x00" + mtype + "\x00" + strings.Join(kept, ",")
+}
+
+func (r *rollupAggregator) contextKey(name, host string, tags []string) ckey.ContextKey {
+	r.tagsBuf.Reset()
+	r.tagsBuf.Append(tags...)
+	return r.keyGen.Generate(name, host, r.tagsBuf)
+}
+
+// addSerie rolls up the serie if a rule applies to it, and returns whether the
+// original serie must still be sent.
+func (r *rollupAggregator) addSerie(serie *metrics.Serie) bool {
+	rule := r.lookup(serie.Name)
+	if rule == nil {
+		return true
+	}
+
+	name := serie.Name + rule.nameSuffix
+	tags, key := r.rollupTags(rule, name, serie.Host, serie.Device, serie.MType.String(), serie.Tags)
+	rolledUp, found := r.series[key]
+	if !found {
+		rolledUp = &rolledUpSerie{
+			serie: &metrics.Serie{
+				Name:           name,
+				Tags:           tagset.CompositeTagsFromSlice(tags),
+				Host:           serie.Host,
+				Device:         serie.Device,
+				MType:          serie.MType,
+				Interval:       serie.Interval,
+				SourceTypeName: serie.SourceTypeName,
+				ContextKey:     r.contextKey(name, serie.Host, tags),
+				NoIndex:        serie.NoIndex,
+				Resources:      serie.Resources,
+				Source:         serie.Source,
+			},
+			rule:   rule,
+			summed: isSummed(serie),
+		}
+		r.series[key] = rolledUp
+	}
+
+	for _, p := range serie.Points {
+		rolledUp.addPoint(p, sampleTimestamp(serie, p))
+	}
+	return rule.keepOriginal
+}
+
+// isSummed returns true if the points of the serie are summed when rolled up,
+// which is the case of the counts and the rates, including the rates of the
+// checks and the sums of the histograms that are sent as gauges.
+func isSummed(serie *metrics.Serie) bool {
+	if serie.MType != metrics.APIGaugeType {
+		return true
+	}
+	switch serie.SourceMType {
+	case metrics.RateType:
+		return true
+	case metrics.HistogramType, metrics.HistorateType:
+		return serie.NameSuffix == ".sum"
+	}
+	return false
+}
+
+// sampleTimestamp returns the timestamp of the last sample of the point, or the
+// timestamp of the point if it is unknown. The series of the time samplers can
+// hold the points of several buckets, the timestamp of the last sample of the
+// serie only applies to the point of its bucket.
+func sampleTimestamp(serie *metrics.Serie, p metrics.Point) float64 {
+	ts := serie.SampleTimestamp
+	if ts == 0 || serie.Interval > 0 && (ts < p.Ts || ts >= p.Ts+float64(serie.Interval)) {
+		return p.Ts
+	}
+	return ts
+}
+
+func (s *rolledUpSerie) addPoint(p metrics.Point, sampleTimestamp float64) {
+	for i := range s.serie.Points {
+		existing := &s.serie.Points[i]
+		if existing.Ts != p.Ts {
+			continue
+		}
+		switch {
+		case s.summed:
+			existing.Value += p.Value
+		case s.rule.gaugeAggregation == rollupGaugeMax:
+			if p.Value > existing.Value {
+				existing.Value = p.Value
+			}
+		case sampleTimestamp > s.sampleTimestamps[i] || sampleTimestamp == s.sampleTimestamps[i] && p.Value > existing.Value:
+			// the value of the last sample is kept, the highest one if several
+			// were sampled at the same time so that it doesn't depend on the
+			// order of the series
+			existing.Value = p.Value
+			s.sampleTimestamps[i] = sampleTimestamp
+		}
+		return
+	}
+	s.serie.Points = append(s.serie.Points, p)
+	s.sampleTimestamps = append(s.sampleTimestamps, sampleTimestamp)
+}
+
+// addSketch rolls up the sketch if a rule applies to it, and returns whether
+// the original sketch must still be sent.
+func (r *rollupAggregator) addSketch(sketch *metrics.SketchSeries) bool {
+	rule := r.lookup(sketch.Name)
+	if rule == nil {
+		return true
+	}
+
+	name := sketch.Name + rule.nameSuffix
+	tags, key := r.rollupTags(rule, name, sketch.Host, "", "sketch", sketch.Tags)
+	rolledUp, found := r.sketches[key]
+	if !found {
+		rolledUp = &metrics.SketchSeries{
+			Name:       name,
+			Tags:       tagset.CompositeTagsFromSlice(tags),
+			Host:       sketch.Host,
+			Interval:   sketch.Interval,
+			ContextKey: r.contextKey(name, sketch.Host, tags),
+			NoIndex:    sketch.NoIndex,
+			Source:     sketch.Source,
+		}
+		r.sketches[key] = rolledUp
+	}
+
+	config := quantile.Default()
+	for _, p := range sketch.Points {
+		if p.Sketch == nil {
+			continue
+		}
+		merged := false
+		for i := range rolledUp.Points {
+			if rolledUp.Points[i].Ts == p.Ts {
+				rolledUp.Points[i].Sketch.Merge(config, p.Sketch)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			// the original sketch may still be sent, it is copied
+			s := &quantile.Sketch{}
+			s.Merge(config, p.Sketch)
+			rolledUp.Points = append(rolledUp.Points, metrics.SketchPoint{Ts: p.Ts, Sketch: s})
+		}
+	}
+	return rule.keepOriginal
+}
+
+// flushSeries appends the rolled up series to the sink.
+func (r *rollupAggregator) flushSeries(sink metrics.SerieSink) {
+	for key, rolledUp := range r.series {
+		sink.Append(rolledUp.serie)
+		delete(r.series, key)
+	}
+}
+
+// flushSketches appends the rolled up sketches to the sink.
+func (r *rollupAggregator) flushSketches(sink metrics.SketchesSink) {
+	for key, sketch := range r.sketches {
+		sink.Append(sketch)
+		delete(r.sketches, key)
+	}
+}
+
+// rollupFlush calls flush with sinks rolling up the series and sketches appended
+// to them, then appends the rolled up ones to the sinks. The rollups are computed
+// over all the samplers of a flush: the contexts of a rolled up metric are spread
+// over the DogStatsD pipelines, sharded by context, and over the check samplers.
+func rollupFlush(seriesSink metrics.SerieSink, sketchesSink metrics.SketchesSink, flush func(metrics.SerieSink, metrics.SketchesSink)) {
+	rollup := newRollupAggregator(currentRollupRules.Load())
+	if rollup == nil {
+		flush(seriesSink, sketchesSink)
+		return
+	}
+	flush(rollupSerieSink{sink: seriesSink, rollup: rollup}, rollupSketchesSink{sink: sketchesSink, rollup: rollup})
+	rollup.flushSeries(seriesSink)
+	rollup.flushSketches(sketchesSink)
+}
+
+// rollupSerieSink rolls up the series appended to it, and forwards the other
+// ones to its sink.
+type rollupSerieSink struct {
+	sink   metrics.SerieSink
+	rollup *rollupAggregator
+}
+
+func (s rollupSerieSink) Append(serie *metrics.Serie) {
+	if s.rollup.addSerie(serie) {
+		s.sink.Append(serie)
+	}
+}
+
+// rollupSketchesSink rolls up the sketches appended to it, and forwards the
+// other ones to its sink.
+type rollupSketchesSink struct {
+	sink   metrics.SketchesSink
+	rollup *rollupAggregator
+}
+
+func (s rollupSketchesSink) Append(sketch *metrics.SketchSeries) {
+	if s.rollup.addSketch(sketch) {
+		s.sink.Append(sketch)
+	}
+}
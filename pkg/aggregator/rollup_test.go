@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/DataDog/opentelemetry-mapping-go/pkg/quantile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// setRollupRules sets the rollup rules used by the samplers for the duration
+// of the test.
+func setRollupRules(t *testing.T, configs []RollupRuleConfig) {
+	rules, err := newRollupRules(configs)
+	require.NoError(t, err)
+	currentRollupRules.Store(rules)
+	t.Cleanup(func() { currentRollupRules.Store(nil) })
+}
+
+func sortedSeries(series metrics.Series) metrics.Series {
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Name != series[j].Name {
+			return series[i].Name < series[j].Name
+		}
+		return series[i].Tags.Join(",") < series[j].Tags.Join(",")
+	})
+	return series
+}
+
+func TestNewRollupRulesErrors(t *testing.T) {
+	for name, c := range map[string]RollupRuleConfig{
+		"missing match":     {DropTags: []string{"user_id"}},
+		"invalid pattern":   {Match: "my.[metric", DropTags: []string{"user_id"}},
+		"missing drop_tags": {Match: "my.*"},
+		"unknown gauge agg": {Match: "my.*", DropTags: []string{"user_id"}, GaugeAggregation: "avg"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newRollupRules([]RollupRuleConfig{c})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGetRollupRules(t *testing.T) {
+	cfg := configmock.New(t)
+
+	rules, err := getRollupRules(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+	assert.Nil(t, newRollupAggregator(rules))
+
+	cfg.SetWithoutSource("metric_rollups", []interface{}{
+		map[string]interface{}{"match": "http.*", "drop_tags": []interface{}{"user_id"}, "keep_original": true},
+	})
+	rules, err = getRollupRules(cfg)
+	require.NoError(t, err)
+	rule := rules.lookup("http.requests")
+	require.NotNil(t, rule)
+	assert.Equal(t, rollupGaugeLast, rule.gaugeAggregation)
+	assert.True(t, rule.keepOriginal)
+	assert.Nil(t, rules.lookup("db.queries"))
+}
+
+func TestRollupSeries(t *testing.T) {
+	rules, err := newRollupRules([]RollupRuleConfig{
+		{Match: "http.*", DropTags: []string{"user_id", "pod"}},
+		{Match: "queue.*", DropTags: []string{"pod"}, GaugeAggregation: rollupGaugeMax, KeepOriginal: true, NameSuffix: ".rollup"},
+	})
+	require.NoError(t, err)
+	rollup := newRollupAggregator(rules)
+
+	var series metrics.Series
+	sink := rollupSerieSink{sink: &series, rollup: rollup}
+	for _, serie := range []*metrics.Serie{
+		{Name: "http.requests", MType: metrics.APICountType, Points: []metrics.Point{{Ts: 10, Value: 1}, {Ts: 20, Value: 2}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:web", "user_id:1", "pod:a"})},
+		{Name: "http.requests", MType: metrics.APICountType, Points: []metrics.Point{{Ts: 10, Value: 3}}, Tags: tagset.CompositeTagsFromSlice([]string{"user_id:2", "service:web", "pod:b"})},
+		{Name: "http.requests", MType: metrics.APICountType, Points: []metrics.Point{{Ts: 10, Value: 5}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:api", "user_id:1"})},
+		{Name: "http.latency", MType: metrics.APIGaugeType, SampleTimestamp: 8, Points: []metrics.Point{{Ts: 10, Value: 4}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:web", "user_id:1"})},
+		{Name: "http.latency", MType: metrics.APIGaugeType, SampleTimestamp: 7, Points: []metrics.Point{{Ts: 10, Value: 2}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:web", "user_id:2"})},
+		{Name: "http.latency", MType: metrics.APIGaugeType, SampleTimestamp: 8, Points: []metrics.Point{{Ts: 10, Value: 3}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:web", "user_id:3"})},
+		{Name: "http.throughput", MType: metrics.APIGaugeType, SourceMType: metrics.RateType, Points: []metrics.Point{{Ts: 10, Value: 1.5}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:web", "user_id:1"})},
+		{Name: "http.throughput", MType: metrics.APIGaugeType, SourceMType: metrics.RateType, Points: []metrics.Point{{Ts: 10, Value: 2.5}}, Tags: tagset.CompositeTagsFromSlice([]string{"service:web", "user_id:2"})},
+		{Name: "queue.size", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 10, Value: 4}}, Tags: tagset.CompositeTagsFromSlice([]string{"pod:a"})},
+		{Name: "queue.size", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 10, Value: 7}}, Tags: tagset.CompositeTagsFromSlice([]string{"pod:b"})},
+		{Name: "other.metric", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 10, Value: 1}}, Tags: tagset.CompositeTagsFromSlice([]string{"pod:a"})},
+	} {
+		sink.Append(serie)
+	}
+	// the series without rule, and the ones kept with their rollup, are
+	// forwarded as is
+	require.Len(t, series, 3)
+
+	rollup.flushSeries(&series)
+	series = sortedSeries(series)
+	require.Len(t, series, 8)
+
+	type result struct {
+		name   string
+		tags   string
+		points []metrics.Point
+	}
+	var results []result
+	for _, serie := range series {
+		results = append(results, result{serie.Name, serie.Tags.Join(","), serie.Points})
+	}
+	assert.Equal(t, []result{
+		// value of the last sample of the gauges, the highest one if they were
+		// sampled at the same time
+		{"http.latency", "service:web", []metrics.Point{{Ts: 10, Value: 4}}},
+		// sum of the counts and rates, including the rates of the checks
+		{"http.requests", "service:api", []metrics.Point{{Ts: 10, Value: 5}}},
+		{"http.requests", "service:web", []metrics.Point{{Ts: 10, Value: 4}, {Ts: 20, Value: 2}}},
+		{"http.throughput", "service:web", []metrics.Point{{Ts: 10, Value: 4}}},
+		{"other.metric", "pod:a", []metrics.Point{{Ts: 10, Value: 1}}},
+		{"queue.size", "pod:a", []metrics.Point{{Ts: 10, Value: 4}}},
+		{"queue.size", "pod:b", []metrics.Point{{Ts: 10, Value: 7}}},
+		// highest value of the gauges
+		{"queue.size.rollup", "", []metrics.Point{{Ts: 10, Value: 7}}},
+	}, results)
+	assert.NotEqual(t, series[1].ContextKey, series[2].ContextKey)
+
+	// the points of the buckets before the one of the last sample use the
+	// timestamp of their bucket
+	serie := &metrics.Serie{Interval: 10, SampleTimestamp: 25}
+	assert.Equal(t, 10.0, sampleTimestamp(serie, metrics.Point{Ts: 10}))
+	assert.Equal(t, 25.0, sampleTimestamp(serie, metrics.Point{Ts: 20}))
+
+	// the rolled up series are only flushed once
+	var empty metrics.Series
+	rollup.flushSeries(&empty)
+	assert.Empty(t, empty)
+}
+
+func TestRollupSketches(t *testing.T) {
+	rules, err := newRollupRules([]RollupRuleConfig{
+		{Match: "http.*", DropTags: []string{"user_id"}, KeepOriginal: true},
+	})
+	require.NoError(t, err)
+	rollup := newRollupAggregator(rules)
+
+	config := quantile.Default()
+	sketch1, sketch2 := &quantile.Sketch{}, &quantile.Sketch{}
+	sketch1.Insert(config, 1, 2)
+	sketch2.Insert(config, 3)
+
+	var sketches metrics.SketchSeriesList
+	sink := rollupSketchesSink{sink: &sketches, rollup: rollup}
+	sink.Append(&metrics.SketchSeries{Name: "http.latency", Tags: tagset.CompositeTagsFromSlice([]string{"user_id:1", "service:web"}), Points: []metrics.SketchPoint{{Ts: 10, Sketch: sketch1}}})
+	sink.Append(&metrics.SketchSeries{Name: "http.latency", Tags: tagset.CompositeTagsFromSlice([]string{"user_id:2", "service:web"}), Points: []metrics.SketchPoint{{Ts: 10, Sketch: sketch2}}})
+	require.Len(t, sketches, 2)
+
+	rollup.flushSketches(&sketches)
+	require.Len(t, sketches, 3)
+	rolledUp := sketches[2]
+	assert.Equal(t, "http.latency", rolledUp.Name)
+	assert.Equal(t, "service:web", rolledUp.Tags.Join(","))
+	require.Len(t, rolledUp.Points, 1)
+	assert.Equal(t, int64(10), rolledUp.Points[0].Ts)
+	assert.Equal(t, int64(3), rolledUp.Points[0].Sketch.Basic.Cnt)
+	assert.Equal(t, 6.0, rolledUp.Points[0].Sketch.Basic.Sum)
+
+	// the original sketches are left untouched
+	assert.Equal(t, int64(2), sketch1.Basic.Cnt)
+	assert.Equal(t, int64(1), sketch2.Basic.Cnt)
+}
@@ -12,6 +12,7 @@ import (
 
 	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/limiter"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/tags"
 	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
@@ -44,8 +45,9 @@ type TimeSampler struct {
 	hostname string
 }
 
-// NewTimeSampler returns a newly initialized TimeSampler
-func NewTimeSampler(id TimeSamplerID, interval int64, cache *tags.Store, tagger tagger.Component, hostname string) *TimeSampler {
+// NewTimeSampler returns a newly initialized TimeSampler. The number of contexts
+// it tracks is bounded by contextsLimiter, which can be nil.
+func NewTimeSampler(id TimeSamplerID, interval int64, cache *tags.Store, contextsLimiter *limiter.Limiter, tagger tagger.Component, hostname string) *TimeSampler {
 	if interval == 0 {
 		interval = bucketSize
 	}
@@ -58,7 +60,7 @@ func NewTimeSampler(id TimeSamplerID, interval int64, cache *tags.Store, tagger
 
 	s := &TimeSampler{
 		interval:           interval,
-		contextResolver:    newTimestampContextResolver(tagger, cache, idString, contextExpireTime, counterExpireTime),
+		contextResolver:    newTimestampContextResolver(tagger, cache, contextsLimiter, idString, contextExpireTime, counterExpireTime),
 		metricsByTimestamp: map[int64]metrics.ContextMetrics{},
 		sketchMap:          make(sketchMap),
 		id:                 id,
@@ -98,7 +100,7 @@ func (s *TimeSampler) sample(metricSample *metrics.MetricSample, timestamp float
 			s.metricsByTimestamp[bucketStart] = bucketMetrics
 		}
 		// Add sample to bucket
-		if err := bucketMetrics.AddSample(contextKey, metricSample, timestamp, s.interval, nil, pkgconfigsetup.Datadog()); err != nil {
+		if err := bucketMetrics.AddSample(contextKey, metricSample, timestamp, s.interval, s.contextResolver.histogramConfig(contextKey, metricSample.Mtype), nil, pkgconfigsetup.Datadog()); err != nil {
 			log.Debugf("TimeSampler #%d Ignoring sample '%s' on host '%s' and tags '%s': %s", s.id, metricSample.Name, metricSample.Host, metricSample.Tags, err)
 		}
 	}
@@ -176,6 +178,7 @@ func (s *TimeSampler) dedupSerieBySerieSignature(
 
 		if existingSerie, ok := serieBySignature[serieSignature]; ok {
 			existingSerie.Points = append(existingSerie.Points, serie.Points[0])
+			existingSerie.SampleTimestamp = max(existingSerie.SampleTimestamp, serie.SampleTimestamp)
 		} else {
 			// Resolve context and populate new Serie
 			context, ok := s.contextResolver.get(serie.ContextKey)
@@ -189,6 +192,7 @@ func (s *TimeSampler) dedupSerieBySerieSignature(
 			serie.NoIndex = context.noIndex
 			serie.Interval = s.interval
 			serie.Source = context.source
+			serie.SourceMType = context.mtype
 
 			serieBySignature[serieSignature] = serie
 		}
@@ -229,6 +233,8 @@ func (s *TimeSampler) flush(timestamp float64, series metrics.SerieSink, sketche
 	s.lastCutOffTime = cutoffTime
 
 	s.updateMetrics()
+	// the limited contexts reported by updateMetrics are counted by flush
+	s.contextResolver.resetLimited()
 	s.sendTelemetry(timestamp, series)
 }
 
@@ -248,6 +254,7 @@ func (s *TimeSampler) updateMetrics() {
 		aggregatorDogstatsdContextsByMtype[i].Set(int64(count))
 	}
 	s.contextResolver.updateMetrics(tlmDogstatsdContextsByMtype, tlmDogstatsdContextsBytesByMtype)
+	contextLimiterOffenders.update(s.id, s.contextResolver.topLimitedMetrics(contextLimiterTopOffenders))
 }
 
 // flushContextMetrics flushes the contextMetrics inside contextMetricsFlusher, handles its errors,
@@ -280,7 +287,7 @@ func (s *TimeSampler) countersSampleZeroValue(timestamp int64, contextMetrics me
 			}
 			// Add a zero value sample to the counter
 			// It is ok to add a 0 sample to a counter that was already sampled in the bucket, it won't change its value
-			contextMetrics.AddSample(counterContext, sample, float64(timestamp), s.interval, nil, pkgconfigsetup.Datadog()) //nolint:errcheck
+			contextMetrics.AddSample(counterContext, sample, float64(timestamp), s.interval, nil, nil, pkgconfigsetup.Datadog()) //nolint:errcheck
 		}
 	}
 }
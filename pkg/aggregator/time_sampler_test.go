@@ -34,7 +34,7 @@ func generateSerieContextKey(serie *metrics.Serie) ckey.ContextKey {
 }
 
 func testTimeSampler(store *tags.Store) *TimeSampler {
-	sampler := NewTimeSampler(TimeSamplerID(0), 10, store, nooptagger.NewComponent(), "host")
+	sampler := NewTimeSampler(TimeSamplerID(0), 10, store, nil, nooptagger.NewComponent(), "host")
 	return sampler
 }
 
@@ -535,7 +535,7 @@ func TestFlushMissingContext(t *testing.T) {
 }
 
 func benchmarkTimeSampler(b *testing.B, store *tags.Store) {
-	sampler := NewTimeSampler(TimeSamplerID(0), 10, store, nooptagger.NewComponent(), "host")
+	sampler := NewTimeSampler(TimeSamplerID(0), 10, store, nil, nooptagger.NewComponent(), "host")
 
 	sample := metrics.MetricSample{
 		Name:       "my.metric.name",
@@ -560,3 +560,49 @@ func flushSerie(sampler *TimeSampler, timestamp float64) (metrics.Series, metric
 	sampler.flush(timestamp, &series, &sketches)
 	return series, sketches
 }
+
+// flushWithRollups flushes the samplers together, as the DogStatsD pipelines are
+// flushed by the demultiplexer.
+func flushWithRollups(timestamp float64, samplers ...*TimeSampler) (metrics.Series, metrics.SketchSeriesList) {
+	var series metrics.Series
+	var sketches metrics.SketchSeriesList
+	rollupFlush(&series, &sketches, func(seriesSink metrics.SerieSink, sketchesSink metrics.SketchesSink) {
+		for _, sampler := range samplers {
+			sampler.flush(timestamp, seriesSink, sketchesSink)
+		}
+	})
+	return series, sketches
+}
+
+func testRollup(t *testing.T, store *tags.Store) {
+	setRollupRules(t, []RollupRuleConfig{{Match: "my.*", DropTags: []string{"user_id"}, GaugeAggregation: rollupGaugeMax}})
+	// the contexts are sharded over the DogStatsD pipelines, the rolled up
+	// series are merged over all of them
+	samplers := []*TimeSampler{testTimeSampler(store), testTimeSampler(store)}
+
+	for i, userID := range []string{"user_id:1", "user_id:2", "user_id:3"} {
+		sampler := samplers[i%len(samplers)]
+		sampler.sample(&metrics.MetricSample{Name: "my.count", Value: 1, Mtype: metrics.CountType, Tags: []string{"env:prod", userID}, SampleRate: 1}, 12345.0)
+		sampler.sample(&metrics.MetricSample{Name: "my.gauge", Value: float64(i), Mtype: metrics.GaugeType, Tags: []string{"env:prod", userID}, SampleRate: 1}, 12345.0)
+		sampler.sample(&metrics.MetricSample{Name: "my.distribution", Value: float64(i), Mtype: metrics.DistributionType, Tags: []string{"env:prod", userID}, SampleRate: 1}, 12345.0)
+	}
+
+	series, sketches := flushWithRollups(12360.0, samplers...)
+
+	require.Len(t, series, 2)
+	sort.Slice(series, func(i, j int) bool { return series[i].Name < series[j].Name })
+	assert.Equal(t, "my.count", series[0].Name)
+	assert.Equal(t, "env:prod", series[0].Tags.Join(","))
+	assert.Equal(t, []metrics.Point{{Ts: 12340.0, Value: 3}}, series[0].Points)
+	assert.Equal(t, "my.gauge", series[1].Name)
+	assert.Equal(t, []metrics.Point{{Ts: 12340.0, Value: 2}}, series[1].Points)
+
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "my.distribution", sketches[0].Name)
+	assert.Equal(t, "env:prod", sketches[0].Tags.Join(","))
+	require.Len(t, sketches[0].Points, 1)
+	assert.Equal(t, int64(3), sketches[0].Points[0].Sketch.Basic.Cnt)
+}
+func TestRollup(t *testing.T) {
+	testWithTagsStore(t, testRollup)
+}
@@ -108,6 +108,10 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnv("apm_config.errors_per_second", "DD_APM_ERROR_TPS")
 	config.BindEnv("apm_config.enable_rare_sampler", "DD_APM_ENABLE_RARE_SAMPLER")
 	config.BindEnv("apm_config.disable_rare_sampler", "DD_APM_DISABLE_RARE_SAMPLER") // Deprecated
+	config.BindEnv("apm_config.latency_sampler.enabled", "DD_APM_LATENCY_SAMPLER_ENABLED")
+	config.BindEnv("apm_config.latency_sampler.tps", "DD_APM_LATENCY_SAMPLER_TPS")
+	config.BindEnv("apm_config.latency_sampler.percentile", "DD_APM_LATENCY_SAMPLER_PERCENTILE")
+	config.BindEnv("apm_config.trace_sampling_rules", "DD_APM_TRACE_SAMPLING_RULES")
 	config.BindEnv("apm_config.max_remote_traces_per_second", "DD_APM_MAX_REMOTE_TPS")
 	config.BindEnv("apm_config.probabilistic_sampler.enabled", "DD_APM_PROBABILISTIC_SAMPLER_ENABLED")
 	config.BindEnv("apm_config.probabilistic_sampler.sampling_percentage", "DD_APM_PROBABILISTIC_SAMPLER_SAMPLING_PERCENTAGE")
@@ -122,10 +126,16 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnv("apm_config.connection_limit", "DD_APM_CONNECTION_LIMIT", "DD_CONNECTION_LIMIT")
 	config.BindEnv("apm_config.connection_reset_interval", "DD_APM_CONNECTION_RESET_INTERVAL")
 	config.BindEnv("apm_config.max_sender_retries", "DD_APM_MAX_SENDER_RETRIES")
+	config.BindEnvAndSetDefault("apm_config.payload_spill.enabled", false, "DD_APM_PAYLOAD_SPILL_ENABLED")
+	// Defaults to <run_path>/apm-payload-spill when empty
+	config.BindEnvAndSetDefault("apm_config.payload_spill.dir", "", "DD_APM_PAYLOAD_SPILL_DIR")
+	config.BindEnvAndSetDefault("apm_config.payload_spill.max_size", 512*1024*1024, "DD_APM_PAYLOAD_SPILL_MAX_SIZE") // 512MB
+	config.BindEnvAndSetDefault("apm_config.payload_spill.max_age", 3600, "DD_APM_PAYLOAD_SPILL_MAX_AGE")            // 1 hour
 	config.BindEnv("apm_config.profiling_dd_url", "DD_APM_PROFILING_DD_URL")
 	config.BindEnv("apm_config.profiling_additional_endpoints", "DD_APM_PROFILING_ADDITIONAL_ENDPOINTS")
 	config.BindEnv("apm_config.additional_endpoints", "DD_APM_ADDITIONAL_ENDPOINTS")
 	config.BindEnv("apm_config.replace_tags", "DD_APM_REPLACE_TAGS")
+	config.BindEnv("apm_config.span_rules", "DD_APM_SPAN_RULES")
 	config.BindEnv("apm_config.analyzed_spans", "DD_APM_ANALYZED_SPANS")
 	config.BindEnv("apm_config.ignore_resources", "DD_APM_IGNORE_RESOURCES", "DD_IGNORE_RESOURCE")
 	config.BindEnv("apm_config.receiver_socket", "DD_APM_RECEIVER_SOCKET")
@@ -194,6 +204,22 @@ func setupAPM(config pkgconfigmodel.Setup) {
 		return out
 	})
 
+	config.ParseEnvAsSlice("apm_config.span_rules", func(in string) []interface{} {
+		var out []interface{}
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.span_rules" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
+	config.ParseEnvAsSlice("apm_config.trace_sampling_rules", func(in string) []interface{} {
+		var out []interface{}
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.trace_sampling_rules" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
 	config.ParseEnvAsMapStringInterface("apm_config.analyzed_spans", func(in string) map[string]interface{} {
 		out, err := parseAnalyzedSpans(in)
 		if err != nil {
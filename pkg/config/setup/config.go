@@ -1318,6 +1318,19 @@ func aggregator(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("basic_telemetry_add_container_tags", false) // configure adding the agent container tags to the basic agent telemetry metrics (e.g. `datadog.agent.running`)
 	config.BindEnvAndSetDefault("aggregator_flush_metrics_and_serialize_in_parallel_chan_size", 200)
 	config.BindEnvAndSetDefault("aggregator_flush_metrics_and_serialize_in_parallel_buffer_size", 4000)
+
+	// OpenMetrics exposition of the flushed metrics
+	config.BindEnvAndSetDefault("openmetrics_exposition.enabled", false)
+	config.BindEnvAndSetDefault("openmetrics_exposition.bind_host", "localhost")
+	config.BindEnvAndSetDefault("openmetrics_exposition.port", 5015)
+	config.BindEnvAndSetDefault("openmetrics_exposition.allowlist", []string{})
+	config.BindEnvAndSetDefault("openmetrics_exposition.denylist", []string{})
+
+	// Prometheus remote-write receiver, feeding the no-aggregation pipeline
+	config.BindEnvAndSetDefault("prometheus_remote_write.enabled", false)
+	config.BindEnvAndSetDefault("prometheus_remote_write.bind_host", "localhost")
+	config.BindEnvAndSetDefault("prometheus_remote_write.port", 5016)
+	config.BindEnvAndSetDefault("prometheus_remote_write.pod_uid_label", "pod_uid")
 }
 
 func serverless(config pkgconfigmodel.Setup) {
@@ -1368,6 +1381,18 @@ func dogstatsd(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("use_dogstatsd", true)
 	config.BindEnvAndSetDefault("dogstatsd_port", 8125)    // Notice: 0 means UDP port closed
 	config.BindEnvAndSetDefault("dogstatsd_pipe_name", "") // experimental and not officially supported for now.
+	config.BindEnvAndSetDefault("dogstatsd_tcp_port", 0)   // Notice: 0 means TCP port closed
+	config.BindEnvAndSetDefault("dogstatsd_tcp_framing", "newline")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_origin_tags", false)
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls.enabled", false)
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls.cert_file", "")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls.key_file", "")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls.ca_file", "")
+	// Graphite plaintext and InfluxDB line protocol listeners, 0 means the port is closed
+	config.BindEnvAndSetDefault("dogstatsd_graphite.udp_port", 0)
+	config.BindEnvAndSetDefault("dogstatsd_graphite.tcp_port", 0)
+	config.BindEnvAndSetDefault("dogstatsd_influx.udp_port", 0)
+	config.BindEnvAndSetDefault("dogstatsd_influx.tcp_port", 0)
 	// Experimental and not officially supported for now.
 	// Options are: udp, uds, named_pipe
 	config.BindEnvAndSetDefault("dogstatsd_eol_required", []string{})
@@ -1401,6 +1426,11 @@ func dogstatsd(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("dogstatsd_expiry_seconds", 300)
 	// Control how long we keep dogstatsd contexts in memory.
 	config.BindEnvAndSetDefault("dogstatsd_context_expiry_seconds", 20)
+	// Bound the number of dogstatsd contexts, per metric name and per origin.
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.enabled", false)
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.metric_limit", 0)
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.origin_limit", 0)
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.strip_tags", []string{})
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection", false) // Only supported for socket traffic
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection_client", false)
 	config.BindEnvAndSetDefault("dogstatsd_origin_optout_enabled", true)
@@ -1446,6 +1476,14 @@ func dogstatsd(config pkgconfigmodel.Setup) {
 		}
 		return mappings
 	})
+	config.BindEnv("dogstatsd_metric_rules")
+	config.ParseEnvAsSlice("dogstatsd_metric_rules", func(in string) []interface{} {
+		var rules []interface{}
+		if err := json.Unmarshal([]byte(in), &rules); err != nil {
+			log.Errorf(`"dogstatsd_metric_rules" can not be parsed: %v`, err)
+		}
+		return rules
+	})
 
 	config.BindEnvAndSetDefault("statsd_forward_host", "")
 	config.BindEnvAndSetDefault("statsd_forward_port", 0)
@@ -1458,6 +1496,22 @@ func dogstatsd(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("histogram_copy_to_distribution_prefix", "")
 	config.BindEnvAndSetDefault("histogram_aggregates", []string{"max", "median", "avg", "count"})
 	config.BindEnvAndSetDefault("histogram_percentiles", []string{"0.95"})
+	config.BindEnv("histogram_overrides")
+	config.ParseEnvAsSlice("histogram_overrides", func(in string) []interface{} {
+		var overrides []interface{}
+		if err := json.Unmarshal([]byte(in), &overrides); err != nil {
+			log.Errorf(`"histogram_overrides" can not be parsed: %v`, err)
+		}
+		return overrides
+	})
+	config.BindEnv("metric_rollups")
+	config.ParseEnvAsSlice("metric_rollups", func(in string) []interface{} {
+		var rollups []interface{}
+		if err := json.Unmarshal([]byte(in), &rollups); err != nil {
+			log.Errorf(`"metric_rollups" can not be parsed: %v`, err)
+		}
+		return rollups
+	})
 }
 
 func logsagent(config pkgconfigmodel.Setup) {
@@ -1495,6 +1549,8 @@ func logsagent(config pkgconfigmodel.Setup) {
 	}
 	// add global processing rules that are applied on all logs
 	config.BindEnv("logs_config.processing_rules")
+	// add global metric rules generating metrics from all logs
+	config.BindEnv("logs_config.metric_rules")
 	// enforce the agent to use files to collect container logs on kubernetes environment
 	config.BindEnvAndSetDefault("logs_config.k8s_container_use_file", false)
 	// Enable the agent to use files to collect container logs on standalone docker environment, containers
@@ -1544,6 +1600,12 @@ func logsagent(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("logs_config.message_channel_size", 100)
 	config.BindEnvAndSetDefault("logs_config.payload_channel_size", 10)
 
+	// Spill the payloads to disk while the reliable destinations are blocked
+	config.BindEnvAndSetDefault("logs_config.disk_buffer.enabled", false)
+	config.BindEnvAndSetDefault("logs_config.disk_buffer.max_size", 1024*1024*1024) // 1 GiB
+	// Defaults to <logs_config.run_path>/logs-buffer when empty
+	config.BindEnvAndSetDefault("logs_config.disk_buffer.path", "")
+
 	// maximum time that the unix tailer will hold a log file open after it has been rotated
 	config.BindEnvAndSetDefault("logs_config.close_timeout", 60)
 	// maximum time that the windows tailer will hold a log file open, while waiting for
@@ -1608,6 +1670,10 @@ func logsagent(config pkgconfigmodel.Setup) {
 	// more disk I/O at the wildcard log paths
 	config.BindEnvAndSetDefault("logs_config.file_wildcard_selection_mode", "by_name")
 
+	// Discover new files to tail as soon as they are created, using inotify (Linux only).
+	// The periodic scan is kept as a fallback and reconciliation loop.
+	config.BindEnvAndSetDefault("logs_config.file_event_discovery", false)
+
 	// Max size in MB an integration logs file can use
 	config.BindEnvAndSetDefault("logs_config.integrations_logs_files_max_size", 10)
 	// Max disk usage in MB all integrations logs files are allowed to use in total
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,6 +30,10 @@ const defaultCleanupPeriod = 300 * time.Second
 // latest version of the API used by the auditor to retrieve the registry from disk.
 const registryAPIVersion = 2
 
+// CompletedArchivePrefix prefixes the offset recorded for compressed archives
+// which have been read entirely, so that they are never sent twice.
+const CompletedArchivePrefix = "archive_completed:"
+
 // Registry holds a list of offsets.
 type Registry interface {
 	GetOffset(identifier string) string
@@ -219,12 +224,26 @@ func (a *RegistryAuditor) cleanupRegistry() {
 	expireBefore := time.Now().UTC().Add(-a.entryTTL)
 	for path, entry := range a.registry {
 		if entry.LastUpdated.Before(expireBefore) {
+			if isPresentCompletedArchive(path, entry) {
+				continue
+			}
 			log.Debugf("TTL for %s expired, removing from registry.", path)
 			delete(a.registry, path)
 		}
 	}
 }
 
+// isPresentCompletedArchive returns true if the entry belongs to a compressed archive
+// which has been read entirely and is still present on disk. Such entries must outlive
+// their TTL, otherwise the archive would be read again.
+func isPresentCompletedArchive(identifier string, entry *RegistryEntry) bool {
+	if !strings.HasPrefix(entry.Offset, CompletedArchivePrefix) {
+		return false
+	}
+	_, err := os.Stat(strings.TrimPrefix(identifier, "file:"))
+	return err == nil
+}
+
 // updateRegistry updates the registry entry matching identifier with new the offset and timestamp
 func (a *RegistryAuditor) updateRegistry(identifier string, offset string, tailingMode string, ingestionTimestamp int64) {
 	a.registryMutex.Lock()
@@ -112,6 +112,26 @@ func (suite *AuditorTestSuite) TestAuditorCleansupRegistry() {
 	suite.Equal("43", suite.a.registry[otherpath].Offset)
 }
 
+func (suite *AuditorTestSuite) TestAuditorKeepsPresentCompletedArchives() {
+	archivePath := filepath.Join(suite.testRunPathDir, "app.log.1.gz")
+	suite.NoError(os.WriteFile(archivePath, []byte("archive"), 0644))
+	expired := time.Date(2006, time.January, 12, 1, 1, 1, 1, time.UTC)
+
+	suite.a.registry = make(map[string]*RegistryEntry)
+	suite.a.registry["file:"+archivePath] = &RegistryEntry{
+		LastUpdated: expired,
+		Offset:      CompletedArchivePrefix + "7",
+	}
+	suite.a.registry["file:"+archivePath+".missing"] = &RegistryEntry{
+		LastUpdated: expired,
+		Offset:      CompletedArchivePrefix + "7",
+	}
+
+	suite.a.cleanupRegistry()
+	suite.Equal(1, len(suite.a.registry))
+	suite.Equal(CompletedArchivePrefix+"7", suite.a.registry["file:"+archivePath].Offset)
+}
+
 func TestScannerTestSuite(t *testing.T) {
 	suite.Run(t, new(AuditorTestSuite))
 }
@@ -0,0 +1,321 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package kafka implements the destination producing logs to Kafka topics.
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/backoff"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Default retry settings, used when the endpoint does not set them.
+const (
+	defaultBackoffFactor    = 2.0
+	defaultBackoffBase      = 1.0
+	defaultBackoffMax       = 120.0
+	defaultRecoveryInterval = 2
+)
+
+var (
+	tlmSend = telemetry.NewCounter("logs_client_kafka_destination", "send", []string{"error"}, "Payloads produced")
+
+	// invalidTopicChars matches the characters not allowed in a topic name.
+	invalidTopicChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+	// errInvalidPayload is returned when the encoded content of a payload can't be decoded.
+	errInvalidPayload = errors.New("invalid encoded payload")
+)
+
+// Destination produces the encoded messages of the payloads to a Kafka topic,
+// one record per message. Payloads are batched by the strategy of the pipeline,
+// and a payload is only handed to the auditor once all its records are
+// acknowledged by the brokers. The payloads without messages are produced from
+// their encoded content.
+type Destination struct {
+	endpoint            config.Endpoint
+	target              string
+	destinationsContext *client.DestinationsContext
+	newProducer         func(config.Endpoint) (producer, error)
+	shouldRetry         bool
+	isMRF               bool
+	senderDoneChan      chan *sync.WaitGroup
+
+	// Retry
+	backoff        backoff.Policy
+	nbErrors       int
+	retryLock      sync.Mutex
+	lastRetryError error
+}
+
+// NewDestination returns a new destination producing to the brokers and topic
+// of the endpoint. Payloads failing to be produced are retried when shouldRetry
+// is true, and dropped otherwise. When senderDoneChan is set, the sender is
+// notified once each payload is produced or dropped, as in serverless mode.
+func NewDestination(endpoint config.Endpoint, destinationsContext *client.DestinationsContext, shouldRetry bool, senderDoneChan chan *sync.WaitGroup) *Destination {
+	factor, base, maximum, recoveryInterval := endpoint.BackoffFactor, endpoint.BackoffBase, endpoint.BackoffMax, endpoint.RecoveryInterval
+	if maximum <= 0 {
+		factor, base, maximum, recoveryInterval = defaultBackoffFactor, defaultBackoffBase, defaultBackoffMax, defaultRecoveryInterval
+	}
+	d := &Destination{
+		endpoint:            endpoint,
+		target:              "kafka://" + strings.Join(endpoint.Brokers, ",") + "/" + endpoint.Topic,
+		destinationsContext: destinationsContext,
+		newProducer:         newKgoProducer,
+		shouldRetry:         shouldRetry,
+		isMRF:               endpoint.IsMRF,
+		senderDoneChan:      senderDoneChan,
+		backoff:             backoff.NewExpBackoffPolicy(factor, base, maximum, recoveryInterval, endpoint.RecoveryReset),
+	}
+	metrics.DestinationLogsDropped.Set(d.target, &expvar.Int{})
+	return d
+}
+
+// IsMRF indicates that this destination is a Multi-Region Failover destination.
+func (d *Destination) IsMRF() bool {
+	return d.isMRF
+}
+
+// Target is the address of the brokers and the topic of the destination.
+func (d *Destination) Target() string {
+	return d.target
+}
+
+// Metadata is not supported for Kafka destinations
+func (d *Destination) Metadata() *client.DestinationMetadata {
+	return client.NewNoopDestinationMetadata()
+}
+
+// Start starts reading the input channel
+func (d *Destination) Start(input chan *message.Payload, output chan *message.Payload, isRetrying chan bool) (stopChan <-chan struct{}) {
+	stop := make(chan struct{})
+	go func() {
+		p, err := d.newProducer(d.endpoint)
+		if err != nil {
+			log.Errorf("Can't create the Kafka producer of %s: %v", d.target, err)
+		}
+		for payload := range input {
+			if p == nil {
+				d.incrementErrors(payload)
+				output <- payload
+			} else {
+				d.sendAndRetry(p, payload, output, isRetrying)
+			}
+			if d.senderDoneChan != nil {
+				// Notify the sender that the payload has been sent
+				senderDoneWg := <-d.senderDoneChan
+				senderDoneWg.Done()
+			}
+		}
+		if p != nil {
+			p.close()
+		}
+		d.updateRetryState(nil, isRetrying)
+		stop <- struct{}{}
+	}()
+	return stop
+}
+
+func (d *Destination) sendAndRetry(p producer, payload *message.Payload, output chan *message.Payload, isRetrying chan bool) {
+	ctx := d.destinationsContext.Context()
+	for {
+		d.retryLock.Lock()
+		nbErrors := d.nbErrors
+		d.retryLock.Unlock()
+		if backoffDuration := d.backoff.GetBackoffDuration(nbErrors); backoffDuration > 0 {
+			log.Warnf("%s: sleeping %s before retrying due to %d errors", d.target, backoffDuration, nbErrors)
+			d.waitForBackoff(ctx, backoffDuration)
+			metrics.RetryTimeSpent.Add(int64(backoffDuration))
+			metrics.RetryCount.Add(1)
+			metrics.TlmRetryCount.Add(1)
+		}
+
+		err := d.send(ctx, p, payload)
+		if err != nil && ctx.Err() != nil {
+			// the pipeline is stopping, the payload will be sent again on restart
+			d.updateRetryState(nil, isRetrying)
+			return
+		}
+		if err != nil {
+			metrics.DestinationErrors.Add(1)
+			metrics.TlmDestinationErrors.Inc()
+			if d.shouldRetry && isRetryable(err) {
+				log.Warnf("Could not produce payload to %s: %v", d.target, err)
+				d.updateRetryState(err, isRetrying)
+				continue
+			}
+			log.Warnf("Dropping payload which could not be produced to %s: %v", d.target, err)
+			d.incrementErrors(payload)
+		} else {
+			metrics.LogsSent.Add(int64(len(payload.Messages)))
+			metrics.TlmLogsSent.Add(float64(len(payload.Messages)))
+		}
+		d.updateRetryState(nil, isRetrying)
+		output <- payload
+		return
+	}
+}
+
+// send produces one record per message of the payload.
+func (d *Destination) send(ctx context.Context, p producer, payload *message.Payload) (err error) {
+	defer func() {
+		tlmSend.Inc(errorToTag(err))
+	}()
+
+	records, err := d.records(payload)
+	if err != nil {
+		return err
+	}
+	if err := p.produce(ctx, records); err != nil {
+		return err
+	}
+	metrics.BytesSent.Add(int64(payload.UnencodedSize))
+	metrics.TlmBytesSent.Add(float64(payload.UnencodedSize))
+	return nil
+}
+
+// records returns one record per message of the payload. A payload without
+// messages is produced from its encoded content, so that it is never reported
+// as sent without any record.
+func (d *Destination) records(payload *message.Payload) ([]*kgo.Record, error) {
+	if len(payload.Messages) == 0 && len(payload.Encoded) > 0 {
+		contents, err := encodedContents(payload)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]*kgo.Record, 0, len(contents))
+		for _, content := range contents {
+			records = append(records, &kgo.Record{
+				Topic: d.topic(nil),
+				Value: content,
+			})
+		}
+		return records, nil
+	}
+
+	records := make([]*kgo.Record, 0, len(payload.Messages))
+	for _, msg := range payload.Messages {
+		records = append(records, &kgo.Record{
+			Topic: d.topic(msg),
+			Value: msg.GetContent(),
+		})
+	}
+	return records, nil
+}
+
+// encodedContents decodes the content of the payload, and splits it into the
+// encoded messages of the batch when it is a JSON array.
+func encodedContents(payload *message.Payload) ([][]byte, error) {
+	content := payload.Encoded
+	if payload.Encoding == "gzip" {
+		r, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPayload, err)
+		}
+		if content, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPayload, err)
+		}
+	}
+	var elements []json.RawMessage
+	if len(content) == 0 || content[0] != '[' || json.Unmarshal(content, &elements) != nil {
+		return [][]byte{content}, nil
+	}
+	contents := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		contents = append(contents, element)
+	}
+	return contents, nil
+}
+
+// topic returns the topic of the message, replacing the {source} and {service}
+// placeholders of the topic of the endpoint.
+func (d *Destination) topic(msg *message.Message) string {
+	topic := d.endpoint.Topic
+	if !strings.Contains(topic, "{") {
+		return topic
+	}
+	var source, service string
+	if msg != nil && msg.Origin != nil && msg.Origin.LogSource != nil {
+		source = msg.Origin.Source()
+		service = msg.Origin.Service()
+	}
+	return strings.NewReplacer("{source}", topicPart(source), "{service}", topicPart(service)).Replace(topic)
+}
+
+// topicPart makes a value usable in a topic name.
+func topicPart(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return invalidTopicChars.ReplaceAllString(value, "_")
+}
+
+// isRetryable returns false for the errors which producing the records again can't fix.
+func isRetryable(err error) bool {
+	return !errors.Is(err, errInvalidPayload) &&
+		!errors.Is(err, kerr.MessageTooLarge) &&
+		!errors.Is(err, kerr.RecordListTooLarge) &&
+		!errors.Is(err, kerr.InvalidTopicException) &&
+		!errors.Is(err, kerr.InvalidRecord)
+}
+
+func errorToTag(err error) string {
+	if err == nil {
+		return "none"
+	} else if isRetryable(err) {
+		return "retryable"
+	}
+	return "non-retryable"
+}
+
+func (d *Destination) updateRetryState(err error, isRetrying chan bool) {
+	d.retryLock.Lock()
+	defer d.retryLock.Unlock()
+
+	if err != nil {
+		d.nbErrors = d.backoff.IncError(d.nbErrors)
+		if isRetrying != nil && d.lastRetryError == nil {
+			isRetrying <- true
+		}
+	} else {
+		d.nbErrors = d.backoff.DecError(d.nbErrors)
+		if isRetrying != nil && d.lastRetryError != nil {
+			isRetrying <- false
+		}
+	}
+	d.lastRetryError = err
+}
+
+func (d *Destination) waitForBackoff(ctx context.Context, duration time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	<-ctx.Done()
+}
+
+func (d *Destination) incrementErrors(payload *message.Payload) {
+	metrics.DestinationLogsDropped.Add(d.target, int64(len(payload.Messages)))
+	metrics.TlmLogsDropped.Add(float64(len(payload.Messages)), d.target)
+}
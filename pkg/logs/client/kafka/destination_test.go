@@ -0,0 +1,227 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+// fakeBroker is an in-process stand-in for the Kafka brokers, it stores the
+// records it acknowledges by topic.
+type fakeBroker struct {
+	mu       sync.Mutex
+	topics   map[string][]string
+	errors   []error // returned by the next produce calls
+	attempts int
+	closed   bool
+}
+
+func newFakeBroker(errors ...error) *fakeBroker {
+	return &fakeBroker{topics: map[string][]string{}, errors: errors}
+}
+
+func (b *fakeBroker) produce(_ context.Context, records []*kgo.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attempts++
+	if len(b.errors) > 0 {
+		err := b.errors[0]
+		b.errors = b.errors[1:]
+		return err
+	}
+	for _, record := range records {
+		b.topics[record.Topic] = append(b.topics[record.Topic], string(record.Value))
+	}
+	return nil
+}
+
+func (b *fakeBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+}
+
+func newTestDestination(t *testing.T, endpoint config.Endpoint, broker *fakeBroker, shouldRetry bool) *Destination {
+	destinationsCtx := client.NewDestinationsContext()
+	destinationsCtx.Start()
+	t.Cleanup(destinationsCtx.Stop)
+
+	endpoint.Type = config.KafkaDestination
+	endpoint.Brokers = []string{"localhost:9092"}
+	endpoint.BackoffFactor = 1
+	endpoint.BackoffBase = 0.001
+	endpoint.BackoffMax = 0.01
+	endpoint.RecoveryInterval = 1
+	d := NewDestination(endpoint, destinationsCtx, shouldRetry, nil)
+	d.newProducer = func(config.Endpoint) (producer, error) { return broker, nil }
+	return d
+}
+
+func newPayload(source *sources.LogSource, contents ...string) *message.Payload {
+	payload := &message.Payload{}
+	for _, content := range contents {
+		payload.Messages = append(payload.Messages, message.NewMessageWithSource([]byte(content), "", source, 0))
+		payload.UnencodedSize += len(content)
+	}
+	return payload
+}
+
+func TestDestinationProducesOneRecordPerMessage(t *testing.T) {
+	broker := newFakeBroker()
+	d := newTestDestination(t, config.Endpoint{Topic: "logs-{source}-{service}"}, broker, true)
+	assert.Equal(t, "kafka://localhost:9092/logs-{source}-{service}", d.Target())
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, 2)
+	stop := d.Start(input, output, nil)
+
+	api := sources.NewLogSource("", &config.LogsConfig{Source: "nginx", Service: "web api"})
+	other := sources.NewLogSource("", &config.LogsConfig{})
+	first := newPayload(api, `{"message":"a"}`, `{"message":"b"}`)
+	second := newPayload(other, `{"message":"c"}`)
+	input <- first
+	input <- second
+	close(input)
+	<-stop
+
+	// the payloads are handed to the auditor once acknowledged
+	assert.Equal(t, first, <-output)
+	assert.Equal(t, second, <-output)
+	assert.Equal(t, map[string][]string{
+		"logs-nginx-web_api":   {`{"message":"a"}`, `{"message":"b"}`},
+		"logs-unknown-unknown": {`{"message":"c"}`},
+	}, broker.topics)
+	assert.True(t, broker.closed)
+}
+
+func TestDestinationProducesEncodedPayloads(t *testing.T) {
+	broker := newFakeBroker()
+	d := newTestDestination(t, config.Endpoint{Topic: "logs-{source}"}, broker, true)
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, 2)
+	stop := d.Start(input, output, nil)
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(`[{"message":"a"},{"message":"b"}]`))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	batch := &message.Payload{Encoded: compressed.Bytes(), Encoding: "gzip"}
+	line := &message.Payload{Encoded: []byte("raw line"), Encoding: "identity"}
+	input <- batch
+	input <- line
+	close(input)
+	<-stop
+
+	assert.Equal(t, batch, <-output)
+	assert.Equal(t, line, <-output)
+	assert.Equal(t, map[string][]string{
+		"logs-unknown": {`{"message":"a"}`, `{"message":"b"}`, "raw line"},
+	}, broker.topics)
+}
+
+func TestDestinationNotifiesTheSender(t *testing.T) {
+	broker := newFakeBroker(kerr.NotLeaderForPartition)
+	senderDoneChan := make(chan *sync.WaitGroup)
+	d := newTestDestination(t, config.Endpoint{Topic: "logs"}, broker, false)
+	d.senderDoneChan = senderDoneChan
+
+	input := make(chan *message.Payload, 2)
+	output := make(chan *message.Payload, 2)
+	stop := d.Start(input, output, nil)
+
+	// the sender waits for the dropped payloads as well
+	senderDoneWg := &sync.WaitGroup{}
+	for _, content := range []string{"dropped", "sent"} {
+		input <- newPayload(sources.NewLogSource("", &config.LogsConfig{}), content)
+		senderDoneWg.Add(1)
+		senderDoneChan <- senderDoneWg
+	}
+	senderDoneWg.Wait()
+	close(input)
+	<-stop
+	assert.Equal(t, []string{"sent"}, broker.topics["logs"])
+}
+
+func TestDestinationRetriesUntilAcknowledged(t *testing.T) {
+	broker := newFakeBroker(kerr.NotLeaderForPartition, kgo.ErrRecordTimeout)
+	d := newTestDestination(t, config.Endpoint{Topic: "logs"}, broker, true)
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, 1)
+	isRetrying := make(chan bool, 2)
+	stop := d.Start(input, output, isRetrying)
+
+	payload := newPayload(sources.NewLogSource("", &config.LogsConfig{}), "a")
+	input <- payload
+	assert.Equal(t, payload, <-output)
+	assert.True(t, <-isRetrying)
+	assert.False(t, <-isRetrying)
+	assert.Equal(t, 3, broker.attempts)
+	assert.Equal(t, []string{"a"}, broker.topics["logs"])
+
+	close(input)
+	<-stop
+}
+
+func TestDestinationDropsPayloadsItCannotProduce(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err         error
+		shouldRetry bool
+	}{
+		"non-retryable error": {err: kerr.MessageTooLarge, shouldRetry: true},
+		"unreliable":          {err: kerr.NotLeaderForPartition, shouldRetry: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			broker := newFakeBroker(tc.err)
+			d := newTestDestination(t, config.Endpoint{Topic: "logs"}, broker, tc.shouldRetry)
+
+			input := make(chan *message.Payload)
+			output := make(chan *message.Payload, 2)
+			stop := d.Start(input, output, nil)
+
+			dropped := newPayload(sources.NewLogSource("", &config.LogsConfig{}), "dropped")
+			sent := newPayload(sources.NewLogSource("", &config.LogsConfig{}), "sent")
+			input <- dropped
+			input <- sent
+			close(input)
+			<-stop
+
+			assert.Equal(t, dropped, <-output)
+			assert.Equal(t, sent, <-output)
+			assert.Equal(t, 2, broker.attempts)
+			assert.Equal(t, []string{"sent"}, broker.topics["logs"])
+		})
+	}
+}
+
+func TestNewKgoProducer(t *testing.T) {
+	endpoint := config.Endpoint{
+		Type:         config.KafkaDestination,
+		Brokers:      []string{"localhost:9092"},
+		Topic:        "logs",
+		Compression:  config.KafkaCompressionZstd,
+		RequiredAcks: config.KafkaAcksLeader,
+	}
+	p, err := newKgoProducer(endpoint)
+	require.NoError(t, err)
+	p.close()
+}
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+)
+
+// recordDeliveryTimeout bounds the time spent producing a payload, past it the
+// destination reports it is retrying instead of blocking silently.
+const recordDeliveryTimeout = 30 * time.Second
+
+// producer sends records to the Kafka brokers.
+type producer interface {
+	// produce blocks until all the records are acknowledged by the brokers,
+	// it returns the first error if some are not.
+	produce(ctx context.Context, records []*kgo.Record) error
+	close()
+}
+
+// kgoProducer is the producer backed by a franz-go client.
+type kgoProducer struct {
+	client *kgo.Client
+}
+
+// newKgoProducer returns a producer for the brokers of the endpoint. The
+// connections to the brokers are only opened when records are produced.
+func newKgoProducer(endpoint config.Endpoint) (producer, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(endpoint.Brokers...),
+		kgo.ClientID("datadog-agent"),
+		kgo.ProducerBatchCompression(compressionCodec(endpoint.Compression)),
+		kgo.RecordDeliveryTimeout(recordDeliveryTimeout),
+		// the records have no key, they are spread over the partitions batch by batch
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+	}
+	if endpoint.RequiredAcks == config.KafkaAcksLeader {
+		// idempotent writes require the acknowledgements of all the replicas
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	} else {
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	}
+	if endpoint.UseSSL() {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &kgoProducer{client: client}, nil
+}
+
+func (p *kgoProducer) produce(ctx context.Context, records []*kgo.Record) error {
+	return p.client.ProduceSync(ctx, records...).FirstErr()
+}
+
+func (p *kgoProducer) close() {
+	p.client.Close()
+}
+
+// compressionCodec returns the codec of the compression setting of an endpoint,
+// which has been validated when the endpoint was loaded.
+func compressionCodec(compression string) kgo.CompressionCodec {
+	switch compression {
+	case config.KafkaCompressionGzip:
+		return kgo.GzipCompression()
+	case config.KafkaCompressionSnappy:
+		return kgo.SnappyCompression()
+	case config.KafkaCompressionLz4:
+		return kgo.Lz4Compression()
+	case config.KafkaCompressionZstd:
+		return kgo.ZstdCompression()
+	default:
+		return kgo.NoCompression()
+	}
+}
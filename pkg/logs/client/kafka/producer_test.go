@@ -0,0 +1,303 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+)
+
+const (
+	mockBrokerPartitions = 3
+	mockProducerID       = 42
+)
+
+// producedBatch is a record batch received by the mockBroker.
+type producedBatch struct {
+	acks       int16
+	topic      string
+	partition  int32
+	codec      int16
+	producerID int64
+	numRecords int32
+}
+
+// mockBroker is a single in-process Kafka broker speaking the wire protocol,
+// it acknowledges the record batches it receives and keeps track of them.
+type mockBroker struct {
+	listener net.Listener
+	host     string
+	port     int32
+
+	mu      sync.Mutex
+	batches []producedBatch
+}
+
+func newMockBroker(t *testing.T) *mockBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	b := &mockBroker{listener: listener, host: host, port: int32(portNum)}
+	go b.serve()
+	t.Cleanup(func() { listener.Close() })
+	return b
+}
+
+func (b *mockBroker) addr() string {
+	return b.listener.Addr().String()
+}
+
+func (b *mockBroker) producedBatches() []producedBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]producedBatch{}, b.batches...)
+}
+
+func (b *mockBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+// handle answers the requests of a connection until it is closed.
+func (b *mockBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		var size int32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return
+		}
+		response, err := b.respond(frame)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+// respond parses a request frame and returns the response frame.
+func (b *mockBroker) respond(frame []byte) ([]byte, error) {
+	if len(frame) < 10 {
+		return nil, errors.New("truncated request header")
+	}
+	key := int16(binary.BigEndian.Uint16(frame[0:]))
+	version := int16(binary.BigEndian.Uint16(frame[2:]))
+	correlationID := binary.BigEndian.Uint32(frame[4:])
+	body := frame[8:]
+	clientIDLen := int16(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	if clientIDLen > 0 {
+		body = body[clientIDLen:]
+	}
+
+	req := kmsg.RequestForKey(key)
+	if req == nil {
+		return nil, errors.New("unknown request key")
+	}
+	req.SetVersion(version)
+	if req.IsFlexible() {
+		// the client sends no tagged field in the header
+		if len(body) == 0 || body[0] != 0 {
+			return nil, errors.New("unexpected tagged fields")
+		}
+		body = body[1:]
+	}
+	if err := req.ReadFrom(body); err != nil {
+		return nil, err
+	}
+
+	resp := b.handleRequest(req)
+	resp.SetVersion(version)
+	out := make([]byte, 8, 64)
+	binary.BigEndian.PutUint32(out[4:], correlationID)
+	if resp.IsFlexible() && key != int16(kmsg.ApiVersions) {
+		// the response header of ApiVersions never has tagged fields
+		out = append(out, 0)
+	}
+	out = resp.AppendTo(out)
+	binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+	return out, nil
+}
+
+func (b *mockBroker) handleRequest(req kmsg.Request) kmsg.Response {
+	switch req := req.(type) {
+	case *kmsg.ApiVersionsRequest:
+		resp := kmsg.NewPtrApiVersionsResponse()
+		for _, key := range []kmsg.Key{kmsg.Produce, kmsg.Metadata, kmsg.ApiVersions, kmsg.InitProducerID} {
+			apiKey := kmsg.NewApiVersionsResponseApiKey()
+			apiKey.ApiKey = int16(key)
+			apiKey.MaxVersion = key.Request().MaxVersion()
+			resp.ApiKeys = append(resp.ApiKeys, apiKey)
+		}
+		return resp
+
+	case *kmsg.MetadataRequest:
+		resp := kmsg.NewPtrMetadataResponse()
+		broker := kmsg.NewMetadataResponseBroker()
+		broker.Host, broker.Port = b.host, b.port
+		resp.Brokers = append(resp.Brokers, broker)
+		for _, requested := range req.Topics {
+			topic := kmsg.NewMetadataResponseTopic()
+			topic.Topic = requested.Topic
+			for i := int32(0); i < mockBrokerPartitions; i++ {
+				partition := kmsg.NewMetadataResponseTopicPartition()
+				partition.Partition = i
+				partition.Replicas = []int32{0}
+				partition.ISR = []int32{0}
+				topic.Partitions = append(topic.Partitions, partition)
+			}
+			resp.Topics = append(resp.Topics, topic)
+		}
+		return resp
+
+	case *kmsg.InitProducerIDRequest:
+		resp := kmsg.NewPtrInitProducerIDResponse()
+		resp.ProducerID = mockProducerID
+		return resp
+
+	case *kmsg.ProduceRequest:
+		resp := kmsg.NewPtrProduceResponse()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for _, topic := range req.Topics {
+			respTopic := kmsg.NewProduceResponseTopic()
+			respTopic.Topic = topic.Topic
+			for _, partition := range topic.Partitions {
+				var batch kmsg.RecordBatch
+				respPartition := kmsg.NewProduceResponseTopicPartition()
+				respPartition.Partition = partition.Partition
+				if err := batch.ReadFrom(partition.Records); err != nil {
+					respPartition.ErrorCode = 2 // CORRUPT_MESSAGE
+				} else {
+					respPartition.BaseOffset = int64(len(b.batches))
+					b.batches = append(b.batches, producedBatch{
+						acks:       req.Acks,
+						topic:      topic.Topic,
+						partition:  partition.Partition,
+						codec:      batch.Attributes & 0x07,
+						producerID: batch.ProducerID,
+						numRecords: batch.NumRecords,
+					})
+				}
+				respTopic.Partitions = append(respTopic.Partitions, respPartition)
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp
+	}
+	return req.ResponseKind()
+}
+
+func TestKgoProducerConfiguration(t *testing.T) {
+	for name, tt := range map[string]struct {
+		compression string
+		acks        string
+		codec       int16
+		wantAcks    int16
+		producerID  int64
+	}{
+		"default": {
+			codec:      0,
+			wantAcks:   -1,
+			producerID: mockProducerID,
+		},
+		"gzip all acks": {
+			compression: config.KafkaCompressionGzip,
+			acks:        config.KafkaAcksAll,
+			codec:       1,
+			wantAcks:    -1,
+			producerID:  mockProducerID,
+		},
+		"snappy leader ack": {
+			compression: config.KafkaCompressionSnappy,
+			acks:        config.KafkaAcksLeader,
+			codec:       2,
+			wantAcks:    1,
+			// the writes are not idempotent
+			producerID: -1,
+		},
+		"lz4": {
+			compression: config.KafkaCompressionLz4,
+			codec:       3,
+			wantAcks:    -1,
+			producerID:  mockProducerID,
+		},
+		"zstd": {
+			compression: config.KafkaCompressionZstd,
+			codec:       4,
+			wantAcks:    -1,
+			producerID:  mockProducerID,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			broker := newMockBroker(t)
+			p, err := newKgoProducer(config.Endpoint{
+				Type:         config.KafkaDestination,
+				Brokers:      []string{broker.addr()},
+				Compression:  tt.compression,
+				RequiredAcks: tt.acks,
+			})
+			require.NoError(t, err)
+			defer p.close()
+
+			for i := 0; i < 4; i++ {
+				var records []*kgo.Record
+				for j := 0; j < 3; j++ {
+					// the batches are only compressed if it makes them smaller
+					value := strings.Repeat("log message ", 32)
+					records = append(records, &kgo.Record{Topic: "logs", Value: []byte(value)})
+				}
+				require.NoError(t, p.produce(context.Background(), records))
+			}
+
+			var numRecords int32
+			partitions := map[int32]struct{}{}
+			for _, batch := range broker.producedBatches() {
+				assert.Equal(t, "logs", batch.topic)
+				assert.Equal(t, tt.wantAcks, batch.acks)
+				assert.Equal(t, tt.codec, batch.codec)
+				assert.Equal(t, tt.producerID, batch.producerID)
+				assert.True(t, batch.partition >= 0 && batch.partition < mockBrokerPartitions, batch.partition)
+				partitions[batch.partition] = struct{}{}
+				numRecords += batch.numRecords
+			}
+			assert.Equal(t, int32(12), numRecords)
+			// the records without key are spread over the partitions, a new
+			// one being picked for each batch
+			assert.Greater(t, len(partitions), 1)
+		})
+	}
+}
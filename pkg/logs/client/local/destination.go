@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package local implements the destinations writing logs locally, to a file
+// or to the standard output, instead of sending them to an intake.
+package local
+
+import (
+	"expvar"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// stdout is shared by all the stdout destinations, the lock makes sure that
+// the messages of concurrent pipelines are not interleaved.
+var stdout = &lockedWriter{w: os.Stdout}
+
+// Destination writes the encoded messages of the payloads, one per line, to a
+// rotated file or to the standard output. The messages are written as they
+// would be sent to the intake, which is the JSON payload of the HTTP intake
+// when logs are sent over HTTP.
+type Destination struct {
+	target         string
+	open           func() (io.WriteCloser, error)
+	isMRF          bool
+	senderDoneChan chan *sync.WaitGroup
+}
+
+// NewDestination returns a new destination writing to the file or to the
+// standard output, depending on the type of the endpoint. When senderDoneChan
+// is set, the sender is notified once each payload is written, as in serverless
+// mode.
+func NewDestination(endpoint config.Endpoint, senderDoneChan chan *sync.WaitGroup) *Destination {
+	d := &Destination{isMRF: endpoint.IsMRF, senderDoneChan: senderDoneChan}
+	if endpoint.Type == config.StdoutDestination {
+		d.target = "stdout"
+		d.open = func() (io.WriteCloser, error) { return stdout, nil }
+	} else {
+		d.target = endpoint.Path
+		d.open = func() (io.WriteCloser, error) {
+			w, err := openFileWriter(endpoint.Path, endpoint.MaxFileSize, endpoint.MaxFileRolls)
+			if err != nil {
+				return nil, err
+			}
+			return w, nil
+		}
+	}
+	metrics.DestinationLogsDropped.Set(d.target, &expvar.Int{})
+	return d
+}
+
+// IsMRF indicates that this destination is a Multi-Region Failover destination.
+func (d *Destination) IsMRF() bool {
+	return d.isMRF
+}
+
+// Target is the path of the file the destination writes to, or stdout.
+func (d *Destination) Target() string {
+	return d.target
+}
+
+// Metadata is not supported for local destinations
+func (d *Destination) Metadata() *client.DestinationMetadata {
+	return client.NewNoopDestinationMetadata()
+}
+
+// Start reads from the input and writes the messages of the payloads. Local
+// destinations never retry, the payloads they fail to write are dropped.
+func (d *Destination) Start(input chan *message.Payload, output chan *message.Payload, _ chan bool) (stopChan <-chan struct{}) {
+	stop := make(chan struct{})
+	go func() {
+		w, err := d.open()
+		if err != nil {
+			log.Errorf("Can't open the logs destination %s: %v", d.target, err)
+		}
+		for payload := range input {
+			if w == nil || !d.write(w, payload) {
+				d.incrementErrors(payload)
+			}
+			if d.senderDoneChan != nil {
+				// Notify the sender that the payload has been sent
+				senderDoneWg := <-d.senderDoneChan
+				senderDoneWg.Done()
+			}
+			output <- payload
+		}
+		if w != nil {
+			w.Close()
+		}
+		stop <- struct{}{}
+	}()
+	return stop
+}
+
+// write writes the messages of the payload, it returns false if it failed.
+func (d *Destination) write(w io.Writer, payload *message.Payload) bool {
+	buf := make([]byte, 0, payload.UnencodedSize+len(payload.Messages))
+	for _, msg := range payload.Messages {
+		buf = append(buf, msg.GetContent()...)
+		buf = append(buf, '\n')
+	}
+	if _, err := w.Write(buf); err != nil {
+		log.Warnf("Can't write logs to %s: %v", d.target, err)
+		return false
+	}
+	return true
+}
+
+func (d *Destination) incrementErrors(payload *message.Payload) {
+	metrics.DestinationLogsDropped.Add(d.target, int64(len(payload.Messages)))
+	metrics.TlmLogsDropped.Add(float64(len(payload.Messages)), d.target)
+	metrics.DestinationErrors.Add(1)
+	metrics.TlmDestinationErrors.Inc()
+}
+
+// lockedWriter serializes the writes of its users, closing it is a no-op.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (l *lockedWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func newPayload(contents ...string) *message.Payload {
+	payload := &message.Payload{}
+	for _, content := range contents {
+		payload.Messages = append(payload.Messages, message.NewMessage([]byte(content), nil, "", 0))
+		payload.UnencodedSize += len(content)
+	}
+	return payload
+}
+
+func runDestination(d *Destination, payloads ...*message.Payload) []*message.Payload {
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, len(payloads))
+	stop := d.Start(input, output, nil)
+	for _, payload := range payloads {
+		input <- payload
+	}
+	close(input)
+	<-stop
+	close(output)
+
+	var sent []*message.Payload
+	for payload := range output {
+		sent = append(sent, payload)
+	}
+	return sent
+}
+
+func TestFileDestinationWritesOneMessagePerLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "archive.json")
+	d := NewDestination(config.Endpoint{Type: config.FileDestination, Path: path}, nil)
+	assert.Equal(t, path, d.Target())
+
+	payloads := []*message.Payload{newPayload(`{"message":"a"}`, `{"message":"b"}`), newPayload(`{"message":"c"}`)}
+	assert.Equal(t, payloads, runDestination(d, payloads...))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"message\":\"a\"}\n{\"message\":\"b\"}\n{\"message\":\"c\"}\n", string(content))
+	assert.Empty(t, fileWriters)
+}
+
+func TestFileDestinationRotatesFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.json")
+	d := NewDestination(config.Endpoint{Type: config.FileDestination, Path: path, MaxFileSize: 10, MaxFileRolls: 2}, nil)
+
+	runDestination(d, newPayload("aaaa"), newPayload("bbbb"), newPayload("cccc"), newPayload("dddd"), newPayload("eeee"), newPayload("ffff", "gggg"))
+
+	for file, expected := range map[string]string{
+		path:        "ffff\ngggg\n",
+		path + ".1": "eeee\n",
+		path + ".2": "cccc\ndddd\n",
+	} {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content), file)
+	}
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileDestinationsShareTheirWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.json")
+	w1, err := openFileWriter(path, 0, 0)
+	require.NoError(t, err)
+	w2, err := openFileWriter(path, 0, 0)
+	require.NoError(t, err)
+	assert.Same(t, w1, w2)
+	assert.EqualValues(t, defaultMaxFileSize, w1.maxSize)
+
+	require.NoError(t, w1.Close())
+	assert.Contains(t, fileWriters, path)
+	require.NoError(t, w2.Close())
+	assert.NotContains(t, fileWriters, path)
+}
+
+func TestFileDestinationDropsPayloadsItCannotWrite(t *testing.T) {
+	// the parent of the file is a regular file, it can't be created
+	parent := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(parent, nil, 0600))
+	d := NewDestination(config.Endpoint{Type: config.FileDestination, Path: filepath.Join(parent, "archive.json")}, nil)
+
+	payload := newPayload("lost")
+	assert.Equal(t, []*message.Payload{payload}, runDestination(d, payload))
+}
+
+func TestStdoutDestination(t *testing.T) {
+	var buf bytes.Buffer
+	stdout.w = &buf
+	defer func() { stdout.w = os.Stdout }()
+
+	d := NewDestination(config.Endpoint{Type: config.StdoutDestination}, nil)
+	assert.Equal(t, "stdout", d.Target())
+	runDestination(d, newPayload("hello", "world"))
+	assert.Equal(t, "hello\nworld\n", buf.String())
+}
+
+func TestDestinationNotifiesTheSender(t *testing.T) {
+	senderDoneChan := make(chan *sync.WaitGroup)
+	d := NewDestination(config.Endpoint{Type: config.FileDestination, Path: filepath.Join(t.TempDir(), "archive.json")}, senderDoneChan)
+
+	input := make(chan *message.Payload, 2)
+	output := make(chan *message.Payload, 2)
+	stop := d.Start(input, output, nil)
+
+	// the serverless flush waits for the payloads to be written
+	senderDoneWg := &sync.WaitGroup{}
+	for _, content := range []string{"first", "second"} {
+		input <- newPayload(content)
+		senderDoneWg.Add(1)
+		senderDoneChan <- senderDoneWg
+	}
+	senderDoneWg.Wait()
+	close(input)
+	<-stop
+	assert.Len(t, output, 2)
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// defaultMaxFileSize is the size at which files are rotated when the endpoint does not set it.
+	defaultMaxFileSize = 10 * 1024 * 1024
+	// defaultMaxFileRolls is the number of rotated files kept when the endpoint does not set it.
+	defaultMaxFileRolls = 5
+)
+
+var (
+	// fileWriters holds the writers opened by the file destinations. Each
+	// pipeline has its own destinations, they share the writer of a path so
+	// that the rotation of the file is not performed concurrently.
+	fileWriters   = map[string]*fileWriter{}
+	fileWritersMu sync.Mutex
+)
+
+// fileWriter appends to a file and rotates it when it reaches its maximum
+// size: path is renamed to path.1, path.1 to path.2 and so on up to the
+// maximum number of rolls, the oldest file being removed.
+type fileWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxRolls int
+	file     *os.File
+	size     int64
+	refs     int
+}
+
+// openFileWriter returns the writer of the path, opening the file if it is not
+// already used by another destination. The writer must be closed once done.
+func openFileWriter(path string, maxSize int64, maxRolls int) (*fileWriter, error) {
+	fileWritersMu.Lock()
+	defer fileWritersMu.Unlock()
+
+	if w, ok := fileWriters[path]; ok {
+		w.refs++
+		return w, nil
+	}
+
+	if maxSize <= 0 {
+		maxSize = defaultMaxFileSize
+	}
+	if maxRolls <= 0 {
+		maxRolls = defaultMaxFileRolls
+	}
+	w := &fileWriter{
+		path:     path,
+		maxSize:  maxSize,
+		maxRolls: maxRolls,
+		refs:     1,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	fileWriters[path] = w
+	return w, nil
+}
+
+func (w *fileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p does not fit in it.
+func (w *fileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		// a previous rotation failed to reopen the file
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files and reopens an empty file.
+func (w *fileWriter) rotate() error {
+	w.file.Close()
+	w.file = nil
+
+	os.Remove(rolledPath(w.path, w.maxRolls))
+	for i := w.maxRolls - 1; i > 0; i-- {
+		os.Rename(rolledPath(w.path, i), rolledPath(w.path, i+1))
+	}
+	if err := os.Rename(w.path, rolledPath(w.path, 1)); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close releases the writer, the file is closed once no destination uses it.
+func (w *fileWriter) Close() error {
+	fileWritersMu.Lock()
+	defer fileWritersMu.Unlock()
+
+	w.refs--
+	if w.refs > 0 {
+		return nil
+	}
+	delete(fileWriters, w.path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func rolledPath(path string, roll int) string {
+	return fmt.Sprintf("%s.%d", path, roll)
+}
@@ -92,7 +92,7 @@ func NewDecoderWithFraming(source *sources.ReplaceableSource, parser parsers.Par
 	var lineHandler LineHandler
 	for _, rule := range source.Config().ProcessingRules {
 		if rule.Type == config.MultiLine {
-			lh := NewMultiLineHandler(outputFn, rule.Regex, config.AggregationTimeout(pkgconfigsetup.Datadog()), maxContentSize, false, tailerInfo, "multi_line")
+			lh := NewMultiLineHandlerFromRule(outputFn, rule, config.AggregationTimeout(pkgconfigsetup.Datadog()), maxContentSize, tailerInfo)
 			syncSourceInfo(source, lh)
 			lineHandler = lh
 		}
@@ -232,6 +232,93 @@ func TestMultiLineHandlerDropsEmptyMessages(t *testing.T) {
 	assert.Equal(t, "1.third line\\nfourth line", string(output.GetContent()))
 }
 
+func newMultiLineHandlerFromRule(t *testing.T, rule *config.ProcessingRule, flushTimeout time.Duration) (*MultiLineHandler, chan *message.Message) {
+	rule.Type = config.MultiLine
+	rule.Name = "multi_line"
+	rules := []*config.ProcessingRule{rule}
+	assert.Nil(t, config.ValidateProcessingRules(rules))
+	assert.Nil(t, config.CompileProcessingRules(rules))
+	outputFn, outputChan := lineHandlerChans()
+	return NewMultiLineHandlerFromRule(outputFn, rule, flushTimeout, 1000, status.NewInfoRegistry()), outputChan
+}
+
+func TestMultiLineHandlerIndentation(t *testing.T) {
+	h, outputChan := newMultiLineHandlerFromRule(t, &config.ProcessingRule{Mode: config.MultiLineModeIndentation}, time.Minute)
+
+	h.process(getDummyMessageWithLF("Exception in thread main"))
+	h.process(getDummyMessageWithLF("\tat com.example.Foo.bar(Foo.java:12)"))
+	h.process(getDummyMessageWithLF(""))
+	h.process(getDummyMessageWithLF("    at com.example.Main.main(Main.java:3)"))
+	h.process(getDummyMessageWithLF("next message"))
+
+	output := <-outputChan
+	assert.Equal(t, "Exception in thread main\\n\tat com.example.Foo.bar(Foo.java:12)\\n\\n    at com.example.Main.main(Main.java:3)", string(output.GetContent()))
+	assert.Equal(t, len("Exception in thread main")+len("\tat com.example.Foo.bar(Foo.java:12)")+len("    at com.example.Main.main(Main.java:3)")+4, output.RawDataLen)
+
+	assertNothingInChannel(t, outputChan)
+	h.flush()
+	output = <-outputChan
+	assert.Equal(t, "next message", string(output.GetContent()))
+}
+
+func TestMultiLineHandlerMarkers(t *testing.T) {
+	h, outputChan := newMultiLineHandlerFromRule(t, &config.ProcessingRule{Mode: config.MultiLineModeMarkers, Pattern: "BEGIN", EndPattern: "END"}, time.Minute)
+
+	h.process(getDummyMessageWithLF("BEGIN transaction"))
+	h.process(getDummyMessageWithLF("update"))
+	assertNothingInChannel(t, outputChan)
+	h.process(getDummyMessageWithLF("END transaction"))
+	assert.Equal(t, "BEGIN transaction\\nupdate\\nEND transaction", string((<-outputChan).GetContent()))
+
+	// lines out of a block are sent on their own
+	h.process(getDummyMessageWithLF("outside"))
+	assert.Equal(t, "outside", string((<-outputChan).GetContent()))
+
+	// a block without an end is closed when flushed
+	h.process(getDummyMessageWithLF("BEGIN"))
+	h.process(getDummyMessageWithLF("never ends"))
+	assertNothingInChannel(t, outputChan)
+	h.flush()
+	assert.Equal(t, "BEGIN\\nnever ends", string((<-outputChan).GetContent()))
+	h.process(getDummyMessageWithLF("outside"))
+	assert.Equal(t, "outside", string((<-outputChan).GetContent()))
+}
+
+func TestMultiLineHandlerMaxLines(t *testing.T) {
+	h, outputChan := newMultiLineHandlerFromRule(t, &config.ProcessingRule{Pattern: "[0-9]+\\.", MaxLines: 2}, time.Minute)
+
+	h.process(getDummyMessageWithLF("1. first"))
+	assertNothingInChannel(t, outputChan)
+	h.process(getDummyMessageWithLF("second"))
+	assert.Equal(t, "1. first\\nsecond", string((<-outputChan).GetContent()))
+
+	h.process(getDummyMessageWithLF("third"))
+	h.process(getDummyMessageWithLF("2. next"))
+	assert.Equal(t, "third", string((<-outputChan).GetContent()))
+	assertNothingInChannel(t, outputChan)
+}
+
+func TestMultiLineHandlerMaxDuration(t *testing.T) {
+	h, outputChan := newMultiLineHandlerFromRule(t, &config.ProcessingRule{Mode: config.MultiLineModeIndentation, MaxDuration: 0.05}, time.Minute)
+
+	// the message is flushed once it reaches its maximum duration, before the flush timeout
+	h.process(getDummyMessageWithLF("first"))
+	select {
+	case <-h.flushChan():
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "the message was not flushed after its maximum duration")
+	}
+	h.flush()
+	assert.Equal(t, "first", string((<-outputChan).GetContent()))
+
+	// lines received past the maximum duration end the message
+	h.process(getDummyMessageWithLF("second"))
+	time.Sleep(60 * time.Millisecond)
+	h.process(getDummyMessageWithLF("  continued"))
+	assert.Equal(t, "second\\n  continued", string((<-outputChan).GetContent()))
+	assertNothingInChannel(t, outputChan)
+}
+
 func TestSingleLineHandlerSendsRawInvalidMessages(t *testing.T) {
 	outputFn, outputChan := lineHandlerChans()
 	h := NewSingleLineHandler(outputFn, 100)
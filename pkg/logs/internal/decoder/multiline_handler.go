@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
 	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	status "github.com/DataDog/datadog-agent/pkg/logs/status/utils"
@@ -21,10 +22,20 @@ import (
 const linesCombinedTelemetryMetricName = "datadog.logs_agent.auto_multi_line_lines_combined"
 
 // MultiLineHandler makes sure that multiple lines from a same content
-// are properly put together.
+// are properly put together. By default, a new message starts at each line
+// matching the new content regular expression. The handler can also be built
+// from a multi_line rule aggregating lines by indentation or between begin
+// and end markers, and bounding the number of lines and the time spent
+// aggregating a message.
 type MultiLineHandler struct {
 	outputFn          func(*message.Message)
 	newContentRe      *regexp.Regexp
+	endContentRe      *regexp.Regexp
+	mode              string
+	inBlock           bool
+	maxLines          int
+	maxDuration       time.Duration
+	firstLineTime     time.Time
 	buffer            *bytes.Buffer
 	flushTimeout      time.Duration
 	flushTimer        *time.Timer
@@ -45,12 +56,15 @@ type MultiLineHandler struct {
 func NewMultiLineHandler(outputFn func(*message.Message), newContentRe *regexp.Regexp, flushTimeout time.Duration, lineLimit int, telemetryEnabled bool, tailerInfo *status.InfoRegistry, multiLineTagValue string) *MultiLineHandler {
 
 	i := status.NewMappedInfo("Multi-Line Pattern")
-	i.SetMessage("Pattern", newContentRe.String())
+	if newContentRe != nil {
+		i.SetMessage("Pattern", newContentRe.String())
+	}
 	tailerInfo.Register(i)
 
 	h := &MultiLineHandler{
 		outputFn:          outputFn,
 		newContentRe:      newContentRe,
+		mode:              config.MultiLineModePattern,
 		buffer:            bytes.NewBuffer(nil),
 		flushTimeout:      flushTimeout,
 		lineLimit:         lineLimit,
@@ -63,6 +77,26 @@ func NewMultiLineHandler(outputFn func(*message.Message), newContentRe *regexp.R
 	return h
 }
 
+// NewMultiLineHandlerFromRule returns a new MultiLineHandler aggregating lines
+// as configured by a compiled multi_line processing rule.
+func NewMultiLineHandlerFromRule(outputFn func(*message.Message), rule *config.ProcessingRule, flushTimeout time.Duration, lineLimit int, tailerInfo *status.InfoRegistry) *MultiLineHandler {
+	h := NewMultiLineHandler(outputFn, rule.Regex, flushTimeout, lineLimit, false, tailerInfo, "multi_line")
+	h.mode = rule.MultiLineMode()
+	h.endContentRe = rule.EndRegex
+	h.maxLines = rule.MaxLines
+	h.maxDuration = time.Duration(rule.MaxDuration * float64(time.Second))
+
+	if h.mode != config.MultiLineModePattern {
+		i := status.NewMappedInfo("Multi-Line Mode")
+		i.SetMessage("Mode", h.mode)
+		if h.endContentRe != nil {
+			i.SetMessage("End pattern", h.endContentRe.String())
+		}
+		tailerInfo.Register(i)
+	}
+	return h
+}
+
 func (h *MultiLineHandler) flushChan() <-chan time.Time {
 	if h.flushTimer != nil && h.buffer.Len() > 0 {
 		return h.flushTimer.C
@@ -71,6 +105,9 @@ func (h *MultiLineHandler) flushChan() <-chan time.Time {
 }
 
 func (h *MultiLineHandler) flush() {
+	// a block which did not end in time is closed, so that the next lines
+	// are not aggregated with it
+	h.inBlock = false
 	h.sendBuffer()
 }
 
@@ -87,7 +124,7 @@ func (h *MultiLineHandler) process(msg *message.Message) {
 		}
 	}
 
-	if h.newContentRe.Match(msg.GetContent()) {
+	if h.startsNewMessage(msg.GetContent()) {
 		h.countInfo.Add(1)
 		// the current line is part of a new message,
 		// send the buffer
@@ -103,6 +140,9 @@ func (h *MultiLineHandler) process(msg *message.Message) {
 	h.timestamp = msg.ParsingExtra.Timestamp
 	h.status = msg.Status
 	h.linesCombined++
+	if h.linesCombined == 1 {
+		h.firstLineTime = time.Now()
+	}
 
 	if h.buffer.Len() > 0 {
 		// the buffer already contains some data which means that
@@ -127,18 +167,70 @@ func (h *MultiLineHandler) process(msg *message.Message) {
 		h.isBufferTruncated = true
 		h.sendBuffer()
 		h.shouldTruncate = true
+	} else if h.endsMessage(msg.GetContent()) || h.reachedBounds() {
+		h.sendBuffer()
 	}
 
 	if h.buffer.Len() > 0 {
 		// since there's buffered data, start the flush timer to flush it
+		flushTimeout := h.flushTimeout
+		if h.maxDuration > 0 {
+			// flush the message once it reaches its maximum duration, even if
+			// no other line is received
+			if remaining := h.maxDuration - time.Since(h.firstLineTime); remaining < flushTimeout {
+				flushTimeout = remaining
+			}
+		}
 		if h.flushTimer == nil {
-			h.flushTimer = time.NewTimer(h.flushTimeout)
+			h.flushTimer = time.NewTimer(flushTimeout)
 		} else {
-			h.flushTimer.Reset(h.flushTimeout)
+			h.flushTimer.Reset(flushTimeout)
 		}
 	}
 }
 
+// startsNewMessage returns true if the line is the first line of a message.
+func (h *MultiLineHandler) startsNewMessage(content []byte) bool {
+	switch h.mode {
+	case config.MultiLineModeIndentation:
+		// empty lines don't start a new message
+		return len(content) > 0 && content[0] != ' ' && content[0] != '\t'
+	case config.MultiLineModeMarkers:
+		if h.newContentRe.Match(content) {
+			h.inBlock = true
+			return true
+		}
+		// the lines out of a block are messages of their own
+		return !h.inBlock
+	default:
+		return h.newContentRe.Match(content)
+	}
+}
+
+// endsMessage returns true if the line is the last line of a message.
+func (h *MultiLineHandler) endsMessage(content []byte) bool {
+	if h.mode != config.MultiLineModeMarkers {
+		return false
+	}
+	if !h.inBlock {
+		return true
+	}
+	if h.endContentRe.Match(content) {
+		h.inBlock = false
+		return true
+	}
+	return false
+}
+
+// reachedBounds returns true if the message reached its maximum number of
+// lines or its maximum duration.
+func (h *MultiLineHandler) reachedBounds() bool {
+	if h.maxLines > 0 && h.linesCombined >= h.maxLines {
+		return true
+	}
+	return h.maxDuration > 0 && time.Since(h.firstLineTime) >= h.maxDuration
+}
+
 // sendBuffer forwards the content stored in the buffer
 // to the output function.
 func (h *MultiLineHandler) sendBuffer() {
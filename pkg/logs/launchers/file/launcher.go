@@ -7,7 +7,9 @@
 package file
 
 import (
+	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/util"
@@ -53,12 +55,21 @@ type Launcher struct {
 	// Feature flag defaulting to false, use `logs_config.validate_pod_container_id`.
 	validatePodContainerID bool
 	scanPeriod             time.Duration
-	flarecontroller        *flareController.FlareController
-	tagger                 tagger.Component
+	// set to true to discover new files as soon as they are created, with the
+	// periodic scan acting as a fallback and reconciliation loop.
+	// Feature flag defaulting to false, use `logs_config.file_event_discovery`.
+	fileEventDiscovery bool
+	watcher            fileWatcher
+	flarecontroller    *flareController.FlareController
+	tagger             tagger.Component
+	// completedArchives holds the compressed size of the archives which have
+	// been read entirely, indexed by scan key, so that they are not read again
+	// before the registry is updated.
+	completedArchives map[string]int64
 }
 
 // NewLauncher returns a new launcher.
-func NewLauncher(tailingLimit int, tailerSleepDuration time.Duration, validatePodContainerID bool, scanPeriod time.Duration, wildcardMode string, flarecontroller *flareController.FlareController, tagger tagger.Component) *Launcher {
+func NewLauncher(tailingLimit int, tailerSleepDuration time.Duration, validatePodContainerID bool, scanPeriod time.Duration, wildcardMode string, fileEventDiscovery bool, flarecontroller *flareController.FlareController, tagger tagger.Component) *Launcher {
 
 	var wildcardStrategy fileprovider.WildcardSelectionStrategy
 	switch wildcardMode {
@@ -81,8 +92,10 @@ func NewLauncher(tailingLimit int, tailerSleepDuration time.Duration, validatePo
 		done:                   make(chan struct{}),
 		validatePodContainerID: validatePodContainerID,
 		scanPeriod:             scanPeriod,
+		fileEventDiscovery:     fileEventDiscovery,
 		flarecontroller:        flarecontroller,
 		tagger:                 tagger,
+		completedArchives:      make(map[string]int64),
 	}
 }
 
@@ -92,6 +105,14 @@ func (s *Launcher) Start(sourceProvider launchers.SourceProvider, pipelineProvid
 	s.addedSources, s.removedSources = sourceProvider.SubscribeForType(config.FileType)
 	s.registry = registry
 	tracker.Add(s.tailers)
+	if s.fileEventDiscovery {
+		watcher, err := newFileWatcher()
+		if err != nil {
+			log.Warnf("Could not set up event-driven file discovery, relying on the periodic scan only: %v", err)
+		} else {
+			s.watcher = watcher
+		}
+	}
 	go s.run()
 }
 
@@ -105,8 +126,15 @@ func (s *Launcher) Stop() {
 // run checks periodically if there are new files to tail and the state of its tailers until stop
 func (s *Launcher) run() {
 	scanTicker := time.NewTicker(s.scanPeriod)
+	var fileEvents <-chan string
+	if s.watcher != nil {
+		fileEvents = s.watcher.Events()
+	}
 	defer func() {
 		scanTicker.Stop()
+		if s.watcher != nil {
+			s.watcher.Close()
+		}
 		close(s.done)
 	}()
 
@@ -116,6 +144,8 @@ func (s *Launcher) run() {
 			s.addSource(source)
 		case source := <-s.removedSources:
 			s.removeSource(source)
+		case path := <-fileEvents:
+			s.handleFileCreated(path)
 		case <-scanTicker.C:
 			s.cleanUpRotatedTailers()
 			// check if there are new files to tail, tailers to stop and tailer to restart because of file rotation
@@ -155,6 +185,8 @@ func (s *Launcher) scan() {
 
 	log.Debugf("Scan - got %d files from FilesToTail and currently tailing %d files\n", len(files), s.tailers.Count())
 
+	s.forgetUnmatchedArchives(files)
+
 	// Pass 1 - Compare 'files' to our current set of tailed files. If any no longer need to be tailed,
 	// stop the tailers.
 	// Defer creation of new tailers until second pass.
@@ -172,6 +204,9 @@ func (s *Launcher) scan() {
 		scanKey := file.GetScanKey()
 		tailer, isTailed := s.tailers.Get(scanKey)
 		if isTailed && tailer.IsFinished() {
+			if size, completed := tailer.CompletedArchiveSize(); completed {
+				s.completedArchives[scanKey] = size
+			}
 			// skip this tailer as it must be stopped
 			continue
 		}
@@ -230,6 +265,8 @@ func (s *Launcher) scan() {
 	}
 	log.Debugf("After starting new tailers, there are %d tailers running. Limit is %d.\n", tailersLen, s.tailingLimit)
 
+	s.updateWatchedDirectories()
+
 	// Check how many file handles the Agent process has open and log a warning if the process is coming close to the OS file limit
 	fileStats, err := util.GetProcessFileStats()
 	if err == nil {
@@ -252,6 +289,7 @@ func (s *Launcher) cleanUpRotatedTailers() {
 func (s *Launcher) addSource(source *sources.LogSource) {
 	s.activeSources = append(s.activeSources, source)
 	s.launchTailers(source)
+	s.updateWatchedDirectories()
 }
 
 // removeSource removes the source from cache.
@@ -265,6 +303,55 @@ func (s *Launcher) removeSource(source *sources.LogSource) {
 	}
 }
 
+// updateWatchedDirectories makes the watcher, if any, watch the directories of
+// the active sources.
+func (s *Launcher) updateWatchedDirectories() {
+	if s.watcher == nil {
+		return
+	}
+	s.watcher.Watch(watchedDirectories(s.activeSources))
+}
+
+// handleFileCreated starts tailing a file as soon as it is created in a watched
+// directory, instead of waiting for the next scan.  Files which are deleted or
+// renamed are left to the next scan, so that rotated tailers can finish reading
+// them; the scan also reconciles the tailers with the wildcard selection
+// strategy and the tailing limit.
+func (s *Launcher) handleFileCreated(path string) {
+	for _, source := range s.activeSources {
+		if !sourceMatchesPath(source, path) {
+			continue
+		}
+		file := tailer.NewFile(path, source, config.ContainsWildcard(source.Config.Path))
+		if fileprovider.ShouldIgnore(s.validatePodContainerID, file) {
+			continue
+		}
+
+		if tailer, isTailed := s.tailers.Get(file.GetScanKey()); isTailed {
+			if tailer.IsFinished() {
+				continue
+			}
+			// the file has been recreated at the same path
+			didRotate, err := tailer.DidRotate()
+			if err != nil {
+				log.Debugf("failed to detect log rotation: %v", err)
+				continue
+			}
+			if didRotate {
+				s.restartTailerAfterFileRotation(tailer, file)
+			}
+			continue
+		}
+
+		if s.tailers.Count() >= s.tailingLimit {
+			log.Debugf("Not tailing %s yet, the limit on the number of tailed files has been reached", path)
+			return
+		}
+		log.Debugf("Discovered new file %s", path)
+		s.startNewTailer(file, config.Beginning)
+	}
+}
+
 // launch launches new tailers for a new source.
 func (s *Launcher) launchTailers(source *sources.LogSource) {
 	// If we're at the limit already, no need to do a 'CollectFiles', just wait for the next 'scan'
@@ -317,6 +404,13 @@ func (s *Launcher) startNewTailer(file *tailer.File, m config.TailingMode) bool
 
 	var offset int64
 	var whence int
+	if tailer.IsArchive() {
+		var skip bool
+		m, skip = s.archiveTailingMode(file, tailer.Identifier(), m)
+		if skip {
+			return false
+		}
+	}
 	mode := s.handleTailingModeChange(tailer.Identifier(), m)
 	offset, whence, err := Position(s.registry, tailer.Identifier(), mode)
 	if err != nil {
@@ -356,6 +450,53 @@ func (s *Launcher) handleTailingModeChange(tailerID string, currentTailingMode c
 	return currentTailingMode
 }
 
+// archiveTailingMode returns the tailing mode to use for a compressed archive, and
+// whether the archive must be skipped.  Archives do not grow, so they are read from
+// their beginning unless the end is forced or they have already been read entirely.
+func (s *Launcher) archiveTailingMode(file *tailer.File, tailerID string, mode config.TailingMode) (config.TailingMode, bool) {
+	if mode == config.ForceEnd {
+		return mode, true
+	}
+	fi, err := os.Stat(file.Path)
+	if err != nil {
+		// let the tailer report the error
+		return mode, false
+	}
+	if size, completed := s.completedArchives[file.GetScanKey()]; completed && size == fi.Size() {
+		return mode, true
+	}
+
+	offset := s.registry.GetOffset(tailerID)
+	switch {
+	case offset == tailer.CompletedArchiveOffset(fi.Size()):
+		log.Debugf("Archive %s has already been read entirely, skipping it", file.Path)
+		return mode, true
+	case strings.HasPrefix(offset, auditor.CompletedArchivePrefix):
+		log.Infof("Archive %s has changed since it was read, reading it again", file.Path)
+		return config.ForceBeginning, false
+	case mode == config.End:
+		return config.Beginning, false
+	}
+	return mode, false
+}
+
+// forgetUnmatchedArchives removes the completed archives which are no longer
+// matched by any source.
+func (s *Launcher) forgetUnmatchedArchives(files []*tailer.File) {
+	if len(s.completedArchives) == 0 {
+		return
+	}
+	matched := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		matched[file.GetScanKey()] = struct{}{}
+	}
+	for scanKey := range s.completedArchives {
+		if _, ok := matched[scanKey]; !ok {
+			delete(s.completedArchives, scanKey)
+		}
+	}
+}
+
 // stopTailer stops the tailer
 func (s *Launcher) stopTailer(tailer *tailer.Tailer) {
 	go tailer.Stop()
@@ -8,6 +8,7 @@
 package file
 
 import (
+	"compress/gzip"
 	"fmt"
 	"os"
 	"testing"
@@ -75,7 +76,7 @@ func (suite *LauncherTestSuite) SetupTest() {
 	suite.source = sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Identifier: suite.configID, Path: suite.testPath})
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	suite.s = NewLauncher(suite.openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, suite.tagger)
+	suite.s = NewLauncher(suite.openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, suite.tagger)
 	suite.s.pipelineProvider = suite.pipelineProvider
 	suite.s.registry = auditor.NewRegistry()
 	suite.s.activeSources = append(suite.s.activeSources, suite.source)
@@ -234,7 +235,7 @@ func TestLauncherScanStartNewTailer(t *testing.T) {
 		openFilesLimit := 2
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditor.NewRegistry()
 		outputChan := launcher.pipelineProvider.NextPipelineChan()
@@ -274,7 +275,7 @@ func TestLauncherWithConcurrentContainerTailer(t *testing.T) {
 	openFilesLimit := 3
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditor.NewRegistry()
 	outputChan := launcher.pipelineProvider.NextPipelineChan()
@@ -323,7 +324,7 @@ func TestLauncherTailFromTheBeginning(t *testing.T) {
 	openFilesLimit := 3
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditor.NewRegistry()
 	outputChan := launcher.pipelineProvider.NextPipelineChan()
@@ -377,7 +378,7 @@ func TestLauncherSetTail(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditor.NewRegistry()
 
@@ -402,7 +403,7 @@ func TestLauncherConfigIdentifier(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditor.NewRegistry()
 
@@ -440,7 +441,7 @@ func TestLauncherScanWithTooManyFiles(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditor.NewRegistry()
 	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
@@ -470,7 +471,7 @@ func TestLauncherUpdatesSourceForExistingTailer(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditor.NewRegistry()
 
@@ -584,7 +585,7 @@ func TestLauncherScanRecentFilesWithNewFiles(t *testing.T) {
 	createLauncher := func() *Launcher {
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_modification_time", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_modification_time", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditor.NewRegistry()
 		logDirectory := fmt.Sprintf("%s/*.log", testDir)
@@ -646,7 +647,7 @@ func TestLauncherFileRotation(t *testing.T) {
 	createLauncher := func() *Launcher {
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditor.NewRegistry()
 		logDirectory := fmt.Sprintf("%s/*.log", testDir)
@@ -712,7 +713,7 @@ func TestLauncherFileDetectionSingleScan(t *testing.T) {
 	createLauncher := func() *Launcher {
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditor.NewRegistry()
 		logDirectory := fmt.Sprintf("%s/*.log", testDir)
@@ -742,6 +743,60 @@ func TestLauncherFileDetectionSingleScan(t *testing.T) {
 	assert.True(t, launcher.tailers.Contains(path("b.log")))
 }
 
+func TestLauncherReadsArchiveOnce(t *testing.T) {
+	testDir := t.TempDir()
+	fakeTagger := taggerMock.SetupFakeTagger(t)
+
+	path := fmt.Sprintf("%s/app.log.1.gz", testDir)
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte("Once\nUpon\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+	assert.Nil(t, file.Close())
+
+	openFilesLimit := 2
+	sleepDuration := 20 * time.Millisecond
+	fc := flareController.NewFlareController()
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
+	launcher.pipelineProvider = mock.NewMockProvider()
+	registry := auditor.NewRegistry()
+	launcher.registry = registry
+	outputChan := launcher.pipelineProvider.NextPipelineChan()
+	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.gz", testDir), Compression: config.AutoCompression})
+	launcher.activeSources = append(launcher.activeSources, source)
+	status.Clear()
+	status.InitStatus(pkgconfigsetup.Datadog(), util.CreateSources([]*sources.LogSource{source}))
+	defer status.Clear()
+
+	// archives are read from the beginning even though the default tailing mode is "end"
+	launcher.scan()
+	assert.Equal(t, 1, launcher.tailers.Count())
+	msg := <-outputChan
+	assert.Equal(t, "Once", string(msg.GetContent()))
+	msg = <-outputChan
+	assert.Equal(t, "Upon", string(msg.GetContent()))
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, filetailer.CompletedArchiveOffset(fi.Size()), msg.Origin.Offset)
+
+	tailer, _ := launcher.tailers.Get(path)
+	assert.Eventually(t, tailer.IsFinished, 5*time.Second, 10*time.Millisecond)
+
+	// the completed archive is not read again
+	launcher.scan()
+	launcher.scan()
+	assert.Equal(t, 0, launcher.tailers.Count())
+
+	// nor after a restart, once the registry knows it has been read entirely
+	launcher.completedArchives = make(map[string]int64)
+	registry.SetOffset(msg.Origin.Offset)
+	launcher.scan()
+	assert.Equal(t, 0, launcher.tailers.Count())
+	launcher.cleanup()
+}
+
 func getScanKey(path string, source *sources.LogSource) string {
 	return filetailer.NewFile(path, source, false).GetScanKey()
 }
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+// fileWatcherEventsBufferSize is the number of events buffered while the launcher
+// is busy. Events exceeding it are dropped, the files are then picked up by the
+// next scan.
+const fileWatcherEventsBufferSize = 1024
+
+// fileWatcher notifies the launcher of the files appearing in the directories
+// of its sources, so that they are tailed without waiting for the next scan.
+type fileWatcher interface {
+	// Events returns the channel receiving the path of each file created or
+	// renamed in a watched directory.
+	Events() <-chan string
+	// Watch replaces the set of watched directories.
+	Watch(dirs []string)
+	// Close stops watching all the directories.
+	Close()
+}
+
+// watchedDirectories returns the directories in which files matching the given
+// sources may appear.
+func watchedDirectories(sources []*sources.LogSource) []string {
+	dirs := make(map[string]struct{})
+	for _, source := range sources {
+		dir := filepath.Dir(source.Config.Path)
+		if !config.ContainsWildcard(dir) {
+			dirs[dir] = struct{}{}
+			continue
+		}
+		matches, err := filepath.Glob(dir)
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			dirs[match] = struct{}{}
+		}
+	}
+
+	watched := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		watched = append(watched, dir)
+	}
+	sort.Strings(watched)
+	return watched
+}
+
+// sourceMatchesPath returns true if the path is matched by the source path
+// pattern and not by one of its exclusion patterns.
+func sourceMatchesPath(source *sources.LogSource, path string) bool {
+	if matched, err := filepath.Match(source.Config.Path, path); err != nil || !matched {
+		return false
+	}
+	for _, excludePattern := range source.Config.ExcludePaths {
+		if excluded, err := filepath.Match(excludePattern, path); err == nil && excluded {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package file
+
+import (
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// inotifyWatcher is a fileWatcher relying on inotify.
+type inotifyWatcher struct {
+	watcher *fsnotify.Watcher
+	dirs    map[string]struct{}
+	events  chan string
+}
+
+// newFileWatcher returns a fileWatcher relying on inotify.
+func newFileWatcher() (fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		watcher: watcher,
+		dirs:    make(map[string]struct{}),
+		events:  make(chan string, fileWatcherEventsBufferSize),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Events returns the channel receiving the path of each file created or
+// renamed in a watched directory.
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+// Watch replaces the set of watched directories.
+func (w *inotifyWatcher) Watch(dirs []string) {
+	watched := make(map[string]struct{}, len(dirs))
+	for _, dir := range dirs {
+		watched[dir] = struct{}{}
+		if _, ok := w.dirs[dir]; ok {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			// the directory may not exist yet, or the inotify watches limit
+			// (fs.inotify.max_user_watches) may have been reached
+			log.Debugf("Could not watch directory %s, its files will be discovered by the periodic scan: %v", dir, err)
+			delete(watched, dir)
+		}
+	}
+	for dir := range w.dirs {
+		if _, ok := watched[dir]; !ok {
+			if err := w.watcher.Remove(dir); err != nil {
+				log.Debugf("Could not stop watching directory %s: %v", dir, err)
+			}
+		}
+	}
+	w.dirs = watched
+}
+
+// Close stops watching all the directories.
+func (w *inotifyWatcher) Close() {
+	if err := w.watcher.Close(); err != nil {
+		log.Debugf("Could not close the file watcher: %v", err)
+	}
+}
+
+func (w *inotifyWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// a renamed file appears as created under its new name, deletions and
+			// renames are left to the periodic scan, so that rotated tailers can
+			// finish reading their file
+			if !event.Has(fsnotify.Create) {
+				continue
+			}
+			select {
+			case w.events <- event.Name:
+			default:
+				log.Debugf("File watcher events buffer is full, %s will be discovered by the periodic scan", event.Name)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("File watcher error, new files will be discovered by the periodic scan: %v", err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	taggerMock "github.com/DataDog/datadog-agent/comp/core/tagger/mock"
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	flareController "github.com/DataDog/datadog-agent/comp/logs/agent/flare"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
+	auditor "github.com/DataDog/datadog-agent/pkg/logs/auditor/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/util"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/logs/status"
+)
+
+func TestLauncherTailsCreatedFilesOnEvent(t *testing.T) {
+	testDir := t.TempDir()
+	fakeTagger := taggerMock.SetupFakeTagger(t)
+
+	openFilesLimit := 2
+	sleepDuration := 20 * time.Millisecond
+	fc := flareController.NewFlareController()
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, time.Hour, "by_name", true, fc, fakeTagger)
+	launcher.pipelineProvider = mock.NewMockProvider()
+	launcher.registry = auditor.NewRegistry()
+	outputChan := launcher.pipelineProvider.NextPipelineChan()
+	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir)})
+	status.Clear()
+	status.InitStatus(pkgconfigsetup.Datadog(), util.CreateSources([]*sources.LogSource{source}))
+	defer status.Clear()
+
+	watcher, err := newFileWatcher()
+	require.NoError(t, err)
+	launcher.watcher = watcher
+	defer watcher.Close()
+	launcher.addSource(source)
+	assert.Equal(t, 0, launcher.tailers.Count())
+
+	path := fmt.Sprintf("%s/new.log", testDir)
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString("hello\n")
+	require.NoError(t, err)
+
+	// files which do not match the source are ignored
+	ignored, err := os.Create(fmt.Sprintf("%s/new.txt", testDir))
+	require.NoError(t, err)
+	ignored.Close()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case created := <-watcher.Events():
+			launcher.handleFileCreated(created)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timeout waiting for the file creation events")
+		}
+	}
+
+	assert.Equal(t, 1, launcher.tailers.Count())
+	assert.True(t, launcher.tailers.Contains(path))
+	msg := <-outputChan
+	assert.Equal(t, "hello", string(msg.GetContent()))
+	launcher.cleanup()
+}
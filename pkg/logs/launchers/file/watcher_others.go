@@ -0,0 +1,16 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !linux
+
+package file
+
+import "errors"
+
+// newFileWatcher returns an error as event-driven file discovery is only
+// supported on Linux.
+func newFileWatcher() (fileWatcher, error) {
+	return nil, errors.New("event-driven file discovery is only supported on Linux")
+}
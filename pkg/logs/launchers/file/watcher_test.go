@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func TestWatchedDirectories(t *testing.T) {
+	testDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(testDir, "a"), 0755))
+	assert.NoError(t, os.Mkdir(filepath.Join(testDir, "b"), 0755))
+
+	logSources := []*sources.LogSource{
+		sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: filepath.Join(testDir, "*.log")}),
+		sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: filepath.Join(testDir, "app.log")}),
+		sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: filepath.Join(testDir, "*", "app.log")}),
+	}
+
+	assert.Equal(t, []string{
+		testDir,
+		filepath.Join(testDir, "a"),
+		filepath.Join(testDir, "b"),
+	}, watchedDirectories(logSources))
+}
+
+func TestSourceMatchesPath(t *testing.T) {
+	source := sources.NewLogSource("", &config.LogsConfig{
+		Type:         config.FileType,
+		Path:         "/var/log/app/*.log",
+		ExcludePaths: []string{"/var/log/app/debug*.log"},
+	})
+
+	assert.True(t, sourceMatchesPath(source, "/var/log/app/server.log"))
+	assert.False(t, sourceMatchesPath(source, "/var/log/app/debug-server.log"))
+	assert.False(t, sourceMatchesPath(source, "/var/log/app/server.log.1"))
+	assert.False(t, sourceMatchesPath(source, "/var/log/other/server.log"))
+}
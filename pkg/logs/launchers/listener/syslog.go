@@ -0,0 +1,253 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/logs/tailers/syslog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/startstop"
+)
+
+// A SyslogListener receives syslog messages over TCP, optionally with TLS, or
+// over UDP, and delegates their parsing to syslog tailers.
+type SyslogListener struct {
+	pipelineProvider pipeline.Provider
+	source           *sources.LogSource
+	idleTimeout      time.Duration
+	frameSize        int
+	listener         net.Listener
+	udpConn          *net.UDPConn
+	tailers          []*syslog.Tailer
+	mu               sync.Mutex
+	stop             chan struct{}
+}
+
+// NewSyslogListener returns an initialized SyslogListener
+func NewSyslogListener(pipelineProvider pipeline.Provider, source *sources.LogSource, frameSize int) *SyslogListener {
+	var idleTimeout time.Duration
+	if source.Config.IdleTimeout != "" {
+		var err error
+		idleTimeout, err = time.ParseDuration(source.Config.IdleTimeout)
+		if err != nil {
+			log.Errorf("Error parsing log's idle_timeout as a duration: %s", err)
+			idleTimeout = 0
+		}
+	}
+
+	return &SyslogListener{
+		pipelineProvider: pipelineProvider,
+		source:           source,
+		idleTimeout:      idleTimeout,
+		frameSize:        frameSize,
+		tailers:          []*syslog.Tailer{},
+		stop:             make(chan struct{}, 1),
+	}
+}
+
+func (l *SyslogListener) protocol() string {
+	if l.source.Config.Protocol == config.UDPType {
+		return config.UDPType
+	}
+	return config.TCPType
+}
+
+// Start starts the listener to receive syslog messages.
+func (l *SyslogListener) Start() {
+	log.Infof("Starting syslog %s forwarder on port %d, with max message size: %d", l.protocol(), l.source.Config.Port, l.frameSize)
+	var err error
+	if l.protocol() == config.UDPType {
+		err = l.startUDPTailer()
+	} else {
+		err = l.startListener()
+	}
+	if err != nil {
+		log.Errorf("Can't start syslog %s forwarder on port %d: %v", l.protocol(), l.source.Config.Port, err)
+		l.source.Status.Error(err)
+		return
+	}
+	l.source.Status.Success()
+	if l.listener != nil {
+		go l.run()
+	}
+}
+
+// Stop stops the listener from accepting new connections and all the active tailers.
+func (l *SyslogListener) Stop() {
+	log.Infof("Stopping syslog %s forwarder on port %d", l.protocol(), l.source.Config.Port)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stop <- struct{}{}
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	stopper := startstop.NewParallelStopper()
+	for _, tailer := range l.tailers {
+		stopper.Add(tailer)
+	}
+	stopper.Stop()
+
+	// At this point all the tailers have been stopped - remove them all from the active tailer list
+	l.tailers = []*syslog.Tailer{}
+}
+
+// run accepts new TCP connections and create a dedicated tailer for each.
+func (l *SyslogListener) run() {
+	defer l.listener.Close()
+	for {
+		select {
+		case <-l.stop:
+			// stop accepting new connections.
+			return
+		default:
+			conn, err := l.listener.Accept()
+			switch {
+			case err != nil && isClosedConnError(err):
+				return
+			case err != nil:
+				// an error occurred, restart the listener.
+				log.Warnf("Can't listen on port %d, restarting a listener: %v", l.source.Config.Port, err)
+				l.listener.Close()
+				err := l.startListener()
+				if err != nil {
+					log.Errorf("Can't restart listener on port %d: %v", l.source.Config.Port, err)
+					l.source.Status.Error(err)
+					return
+				}
+				l.source.Status.Success()
+				continue
+			default:
+				l.startTCPTailer(conn)
+				l.source.Status.Success()
+			}
+		}
+	}
+}
+
+// startListener starts a new TCP listener, wrapped with TLS when a certificate
+// is configured, returns an error if it failed.
+func (l *SyslogListener) startListener() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", l.source.Config.Port))
+	if err != nil {
+		return err
+	}
+	if l.source.Config.TLSCertFile != "" {
+		tlsConfig, err := l.tlsConfig()
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	l.listener = listener
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the listener, client certificates
+// are required and verified when a CA is configured.
+func (l *SyslogListener) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(l.source.Config.TLSCertFile, l.source.Config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if l.source.Config.TLSCAFile != "" {
+		caCert, err := os.ReadFile(l.source.Config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("could not parse the TLS CA %s", l.source.Config.TLSCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// startTCPTailer creates and starts a new tailer that reads the syslog frames of the connection.
+func (l *SyslogListener) startTCPTailer(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	reader := bufio.NewReader(conn)
+	read := func(tailer *syslog.Tailer) ([]byte, string, error) {
+		if l.idleTimeout > 0 {
+			tailer.Conn.SetReadDeadline(time.Now().Add(l.idleTimeout)) //nolint:errcheck
+		}
+		frame, err := syslog.ReadFrame(reader, l.frameSize)
+		if err != nil {
+			l.source.Status.Error(err)
+			go l.stopTailer(tailer)
+			return nil, "", err
+		}
+		return frame, tailer.Conn.RemoteAddr().String(), nil
+	}
+	tailer := syslog.NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), read)
+	l.tailers = append(l.tailers, tailer)
+	tailer.Start()
+}
+
+// stopTailer stops the tailer.
+func (l *SyslogListener) stopTailer(tailer *syslog.Tailer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, t := range l.tailers {
+		if t == tailer {
+			// Only stop the tailer if it has not already been stopped
+			tailer.Stop()
+			l.tailers = append(l.tailers[:i], l.tailers[i+1:]...)
+			break
+		}
+	}
+}
+
+// startUDPTailer opens a UDP connection and starts a tailer reading one
+// syslog message per datagram.
+func (l *SyslogListener) startUDPTailer() error {
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", l.source.Config.Port))
+	if err != nil {
+		return err
+	}
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	l.udpConn = udpConn
+
+	read := func(_ *syslog.Tailer) ([]byte, string, error) {
+		frame := make([]byte, l.frameSize)
+		n, addr, err := udpConn.ReadFromUDP(frame)
+		switch {
+		case err != nil && isClosedConnError(err):
+			return nil, "", io.EOF
+		case err != nil:
+			// datagrams are independent, keep reading the next ones
+			log.Warnf("Couldn't read syslog datagram on port %d: %v", l.source.Config.Port, err)
+			return nil, "", nil
+		}
+		return frame[:n], addr.IP.String(), nil
+	}
+	tailer := syslog.NewTailer(l.source, udpConn, l.pipelineProvider.NextPipelineChan(), read)
+	l.tailers = append(l.tailers, tailer)
+	tailer.Start()
+	return nil
+}
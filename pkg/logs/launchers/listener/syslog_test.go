@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func TestSyslogTCPShouldReceiveFramedMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewSyslogListener(pp, sources.NewLogSource("", &config.LogsConfig{Type: config.SyslogType, Port: tcpTestPort}), 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", listener.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	octetCounted := "<11>1 - host app - - - multi\nline"
+	fmt.Fprintf(conn, "%d %s<14>Oct 11 22:14:15 host app[12]: hello world\n", len(octetCounted), octetCounted)
+
+	var msg *message.Message
+	msg = <-msgChan
+	assert.Equal(t, "multi\nline", string(msg.GetContent()))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	msg = <-msgChan
+	assert.Equal(t, "hello world", string(msg.GetContent()))
+	assert.Equal(t, "app", msg.Origin.Service())
+}
+
+func TestSyslogUDPShouldReceiveMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewSyslogListener(pp, sources.NewLogSource("", &config.LogsConfig{Type: config.SyslogType, Port: udpTestPort, Protocol: config.UDPType}), 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("udp", listener.udpConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "<12>1 2003-10-11T22:14:15.003Z host app - - - disk almost full")
+	msg := <-msgChan
+	assert.Equal(t, "disk almost full", string(msg.GetContent()))
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+}
+
+func TestSyslogTLSShouldReceiveMessages(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewSyslogListener(pp, sources.NewLogSource("", &config.LogsConfig{
+		Type:        config.SyslogType,
+		Port:        tcpTestPort,
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	}), 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := tls.Dial("tcp", listener.listener.Addr().String(), &tls.Config{InsecureSkipVerify: true}) //nolint:gosec // self-signed test certificate
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "<14>1 - host app - - - over tls\n")
+	msg := <-msgChan
+	assert.Equal(t, "over tls", string(msg.GetContent()))
+}
+
+func TestSyslogTLSWithInvalidCertificate(t *testing.T) {
+	source := sources.NewLogSource("", &config.LogsConfig{
+		Type:        config.SyslogType,
+		Port:        tcpTestPort,
+		TLSCertFile: filepath.Join(t.TempDir(), "missing.pem"),
+		TLSKeyFile:  filepath.Join(t.TempDir(), "missing.key"),
+	})
+	listener := NewSyslogListener(mock.NewMockProvider(), source, 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	assert.True(t, source.Status.IsError())
+	assert.Nil(t, listener.listener)
+}
+
+// writeTestCertificate writes a self-signed certificate and its key, and returns their paths.
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
@@ -41,6 +41,11 @@ var (
 	// TlmLogsDropped is the total number of logs dropped per Destination
 	TlmLogsDropped = telemetry.NewCounter("logs", "dropped",
 		[]string{"destination"}, "Total number of logs dropped per Destination")
+	// LogsDroppedByProcessingRules is the total number of logs dropped by the sample and rate_limit processing rules, per rule type
+	LogsDroppedByProcessingRules = expvar.Map{}
+	// TlmLogsDroppedByProcessingRules is the total number of logs dropped by the sample and rate_limit processing rules
+	TlmLogsDroppedByProcessingRules = telemetry.NewCounter("logs", "dropped_by_processing_rule",
+		[]string{"rule_type", "source"}, "Total number of logs dropped by the sample and rate_limit processing rules")
 	// BytesSent is the total number of sent bytes before encoding if any
 	BytesSent = expvar.Int{}
 	// TlmBytesSent is the total number of sent bytes before encoding if any
@@ -100,6 +105,7 @@ func init() {
 	LogsExpvars.Set("LogsSent", &LogsSent)
 	LogsExpvars.Set("DestinationErrors", &DestinationErrors)
 	LogsExpvars.Set("DestinationLogsDropped", &DestinationLogsDropped)
+	LogsExpvars.Set("LogsDroppedByProcessingRules", &LogsDroppedByProcessingRules)
 	LogsExpvars.Set("BytesSent", &BytesSent)
 	LogsExpvars.Set("RetryCount", &RetryCount)
 	LogsExpvars.Set("RetryTimeSpent", &RetryTimeSpent)
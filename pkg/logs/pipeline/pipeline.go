@@ -17,6 +17,8 @@ import (
 	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/http"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/kafka"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/local"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/tcp"
 	"github.com/DataDog/datadog-agent/pkg/logs/diagnostic"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
@@ -48,7 +50,8 @@ func NewPipeline(outputChan chan *message.Payload,
 	pipelineID int,
 	status statusinterface.Status,
 	hostname hostnameinterface.Component,
-	cfg pkgconfigmodel.Reader) *Pipeline {
+	cfg pkgconfigmodel.Reader,
+	metricGenerator *processor.MetricGenerator) *Pipeline {
 
 	var senderDoneChan chan *sync.WaitGroup
 	var flushWg *sync.WaitGroup
@@ -83,7 +86,7 @@ func NewPipeline(outputChan chan *message.Payload,
 	inputChan := make(chan *message.Message, pkgconfigsetup.Datadog().GetInt("logs_config.message_channel_size"))
 
 	processor := processor.New(cfg, inputChan, strategyInput, processingRules,
-		encoder, diagnosticMessageReceiver, hostname, pipelineMonitor)
+		encoder, diagnosticMessageReceiver, hostname, pipelineMonitor, metricGenerator)
 
 	return &Pipeline{
 		InputChan:       inputChan,
@@ -129,7 +132,11 @@ func getDestinations(endpoints *config.Endpoints, destinationsContext *client.De
 	if endpoints.UseHTTP {
 		for i, endpoint := range endpoints.GetReliableEndpoints() {
 			destMeta := client.NewDestinationMetadata("logs", pipelineMonitor.ID(), "reliable", strconv.Itoa(i))
-			if serverless {
+			if endpoint.IsLocal() {
+				reliable = append(reliable, local.NewDestination(endpoint, senderDoneChan))
+			} else if endpoint.Type == config.KafkaDestination {
+				reliable = append(reliable, kafka.NewDestination(endpoint, destinationsContext, !serverless, senderDoneChan))
+			} else if serverless {
 				reliable = append(reliable, http.NewSyncDestination(endpoint, http.JSONContentType, destinationsContext, senderDoneChan, destMeta, cfg))
 			} else {
 				reliable = append(reliable, http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend, true, destMeta, cfg, pipelineMonitor))
@@ -137,7 +144,11 @@ func getDestinations(endpoints *config.Endpoints, destinationsContext *client.De
 		}
 		for i, endpoint := range endpoints.GetUnReliableEndpoints() {
 			destMeta := client.NewDestinationMetadata("logs", pipelineMonitor.ID(), "unreliable", strconv.Itoa(i))
-			if serverless {
+			if endpoint.IsLocal() {
+				additionals = append(additionals, local.NewDestination(endpoint, senderDoneChan))
+			} else if endpoint.Type == config.KafkaDestination {
+				additionals = append(additionals, kafka.NewDestination(endpoint, destinationsContext, false, senderDoneChan))
+			} else if serverless {
 				additionals = append(additionals, http.NewSyncDestination(endpoint, http.JSONContentType, destinationsContext, senderDoneChan, destMeta, cfg))
 			} else {
 				additionals = append(additionals, http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend, false, destMeta, cfg, pipelineMonitor))
@@ -146,9 +157,25 @@ func getDestinations(endpoints *config.Endpoints, destinationsContext *client.De
 		return client.NewDestinations(reliable, additionals)
 	}
 	for _, endpoint := range endpoints.GetReliableEndpoints() {
+		if endpoint.IsLocal() {
+			reliable = append(reliable, local.NewDestination(endpoint, senderDoneChan))
+			continue
+		}
+		if endpoint.Type == config.KafkaDestination {
+			reliable = append(reliable, kafka.NewDestination(endpoint, destinationsContext, !serverless, senderDoneChan))
+			continue
+		}
 		reliable = append(reliable, tcp.NewDestination(endpoint, endpoints.UseProto, destinationsContext, !serverless, status))
 	}
 	for _, endpoint := range endpoints.GetUnReliableEndpoints() {
+		if endpoint.IsLocal() {
+			additionals = append(additionals, local.NewDestination(endpoint, senderDoneChan))
+			continue
+		}
+		if endpoint.Type == config.KafkaDestination {
+			additionals = append(additionals, kafka.NewDestination(endpoint, destinationsContext, false, senderDoneChan))
+			continue
+		}
 		additionals = append(additionals, tcp.NewDestination(endpoint, endpoints.UseProto, destinationsContext, false, status))
 	}
 
@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// metricsCommitInterval is the interval at which the metrics generated from logs
+// are committed to the aggregator.
+const metricsCommitInterval = time.Second
+
+// MetricSender sends the metrics generated from logs, it is implemented by a
+// sender of the aggregator dedicated to the MetricGenerator.
+type MetricSender interface {
+	Count(metric string, value float64, hostname string, tags []string)
+	Distribution(metric string, value float64, hostname string, tags []string)
+	Commit()
+}
+
+// A MetricGenerator generates counts and distributions from the logs matching
+// metric rules, either global or defined by their source. It is shared by the
+// processors of all the pipelines, and is the only one committing its sender.
+type MetricGenerator struct {
+	sender  MetricSender
+	rules   []*config.MetricRule
+	pending atomic.Bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMetricGenerator returns a MetricGenerator applying the global rules, along
+// with the rules of the source of each message.
+func NewMetricGenerator(sender MetricSender, rules []*config.MetricRule) *MetricGenerator {
+	return &MetricGenerator{
+		sender: sender,
+		rules:  rules,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start starts committing periodically the metrics generated by the processors.
+func (g *MetricGenerator) Start() {
+	go func() {
+		defer close(g.done)
+		ticker := time.NewTicker(metricsCommitInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.Commit()
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic commits, and commits the metrics generated since the
+// last one. It must be called once the processors are stopped.
+func (g *MetricGenerator) Stop() {
+	close(g.stop)
+	<-g.done
+	g.Commit()
+}
+
+// process generates the metrics of all the rules matching the content, it
+// returns true if at least one metric has been sent.
+func (g *MetricGenerator) process(msg *message.Message, content []byte) bool {
+	var tags []string
+	sent := false
+	for _, rules := range [][]*config.MetricRule{g.rules, msg.Origin.LogSource.Config.MetricRules} {
+		for _, rule := range rules {
+			match := rule.Regex.FindSubmatch(content)
+			if match == nil {
+				continue
+			}
+
+			value := 1.0
+			if rule.ValueIndex >= 0 {
+				var err error
+				if value, err = strconv.ParseFloat(string(match[rule.ValueIndex]), 64); err != nil {
+					log.Debugf("Can't generate metric %s, %q is not a number", rule.Name, match[rule.ValueIndex])
+					continue
+				}
+			}
+
+			if tags == nil {
+				tags = metricSourceTags(msg)
+			}
+			metricTags := make([]string, 0, len(tags)+len(rule.Tags)+len(rule.TagIndexes))
+			metricTags = append(metricTags, tags...)
+			metricTags = append(metricTags, rule.Tags...)
+			for i, index := range rule.TagIndexes {
+				if len(match[index]) > 0 {
+					metricTags = append(metricTags, rule.TagGroups[i]+":"+string(match[index]))
+				}
+			}
+
+			switch rule.Type {
+			case config.CountMetric:
+				g.sender.Count(rule.Name, value, msg.Hostname, metricTags)
+			case config.DistributionMetric:
+				g.sender.Distribution(rule.Name, value, msg.Hostname, metricTags)
+			}
+			sent = true
+		}
+	}
+	if sent {
+		g.pending.Store(true)
+	}
+	return sent
+}
+
+// Commit commits the metrics sent since the last commit, if any.
+func (g *MetricGenerator) Commit() {
+	if g.pending.Swap(false) {
+		g.sender.Commit()
+	}
+}
+
+// metricSourceTags returns the tags of the source of the message, along with its
+// source and service.
+func metricSourceTags(msg *message.Message) []string {
+	// copy the tags to not alter the ones of the origin
+	tags := append([]string{}, msg.Origin.Tags(nil)...)
+	if source := msg.Origin.Source(); source != "" {
+		tags = append(tags, "source:"+source)
+	}
+	if service := msg.Origin.Service(); service != "" {
+		tags = append(tags, "service:"+service)
+	}
+	return tags
+}
@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/diagnostic"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+type sentMetric struct {
+	metricType string
+	name       string
+	value      float64
+	hostname   string
+	tags       []string
+}
+
+type fakeMetricSender struct {
+	metrics []sentMetric
+	commits int
+}
+
+func (s *fakeMetricSender) Count(metric string, value float64, hostname string, tags []string) {
+	s.metrics = append(s.metrics, sentMetric{config.CountMetric, metric, value, hostname, tags})
+}
+
+func (s *fakeMetricSender) Distribution(metric string, value float64, hostname string, tags []string) {
+	s.metrics = append(s.metrics, sentMetric{config.DistributionMetric, metric, value, hostname, tags})
+}
+
+func (s *fakeMetricSender) Commit() {
+	s.commits++
+}
+
+func newMetricRules(t *testing.T, rules ...*config.MetricRule) []*config.MetricRule {
+	require.NoError(t, config.ValidateMetricRules(rules))
+	require.NoError(t, config.CompileMetricRules(rules))
+	return rules
+}
+
+func TestMetricGeneratorCount(t *testing.T) {
+	sender := &fakeMetricSender{}
+	generator := NewMetricGenerator(sender, newMetricRules(t,
+		&config.MetricRule{Name: "app.logs.errors", Type: config.CountMetric, Pattern: `level=(?P<level>error|critical)`, TagGroups: []string{"level"}, Tags: []string{"team:storage"}},
+	))
+	source := sources.NewLogSource("", &config.LogsConfig{Source: "app", Service: "api", Tags: []string{"env:prod"}})
+
+	assert.False(t, generator.process(newMessage([]byte("level=info all good"), source, ""), []byte("level=info all good")))
+	assert.True(t, generator.process(newMessage([]byte("level=error disk full"), source, ""), []byte("level=error disk full")))
+
+	require.Len(t, sender.metrics, 1)
+	assert.Equal(t, sentMetric{
+		metricType: config.CountMetric,
+		name:       "app.logs.errors",
+		value:      1,
+		tags:       []string{"env:prod", "source:app", "service:api", "team:storage", "level:error"},
+	}, sender.metrics[0])
+}
+
+func TestMetricGeneratorDistributionFromSourceRules(t *testing.T) {
+	sender := &fakeMetricSender{}
+	generator := NewMetricGenerator(sender, nil)
+	source := sources.NewLogSource("", &config.LogsConfig{MetricRules: newMetricRules(t,
+		&config.MetricRule{Name: "app.request.duration", Type: config.DistributionMetric, Pattern: `(?P<path>/\S*)? took (?P<duration>\S+)ms`, ValueGroup: "duration", TagGroups: []string{"path"}},
+		&config.MetricRule{Name: "app.request.bytes", Type: config.CountMetric, Pattern: `sent (?P<bytes>\d+) bytes`, ValueGroup: "bytes"},
+	)})
+
+	msg := newMessage(nil, source, "")
+	msg.Hostname = "web-1"
+	assert.True(t, generator.process(msg, []byte("GET /users took 12.5ms, sent 512 bytes")))
+	// the value is not a number, only the count is sent
+	assert.True(t, generator.process(msg, []byte("GET took fast ms, sent 64 bytes")))
+
+	assert.Equal(t, []sentMetric{
+		{config.DistributionMetric, "app.request.duration", 12.5, "web-1", []string{"path:/users"}},
+		{config.CountMetric, "app.request.bytes", 512, "web-1", []string{}},
+		{config.CountMetric, "app.request.bytes", 64, "web-1", []string{}},
+	}, sender.metrics)
+}
+
+func TestMetricsAreGeneratedFromExcludedMessages(t *testing.T) {
+	sender := &fakeMetricSender{}
+	generator := NewMetricGenerator(sender, newMetricRules(t,
+		&config.MetricRule{Name: "app.requests", Type: config.CountMetric, Pattern: "GET"},
+	))
+	pm := metrics.NewNoopPipelineMonitor("")
+	p := &Processor{
+		processingRules:           []*config.ProcessingRule{newProcessingRule(config.ExcludeAtMatch, "", "healthcheck")},
+		encoder:                   RawEncoder,
+		outputChan:                make(chan *message.Message, 2),
+		diagnosticMessageReceiver: &diagnostic.NoopMessageReceiver{},
+		pipelineMonitor:           pm,
+		utilization:               pm.MakeUtilizationMonitor("processor"),
+		metricGenerator:           generator,
+	}
+	source := sources.NewLogSource("", &config.LogsConfig{})
+
+	p.processMessage(newMessage([]byte("GET /healthcheck"), source, ""))
+	p.processMessage(newMessage([]byte("GET /users"), source, ""))
+	assert.Len(t, p.outputChan, 1)
+	assert.Len(t, sender.metrics, 2)
+
+	generator.Commit()
+	generator.Commit()
+	assert.Equal(t, 1, sender.commits)
+}
+
+func TestMetricGeneratorCommitsOnStop(t *testing.T) {
+	sender := &fakeMetricSender{}
+	generator := NewMetricGenerator(sender, newMetricRules(t,
+		&config.MetricRule{Name: "app.requests", Type: config.CountMetric, Pattern: "GET"},
+	))
+	source := sources.NewLogSource("", &config.LogsConfig{})
+
+	generator.Start()
+	generator.process(newMessage(nil, source, ""), []byte("GET /users"))
+	generator.Stop()
+	assert.Equal(t, 1, sender.commits)
+}
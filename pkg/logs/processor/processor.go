@@ -8,6 +8,7 @@ package processor
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/DataDog/datadog-agent/comp/core/hostname/hostnameinterface"
 	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
@@ -40,6 +41,9 @@ type Processor struct {
 
 	sds sdsProcessor
 
+	// metricGenerator generates metrics from the messages, when metric rules are configured
+	metricGenerator *MetricGenerator
+
 	// Telemetry
 	pipelineMonitor metrics.PipelineMonitor
 	utilization     metrics.UtilizationMonitor
@@ -61,7 +65,7 @@ type sdsProcessor struct {
 // New returns an initialized Processor.
 func New(cfg pkgconfigmodel.Reader, inputChan, outputChan chan *message.Message, processingRules []*config.ProcessingRule,
 	encoder Encoder, diagnosticMessageReceiver diagnostic.MessageReceiver, hostname hostnameinterface.Component,
-	pipelineMonitor metrics.PipelineMonitor) *Processor {
+	pipelineMonitor metrics.PipelineMonitor, metricGenerator *MetricGenerator) *Processor {
 
 	waitForSDSConfig := sds.ShouldBufferUntilSDSConfiguration(cfg)
 	maxBufferSize := sds.WaitForConfigurationBufferMaxSize(cfg)
@@ -77,6 +81,7 @@ func New(cfg pkgconfigmodel.Reader, inputChan, outputChan chan *message.Message,
 		hostname:                  hostname,
 		pipelineMonitor:           pipelineMonitor,
 		utilization:               pipelineMonitor.MakeUtilizationMonitor("processor"),
+		metricGenerator:           metricGenerator,
 
 		sds: sdsProcessor{
 			// will immediately starts buffering if it has been configured as so
@@ -227,6 +232,12 @@ func (p *Processor) processMessage(msg *message.Message) {
 	metrics.LogsDecoded.Add(1)
 	metrics.TlmLogsDecoded.Inc()
 
+	// generate the metrics before the processing rules, so that the logs
+	// excluded or sampled out are accounted for
+	if p.metricGenerator != nil {
+		p.metricGenerator.process(msg, msg.GetContent())
+	}
+
 	if toSend := p.applyRedactingRules(msg); toSend {
 		metrics.LogsProcessed.Add(1)
 		metrics.TlmLogsProcessed.Inc()
@@ -263,8 +274,39 @@ func (p *Processor) applyRedactingRules(msg *message.Message) bool {
 	// Use the internal scrubbing implementation of the Agent
 	// ---------------------------
 
+	// fields holds the content parsed for the structured rules, it is serialized
+	// back into content before applying a raw content rule
+	var fields structuredFields
+	var fieldsFormat string
+	var fieldsModified bool
+	flushFields := func() {
+		if fields != nil && fieldsModified {
+			if marshaled, err := fields.marshal(); err != nil {
+				log.Debug("can't serialize the structured content of the msg", err)
+			} else {
+				content = marshaled
+			}
+		}
+		fields, fieldsFormat, fieldsModified = nil, "", false
+	}
+
 	rules := append(p.processingRules, msg.Origin.LogSource.Config.ProcessingRules...)
 	for _, rule := range rules {
+		if rule.IsStructured() {
+			if format := rule.StructuredFormat(); fields == nil || fieldsFormat != format {
+				flushFields()
+				var ok bool
+				if fields, ok = parseStructuredFields(content, format); !ok {
+					// the message is not in the format of the rule, ignore the rule
+					continue
+				}
+				fieldsFormat = format
+			}
+			fieldsModified = applyStructuredRule(rule, fields, msg) || fieldsModified
+			continue
+		}
+		flushFields()
+
 		switch rule.Type {
 		case config.ExcludeAtMatch:
 			// if this message matches, we ignore it
@@ -278,8 +320,19 @@ func (p *Processor) applyRedactingRules(msg *message.Message) bool {
 			}
 		case config.MaskSequences:
 			content = rule.Regex.ReplaceAll(content, rule.Placeholder)
+		case config.Sample:
+			if !sampleMessage(rule, content) {
+				recordDroppedByRule(rule, msg)
+				return false
+			}
+		case config.RateLimit:
+			if !sourceRateLimiters.allow(rule, msg.Origin.LogSource, content, time.Now()) {
+				recordDroppedByRule(rule, msg)
+				return false
+			}
 		}
 	}
+	flushFields()
 
 	// Use the SDS implementation
 	// --------------------------
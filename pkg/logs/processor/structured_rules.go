@@ -0,0 +1,357 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// structuredFields is the content of a message parsed so that structured
+// processing rules can apply on its keys.
+type structuredFields interface {
+	// get returns the value at the given path.
+	get(path []string) (interface{}, bool)
+	// set sets the value at the given path, creating the intermediate objects
+	// if needed, and returns false if it could not be set.
+	set(path []string, value interface{}) bool
+	// remove removes the value at the given path and returns it.
+	remove(path []string) (interface{}, bool)
+	// marshal serializes the fields back into a message content.
+	marshal() ([]byte, error)
+}
+
+// parseStructuredFields parses the content with the given format, and returns
+// false if the content is not in this format.
+func parseStructuredFields(content []byte, format string) (structuredFields, bool) {
+	switch format {
+	case config.LogfmtFormat:
+		return parseLogfmtFields(content)
+	default:
+		return parseJSONFields(content)
+	}
+}
+
+// applyStructuredRule applies a structured processing rule on the parsed content
+// of the message, and returns true if the content has been modified.
+func applyStructuredRule(rule *config.ProcessingRule, fields structuredFields, msg *message.Message) bool {
+	modified := false
+	switch rule.Type {
+	case config.RemoveKeys:
+		for _, path := range rule.KeyPaths {
+			if _, ok := fields.remove(path); ok {
+				modified = true
+			}
+		}
+	case config.RenameKey:
+		if value, ok := fields.remove(rule.KeyPaths[0]); ok {
+			if fields.set(rule.TargetPath, value) {
+				modified = true
+			} else {
+				// the target can't be set, e.g. its parent is not an object, so
+				// the value is put back at the removed key
+				fields.set(rule.KeyPaths[0], value)
+			}
+		}
+	case config.HashKeys:
+		for _, path := range rule.KeyPaths {
+			if value, ok := fields.get(path); ok {
+				sum := sha256.Sum256([]byte(fieldToString(value)))
+				modified = fields.set(path, hex.EncodeToString(sum[:])) || modified
+			}
+		}
+	case config.MaskKeys:
+		for _, path := range rule.KeyPaths {
+			if value, ok := fields.get(path); ok {
+				masked := string(rule.Placeholder)
+				if rule.Regex != nil {
+					masked = rule.Regex.ReplaceAllString(fieldToString(value), masked)
+				}
+				modified = fields.set(path, masked) || modified
+			}
+		}
+	case config.TagFromKey:
+		if value, ok := fields.get(rule.KeyPaths[0]); ok {
+			msg.ProcessingTags = append(msg.ProcessingTags, rule.Target+":"+fieldToString(value))
+		}
+	case config.StatusFromKey:
+		if value, ok := fields.get(rule.KeyPaths[0]); ok {
+			if status, ok := normalizeStatus(fieldToString(value)); ok {
+				msg.Status = status
+			}
+		}
+	case config.ServiceFromKey:
+		// the service of the log source configuration, if any, takes precedence
+		if value, ok := fields.get(rule.KeyPaths[0]); ok && msg.Origin != nil {
+			msg.Origin.SetService(fieldToString(value))
+		}
+	}
+	return modified
+}
+
+// fieldToString returns the string representation of a field value.
+func fieldToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// statusAliases maps the common spellings of log levels to message statuses.
+var statusAliases = map[string]string{
+	"emerg":         message.StatusEmergency,
+	"fatal":         message.StatusCritical,
+	"crit":          message.StatusCritical,
+	"err":           message.StatusError,
+	"warning":       message.StatusWarning,
+	"information":   message.StatusInfo,
+	"informational": message.StatusInfo,
+	"trace":         message.StatusDebug,
+}
+
+// normalizeStatus returns the message status matching the given log level.
+func normalizeStatus(level string) (string, bool) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch level {
+	case message.StatusEmergency, message.StatusAlert, message.StatusCritical, message.StatusError,
+		message.StatusWarning, message.StatusNotice, message.StatusInfo, message.StatusDebug:
+		return level, true
+	}
+	status, ok := statusAliases[level]
+	return status, ok
+}
+
+// jsonFields are the fields of a message containing a JSON object.
+type jsonFields struct {
+	root map[string]interface{}
+}
+
+func parseJSONFields(content []byte) (structuredFields, bool) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || content[0] != '{' {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// keep numbers as they are written in the message
+	decoder.UseNumber()
+	var root map[string]interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, false
+	}
+	return &jsonFields{root: root}, true
+}
+
+func (f *jsonFields) parent(path []string, create bool) (map[string]interface{}, bool) {
+	current := f.root
+	for _, key := range path[:len(path)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			if _, exists := current[key]; exists || !create {
+				return nil, false
+			}
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	return current, true
+}
+
+func (f *jsonFields) get(path []string) (interface{}, bool) {
+	parent, ok := f.parent(path, false)
+	if !ok {
+		return nil, false
+	}
+	value, ok := parent[path[len(path)-1]]
+	return value, ok
+}
+
+func (f *jsonFields) set(path []string, value interface{}) bool {
+	parent, ok := f.parent(path, true)
+	if !ok {
+		return false
+	}
+	parent[path[len(path)-1]] = value
+	return true
+}
+
+func (f *jsonFields) remove(path []string) (interface{}, bool) {
+	parent, ok := f.parent(path, false)
+	if !ok {
+		return nil, false
+	}
+	key := path[len(path)-1]
+	value, ok := parent[key]
+	if ok {
+		delete(parent, key)
+	}
+	return value, ok
+}
+
+func (f *jsonFields) marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// do not escape the HTML characters, they were not escaped in the original message
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(f.root); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// logfmtPair is a key/value pair of a logfmt message.
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+// logfmtFields are the fields of a logfmt message, kept in their original order.
+type logfmtFields struct {
+	pairs []logfmtPair
+}
+
+var errInvalidLogfmt = errors.New("invalid logfmt message")
+
+// parseLogfmtFields parses a message made of key=value pairs separated by
+// spaces, where values may be double-quoted.
+func parseLogfmtFields(content []byte) (structuredFields, bool) {
+	pairs, err := parseLogfmtPairs(string(content))
+	if err != nil || len(pairs) == 0 {
+		return nil, false
+	}
+	return &logfmtFields{pairs: pairs}, true
+}
+
+func parseLogfmtPairs(s string) ([]logfmtPair, error) {
+	var pairs []logfmtPair
+	i := 0
+	for {
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i == len(s) {
+			return pairs, nil
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' && s[i] != '"' {
+			i++
+		}
+		if i == start || i == len(s) || s[i] != '=' {
+			// every token must be a key=value pair
+			return nil, errInvalidLogfmt
+		}
+		key := s[start:i]
+		i++
+
+		var value string
+		if i < len(s) && s[i] == '"' {
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, errInvalidLogfmt
+			}
+			unquoted, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, errInvalidLogfmt
+			}
+			value = unquoted
+			i = end + 1
+		} else {
+			start = i
+			for i < len(s) && s[i] != ' ' {
+				i++
+			}
+			value = s[start:i]
+		}
+		pairs = append(pairs, logfmtPair{key: key, value: value})
+	}
+}
+
+func (f *logfmtFields) index(path []string) int {
+	for i, pair := range f.pairs {
+		if pair.key == path[0] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *logfmtFields) get(path []string) (interface{}, bool) {
+	if i := f.index(path); i >= 0 {
+		return f.pairs[i].value, true
+	}
+	return nil, false
+}
+
+func (f *logfmtFields) set(path []string, value interface{}) bool {
+	if i := f.index(path); i >= 0 {
+		f.pairs[i].value = fieldToString(value)
+		return true
+	}
+	f.pairs = append(f.pairs, logfmtPair{key: path[0], value: fieldToString(value)})
+	return true
+}
+
+func (f *logfmtFields) remove(path []string) (interface{}, bool) {
+	i := f.index(path)
+	if i < 0 {
+		return nil, false
+	}
+	value := f.pairs[i].value
+	f.pairs = append(f.pairs[:i], f.pairs[i+1:]...)
+	return value, true
+}
+
+func (f *logfmtFields) marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	for i, pair := range f.pairs {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(pair.key)
+		buf.WriteByte('=')
+		if logfmtNeedsQuoting(pair.value) {
+			buf.WriteString(strconv.Quote(pair.value))
+		} else {
+			buf.WriteString(pair.value)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func logfmtNeedsQuoting(value string) bool {
+	return strings.IndexFunc(value, func(r rune) bool {
+		return r == ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r)
+	}) >= 0
+}
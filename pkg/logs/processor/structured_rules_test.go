@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func newStructuredSource(t *testing.T, rules ...*config.ProcessingRule) *sources.LogSource {
+	for _, rule := range rules {
+		rule.Name = "test"
+	}
+	require.NoError(t, config.ValidateProcessingRules(rules))
+	require.NoError(t, config.CompileProcessingRules(rules))
+	return sources.NewLogSource("", &config.LogsConfig{ProcessingRules: rules})
+}
+
+func TestStructuredRulesJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []*config.ProcessingRule
+		input  string
+		output string
+	}{
+		{
+			name:   "remove keys",
+			rules:  []*config.ProcessingRule{{Type: config.RemoveKeys, Paths: []string{"password", "user.token", "missing.key"}}},
+			input:  `{"msg":"login","password":"hunter2","user":{"name":"bob","token":"abc"}}`,
+			output: `{"msg":"login","user":{"name":"bob"}}`,
+		},
+		{
+			name:   "rename key",
+			rules:  []*config.ProcessingRule{{Type: config.RenameKey, Paths: []string{"user.name"}, Target: "usr.login"}},
+			input:  `{"user":{"name":"bob"}}`,
+			output: `{"user":{},"usr":{"login":"bob"}}`,
+		},
+		{
+			name: "rename key to a target which can't be set",
+			rules: []*config.ProcessingRule{
+				{Type: config.RenameKey, Paths: []string{"user.name"}, Target: "msg.login"},
+				{Type: config.RemoveKeys, Paths: []string{"msg"}},
+			},
+			input:  `{"msg":"login","user":{"name":"bob"}}`,
+			output: `{"user":{"name":"bob"}}`,
+		},
+		{
+			name:   "hash keys",
+			rules:  []*config.ProcessingRule{{Type: config.HashKeys, Paths: []string{"email"}}},
+			input:  `{"email":"bob@example.com"}`,
+			output: `{"email":"5ff860bf1190596c7188ab851db691f0f3169c453936e9e1eba2f9a47f7a0018"}`,
+		},
+		{
+			name:   "mask whole value",
+			rules:  []*config.ProcessingRule{{Type: config.MaskKeys, Paths: []string{"card"}, ReplacePlaceholder: "[masked]"}},
+			input:  `{"card":4323124312341234,"amount":12.50}`,
+			output: `{"amount":12.50,"card":"[masked]"}`,
+		},
+		{
+			name:   "mask sequences in value",
+			rules:  []*config.ProcessingRule{{Type: config.MaskKeys, Paths: []string{"url"}, Pattern: "token=\\w+", ReplacePlaceholder: "token=[masked]"}},
+			input:  `{"url":"/login?token=abc&next=/"}`,
+			output: `{"url":"/login?token=[masked]&next=/"}`,
+		},
+		{
+			name:   "not JSON",
+			rules:  []*config.ProcessingRule{{Type: config.RemoveKeys, Paths: []string{"password"}}},
+			input:  `password=hunter2`,
+			output: `password=hunter2`,
+		},
+		{
+			name:   "untouched keys keep their formatting",
+			rules:  []*config.ProcessingRule{{Type: config.RemoveKeys, Paths: []string{"missing"}}},
+			input:  `{"b": 1, "a": 2}`,
+			output: `{"b": 1, "a": 2}`,
+		},
+		{
+			name: "structured and raw rules",
+			rules: []*config.ProcessingRule{
+				{Type: config.RemoveKeys, Paths: []string{"password"}},
+				{Type: config.MaskSequences, Pattern: "bob", ReplacePlaceholder: "[user]"},
+			},
+			input:  `{"password":"hunter2","user":"bob"}`,
+			output: `{"user":"[user]"}`,
+		},
+	}
+
+	p := &Processor{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := newMessage([]byte(test.input), newStructuredSource(t, test.rules...), "")
+			assert.True(t, p.applyRedactingRules(msg))
+			assert.Equal(t, test.output, string(msg.GetContent()))
+		})
+	}
+}
+
+func TestStructuredRulesLogfmt(t *testing.T) {
+	p := &Processor{}
+	source := newStructuredSource(t,
+		&config.ProcessingRule{Type: config.RemoveKeys, Format: config.LogfmtFormat, Paths: []string{"password"}},
+		&config.ProcessingRule{Type: config.RenameKey, Format: config.LogfmtFormat, Paths: []string{"usr"}, Target: "user"},
+	)
+
+	msg := newMessage([]byte(`level=info msg="user logged in" usr=bob password=hunter2`), source, "")
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, `level=info msg="user logged in" user=bob`, string(msg.GetContent()))
+
+	msg = newMessage([]byte(`user bob logged in`), source, "")
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, `user bob logged in`, string(msg.GetContent()))
+}
+
+func TestStructuredRulesPromoteKeys(t *testing.T) {
+	p := &Processor{}
+	source := newStructuredSource(t,
+		&config.ProcessingRule{Type: config.TagFromKey, Paths: []string{"ctx.tenant"}, Target: "tenant"},
+		&config.ProcessingRule{Type: config.StatusFromKey, Paths: []string{"level"}},
+		&config.ProcessingRule{Type: config.ServiceFromKey, Paths: []string{"app"}},
+	)
+
+	content := `{"level":"WARNING","app":"billing","ctx":{"tenant":"acme"}}`
+	msg := newMessage([]byte(content), source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, content, string(msg.GetContent()))
+	assert.Equal(t, []string{"tenant:acme"}, msg.ProcessingTags)
+	assert.Equal(t, message.StatusWarning, msg.Status)
+	assert.Equal(t, "billing", msg.Origin.Service())
+
+	msg = newMessage([]byte(`{"level":"verbose"}`), source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, message.StatusInfo, msg.Status)
+}
+
+func TestParseLogfmtPairs(t *testing.T) {
+	pairs, err := parseLogfmtPairs(`a=1  b="x \"y\" z" c=`)
+	assert.NoError(t, err)
+	assert.Equal(t, []logfmtPair{{"a", "1"}, {"b", `x "y" z`}, {"c", ""}}, pairs)
+
+	for _, invalid := range []string{`a=1 b`, `a="unterminated`, `=1`} {
+		_, err := parseLogfmtPairs(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,163 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+// rateLimiterIdleTimeout is the duration after which the token bucket of a
+// source that did not send any message is forgotten.
+const rateLimiterIdleTimeout = 5 * time.Minute
+
+// sampleRateScale is the precision of the sample rates.
+const sampleRateScale = 1000000
+
+// sourceRateLimiters holds the token buckets of the rate_limit rules. As the
+// messages of a source can be spread over several pipelines, they are shared by
+// all the processors.
+var sourceRateLimiters = newRateLimiters()
+
+// throughputRuleKey returns the part of the content a sample or rate_limit rule
+// applies on, and false if the rule does not apply on the message. When the
+// pattern of the rule has a capturing group, the key is its first submatch.
+func throughputRuleKey(rule *config.ProcessingRule, content []byte) ([]byte, bool) {
+	if rule.Regex == nil {
+		return content, true
+	}
+	match := rule.Regex.FindSubmatch(content)
+	switch {
+	case match == nil:
+		return nil, false
+	case len(match) > 1:
+		return match[1], true
+	default:
+		return match[0], true
+	}
+}
+
+// sampleMessage returns true if the message is kept by a sample rule. The
+// decision is made on a hash of the sampling key, so that all the messages
+// sharing the same key are either kept or dropped together.
+func sampleMessage(rule *config.ProcessingRule, content []byte) bool {
+	key, ok := throughputRuleKey(rule, content)
+	if !ok {
+		return true
+	}
+	switch {
+	case rule.SampleRate >= 1:
+		return true
+	case rule.SampleRate <= 0:
+		return false
+	}
+	h := fnv.New64a()
+	h.Write(key)
+	// the low bits of the hash are the best distributed ones
+	return h.Sum64()%sampleRateScale < uint64(rule.SampleRate*sampleRateScale)
+}
+
+// recordDroppedByRule reports a message dropped by a sample or rate_limit rule.
+func recordDroppedByRule(rule *config.ProcessingRule, msg *message.Message) {
+	source := msg.Origin.LogSource
+	source.RecordDroppedByRule()
+	metrics.LogsDroppedByProcessingRules.Add(rule.Type, 1)
+	metrics.TlmLogsDroppedByProcessingRules.Inc(rule.Type, source.Name)
+}
+
+// tokenBucket lets through a sustained number of messages per second, with
+// bursts up to its capacity.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	capacity := float64(burst)
+	if burst == 0 {
+		capacity = math.Max(1, math.Ceil(rate))
+	}
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+// take returns true if a token is available at the given time, and consumes it.
+func (b *tokenBucket) take(now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type rateLimiterKey struct {
+	rule   *config.ProcessingRule
+	source *sources.LogSource
+}
+
+// rateLimiters holds a token bucket per rate_limit rule and per source, so that
+// global rules limit each source independently.
+type rateLimiters struct {
+	mu        sync.Mutex
+	buckets   map[rateLimiterKey]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiters() *rateLimiters {
+	return &rateLimiters{
+		buckets: make(map[rateLimiterKey]*tokenBucket),
+	}
+}
+
+// allow returns true if the message of the source is let through by the rule.
+func (r *rateLimiters) allow(rule *config.ProcessingRule, source *sources.LogSource, content []byte, now time.Time) bool {
+	if _, ok := throughputRuleKey(rule, content); !ok {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now)
+	key := rateLimiterKey{rule: rule, source: source}
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(rule.MessagesPerSecond, rule.Burst, now)
+		r.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
+
+// sweep forgets the buckets of the sources that have been idle for a while, so
+// that the buckets of removed sources do not pile up.
+func (r *rateLimiters) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < rateLimiterIdleTimeout {
+		return
+	}
+	r.lastSweep = now
+	for key, bucket := range r.buckets {
+		if now.Sub(bucket.last) >= rateLimiterIdleTimeout {
+			delete(r.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func newThroughputRule(t *testing.T, rule *config.ProcessingRule) *config.ProcessingRule {
+	rule.Name = "test"
+	rules := []*config.ProcessingRule{rule}
+	require.NoError(t, config.ValidateProcessingRules(rules))
+	require.NoError(t, config.CompileProcessingRules(rules))
+	return rule
+}
+
+func TestSampleMessage(t *testing.T) {
+	rule := newThroughputRule(t, &config.ProcessingRule{Type: config.Sample, SampleRate: 0.5})
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		if sampleMessage(rule, []byte(fmt.Sprintf("message %d", i))) {
+			kept++
+		}
+	}
+	assert.InDelta(t, 500, kept, 100)
+
+	assert.True(t, sampleMessage(newThroughputRule(t, &config.ProcessingRule{Type: config.Sample, SampleRate: 1}), []byte("message")))
+}
+
+func TestSampleMessageByKey(t *testing.T) {
+	rule := newThroughputRule(t, &config.ProcessingRule{Type: config.Sample, SampleRate: 0.5, Pattern: "trace_id=(\\w+)"})
+
+	keptTraces := 0
+	for i := 0; i < 100; i++ {
+		kept := sampleMessage(rule, []byte(fmt.Sprintf("request started trace_id=%d", i)))
+		// all the messages of a trace share the same decision
+		assert.Equal(t, kept, sampleMessage(rule, []byte(fmt.Sprintf("request ended trace_id=%d status=200", i))))
+		if kept {
+			keptTraces++
+		}
+	}
+	assert.Greater(t, keptTraces, 0)
+	assert.Less(t, keptTraces, 100)
+
+	// messages without a sampling key are not sampled
+	for i := 0; i < 100; i++ {
+		assert.True(t, sampleMessage(rule, []byte(fmt.Sprintf("healthcheck %d", i))))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(2, 3, now)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, bucket.take(now))
+	}
+	assert.False(t, bucket.take(now))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, bucket.take(now))
+	assert.False(t, bucket.take(now))
+
+	// tokens do not accumulate over the capacity
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, bucket.take(now))
+	}
+	assert.False(t, bucket.take(now))
+
+	// the burst defaults to the rate
+	assert.Equal(t, float64(2), newTokenBucket(1.5, 0, now).capacity)
+	assert.Equal(t, float64(1), newTokenBucket(0.1, 0, now).capacity)
+}
+
+func TestRateLimitersPerSource(t *testing.T) {
+	limiters := newRateLimiters()
+	rule := newThroughputRule(t, &config.ProcessingRule{Type: config.RateLimit, MessagesPerSecond: 1, Pattern: "DEBUG"})
+	noisy := sources.NewLogSource("noisy", &config.LogsConfig{})
+	quiet := sources.NewLogSource("quiet", &config.LogsConfig{})
+	now := time.Now()
+
+	assert.True(t, limiters.allow(rule, noisy, []byte("DEBUG first"), now))
+	assert.False(t, limiters.allow(rule, noisy, []byte("DEBUG second"), now))
+	// messages not matching the pattern are not limited
+	assert.True(t, limiters.allow(rule, noisy, []byte("ERROR failure"), now))
+	// each source has its own bucket
+	assert.True(t, limiters.allow(rule, quiet, []byte("DEBUG first"), now))
+
+	// idle buckets are forgotten
+	now = now.Add(rateLimiterIdleTimeout)
+	assert.True(t, limiters.allow(rule, noisy, []byte("DEBUG third"), now))
+	assert.Len(t, limiters.buckets, 1)
+}
+
+func TestRateLimitRule(t *testing.T) {
+	defer metrics.LogsDroppedByProcessingRules.Init()
+
+	p := &Processor{}
+	rule := newThroughputRule(t, &config.ProcessingRule{Type: config.RateLimit, MessagesPerSecond: 0.001, Burst: 2})
+	source := sources.NewLogSource("runaway", &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rule}})
+
+	assert.True(t, p.applyRedactingRules(newMessage([]byte("debug 1"), source, "")))
+	assert.True(t, p.applyRedactingRules(newMessage([]byte("debug 2"), source, "")))
+	assert.False(t, p.applyRedactingRules(newMessage([]byte("debug 3"), source, "")))
+	assert.False(t, p.applyRedactingRules(newMessage([]byte("debug 4"), source, "")))
+
+	assert.Equal(t, int64(2), metrics.LogsDroppedByProcessingRules.Get(config.RateLimit).(*expvar.Int).Value())
+	assert.Equal(t, []string{"2"}, source.GetInfoStatus()["Dropped By Processing Rules"])
+}
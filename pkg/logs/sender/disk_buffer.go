@@ -0,0 +1,336 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const diskBufferExtension = ".payload"
+
+// errDiskBufferFull is returned when a payload does not fit in the disk buffer.
+var errDiskBufferFull = errors.New("the logs disk buffer is full")
+
+// diskPayloadHeader holds the fields of a payload stored along with its encoded
+// content and the content of its messages.
+type diskPayloadHeader struct {
+	Encoding      string              `json:"encoding"`
+	UnencodedSize int                 `json:"unencoded_size"`
+	EncodedSize   int                 `json:"encoded_size"`
+	Messages      []diskMessageHeader `json:"messages"`
+}
+
+// diskMessageHeader holds the fields of a message needed by the destinations.
+type diskMessageHeader struct {
+	Size               int    `json:"size"`
+	Status             string `json:"status,omitempty"`
+	Source             string `json:"source,omitempty"`
+	Service            string `json:"service,omitempty"`
+	IngestionTimestamp int64  `json:"ingestion_timestamp"`
+}
+
+// replayedLogSource is the source of the messages of the replayed payloads. Their
+// origin has no identifier, the auditor does not track them again.
+var replayedLogSource = sources.NewLogSource("disk_buffer", &config.LogsConfig{})
+
+// diskBuffer is a bounded FIFO queue of payloads persisted on disk, one file
+// per payload. Files are named after a sequence number so that the payloads
+// are read back in the order they were stored, including after a restart.
+// A payload handed to the destinations stays on disk until one of them
+// delivers it, so that it is replayed again if the agent stops before.
+type diskBuffer struct {
+	path    string
+	maxSize int64
+
+	mu       sync.Mutex
+	size     int64
+	files    []string                    // payloads waiting to be replayed, oldest first
+	inFlight map[*message.Payload]string // payloads replayed, waiting to be delivered
+	nextID   uint64
+}
+
+// newDiskBuffer returns a disk buffer storing at most maxSize bytes of payloads
+// in path, reloading the payloads stored by a previous run.
+func newDiskBuffer(path string, maxSize int64) (*diskBuffer, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	b := &diskBuffer{
+		path:     path,
+		maxSize:  maxSize,
+		inFlight: make(map[*message.Payload]string),
+	}
+	if err := b.reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *diskBuffer) reload() error {
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if strings.HasSuffix(name, ".tmp") {
+			// a payload which was being stored when the agent stopped
+			os.Remove(filepath.Join(b.path, name))
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, diskBufferExtension), 10, 64)
+		if err != nil || filepath.Ext(name) != diskBufferExtension {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			log.Warnf("Can't get the size of the buffered logs payload %s: %v", name, err)
+			continue
+		}
+		b.size += info.Size()
+		b.files = append(b.files, name)
+		if id >= b.nextID {
+			b.nextID = id + 1
+		}
+	}
+	// the names are zero-padded, the lexical order is the sequence order
+	sort.Strings(b.files)
+	if len(b.files) > 0 {
+		log.Infof("Reloaded %d logs payloads (%d bytes) from the disk buffer %s", len(b.files), b.size, b.path)
+	}
+	return nil
+}
+
+// pending returns the number of payloads waiting to be replayed.
+func (b *diskBuffer) pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.files)
+}
+
+// bytes returns the size of the payloads stored in the buffer, including the
+// payloads not delivered yet.
+func (b *diskBuffer) bytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// push durably stores the payload at the end of the queue. It returns
+// errDiskBufferFull if the payload does not fit in the buffer.
+func (b *diskBuffer) push(payload *message.Payload) error {
+	header := diskPayloadHeader{
+		Encoding:      payload.Encoding,
+		UnencodedSize: payload.UnencodedSize,
+		EncodedSize:   len(payload.Encoded),
+		Messages:      make([]diskMessageHeader, 0, len(payload.Messages)),
+	}
+	contentSize := 0
+	for _, msg := range payload.Messages {
+		m := diskMessageHeader{
+			Size:               len(msg.GetContent()),
+			Status:             msg.Status,
+			IngestionTimestamp: msg.IngestionTimestamp,
+		}
+		if msg.Origin != nil && msg.Origin.LogSource != nil {
+			m.Source = msg.Origin.Source()
+			m.Service = msg.Origin.Service()
+		}
+		header.Messages = append(header.Messages, m)
+		contentSize += m.Size
+	}
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	size := int64(len(encodedHeader) + 1 + len(payload.Encoded) + contentSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.size+size > b.maxSize {
+		return errDiskBufferFull
+	}
+
+	name := fmt.Sprintf("%020d%s", b.nextID, diskBufferExtension)
+	f, err := os.CreateTemp(b.path, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmpName)
+		}
+	}()
+	// the write errors of the buffered writer are returned by Flush
+	w := bufio.NewWriter(f)
+	_, _ = w.Write(encodedHeader)
+	_ = w.WriteByte('\n')
+	_, _ = w.Write(payload.Encoded)
+	for _, msg := range payload.Messages {
+		_, _ = w.Write(msg.GetContent())
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	// the payload is acknowledged to the auditor once stored, it must not be
+	// lost if the host crashes
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, filepath.Join(b.path, name)); err != nil {
+		return err
+	}
+	syncDir(b.path)
+
+	b.nextID++
+	b.size += size
+	b.files = append(b.files, name)
+	return nil
+}
+
+// peek returns the oldest payload waiting to be replayed, without removing it.
+// The messages of the payload are rebuilt with their encoded content.
+func (b *diskBuffer) peek() (*message.Payload, error) {
+	b.mu.Lock()
+	if len(b.files) == 0 {
+		b.mu.Unlock()
+		return nil, nil
+	}
+	name := b.files[0]
+	b.mu.Unlock()
+
+	content, err := os.ReadFile(filepath.Join(b.path, name))
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.IndexByte(content, '\n')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid buffered logs payload %s", name)
+	}
+	var header diskPayloadHeader
+	if err := json.Unmarshal(content[:i], &header); err != nil {
+		return nil, fmt.Errorf("invalid buffered logs payload %s: %v", name, err)
+	}
+	content = content[i+1:]
+	if header.EncodedSize > len(content) {
+		return nil, fmt.Errorf("truncated buffered logs payload %s", name)
+	}
+	payload := &message.Payload{
+		Messages:      make([]*message.Message, 0, len(header.Messages)),
+		Encoded:       content[:header.EncodedSize],
+		Encoding:      header.Encoding,
+		UnencodedSize: header.UnencodedSize,
+	}
+	content = content[header.EncodedSize:]
+	for _, m := range header.Messages {
+		if m.Size > len(content) {
+			return nil, fmt.Errorf("truncated buffered logs payload %s", name)
+		}
+		origin := message.NewOrigin(replayedLogSource)
+		origin.SetSource(m.Source)
+		origin.SetService(m.Service)
+		msg := message.NewMessage(nil, origin, m.Status, m.IngestionTimestamp)
+		msg.SetEncoded(content[:m.Size])
+		payload.Messages = append(payload.Messages, msg)
+		content = content[m.Size:]
+	}
+	return payload, nil
+}
+
+// replaying marks the oldest payload, returned by peek, as being handed to the
+// destinations, so that its file is removed once it is delivered.
+func (b *diskBuffer) replaying(payload *message.Payload) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.files) > 0 {
+		b.inFlight[payload] = b.files[0]
+	}
+}
+
+// replayed ends the replay of the oldest payload. It leaves the queue if a
+// destination accepted it, its file is kept until it is delivered.
+func (b *diskBuffer) replayed(payload *message.Payload, accepted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !accepted {
+		delete(b.inFlight, payload)
+		return
+	}
+	if len(b.files) > 0 {
+		b.files = b.files[1:]
+	}
+}
+
+// delivered removes the file of the payload if it was replayed. It returns
+// false if the payload does not come from the buffer, or was already delivered
+// by another destination.
+func (b *diskBuffer) delivered(payload *message.Payload) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	name, ok := b.inFlight[payload]
+	if !ok {
+		return false
+	}
+	delete(b.inFlight, payload)
+	b.remove(name)
+	return true
+}
+
+// drop removes the oldest payload waiting to be replayed, when it can't be read.
+func (b *diskBuffer) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.files) == 0 {
+		return
+	}
+	name := b.files[0]
+	b.files = b.files[1:]
+	b.remove(name)
+}
+
+// remove deletes the file of a payload, b.mu must be held.
+func (b *diskBuffer) remove(name string) {
+	path := filepath.Join(b.path, name)
+	if info, err := os.Stat(path); err == nil {
+		b.size -= info.Size()
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Can't remove the buffered logs payload %s: %v", path, err)
+	}
+}
+
+// syncDir flushes the entries of a directory, so that a renamed file survives a crash.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	_ = dir.Sync()
+	_ = dir.Close()
+}
@@ -0,0 +1,183 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func newEncodedPayload(content string) *message.Payload {
+	return &message.Payload{
+		Messages:      []*message.Message{message.NewMessage([]byte(content), nil, "", 0)},
+		Encoded:       []byte(content),
+		Encoding:      "gzip",
+		UnencodedSize: len(content),
+	}
+}
+
+func popPayload(t *testing.T, b *diskBuffer) string {
+	payload, err := b.peek()
+	require.NoError(t, err)
+	require.NotNil(t, payload)
+	b.replaying(payload)
+	b.replayed(payload, true)
+	assert.True(t, b.delivered(payload))
+	assert.Equal(t, "gzip", payload.Encoding)
+	assert.Equal(t, len(payload.Encoded), payload.UnencodedSize)
+	require.Len(t, payload.Messages, 1)
+	assert.Equal(t, payload.Encoded, payload.Messages[0].GetContent())
+	return string(payload.Encoded)
+}
+
+func TestDiskBufferIsFIFO(t *testing.T) {
+	b, err := newDiskBuffer(t.TempDir(), 1024)
+	require.NoError(t, err)
+
+	payload, err := b.peek()
+	assert.NoError(t, err)
+	assert.Nil(t, payload)
+
+	for _, content := range []string{"first", "second\nline", "third"} {
+		require.NoError(t, b.push(newEncodedPayload(content)))
+	}
+	assert.Equal(t, 3, b.pending())
+	assert.Equal(t, "first", popPayload(t, b))
+	require.NoError(t, b.push(newEncodedPayload("fourth")))
+	assert.Equal(t, "second\nline", popPayload(t, b))
+	assert.Equal(t, "third", popPayload(t, b))
+	assert.Equal(t, "fourth", popPayload(t, b))
+	assert.Equal(t, 0, b.pending())
+	assert.Zero(t, b.bytes())
+}
+
+func TestDiskBufferReloadsPayloads(t *testing.T) {
+	path := t.TempDir()
+	b, err := newDiskBuffer(path, 1024)
+	require.NoError(t, err)
+	require.NoError(t, b.push(newEncodedPayload("first")))
+	require.NoError(t, b.push(newEncodedPayload("second")))
+	// a payload which was being written when the agent stopped
+	require.NoError(t, os.WriteFile(filepath.Join(path, "00000000000000000002.payload.123.tmp"), []byte("partial"), 0600))
+
+	b, err = newDiskBuffer(path, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, 2, b.pending())
+	require.NoError(t, b.push(newEncodedPayload("third")))
+	assert.Equal(t, "first", popPayload(t, b))
+	assert.Equal(t, "second", popPayload(t, b))
+	assert.Equal(t, "third", popPayload(t, b))
+
+	entries, err := os.ReadDir(path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDiskBufferIsBounded(t *testing.T) {
+	b, err := newDiskBuffer(t.TempDir(), 100)
+	require.NoError(t, err)
+
+	require.NoError(t, b.push(newEncodedPayload("small")))
+	assert.ErrorIs(t, b.push(newEncodedPayload(string(make([]byte, 100)))), errDiskBufferFull)
+	assert.Equal(t, 1, b.pending())
+
+	b.drop()
+	assert.ErrorIs(t, b.push(newEncodedPayload(string(make([]byte, 100)))), errDiskBufferFull)
+	assert.NoError(t, b.push(newEncodedPayload(string(make([]byte, 50)))))
+}
+
+func TestDiskBufferCorruptPayload(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "00000000000000000000.payload"), []byte("no header"), 0600))
+	b, err := newDiskBuffer(path, 1024)
+	require.NoError(t, err)
+	require.NoError(t, b.push(newEncodedPayload("valid")))
+
+	_, err = b.peek()
+	assert.Error(t, err)
+	b.drop()
+	assert.Equal(t, "valid", popPayload(t, b))
+}
+
+func TestDiskBufferRebuildsMessages(t *testing.T) {
+	b, err := newDiskBuffer(t.TempDir(), 1024)
+	require.NoError(t, err)
+
+	origin := message.NewOrigin(sources.NewLogSource("", &config.LogsConfig{Source: "nginx"}))
+	origin.Identifier = "file:/var/log/nginx/access.log"
+	origin.SetService("web")
+	first := message.NewMessage(nil, origin, message.StatusError, 42)
+	first.SetEncoded([]byte(`{"message":"first"}`))
+	second := message.NewMessage(nil, origin, message.StatusInfo, 43)
+	second.SetEncoded([]byte(`{"message":"second"}`))
+	require.NoError(t, b.push(&message.Payload{
+		Messages:      []*message.Message{first, second},
+		Encoded:       []byte(`[{"message":"first"},{"message":"second"}]`),
+		Encoding:      "identity",
+		UnencodedSize: 42,
+	}))
+
+	payload, err := b.peek()
+	require.NoError(t, err)
+	assert.Equal(t, `[{"message":"first"},{"message":"second"}]`, string(payload.Encoded))
+	require.Len(t, payload.Messages, 2)
+	for i, msg := range []*message.Message{first, second} {
+		replayed := payload.Messages[i]
+		assert.Equal(t, msg.GetContent(), replayed.GetContent())
+		assert.Equal(t, msg.Status, replayed.Status)
+		assert.Equal(t, msg.IngestionTimestamp, replayed.IngestionTimestamp)
+		assert.Equal(t, "nginx", replayed.Origin.Source())
+		assert.Equal(t, "web", replayed.Origin.Service())
+		// the offsets were committed when the payload was spilled
+		assert.Empty(t, replayed.Origin.Identifier)
+	}
+}
+
+func TestDiskBufferKeepsPayloadsUntilDelivered(t *testing.T) {
+	path := t.TempDir()
+	b, err := newDiskBuffer(path, 1024)
+	require.NoError(t, err)
+	require.NoError(t, b.push(newEncodedPayload("first")))
+	require.NoError(t, b.push(newEncodedPayload("second")))
+
+	// a payload not accepted by any destination is replayed again
+	payload, err := b.peek()
+	require.NoError(t, err)
+	b.replaying(payload)
+	b.replayed(payload, false)
+	assert.Equal(t, 2, b.pending())
+	assert.False(t, b.delivered(payload))
+
+	// an accepted payload is kept on disk until it is delivered
+	payload, err = b.peek()
+	require.NoError(t, err)
+	b.replaying(payload)
+	b.replayed(payload, true)
+	assert.Equal(t, 1, b.pending())
+	entries, err := os.ReadDir(path)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// it is replayed again if the agent stops before it is delivered
+	reloaded, err := newDiskBuffer(path, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reloaded.pending())
+
+	assert.True(t, b.delivered(payload))
+	assert.False(t, b.delivered(payload))
+	entries, err = os.ReadDir(path)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "second", popPayload(t, b))
+}
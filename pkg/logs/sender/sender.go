@@ -6,6 +6,8 @@
 package sender
 
 import (
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
@@ -15,12 +17,22 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
+// diskBufferReplayInterval is the interval at which the sender tries to replay
+// the payloads of the disk buffer while the reliable destinations are blocked.
+const diskBufferReplayInterval = time.Second
+
 var (
 	tlmPayloadsDropped = telemetry.NewCounterWithOpts("logs_sender", "payloads_dropped", []string{"reliable", "destination"}, "Payloads dropped", telemetry.Options{DefaultMetric: true})
 	tlmMessagesDropped = telemetry.NewCounterWithOpts("logs_sender", "messages_dropped", []string{"reliable", "destination"}, "Messages dropped", telemetry.Options{DefaultMetric: true})
 	tlmSendWaitTime    = telemetry.NewCounter("logs_sender", "send_wait", []string{}, "Time spent waiting for all sends to finish")
+	tlmPayloadsSpilled = telemetry.NewCounter("logs_sender", "payloads_spilled", []string{}, "Payloads stored in the disk buffer")
+	tlmPayloadsReplay  = telemetry.NewCounter("logs_sender", "payloads_replayed", []string{}, "Payloads replayed from the disk buffer")
+	tlmDiskBufferBytes = telemetry.NewGauge("logs_sender", "disk_buffer_bytes", []string{"pipeline"}, "Size of the payloads stored in the disk buffer")
+
+	invalidPathChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
 )
 
 // Sender sends logs to different destinations. Destinations can be either
@@ -30,6 +42,9 @@ var (
 // one reliable destination is also sending logs. However they do not update
 // the auditor or block the pipeline if they fail. There will always be at
 // least 1 reliable destination (the main destination).
+// When the disk buffer is enabled, the payloads are spilled to disk instead of
+// blocking the pipeline while all the reliable destinations are blocked. They
+// are replayed by a separate routine, and removed from the disk once delivered.
 type Sender struct {
 	config         pkgconfigmodel.Reader
 	inputChan      chan *message.Payload
@@ -39,6 +54,8 @@ type Sender struct {
 	bufferSize     int
 	senderDoneChan chan *sync.WaitGroup
 	flushWg        *sync.WaitGroup
+	diskBuffer     *diskBuffer
+	replayNotify   chan struct{} // wakes the replay routine up when a payload is spilled
 
 	pipelineMonitor metrics.PipelineMonitor
 	utilization     metrics.UtilizationMonitor
@@ -46,7 +63,7 @@ type Sender struct {
 
 // NewSender returns a new sender.
 func NewSender(config pkgconfigmodel.Reader, inputChan chan *message.Payload, outputChan chan *message.Payload, destinations *client.Destinations, bufferSize int, senderDoneChan chan *sync.WaitGroup, flushWg *sync.WaitGroup, pipelineMonitor metrics.PipelineMonitor) *Sender {
-	return &Sender{
+	s := &Sender{
 		config:         config,
 		inputChan:      inputChan,
 		outputChan:     outputChan,
@@ -55,11 +72,34 @@ func NewSender(config pkgconfigmodel.Reader, inputChan chan *message.Payload, ou
 		bufferSize:     bufferSize,
 		senderDoneChan: senderDoneChan,
 		flushWg:        flushWg,
+		replayNotify:   make(chan struct{}, 1),
 
 		// Telemetry
 		pipelineMonitor: pipelineMonitor,
 		utilization:     pipelineMonitor.MakeUtilizationMonitor("sender"),
 	}
+	// the serverless flush waits for the payloads to be sent, they can't be spilled
+	if senderDoneChan == nil && config.GetBool("logs_config.disk_buffer.enabled") && len(destinations.Reliable) > 0 {
+		s.diskBuffer = newSenderDiskBuffer(config, destinations, pipelineMonitor.ID())
+	}
+	return s
+}
+
+// newSenderDiskBuffer opens the disk buffer of a pipeline, it returns nil if it fails.
+func newSenderDiskBuffer(config pkgconfigmodel.Reader, destinations *client.Destinations, pipelineID string) *diskBuffer {
+	path := config.GetString("logs_config.disk_buffer.path")
+	if path == "" {
+		path = filepath.Join(config.GetString("logs_config.run_path"), "logs-buffer")
+	}
+	// the pipelines of the different logs agents (logs, compliance, ...) all have
+	// their own buffer, named after their main destination and their ID
+	name := invalidPathChars.ReplaceAllString(destinations.Reliable[0].Target(), "_") + "-" + pipelineID
+	b, err := newDiskBuffer(filepath.Join(path, name), int64(config.GetSizeInBytes("logs_config.disk_buffer.max_size")))
+	if err != nil {
+		log.Errorf("Can't open the logs disk buffer in %s, payloads won't be spilled to disk: %v", path, err)
+		return nil
+	}
+	return b
 }
 
 // Start starts the sender.
@@ -75,55 +115,79 @@ func (s *Sender) Stop() {
 }
 
 func (s *Sender) run() {
-	reliableDestinations := buildDestinationSenders(s.config, s.destinations.Reliable, s.outputChan, s.bufferSize)
+	output := s.outputChan
+	var outputDone chan struct{}
+	if s.diskBuffer != nil {
+		// the payloads delivered by the reliable destinations go through the
+		// sender, which removes the replayed ones from the disk buffer
+		output = make(chan *message.Payload, s.bufferSize)
+		outputDone = make(chan struct{})
+		go s.forwardDelivered(output, outputDone)
+	}
+	reliableDestinations := buildDestinationSenders(s.config, s.destinations.Reliable, output, s.bufferSize)
 
 	sink := additionalDestinationsSink(s.bufferSize)
 	unreliableDestinations := buildDestinationSenders(s.config, s.destinations.Unreliable, sink, s.bufferSize)
 
+	var replayStop, replayDone chan struct{}
+	if s.diskBuffer != nil {
+		tlmDiskBufferBytes.Set(float64(s.diskBuffer.bytes()), s.pipelineMonitor.ID())
+		replayStop = make(chan struct{})
+		replayDone = make(chan struct{})
+		go s.runReplay(reliableDestinations, replayStop, replayDone)
+	}
+
 	for payload := range s.inputChan {
-		s.utilization.Start()
-		var startInUse = time.Now()
-		senderDoneWg := &sync.WaitGroup{}
+		s.send(payload, reliableDestinations, unreliableDestinations)
+	}
 
-		sent := false
-		for !sent {
-			for _, destSender := range reliableDestinations {
-				if destSender.Send(payload) {
-					if destSender.destination.Metadata().ReportingEnabled {
-						s.pipelineMonitor.ReportComponentIngress(payload, destSender.destination.Metadata().MonitorTag())
-					}
-					sent = true
-					if s.senderDoneChan != nil {
-						senderDoneWg.Add(1)
-						s.senderDoneChan <- senderDoneWg
-					}
-				}
-			}
+	if s.diskBuffer != nil {
+		close(replayStop)
+		<-replayDone
+	}
+	// Cleanup the destinations
+	for _, destSender := range reliableDestinations {
+		destSender.Stop()
+	}
+	for _, destSender := range unreliableDestinations {
+		destSender.Stop()
+	}
+	if outputDone != nil {
+		close(output)
+		<-outputDone
+	}
+	close(sink)
+	s.done <- struct{}{}
+}
 
-			if !sent {
-				// Throttle the poll loop while waiting for a send to succeed
-				// This will only happen when all reliable destinations
-				// are blocked so logs have no where to go.
-				time.Sleep(100 * time.Millisecond)
-			}
-		}
+// send sends the payload to the destinations, spilling it to the disk buffer
+// while the reliable destinations are blocked.
+func (s *Sender) send(payload *message.Payload, reliableDestinations []*DestinationSender, unreliableDestinations []*DestinationSender) {
+	s.utilization.Start()
+	var startInUse = time.Now()
+	senderDoneWg := &sync.WaitGroup{}
 
-		for i, destSender := range reliableDestinations {
-			// If an endpoint is stuck in the previous step, try to buffer the payloads if we have room to mitigate
-			// loss on intermittent failures.
-			if !destSender.lastSendSucceeded {
-				if !destSender.NonBlockingSend(payload) {
-					tlmPayloadsDropped.Inc("true", strconv.Itoa(i))
-					tlmMessagesDropped.Add(float64(len(payload.Messages)), "true", strconv.Itoa(i))
-				}
+	spilled := false
+	sent := false
+	for !sent {
+		// the payloads of the disk buffer are older, the new payloads are spilled
+		// after them until they are all replayed
+		if s.diskBuffer != nil && s.diskBuffer.pending() > 0 {
+			if s.spill(payload) {
+				spilled = true
+				break
 			}
+			// the disk buffer is full, wait for the destinations to drain it
+			time.Sleep(100 * time.Millisecond)
+			continue
 		}
 
-		// Attempt to send to unreliable destinations
-		for i, destSender := range unreliableDestinations {
-			if !destSender.NonBlockingSend(payload) {
-				tlmPayloadsDropped.Inc("false", strconv.Itoa(i))
-				tlmMessagesDropped.Add(float64(len(payload.Messages)), "false", strconv.Itoa(i))
+		for _, destSender := range reliableDestinations {
+			if destSender.Send(payload) {
+				if destSender.destination.Metadata().ReportingEnabled {
+					s.pipelineMonitor.ReportComponentIngress(payload, destSender.destination.Metadata().MonitorTag())
+				}
+				sent = true
 				if s.senderDoneChan != nil {
 					senderDoneWg.Add(1)
 					s.senderDoneChan <- senderDoneWg
@@ -131,28 +195,139 @@ func (s *Sender) run() {
 			}
 		}
 
-		inUse := float64(time.Since(startInUse) / time.Millisecond)
-		tlmSendWaitTime.Add(inUse)
-		s.utilization.Stop()
+		if !sent && s.diskBuffer != nil && s.spill(payload) {
+			spilled = true
+			break
+		}
 
-		if s.senderDoneChan != nil && s.flushWg != nil {
-			// Wait for all destinations to finish sending the payload
-			senderDoneWg.Wait()
-			// Decrement the wait group when this payload has been sent
-			s.flushWg.Done()
+		if !sent {
+			// Throttle the poll loop while waiting for a send to succeed
+			// This will only happen when all reliable destinations
+			// are blocked so logs have no where to go.
+			time.Sleep(100 * time.Millisecond)
 		}
-		s.pipelineMonitor.ReportComponentEgress(payload, "sender")
 	}
 
-	// Cleanup the destinations
-	for _, destSender := range reliableDestinations {
-		destSender.Stop()
+	for i, destSender := range reliableDestinations {
+		// If an endpoint is stuck in the previous step, try to buffer the payloads if we have room to mitigate
+		// loss on intermittent failures.
+		// A spilled payload will be replayed, buffering it would send it twice.
+		if !spilled && !destSender.lastSendSucceeded {
+			if !destSender.NonBlockingSend(payload) {
+				tlmPayloadsDropped.Inc("true", strconv.Itoa(i))
+				tlmMessagesDropped.Add(float64(len(payload.Messages)), "true", strconv.Itoa(i))
+			}
+		}
 	}
-	for _, destSender := range unreliableDestinations {
-		destSender.Stop()
+
+	// Attempt to send to unreliable destinations
+	for i, destSender := range unreliableDestinations {
+		if !destSender.NonBlockingSend(payload) {
+			tlmPayloadsDropped.Inc("false", strconv.Itoa(i))
+			tlmMessagesDropped.Add(float64(len(payload.Messages)), "false", strconv.Itoa(i))
+			if s.senderDoneChan != nil {
+				senderDoneWg.Add(1)
+				s.senderDoneChan <- senderDoneWg
+			}
+		}
+	}
+
+	inUse := float64(time.Since(startInUse) / time.Millisecond)
+	tlmSendWaitTime.Add(inUse)
+	s.utilization.Stop()
+
+	if s.senderDoneChan != nil && s.flushWg != nil {
+		// Wait for all destinations to finish sending the payload
+		senderDoneWg.Wait()
+		// Decrement the wait group when this payload has been sent
+		s.flushWg.Done()
+	}
+	s.pipelineMonitor.ReportComponentEgress(payload, "sender")
+}
+
+// spill stores the payload in the disk buffer and hands it to the auditor, so
+// that the offsets of its messages are committed. It returns false if the
+// payload could not be stored.
+func (s *Sender) spill(payload *message.Payload) bool {
+	if err := s.diskBuffer.push(payload); err != nil {
+		if err != errDiskBufferFull {
+			log.Warnf("Can't spill the logs payload to disk: %v", err)
+		}
+		return false
+	}
+	tlmPayloadsSpilled.Inc()
+	tlmDiskBufferBytes.Set(float64(s.diskBuffer.bytes()), s.pipelineMonitor.ID())
+	select {
+	case s.replayNotify <- struct{}{}:
+	default:
+	}
+	s.outputChan <- payload
+	return true
+}
+
+// runReplay replays the payloads of the disk buffer when a payload is spilled,
+// and periodically while the reliable destinations are blocked.
+func (s *Sender) runReplay(reliableDestinations []*DestinationSender, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(diskBufferReplayInterval)
+	defer ticker.Stop()
+	for {
+		s.replay(reliableDestinations, stop)
+		select {
+		case <-s.replayNotify:
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// replay sends the payloads of the disk buffer, oldest first, until a payload
+// is not accepted by any reliable destination. The new payloads are spilled as
+// long as some payloads wait to be replayed, the replay routine is then the only
+// one sending to the reliable destinations.
+func (s *Sender) replay(reliableDestinations []*DestinationSender, stop chan struct{}) {
+	defer func() {
+		tlmDiskBufferBytes.Set(float64(s.diskBuffer.bytes()), s.pipelineMonitor.ID())
+	}()
+	for s.diskBuffer.pending() > 0 {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		payload, err := s.diskBuffer.peek()
+		if err != nil {
+			log.Warnf("Dropping a logs payload of the disk buffer: %v", err)
+			s.diskBuffer.drop()
+			continue
+		}
+		s.diskBuffer.replaying(payload)
+		sent := false
+		for _, destSender := range reliableDestinations {
+			if destSender.Send(payload) {
+				sent = true
+			}
+		}
+		s.diskBuffer.replayed(payload, sent)
+		if !sent {
+			return
+		}
+	}
+}
+
+// forwardDelivered hands the payloads delivered by the reliable destinations to
+// the auditor, and removes the replayed ones from the disk buffer. The messages
+// of the replayed payloads have no identifier, they don't update the registry.
+func (s *Sender) forwardDelivered(delivered chan *message.Payload, done chan struct{}) {
+	defer close(done)
+	for payload := range delivered {
+		if s.diskBuffer.delivered(payload) {
+			tlmPayloadsReplay.Inc()
+			tlmDiskBufferBytes.Set(float64(s.diskBuffer.bytes()), s.pipelineMonitor.ID())
+		}
+		s.outputChan <- payload
 	}
-	close(sink)
-	s.done <- struct{}{}
 }
 
 // Drains the output channel from destinations that don't update the auditor.
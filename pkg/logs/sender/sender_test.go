@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
 	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
@@ -317,3 +318,62 @@ func TestSenderReliableWhenOneFailsAndRecovers(t *testing.T) {
 	reliableServer2.Stop()
 	sender.Stop()
 }
+
+func TestSenderSpillsToDiskWhenMainFails(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("logs_config.disk_buffer.enabled", true)
+	cfg.SetWithoutSource("logs_config.disk_buffer.path", t.TempDir())
+	input := make(chan *message.Payload, 1)
+	output := make(chan *message.Payload, 10)
+
+	reliableRespond := make(chan int)
+	reliableServer := http.NewTestServerWithOptions(200, 0, true, reliableRespond, cfg)
+
+	destinations := client.NewDestinations([]client.Destination{reliableServer.Destination}, nil)
+
+	sender := NewSender(cfg, input, output, destinations, 10, nil, nil, metrics.NewNoopPipelineMonitor("0"))
+	assert.NotNil(t, sender.diskBuffer)
+	sender.Start()
+
+	reliableServer.ChangeStatus(500)
+
+	stuck := &message.Payload{Encoded: []byte("stuck")}
+	input <- stuck
+	<-reliableRespond // let it respond 500 once
+	<-reliableRespond // its in a loop now, the sender has marked the endpoint as retrying
+
+	// the payload is spilled and handed to the auditor while the destination is failing
+	spilled := &message.Payload{
+		Messages:      []*message.Message{message.NewMessage([]byte("spilled"), nil, message.StatusInfo, 0)},
+		Encoded:       []byte("spilled"),
+		Encoding:      "identity",
+		UnencodedSize: 7,
+	}
+	input <- spilled
+	assert.Equal(t, spilled, <-output)
+
+	reliableServer.ChangeStatus(200)
+
+	// Drain any retries
+	for {
+		if (<-reliableRespond) == 200 {
+			break
+		}
+	}
+	assert.Equal(t, stuck, <-output)
+
+	// the spilled payload is replayed with its messages, and removed from the
+	// disk buffer once delivered
+	<-reliableRespond
+	replayed := <-output
+	assert.Equal(t, spilled.Encoded, replayed.Encoded)
+	assert.Equal(t, spilled.Encoding, replayed.Encoding)
+	assert.Equal(t, spilled.UnencodedSize, replayed.UnencodedSize)
+	require.Len(t, replayed.Messages, 1)
+	assert.Equal(t, []byte("spilled"), replayed.Messages[0].GetContent())
+	assert.Zero(t, sender.diskBuffer.bytes())
+
+	reliableServer.Stop()
+	sender.Stop()
+	assert.Equal(t, 0, sender.diskBuffer.pending())
+}
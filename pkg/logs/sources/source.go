@@ -51,6 +51,9 @@ type LogSource struct {
 	LatencyStats     *statstracker.Tracker
 	BytesRead        *status.CountInfo
 	hiddenFromStatus bool
+	// droppedByRules counts the messages dropped by the sample and rate_limit processing rules,
+	// it is only displayed on the status page once a message has been dropped
+	droppedByRules *status.CountInfo
 }
 
 // NewLogSource creates a new log source.
@@ -159,6 +162,23 @@ func (s *LogSource) RecordBytes(n int64) {
 	}
 }
 
+// RecordDroppedByRule reports a message dropped by a sample or rate_limit processing rule.
+// As for RecordBytes, the count is also reported to the parent source, if any.
+func (s *LogSource) RecordDroppedByRule() {
+	s.lock.Lock()
+	if s.droppedByRules == nil {
+		s.droppedByRules = status.NewCountInfo("Dropped By Processing Rules")
+		s.info.Register(s.droppedByRules)
+	}
+	droppedByRules := s.droppedByRules
+	s.lock.Unlock()
+
+	droppedByRules.Add(1)
+	if s.ParentSource != nil {
+		s.ParentSource.RecordDroppedByRule()
+	}
+}
+
 // Dump provides a dump of the LogSource contents, for debugging purposes.  If
 // multiline is true, the result contains newlines for readability.
 func (s *LogSource) Dump(multiline bool) string {
@@ -35,6 +35,18 @@ func (s *LogSourceSuite) TestDump() {
 	assert.Contains(s.T(), dump, "mysource")
 }
 
+func (s *LogSourceSuite) TestRecordDroppedByRule() {
+	parent := NewLogSource("parent", nil)
+	s.source = NewLogSource("child", nil)
+	s.source.ParentSource = parent
+	s.Nil(s.source.GetInfo("Dropped By Processing Rules"))
+
+	s.source.RecordDroppedByRule()
+	s.source.RecordDroppedByRule()
+	s.Equal([]string{"2"}, s.source.GetInfoStatus()["Dropped By Processing Rules"])
+	s.Equal([]string{"2"}, parent.GetInfoStatus()["Dropped By Processing Rules"])
+}
+
 func TestTrackerSuite(t *testing.T) {
 	suite.Run(t, new(LogSourceSuite))
 }
@@ -173,6 +173,9 @@ func (b *Builder) toDictionary(c *config.LogsConfig) map[string]interface{} {
 	switch c.Type {
 	case config.TCPType, config.UDPType:
 		dictionary["Port"] = c.Port
+	case config.SyslogType:
+		dictionary["Port"] = c.Port
+		dictionary["Protocol"] = c.Protocol
 	case config.FileType:
 		dictionary["Path"] = c.Path
 		dictionary["TailingMode"] = c.TailingMode
@@ -209,9 +212,28 @@ func (b *Builder) getMetricsStatus() map[string]string {
 	metrics["RetryCount"] = fmt.Sprintf("%v", b.logsExpVars.Get("RetryCount").(*expvar.Int).Value())
 	metrics["RetryTimeSpent"] = time.Duration(b.logsExpVars.Get("RetryTimeSpent").(*expvar.Int).Value()).String()
 	metrics["EncodedBytesSent"] = fmt.Sprintf("%v", b.logsExpVars.Get("EncodedBytesSent").(*expvar.Int).Value())
+	if dropped := b.getLogsDroppedByProcessingRules(); dropped > 0 {
+		metrics["LogsDroppedByProcessingRules"] = fmt.Sprintf("%v", dropped)
+	}
 	return metrics
 }
 
+// getLogsDroppedByProcessingRules returns the number of logs dropped by the sample
+// and rate_limit processing rules of all sources.
+func (b *Builder) getLogsDroppedByProcessingRules() int64 {
+	droppedByRules, ok := b.logsExpVars.Get("LogsDroppedByProcessingRules").(*expvar.Map)
+	if !ok {
+		return 0
+	}
+	var dropped int64
+	droppedByRules.Do(func(kv expvar.KeyValue) {
+		if count, ok := kv.Value.(*expvar.Int); ok {
+			dropped += count.Value()
+		}
+	})
+	return dropped
+}
+
 func (b *Builder) getProcessFileStats() map[string]uint64 {
 	stats := make(map[string]uint64)
 	fs, err := util.GetProcessFileStats()
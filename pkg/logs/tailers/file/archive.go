@@ -0,0 +1,191 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/DataDog/zstd"
+	"go.uber.org/atomic"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/decoder"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// CompletedArchiveOffset returns the offset recorded in the registry once an
+// archive of the given compressed size has been read entirely.
+func CompletedArchiveOffset(size int64) string {
+	return auditor.CompletedArchivePrefix + strconv.FormatInt(size, 10)
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+// archiveReader streams the decompressed content of an archive, and keeps
+// track of its progress to display it on the status page.
+type archiveReader struct {
+	compression    string
+	compressedSize int64
+	compressedRead *atomic.Int64
+	stream         io.ReadCloser
+	completed      *atomic.Bool
+}
+
+// newArchiveReader returns a reader decompressing the content of f.
+func newArchiveReader(f *os.File, compression string) (*archiveReader, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat %q: %w", f.Name(), err)
+	}
+
+	a := &archiveReader{
+		compression:    compression,
+		compressedSize: st.Size(),
+		compressedRead: atomic.NewInt64(0),
+		completed:      atomic.NewBool(false),
+	}
+	counter := &countingReader{reader: f, count: a.compressedRead}
+
+	switch compression {
+	case config.GzipCompression:
+		stream, err := gzip.NewReader(counter)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip archive %q: %w", f.Name(), err)
+		}
+		a.stream = stream
+	case config.ZstdCompression:
+		a.stream = zstd.NewReader(counter)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q for %q", compression, f.Name())
+	}
+	return a, nil
+}
+
+// Read reads decompressed data from the archive.
+func (a *archiveReader) Read(p []byte) (int, error) {
+	return a.stream.Read(p)
+}
+
+// Close releases the decompressor, the underlying file is not closed.
+func (a *archiveReader) Close() error {
+	return a.stream.Close()
+}
+
+// InfoKey returns the key displayed on the status page.
+func (a *archiveReader) InfoKey() string {
+	return "Archive Progress"
+}
+
+// Info returns the progress of the decompression.
+func (a *archiveReader) Info() []string {
+	if a.completed.Load() {
+		return []string{fmt.Sprintf("%s archive read entirely (%d compressed bytes)", a.compression, a.compressedSize)}
+	}
+	read := min(a.compressedRead.Load(), a.compressedSize)
+	progress := 100.0
+	if a.compressedSize > 0 {
+		progress = float64(read) * 100 / float64(a.compressedSize)
+	}
+	return []string{fmt.Sprintf("%s archive: %d of %d compressed bytes read (%.1f%%)", a.compression, read, a.compressedSize, progress)}
+}
+
+// setupArchive opens the file as a compressed archive. As archives can only be
+// read sequentially, the content before the given offset is decompressed and
+// discarded.
+func (t *Tailer) setupArchive(offset int64, whence int) error {
+	fullpath, err := filepath.Abs(t.file.Path)
+	if err != nil {
+		return err
+	}
+	t.fullpath = fullpath
+
+	// adds metadata to enable users to filter logs by filename
+	t.tags = t.buildTailerTags()
+
+	log.Info("Opening", t.file.Path, "as a", t.archiveCompression, "archive for tailer key", t.file.GetScanKey())
+	f, err := filesystem.OpenShared(fullpath)
+	if err != nil {
+		return err
+	}
+	archive, err := newArchiveReader(f, t.archiveCompression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	var skipped int64
+	if whence == io.SeekEnd {
+		skipped, err = io.Copy(io.Discard, archive)
+	} else {
+		skipped, err = io.CopyN(io.Discard, archive, offset)
+	}
+	if err != nil && err != io.EOF {
+		archive.Close()
+		f.Close()
+		return fmt.Errorf("could not seek to offset %d in archive %q: %w", offset, t.file.Path, err)
+	}
+
+	t.osFile = f
+	t.archive = archive
+	t.lastReadOffset.Store(skipped)
+	t.decodedOffset.Store(skipped)
+	t.info.Register(archive)
+
+	return nil
+}
+
+// readArchive reads the next chunk of decompressed data from the archive, and
+// returns io.EOF once the archive has been read entirely.
+func (t *Tailer) readArchive() (int, error) {
+	inBuf := make([]byte, 4096)
+	n, err := t.archive.Read(inBuf)
+	if n > 0 {
+		t.lastReadOffset.Add(int64(n))
+		t.decoder.InputChan <- decoder.NewInput(inBuf[:n])
+	}
+	switch {
+	case err == io.EOF && n == 0:
+		t.archive.completed.Store(true)
+		log.Info("Finished reading archive", t.file.Path, "for tailer key", t.file.GetScanKey())
+		return 0, io.EOF
+	case err != nil && err != io.EOF:
+		t.file.Source.Status().Error(err)
+		return n, log.Error("Unexpected error occurred while reading archive: ", err)
+	}
+	return n, nil
+}
+
+// IsArchive returns true if the tailer reads a compressed archive rather than
+// tailing a plain file.
+func (t *Tailer) IsArchive() bool {
+	return t.archiveCompression != ""
+}
+
+// CompletedArchiveSize returns the compressed size of the archive read by the
+// tailer, if it has been read entirely.
+func (t *Tailer) CompletedArchiveSize() (int64, bool) {
+	if t.archive == nil || !t.archive.completed.Load() {
+		return 0, false
+	}
+	return t.archive.compressedSize, true
+}
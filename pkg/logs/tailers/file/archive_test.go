@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	status "github.com/DataDog/datadog-agent/pkg/logs/status/utils"
+)
+
+func writeArchive(t *testing.T, path string, compression string, content string) int64 {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var w io.WriteCloser
+	if compression == config.GzipCompression {
+		w = gzip.NewWriter(f)
+	} else {
+		w = zstd.NewWriter(f)
+	}
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	return fi.Size()
+}
+
+func newArchiveTailer(path string, compression string, outputChan chan *message.Message) *Tailer {
+	source := sources.NewLogSource("", &config.LogsConfig{
+		Type:        config.FileType,
+		Path:        path,
+		Compression: compression,
+	})
+	info := status.NewInfoRegistry()
+	file := NewFile(path, source, false)
+	return NewTailer(&TailerOptions{
+		OutputChan:      outputChan,
+		File:            file,
+		SleepDuration:   10 * time.Millisecond,
+		Decoder:         decoder.NewDecoderFromSource(file.Source, info),
+		Info:            info,
+		PipelineMonitor: metrics.NewNoopPipelineMonitor(""),
+	})
+}
+
+func TestArchiveCompression(t *testing.T) {
+	tests := []struct {
+		path        string
+		compression string
+		expected    string
+	}{
+		{"/var/log/app.log.1.gz", "", ""},
+		{"/var/log/app.log.1.gz", config.AutoCompression, config.GzipCompression},
+		{"/var/log/app.log.1.ZST", config.AutoCompression, config.ZstdCompression},
+		{"/var/log/app.log", config.AutoCompression, ""},
+		{"/var/log/app.log.1", config.ZstdCompression, config.ZstdCompression},
+	}
+	for _, test := range tests {
+		source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: test.path, Compression: test.compression})
+		assert.Equal(t, test.expected, NewFile(test.path, source, false).ArchiveCompression(), test.path)
+	}
+}
+
+func TestTailArchive(t *testing.T) {
+	for _, compression := range []string{config.GzipCompression, config.ZstdCompression} {
+		t.Run(compression, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log.1")
+			size := writeArchive(t, path, compression, "hello world\nhello again\ngood bye\n")
+
+			outputChan := make(chan *message.Message, chanSize)
+			tailer := newArchiveTailer(path, compression, outputChan)
+			require.NoError(t, tailer.StartFromBeginning())
+			defer tailer.Stop()
+
+			msg := <-outputChan
+			assert.Equal(t, "hello world", string(msg.GetContent()))
+			assert.Equal(t, "12", msg.Origin.Offset)
+			msg = <-outputChan
+			assert.Equal(t, "hello again", string(msg.GetContent()))
+			assert.Equal(t, "24", msg.Origin.Offset)
+			msg = <-outputChan
+			assert.Equal(t, "good bye", string(msg.GetContent()))
+			assert.Equal(t, CompletedArchiveOffset(size), msg.Origin.Offset)
+
+			assert.Eventually(t, tailer.IsFinished, 5*time.Second, 10*time.Millisecond)
+			completedSize, completed := tailer.CompletedArchiveSize()
+			assert.True(t, completed)
+			assert.Equal(t, size, completedSize)
+			assert.Contains(t, tailer.GetInfo().Rendered()["Archive Progress"][0], "read entirely")
+
+			didRotate, err := tailer.DidRotate()
+			assert.NoError(t, err)
+			assert.False(t, didRotate)
+		})
+	}
+}
+
+func TestTailArchiveFromOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.1.gz")
+	writeArchive(t, path, config.GzipCompression, "hello world\ngood bye\n")
+
+	outputChan := make(chan *message.Message, chanSize)
+	tailer := newArchiveTailer(path, config.AutoCompression, outputChan)
+	require.NoError(t, tailer.Start(12, io.SeekStart))
+	defer tailer.Stop()
+
+	msg := <-outputChan
+	assert.Equal(t, "good bye", string(msg.GetContent()))
+}
@@ -8,7 +8,10 @@ package file
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
 )
 
@@ -45,3 +48,24 @@ func (t *File) GetScanKey() string {
 	}
 	return t.Path
 }
+
+// ArchiveCompression returns the compression format of the file if it must be
+// read as a compressed archive, or an empty string if it must be tailed as a
+// plain file.
+func (t *File) ArchiveCompression() string {
+	if t.Source == nil || t.Source.Config() == nil {
+		return ""
+	}
+	switch compression := t.Source.Config().Compression; compression {
+	case config.GzipCompression, config.ZstdCompression:
+		return compression
+	case config.AutoCompression:
+		switch strings.ToLower(filepath.Ext(t.Path)) {
+		case ".gz", ".gzip":
+			return config.GzipCompression
+		case ".zst", ".zstd":
+			return config.ZstdCompression
+		}
+	}
+	return ""
+}
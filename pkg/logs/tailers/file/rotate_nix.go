@@ -22,6 +22,11 @@ import (
 // - removed and recreated
 // - truncated
 func (t *Tailer) DidRotate() (bool, error) {
+	if t.IsArchive() {
+		// archives are read once until their end and are never rotated
+		return false, nil
+	}
+
 	f, err := filesystem.OpenShared(t.fullpath)
 	if err != nil {
 		return false, fmt.Errorf("open %q: %w", t.fullpath, err)
@@ -19,6 +19,11 @@ import (
 // On Windows, log rotation is identified by the file size being smaller
 // than the last offset read.
 func (t *Tailer) DidRotate() (bool, error) {
+	if t.IsArchive() {
+		// archives are read once until their end and are never rotated
+		return false, nil
+	}
+
 	f, err := filesystem.OpenShared(t.fullpath)
 	if err != nil {
 		return false, fmt.Errorf("open %q: %w", t.fullpath, err)
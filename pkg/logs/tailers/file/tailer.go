@@ -62,6 +62,15 @@ type Tailer struct {
 	// is platform-specific.
 	osFile *os.File
 
+	// archiveCompression is the compression format of the file when it is read
+	// as a compressed archive, and is empty for plain files.
+	archiveCompression string
+
+	// archive decompresses the content of osFile when the tailer reads a
+	// compressed archive.  Archives are read once until their end, and are
+	// never rotated.
+	archive *archiveReader
+
 	// tags are the tags to be attached to each log message, excluding tags provided
 	// by the tag provider.
 	tags []string
@@ -167,6 +176,7 @@ func NewTailer(opts *TailerOptions) *Tailer {
 
 	t := &Tailer{
 		file:                   opts.File,
+		archiveCompression:     opts.File.ArchiveCompression(),
 		outputChan:             opts.OutputChan,
 		decoder:                opts.Decoder,
 		tagProvider:            tagProvider,
@@ -232,7 +242,12 @@ func (t *Tailer) Identifier() string {
 
 // Start begins the tailer's operation in a dedicated goroutine.
 func (t *Tailer) Start(offset int64, whence int) error {
-	err := t.setup(offset, whence)
+	var err error
+	if t.IsArchive() {
+		err = t.setupArchive(offset, whence)
+	} else {
+		err = t.setup(offset, whence)
+	}
 	if err != nil {
 		t.file.Source.Status().Error(err)
 		return err
@@ -290,13 +305,22 @@ func (t *Tailer) StopAfterFileRotation() {
 // until it is closed or the tailer is stopped.
 func (t *Tailer) readForever() {
 	defer func() {
+		if t.archive != nil {
+			t.archive.Close()
+		}
 		t.osFile.Close()
 		t.decoder.Stop()
 		log.Info("Closed", t.file.Path, "for tailer key", t.file.GetScanKey(), "read", t.Source().BytesRead.Get(), "bytes and", t.decoder.GetLineCount(), "lines")
 	}()
 
 	for {
-		n, err := t.read()
+		var n int
+		var err error
+		if t.archive != nil {
+			n, err = t.readArchive()
+		} else {
+			n, err = t.read()
+		}
 		if err != nil {
 			return
 		}
@@ -342,6 +366,10 @@ func (t *Tailer) forwardMessages() {
 		t.isFinished.Store(true)
 		close(t.done)
 	}()
+
+	// pending holds back the latest message read from an archive, so that the
+	// last one can record the archive as completed in the registry.
+	var pending *message.Message
 	for output := range t.decoder.OutputChan {
 		offset := t.decodedOffset.Load() + int64(output.RawDataLen)
 		identifier := t.Identifier()
@@ -365,15 +393,33 @@ func (t *Tailer) forwardMessages() {
 		}
 
 		msg := message.NewMessage(output.GetContent(), origin, output.Status, output.IngestionTimestamp)
-		// Make the write to the output chan cancellable to be able to stop the tailer
-		// after a file rotation when it is stuck on it.
-		// We don't return directly to keep the same shutdown sequence that in the
-		// normal case.
-		select {
-		case t.outputChan <- msg:
-			t.PipelineMonitor.ReportComponentIngress(msg, "processor")
-		case <-t.forwardContext.Done():
+		if t.archive != nil {
+			pending, msg = msg, pending
+			if msg == nil {
+				continue
+			}
+		}
+		t.sendMessage(msg)
+	}
+
+	if pending != nil {
+		if size, completed := t.CompletedArchiveSize(); completed {
+			pending.Origin.Offset = CompletedArchiveOffset(size)
 		}
+		t.sendMessage(pending)
+	}
+}
+
+// sendMessage sends a message to the output channel.
+func (t *Tailer) sendMessage(msg *message.Message) {
+	// Make the write to the output chan cancellable to be able to stop the tailer
+	// after a file rotation when it is stuck on it.
+	// We don't return directly to keep the same shutdown sequence that in the
+	// normal case.
+	select {
+	case t.outputChan <- msg:
+		t.PipelineMonitor.ReportComponentIngress(msg, "processor")
+	case <-t.forwardContext.Done():
 	}
 }
 
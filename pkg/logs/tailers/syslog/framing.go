@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxMessageLengthDigits is the maximum number of digits of the length of an
+// octet-counted message.
+const maxMessageLengthDigits = 10
+
+var errInvalidFrame = errors.New("invalid octet-counted syslog frame")
+
+// ReadFrame reads the next message from a syslog stream. As described in RFC 6587,
+// messages are framed either by octet counting, when they are prefixed with
+// their length, or by a trailing line feed. Messages bigger than maxSize are
+// truncated, and the rest of their content is discarded.
+func ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		return readOctetCountedFrame(r, maxSize)
+	}
+	return readLineFrame(r, maxSize)
+}
+
+// readOctetCountedFrame reads a MSG-LEN SP SYSLOG-MSG frame.
+func readOctetCountedFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var digits []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' {
+			break
+		}
+		if b < '0' || b > '9' || len(digits) == maxMessageLengthDigits {
+			return nil, errInvalidFrame
+		}
+		digits = append(digits, b)
+	}
+	length, err := strconv.Atoi(string(digits))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidFrame, err)
+	}
+
+	frame := make([]byte, min(length, maxSize))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	if length > maxSize {
+		if _, err := r.Discard(length - maxSize); err != nil {
+			return nil, err
+		}
+	}
+	return frame, nil
+}
+
+// readLineFrame reads a frame terminated by a line feed, a frame at the end of
+// the stream does not need to be terminated.
+func readLineFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var frame []byte
+	for {
+		line, err := r.ReadSlice('\n')
+		if len(frame) < maxSize {
+			frame = append(frame, line[:min(len(line), maxSize-len(frame))]...)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(frame) > 0:
+			return frame, nil
+		case err != nil:
+			return nil, err
+		}
+		return frame, nil
+	}
+}
@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFrame(t *testing.T) {
+	multiline := "<14>1 - - - - - multi\nline"
+	stream := "11 <13>1 - - -" + "<14>first line\n" + fmt.Sprintf("%d %s", len(multiline), multiline) + "<14>last line"
+	r := bufio.NewReaderSize(strings.NewReader(stream), 16)
+
+	for _, expected := range []string{"<13>1 - - -", "<14>first line\n", multiline, "<14>last line"} {
+		frame, err := ReadFrame(r, 1024)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(frame))
+	}
+	_, err := ReadFrame(r, 1024)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadFrameTruncatesBigMessages(t *testing.T) {
+	stream := "20 <14>" + strings.Repeat("a", 16) + strings.Repeat("b", 40) + "\n" + "<14>next\n"
+	r := bufio.NewReaderSize(strings.NewReader(stream), 16)
+
+	frame, err := ReadFrame(r, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "<14>aaaaaa", string(frame))
+	frame, err = ReadFrame(r, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "bbbbbbbbbb", string(frame))
+	frame, err = ReadFrame(r, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "<14>next\n", string(frame))
+}
+
+func TestReadFrameInvalidLength(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("12a <14>hello"))
+	_, err := ReadFrame(r, 1024)
+	assert.ErrorIs(t, err, errInvalidFrame)
+
+	r = bufio.NewReader(strings.NewReader("99999999999 <14>hello"))
+	_, err = ReadFrame(r, 1024)
+	assert.ErrorIs(t, err, errInvalidFrame)
+}
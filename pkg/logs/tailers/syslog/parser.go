@@ -0,0 +1,275 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// nilValue is the value of the RFC 5424 header fields that are not set.
+const nilValue = "-"
+
+// rfc3164TimestampLayout is the layout of the timestamps of RFC 3164 messages.
+const rfc3164TimestampLayout = "Jan _2 15:04:05"
+
+var (
+	errMissingPriority = errors.New("syslog message must start with a priority")
+	errInvalidHeader   = errors.New("invalid syslog header")
+	errInvalidSD       = errors.New("invalid syslog structured data")
+
+	utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// severityStatuses maps the syslog severities to message statuses.
+var severityStatuses = []string{
+	message.StatusEmergency,
+	message.StatusAlert,
+	message.StatusCritical,
+	message.StatusError,
+	message.StatusWarning,
+	message.StatusNotice,
+	message.StatusInfo,
+	message.StatusDebug,
+}
+
+// Message is a syslog message, in either the RFC 5424 or the RFC 3164 format.
+type Message struct {
+	Facility int
+	Severity int
+	// Version is 1 for RFC 5424 messages, and 0 for RFC 3164 ones.
+	Version        int
+	Timestamp      string
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData []StructuredElement
+	Msg            []byte
+}
+
+// StructuredElement is an element of the structured data of an RFC 5424 message.
+type StructuredElement struct {
+	ID     string
+	Params []StructuredParam
+}
+
+// StructuredParam is a parameter of a structured data element.
+type StructuredParam struct {
+	Name  string
+	Value string
+}
+
+// Status returns the message status matching the severity of the message.
+func (m *Message) Status() string {
+	return severityStatuses[m.Severity]
+}
+
+// Parse parses a syslog message. RFC 5424 messages are recognized by their
+// version, other messages are parsed as RFC 3164 ones as leniently as possible.
+func Parse(frame []byte) (*Message, error) {
+	frame = bytes.TrimRight(frame, "\r\n\x00")
+	priority, rest, err := parsePriority(frame)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		Facility: priority / 8,
+		Severity: priority % 8,
+	}
+	if len(rest) > 2 && rest[0] == '1' && rest[1] == ' ' {
+		msg.Version = 1
+		err = parseRFC5424(msg, rest[2:])
+	} else {
+		parseRFC3164(msg, rest)
+	}
+	return msg, err
+}
+
+// parsePriority parses the <PRI> part of a message.
+func parsePriority(frame []byte) (int, []byte, error) {
+	if len(frame) < 3 || frame[0] != '<' {
+		return 0, nil, errMissingPriority
+	}
+	end := bytes.IndexByte(frame[:min(len(frame), 5)], '>')
+	if end < 2 {
+		return 0, nil, errMissingPriority
+	}
+	priority, err := strconv.Atoi(string(frame[1:end]))
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, nil, errMissingPriority
+	}
+	return priority, frame[end+1:], nil
+}
+
+// nextField splits the next space-separated field from data.
+func nextField(data []byte) (string, []byte, bool) {
+	end := bytes.IndexByte(data, ' ')
+	if end < 0 {
+		return string(data), nil, len(data) > 0
+	}
+	return string(data[:end]), data[end+1:], end > 0
+}
+
+// headerValue returns the value of an RFC 5424 header field.
+func headerValue(field string) string {
+	if field == nilValue {
+		return ""
+	}
+	return field
+}
+
+// parseRFC5424 parses the content following the version of an RFC 5424 message:
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parseRFC5424(msg *Message, data []byte) error {
+	var fields [5]string
+	for i := range fields {
+		var ok bool
+		if fields[i], data, ok = nextField(data); !ok {
+			return errInvalidHeader
+		}
+	}
+	msg.Timestamp = headerValue(fields[0])
+	msg.Hostname = headerValue(fields[1])
+	msg.AppName = headerValue(fields[2])
+	msg.ProcID = headerValue(fields[3])
+	msg.MsgID = headerValue(fields[4])
+
+	if len(data) == 0 {
+		return errInvalidHeader
+	}
+	if data[0] == '-' {
+		data = data[1:]
+	} else {
+		var err error
+		if msg.StructuredData, data, err = parseStructuredData(data); err != nil {
+			return err
+		}
+	}
+	if len(data) > 0 {
+		if data[0] != ' ' {
+			return errInvalidSD
+		}
+		data = data[1:]
+	}
+	msg.Msg = bytes.TrimPrefix(data, utf8BOM)
+	return nil
+}
+
+// parseStructuredData parses a list of [SD-ID PARAM-NAME="PARAM-VALUE" ...] elements.
+func parseStructuredData(data []byte) ([]StructuredElement, []byte, error) {
+	var elements []StructuredElement
+	for len(data) > 0 && data[0] == '[' {
+		data = data[1:]
+		end := bytes.IndexAny(data, " ]")
+		if end <= 0 {
+			return nil, nil, errInvalidSD
+		}
+		element := StructuredElement{ID: string(data[:end])}
+		data = data[end:]
+
+		for len(data) > 0 && data[0] == ' ' {
+			data = data[1:]
+			eq := bytes.IndexByte(data, '=')
+			if eq <= 0 || eq+1 >= len(data) || data[eq+1] != '"' {
+				return nil, nil, errInvalidSD
+			}
+			name := string(data[:eq])
+			value, rest, err := parseParamValue(data[eq+2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			element.Params = append(element.Params, StructuredParam{Name: name, Value: value})
+			data = rest
+		}
+		if len(data) == 0 || data[0] != ']' {
+			return nil, nil, errInvalidSD
+		}
+		data = data[1:]
+		elements = append(elements, element)
+	}
+	if len(elements) == 0 {
+		return nil, nil, errInvalidSD
+	}
+	return elements, data, nil
+}
+
+// parseParamValue parses a quoted parameter value, in which '"', '\' and ']'
+// are escaped with a backslash.
+func parseParamValue(data []byte) (string, []byte, error) {
+	var value []byte
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) && (data[i+1] == '"' || data[i+1] == '\\' || data[i+1] == ']') {
+				i++
+			}
+			value = append(value, data[i])
+		case '"':
+			return string(value), data[i+1:], nil
+		default:
+			value = append(value, data[i])
+		}
+	}
+	return "", nil, errInvalidSD
+}
+
+// parseRFC3164 parses the content following the priority of an RFC 3164 message:
+// TIMESTAMP HOSTNAME TAG[PID]: MSG
+// As the format is loosely followed, every part of the header is optional and
+// anything that cannot be parsed is kept in the message.
+func parseRFC3164(msg *Message, data []byte) {
+	if len(data) >= len(rfc3164TimestampLayout) {
+		if _, err := time.Parse(rfc3164TimestampLayout, string(data[:len(rfc3164TimestampLayout)])); err == nil {
+			msg.Timestamp = string(data[:len(rfc3164TimestampLayout)])
+			data = bytes.TrimPrefix(data[len(rfc3164TimestampLayout):], []byte(" "))
+		}
+	}
+	if msg.Timestamp == "" {
+		// some senders use RFC 3339 timestamps with the RFC 3164 format
+		if field, rest, ok := nextField(data); ok {
+			if _, err := time.Parse(time.RFC3339Nano, field); err == nil {
+				msg.Timestamp = field
+				data = rest
+			}
+		}
+	}
+
+	// the hostname is only present when followed by a tag
+	if field, rest, ok := nextField(data); ok && !isTag(field) {
+		if tag, _, ok := nextField(rest); ok && isTag(tag) {
+			msg.Hostname = field
+			data = rest
+		}
+	}
+	if field, rest, ok := nextField(data); ok && isTag(field) {
+		field = field[:len(field)-1]
+		if start := bytes.IndexByte([]byte(field), '['); start > 0 && field[len(field)-1] == ']' {
+			msg.ProcID = field[start+1 : len(field)-1]
+			field = field[:start]
+		}
+		msg.AppName = field
+		data = rest
+	}
+	msg.Msg = data
+}
+
+// isTag returns true if the field is a TAG[PID]: field of an RFC 3164 message.
+func isTag(field string) bool {
+	if len(field) < 2 || field[len(field)-1] != ':' {
+		return false
+	}
+	for _, r := range field[:len(field)-1] {
+		if r == ':' || r == '"' || r == '=' {
+			return false
+		}
+	}
+	return true
+}
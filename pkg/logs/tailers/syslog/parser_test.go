@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	msg, err := Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] ` + "\xEF\xBB\xBF" + `An application event log entry...` + "\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, msg.Facility)
+	assert.Equal(t, 5, msg.Severity)
+	assert.Equal(t, message.StatusNotice, msg.Status())
+	assert.Equal(t, 1, msg.Version)
+	assert.Equal(t, "2003-10-11T22:14:15.003Z", msg.Timestamp)
+	assert.Equal(t, "mymachine.example.com", msg.Hostname)
+	assert.Equal(t, "evntslog", msg.AppName)
+	assert.Equal(t, "", msg.ProcID)
+	assert.Equal(t, "ID47", msg.MsgID)
+	assert.Equal(t, []StructuredElement{
+		{ID: "exampleSDID@32473", Params: []StructuredParam{{"iut", "3"}, {"eventSource", "Application"}, {"eventID", "1011"}}},
+		{ID: "examplePriority@32473", Params: []StructuredParam{{"class", "high"}}},
+	}, msg.StructuredData)
+	assert.Equal(t, "An application event log entry...", string(msg.Msg))
+}
+
+func TestParseRFC5424WithoutStructuredData(t *testing.T) {
+	msg, err := Parse([]byte(`<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8`))
+	require.NoError(t, err)
+	assert.Equal(t, message.StatusCritical, msg.Status())
+	assert.Equal(t, "su", msg.AppName)
+	assert.Nil(t, msg.StructuredData)
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", string(msg.Msg))
+
+	msg, err = Parse([]byte(`<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - -`))
+	require.NoError(t, err)
+	assert.Equal(t, "8710", msg.ProcID)
+	assert.Empty(t, msg.Msg)
+}
+
+func TestParseRFC5424EscapedParamValues(t *testing.T) {
+	msg, err := Parse([]byte(`<14>1 - host app - - [meta path="C:\\temp" quote="a \"b\"" bracket="[x\]"] done`))
+	require.NoError(t, err)
+	assert.Equal(t, []StructuredParam{{"path", `C:\temp`}, {"quote", `a "b"`}, {"bracket", "[x]"}}, msg.StructuredData[0].Params)
+	assert.Equal(t, "done", string(msg.Msg))
+}
+
+func TestParseRFC3164(t *testing.T) {
+	tests := []struct {
+		input     string
+		timestamp string
+		hostname  string
+		appName   string
+		procID    string
+		msg       string
+	}{
+		{`<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`, "Oct 11 22:14:15", "mymachine", "su", "", "'su root' failed for lonvick on /dev/pts/8"},
+		{`<13>Feb  5 17:32:18 10.0.0.99 sshd[4242]: Accepted publickey for bob`, "Feb  5 17:32:18", "10.0.0.99", "sshd", "4242", "Accepted publickey for bob"},
+		{`<13>Feb  5 17:32:18 cron[12]: job done`, "Feb  5 17:32:18", "", "cron", "12", "job done"},
+		{`<13>2024-02-05T17:32:18.123+01:00 web-1 nginx: GET / 200`, "2024-02-05T17:32:18.123+01:00", "web-1", "nginx", "", "GET / 200"},
+		{`<13>just some text`, "", "", "", "", "just some text"},
+	}
+	for _, test := range tests {
+		msg, err := Parse([]byte(test.input))
+		require.NoError(t, err, test.input)
+		assert.Equal(t, 0, msg.Version, test.input)
+		assert.Equal(t, test.timestamp, msg.Timestamp, test.input)
+		assert.Equal(t, test.hostname, msg.Hostname, test.input)
+		assert.Equal(t, test.appName, msg.AppName, test.input)
+		assert.Equal(t, test.procID, msg.ProcID, test.input)
+		assert.Equal(t, test.msg, string(msg.Msg), test.input)
+	}
+}
+
+func TestParseInvalidMessages(t *testing.T) {
+	for _, input := range []string{"", "hello", "<>1 - - - - - -", "<192>Oct 11 22:14:15 host app: msg", "<abc>msg"} {
+		msg, err := Parse([]byte(input))
+		assert.ErrorIs(t, err, errMissingPriority, input)
+		assert.Nil(t, msg, input)
+	}
+
+	for _, input := range []string{"<14>1 - host", "<14>1 - host app - - [meta a=\"b] msg", "<14>1 - host app - - [meta]msg"} {
+		msg, err := Parse([]byte(input))
+		assert.Error(t, err, input)
+		assert.NotNil(t, msg, input)
+	}
+}
@@ -0,0 +1,164 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package syslog implements a tailer parsing the syslog messages received on a
+// network connection.
+package syslog
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Tailer reads syslog messages from a net.Conn. It uses a `read` callback
+// returning one message at a time to be generic over types of connections.
+type Tailer struct {
+	source     *sources.LogSource
+	Conn       net.Conn
+	outputChan chan *message.Message
+	read       func(*Tailer) ([]byte, string, error)
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewTailer returns a new Tailer
+func NewTailer(source *sources.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, string, error)) *Tailer {
+	return &Tailer{
+		source:     source,
+		Conn:       conn,
+		outputChan: outputChan,
+		read:       read,
+		stop:       make(chan struct{}, 1),
+		done:       make(chan struct{}, 1),
+	}
+}
+
+// Start starts reading messages from the connection
+func (t *Tailer) Start() {
+	go t.readForever()
+}
+
+// Stop stops the tailer and waits for the last message to be forwarded
+func (t *Tailer) Stop() {
+	t.stop <- struct{}{}
+	t.Conn.Close()
+	<-t.done
+}
+
+// readForever reads the messages from conn.
+func (t *Tailer) readForever() {
+	defer func() {
+		t.Conn.Close()
+		t.done <- struct{}{}
+	}()
+	for {
+		select {
+		case <-t.stop:
+			// stop reading data from the connection
+			return
+		default:
+			frame, ipAddress, err := t.read(t)
+			if err != nil && err == io.EOF {
+				// connection has been closed client-side, stop from reading new data
+				return
+			}
+			if err != nil {
+				// an error occurred, stop from reading new data
+				log.Warnf("Couldn't read syslog message from connection: %v", err)
+				return
+			}
+			if len(bytes.TrimSpace(frame)) == 0 {
+				continue
+			}
+			t.source.RecordBytes(int64(len(frame)))
+			t.outputChan <- t.newMessage(frame, ipAddress)
+		}
+	}
+}
+
+// newMessage builds a message from a syslog frame. The content of the message
+// is the MSG part of the frame, while the header fields are kept as attributes.
+func (t *Tailer) newMessage(frame []byte, ipAddress string) *message.Message {
+	origin := message.NewOrigin(t.source)
+	var tags []string
+	if ipAddress != "" && pkgconfigsetup.Datadog().GetBool("logs_config.use_sourcehost_tag") {
+		if host, _, err := net.SplitHostPort(ipAddress); err == nil {
+			ipAddress = host
+		}
+		tags = append(tags, "source_host:"+ipAddress)
+	}
+
+	parsed, err := Parse(frame)
+	if parsed == nil {
+		// not a syslog message, forward it as is
+		log.Debugf("Could not parse syslog message: %v", err)
+		origin.SetTags(tags)
+		return message.NewMessage(bytes.TrimRight(frame, "\r\n"), origin, message.StatusInfo, time.Now().UnixNano())
+	}
+	if err != nil {
+		// the header could be parsed but not the rest of the message, keep it entirely
+		log.Debugf("Could not parse syslog message: %v", err)
+		parsed.Msg = bytes.TrimRight(frame, "\r\n")
+	}
+
+	attributes := map[string]interface{}{
+		"facility": parsed.Facility,
+		"severity": parsed.Severity,
+		"version":  parsed.Version,
+	}
+	for key, value := range map[string]string{
+		"timestamp": parsed.Timestamp,
+		"hostname":  parsed.Hostname,
+		"appname":   parsed.AppName,
+		"procid":    parsed.ProcID,
+		"msgid":     parsed.MsgID,
+	} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+
+	if len(parsed.StructuredData) > 0 {
+		if t.source.Config.StructuredData == config.SyslogStructuredDataAttributes {
+			structuredData := make(map[string]interface{}, len(parsed.StructuredData))
+			for _, element := range parsed.StructuredData {
+				params := make(map[string]string, len(element.Params))
+				for _, param := range element.Params {
+					params[param.Name] = param.Value
+				}
+				structuredData[element.ID] = params
+			}
+			attributes["structured_data"] = structuredData
+		} else {
+			for _, element := range parsed.StructuredData {
+				for _, param := range element.Params {
+					tags = append(tags, element.ID+"."+param.Name+":"+param.Value)
+				}
+			}
+		}
+	}
+
+	// the service of the integration config, if any, takes precedence
+	if parsed.AppName != "" {
+		origin.SetService(parsed.AppName)
+	}
+	origin.SetTags(tags)
+
+	content := &message.BasicStructuredContent{
+		Data: map[string]interface{}{
+			"syslog": attributes,
+		},
+	}
+	content.SetContent(parsed.Msg)
+	return message.NewStructuredMessage(content, origin, parsed.Status(), time.Now().UnixNano())
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func newTestTailer(cfg *config.LogsConfig) (*Tailer, net.Conn, chan *message.Message) {
+	msgChan := make(chan *message.Message)
+	r, w := net.Pipe()
+	reader := bufio.NewReader(r)
+	read := func(*Tailer) ([]byte, string, error) {
+		frame, err := ReadFrame(reader, 9000)
+		return frame, "", err
+	}
+	return NewTailer(sources.NewLogSource("", cfg), r, msgChan, read), w, msgChan
+}
+
+func renderSyslogAttributes(t *testing.T, msg *message.Message) map[string]interface{} {
+	rendered, err := msg.Render()
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(rendered, &payload))
+	return payload["syslog"].(map[string]interface{})
+}
+
+func TestTailerForwardsParsedMessages(t *testing.T) {
+	tailer, w, msgChan := newTestTailer(&config.LogsConfig{Type: config.SyslogType})
+	tailer.Start()
+	defer tailer.Stop()
+
+	go w.Write([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine evntslog 42 ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event` + "\n"))
+	msg := <-msgChan
+
+	assert.Equal(t, "An application event", string(msg.GetContent()))
+	assert.Equal(t, message.StatusNotice, msg.GetStatus())
+	assert.Equal(t, "evntslog", msg.Origin.Service())
+	assert.Equal(t, []string{"exampleSDID@32473.iut:3", "exampleSDID@32473.eventSource:Application"}, msg.Origin.Tags(nil))
+
+	attributes := renderSyslogAttributes(t, msg)
+	assert.Equal(t, "mymachine", attributes["hostname"])
+	assert.Equal(t, "42", attributes["procid"])
+	assert.Equal(t, "ID47", attributes["msgid"])
+	assert.EqualValues(t, 20, attributes["facility"])
+	assert.EqualValues(t, 5, attributes["severity"])
+	assert.NotContains(t, attributes, "structured_data")
+}
+
+func TestTailerStructuredDataAsAttributes(t *testing.T) {
+	tailer, w, msgChan := newTestTailer(&config.LogsConfig{Type: config.SyslogType, StructuredData: config.SyslogStructuredDataAttributes})
+	tailer.Start()
+	defer tailer.Stop()
+
+	go w.Write([]byte(`<14>1 - host app - - [meta sequenceId="1"] started` + "\n"))
+	msg := <-msgChan
+
+	assert.Empty(t, msg.Origin.Tags(nil))
+	attributes := renderSyslogAttributes(t, msg)
+	assert.Equal(t, map[string]interface{}{"meta": map[string]interface{}{"sequenceId": "1"}}, attributes["structured_data"])
+}
+
+func TestTailerForwardsUnparsableMessages(t *testing.T) {
+	tailer, w, msgChan := newTestTailer(&config.LogsConfig{Type: config.SyslogType})
+	tailer.Start()
+	defer tailer.Stop()
+
+	go w.Write([]byte("not a syslog message\n"))
+	msg := <-msgChan
+	assert.Equal(t, "not a syslog message", string(msg.GetContent()))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+
+	// the header is valid but not the structured data
+	go w.Write([]byte("<11>1 - host app - - [broken\n"))
+	msg = <-msgChan
+	assert.Equal(t, "<11>1 - host app - - [broken", string(msg.GetContent()))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+}
@@ -43,7 +43,9 @@ func (a *AddSampleTelemetry) Inc(isStateful bool) {
 }
 
 // AddSample add a sample to the current ContextMetrics and initialize a new metrics if needed.
-func (m ContextMetrics) AddSample(contextKey ckey.ContextKey, sample *MetricSample, timestamp float64, interval int64, t *AddSampleTelemetry, config pkgconfigmodel.Config) error {
+// New histograms compute the aggregates and percentiles of histogramConfig, or the default
+// ones if it is nil.
+func (m ContextMetrics) AddSample(contextKey ckey.ContextKey, sample *MetricSample, timestamp float64, interval int64, histogramConfig *HistogramConfig, t *AddSampleTelemetry, config pkgconfigmodel.Config) error {
 	if math.IsInf(sample.Value, 0) || math.IsNaN(sample.Value) {
 		return fmt.Errorf("sample with value '%v'", sample.Value)
 	}
@@ -58,9 +60,9 @@ func (m ContextMetrics) AddSample(contextKey ckey.ContextKey, sample *MetricSamp
 		case MonotonicCountType:
 			m[contextKey] = &MonotonicCount{}
 		case HistogramType:
-			m[contextKey] = NewHistogram(interval, config) // default histogram configuration (no call to `configure`) for now
+			m[contextKey] = newConfiguredHistogram(interval, histogramConfig, config)
 		case HistorateType:
-			m[contextKey] = NewHistorate(interval, config) // internal histogram has the configuration for now
+			m[contextKey] = newConfiguredHistorate(interval, histogramConfig, config)
 		case SetType:
 			m[contextKey] = NewSet()
 		case CounterType:
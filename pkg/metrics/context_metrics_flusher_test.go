@@ -78,7 +78,7 @@ func addMetricSample(contextMetrics ContextMetrics, contextKey int, value float6
 		Value: value,
 		Mtype: GaugeType,
 	}
-	contextMetrics.AddSample(ckey.ContextKey(contextKey), &mSample, 1, 10, nil, c)
+	contextMetrics.AddSample(ckey.ContextKey(contextKey), &mSample, 1, 10, nil, nil, c)
 }
 
 func flushAndClear(require *require.Assertions, flusher *ContextMetricsFlusher) [][]*Serie {
@@ -25,7 +25,7 @@ func TestContextMetricsGaugeSampling(t *testing.T) {
 	}
 	c := setupConfig(t)
 
-	metrics.AddSample(contextKey, &mSample, 1, 10, nil, c)
+	metrics.AddSample(contextKey, &mSample, 1, 10, nil, nil, c)
 	series, err := metrics.Flush(12345)
 
 	assert.Len(t, err, 0)
@@ -52,7 +52,7 @@ func TestContextMetricsGaugeSamplingNoSample(t *testing.T) {
 	}
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &mSample, 1, 10, nil, c)
+	metrics.AddSample(contextKey, &mSample, 1, 10, nil, nil, c)
 	series, err := metrics.Flush(12345)
 
 	assert.Len(t, err, 0)
@@ -81,8 +81,8 @@ func TestContextMetricsGaugeSamplingInvalidSamples(t *testing.T) {
 		Mtype: GaugeType,
 	}
 
-	metrics.AddSample(contextKey1, &mSample1, 1, 10, nil, c)
-	metrics.AddSample(contextKey2, &mSample2, 1, 10, nil, c)
+	metrics.AddSample(contextKey1, &mSample1, 1, 10, nil, nil, c)
+	metrics.AddSample(contextKey2, &mSample2, 1, 10, nil, nil, c)
 	series, err := metrics.Flush(20)
 	assert.Len(t, err, 0)
 	assert.Equal(t, 0, len(series))
@@ -92,7 +92,7 @@ func TestContextMetricsGaugeSamplingInvalidSamples(t *testing.T) {
 		Value: math.NaN(),
 		Mtype: GaugeType,
 	}
-	metrics.AddSample(contextKey1, &mSample3, 1, 30, nil, c)
+	metrics.AddSample(contextKey1, &mSample3, 1, 30, nil, nil, c)
 	series, err = metrics.Flush(40)
 	assert.Len(t, err, 0)
 	assert.Equal(t, 0, len(series))
@@ -102,7 +102,7 @@ func TestContextMetricsGaugeSamplingInvalidSamples(t *testing.T) {
 		Value: 1,
 		Mtype: GaugeType,
 	}
-	metrics.AddSample(contextKey1, &mSample4, 1, 50, nil, c)
+	metrics.AddSample(contextKey1, &mSample4, 1, 50, nil, nil, c)
 	series, err = metrics.Flush(60)
 	assert.Len(t, err, 0)
 	expectedSerie := &Serie{
@@ -122,14 +122,14 @@ func TestContextMetricsSingleRateSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12340, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12340, 10, nil, nil, c)
 	series, err := metrics.Flush(12345)
 
 	assert.Len(t, err, 0)
 	// No series flushed since the rate was sampled once only
 	assert.Equal(t, 0, len(series))
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12350, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12350, 10, nil, nil, c)
 	series, err = metrics.Flush(12351)
 
 	assert.Len(t, err, 0)
@@ -152,8 +152,8 @@ func TestContextMetricsNegativeRateSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12350, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12350, 10, nil, nil, c)
 	series, err := metrics.Flush(12351)
 
 	assert.Len(t, series, 0)
@@ -166,8 +166,8 @@ func TestContextMetricsCountSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 1}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 5}, 12345, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 1}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 5}, 12345, 10, nil, nil, c)
 	series, err := metrics.Flush(12350)
 
 	assert.Len(t, err, 0)
@@ -188,8 +188,8 @@ func TestContextMetricsMonotonicCountSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 	c := setupConfig(t)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 1}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 5}, 12345, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 1}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 5}, 12345, 10, nil, nil, c)
 	series, err := metrics.Flush(12350)
 
 	assert.Len(t, err, 0)
@@ -210,10 +210,10 @@ func TestContextMetricsHistogramSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 2}, 12342, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12350, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 6}, 12350, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 2}, 12342, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12350, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 6}, 12350, 10, nil, nil, c)
 	series, err := metrics.Flush(12351)
 
 	assert.Len(t, err, 0)
@@ -262,10 +262,10 @@ func TestContextMetricsHistorateSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 1}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 2}, 12341, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12342, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12343, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 1}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 2}, 12341, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12342, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12343, 10, nil, nil, c)
 	series, err := metrics.Flush(12351)
 
 	assert.Len(t, err, 0)
@@ -321,8 +321,8 @@ func TestContextMetricsGaugeWithTimestampSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: GaugeWithTimestampType, Value: 1}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: GaugeWithTimestampType, Value: 5}, 12345, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: GaugeWithTimestampType, Value: 1}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: GaugeWithTimestampType, Value: 5}, 12345, 10, nil, nil, c)
 	series, err := metrics.Flush(12350)
 
 	assert.Len(t, err, 0)
@@ -343,8 +343,8 @@ func TestContextMetricsCountWithTimestampSampling(t *testing.T) {
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
 	c := setupConfig(t)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: CountWithTimestampType, Value: 1}, 12340, 10, nil, c)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: CountWithTimestampType, Value: 5}, 12345, 10, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: CountWithTimestampType, Value: 1}, 12340, 10, nil, nil, c)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: CountWithTimestampType, Value: 5}, 12345, 10, nil, nil, c)
 	series, err := metrics.Flush(12350)
 
 	assert.Len(t, err, 0)
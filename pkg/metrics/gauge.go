@@ -7,19 +7,20 @@ package metrics
 
 // Gauge tracks the value of a metric
 type Gauge struct {
-	gauge   float64
-	sampled bool
+	gauge     float64
+	timestamp float64
+	sampled   bool
 }
 
-//nolint:revive // TODO(AML) Fix revive linter
-func (g *Gauge) addSample(sample *MetricSample, _ float64) {
+func (g *Gauge) addSample(sample *MetricSample, timestamp float64) {
 	g.gauge = sample.Value
+	g.timestamp = timestamp
 	g.sampled = true
 }
 
 func (g *Gauge) flush(timestamp float64) ([]*Serie, error) {
-	value, sampled := g.gauge, g.sampled
-	g.gauge, g.sampled = 0, false
+	value, sampleTimestamp, sampled := g.gauge, g.timestamp, g.sampled
+	g.gauge, g.timestamp, g.sampled = 0, 0, false
 
 	if !sampled {
 		return []*Serie{}, NoSerieError{}
@@ -28,8 +29,9 @@ func (g *Gauge) flush(timestamp float64) ([]*Serie, error) {
 	return []*Serie{
 		{
 			// we use the timestamp passed to the flush
-			Points: []Point{{Ts: timestamp, Value: value}},
-			MType:  APIGaugeType,
+			Points:          []Point{{Ts: timestamp, Value: value}},
+			MType:           APIGaugeType,
+			SampleTimestamp: sampleTimestamp,
 		},
 	}, nil
 }
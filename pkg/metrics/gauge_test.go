@@ -27,4 +27,5 @@ func TestGaugeSampling(t *testing.T) {
 	assert.Len(t, series[0].Points, 1)
 	assert.InEpsilon(t, 2, series[0].Points[0].Value, epsilon)
 	assert.EqualValues(t, 60, series[0].Points[0].Ts)
+	assert.EqualValues(t, 55, series[0].SampleTimestamp)
 }
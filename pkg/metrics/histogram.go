@@ -59,18 +59,27 @@ func parsePercentiles(percentiles []string) []int {
 			log.Errorf("Could not parse '%s' from 'histogram_percentiles' (skipping): %s", p, err)
 			continue
 		}
-		if i < 0 || i > 1 {
-			log.Errorf("histogram_percentiles must be between 0 and 1: skipping %f", i)
+		percentile, err := toPercentile(i)
+		if err != nil {
+			log.Errorf("histogram_percentiles %s: skipping %f", err, i)
 			continue
 		}
-		// in some cases the '*100' will lower the number resulting in
-		// an int lower by 1 from what is expected (ex: 0.29 would
-		// become 28). As a workaround we add 0.5 before casting.
-		res = append(res, int(i*100+0.5))
+		res = append(res, percentile)
 	}
 	return res
 }
 
+// toPercentile converts a percentile in the 0-1 range to the 0-100 range.
+func toPercentile(p float64) (int, error) {
+	if p < 0 || p > 1 {
+		return 0, fmt.Errorf("must be between 0 and 1")
+	}
+	// in some cases the '*100' will lower the number resulting in
+	// an int lower by 1 from what is expected (ex: 0.29 would
+	// become 28). As a workaround we add 0.5 before casting.
+	return int(p*100 + 0.5), nil
+}
+
 // NewHistogram returns a newly initialized histogram
 func NewHistogram(interval int64, config pkgconfigmodel.Config) *Histogram {
 	// we initialize default value on the first histogram creation
@@ -95,6 +104,18 @@ func NewHistogram(interval int64, config pkgconfigmodel.Config) *Histogram {
 	}
 }
 
+// newConfiguredHistogram returns a newly initialized histogram computing the
+// aggregates and percentiles of histogramConfig, or the default ones if it is nil.
+func newConfiguredHistogram(interval int64, histogramConfig *HistogramConfig, config pkgconfigmodel.Config) *Histogram {
+	h := NewHistogram(interval, config)
+	if histogramConfig != nil {
+		// the percentiles of the configuration are already sorted
+		h.aggregates = histogramConfig.aggregates
+		h.percentiles = histogramConfig.percentiles
+	}
+	return h
+}
+
 func (h *Histogram) configure(aggregates []string, percentiles []int) {
 	h.aggregates = aggregates
 	sort.Ints(percentiles)
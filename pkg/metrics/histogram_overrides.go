@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package metrics
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync/atomic"
+
+	pkgconfigmodel "github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/config/structure"
+)
+
+// HistogramConfig is the aggregates and percentiles computed by a histogram.
+type HistogramConfig struct {
+	aggregates  []string
+	percentiles []int // sorted, each in the 1-100 range
+}
+
+// HistogramOverrideConfig is an entry of the `histogram_overrides` setting. It
+// sets the aggregates and percentiles of the histograms whose name matches its
+// glob pattern, instead of `histogram_aggregates` and `histogram_percentiles`.
+type HistogramOverrideConfig struct {
+	Match       string    `mapstructure:"match" json:"match" yaml:"match"`
+	Aggregates  []string  `mapstructure:"aggregates" json:"aggregates" yaml:"aggregates"`
+	Percentiles []float64 `mapstructure:"percentiles" json:"percentiles" yaml:"percentiles"`
+}
+
+type histogramOverride struct {
+	match  string
+	config *HistogramConfig
+}
+
+// HistogramOverrides holds the histogram configurations overriding the default
+// one for some metric names. The first override whose pattern matches the name
+// of a metric applies.
+type HistogramOverrides struct {
+	overrides []histogramOverride
+}
+
+var (
+	validAggregates = map[string]struct{}{
+		maxAgg:    {},
+		minAgg:    {},
+		medianAgg: {},
+		avgAgg:    {},
+		sumAgg:    {},
+		countAgg:  {},
+	}
+
+	currentHistogramOverrides atomic.Pointer[HistogramOverrides]
+)
+
+// NewHistogramOverrides returns the histogram overrides, or an error if one of
+// them is invalid.
+func NewHistogramOverrides(configs []HistogramOverrideConfig) (*HistogramOverrides, error) {
+	o := &HistogramOverrides{
+		overrides: make([]histogramOverride, 0, len(configs)),
+	}
+	for i, c := range configs {
+		if c.Match == "" {
+			return nil, fmt.Errorf("override num %d: match is required", i)
+		}
+		if _, err := path.Match(c.Match, ""); err != nil {
+			return nil, fmt.Errorf("override num %d: invalid match pattern `%s`: %v", i, c.Match, err)
+		}
+
+		config := &HistogramConfig{
+			aggregates:  make([]string, 0, len(c.Aggregates)),
+			percentiles: make([]int, 0, len(c.Percentiles)),
+		}
+		for _, aggregate := range c.Aggregates {
+			if _, ok := validAggregates[aggregate]; !ok {
+				return nil, fmt.Errorf("override num %d: unknown aggregate '%s'", i, aggregate)
+			}
+			config.aggregates = append(config.aggregates, aggregate)
+		}
+		for _, p := range c.Percentiles {
+			percentile, err := toPercentile(p)
+			if err != nil {
+				return nil, fmt.Errorf("override num %d: percentile %v %v", i, p, err)
+			}
+			if percentile < 1 {
+				return nil, fmt.Errorf("override num %d: percentile %v must be at least 0.01", i, p)
+			}
+			config.percentiles = append(config.percentiles, percentile)
+		}
+		sort.Ints(config.percentiles)
+
+		o.overrides = append(o.overrides, histogramOverride{match: c.Match, config: config})
+	}
+	return o, nil
+}
+
+// GetHistogramOverrides returns the histogram overrides configured in config,
+// or nil if there are none.
+func GetHistogramOverrides(config pkgconfigmodel.Reader) (*HistogramOverrides, error) {
+	var configs []HistogramOverrideConfig
+	if config.IsSet("histogram_overrides") {
+		if err := structure.UnmarshalKey(config, "histogram_overrides", &configs); err != nil {
+			return nil, fmt.Errorf("could not parse histogram_overrides: %v", err)
+		}
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return NewHistogramOverrides(configs)
+}
+
+// SetHistogramOverrides sets the histogram overrides returned by
+// CurrentHistogramOverrides.
+func SetHistogramOverrides(o *HistogramOverrides) {
+	currentHistogramOverrides.Store(o)
+}
+
+// CurrentHistogramOverrides returns the histogram overrides in use, nil if
+// every histogram uses the default configuration.
+func CurrentHistogramOverrides() *HistogramOverrides {
+	return currentHistogramOverrides.Load()
+}
+
+// Lookup returns the configuration of the histograms of the metric, or nil if
+// they use the default configuration.
+func (o *HistogramOverrides) Lookup(name string) *HistogramConfig {
+	if o == nil {
+		return nil
+	}
+	for _, override := range o.overrides {
+		if matched, _ := path.Match(override.match, name); matched {
+			return override.config
+		}
+	}
+	return nil
+}
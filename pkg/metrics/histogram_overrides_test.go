@@ -0,0 +1,115 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+)
+
+func TestNewHistogramOverridesErrors(t *testing.T) {
+	for name, c := range map[string]HistogramOverrideConfig{
+		"missing match":      {Aggregates: []string{"max"}},
+		"invalid pattern":    {Match: "my.[metric"},
+		"unknown aggregate":  {Match: "*", Aggregates: []string{"p99"}},
+		"invalid percentile": {Match: "*", Percentiles: []float64{99}},
+		"zero percentile":    {Match: "*", Percentiles: []float64{0}},
+		"rounded to zero":    {Match: "*", Percentiles: []float64{0.001}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewHistogramOverrides([]HistogramOverrideConfig{c})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHistogramOverridesLookup(t *testing.T) {
+	overrides, err := NewHistogramOverrides([]HistogramOverrideConfig{
+		{Match: "*.latency", Percentiles: []float64{0.99, 0.5}},
+		{Match: "my.*", Aggregates: []string{"max", "count"}},
+		{Match: "my.metric", Aggregates: []string{"min"}},
+	})
+	require.NoError(t, err)
+
+	latency := overrides.Lookup("my.request.latency")
+	require.NotNil(t, latency)
+	assert.Empty(t, latency.aggregates)
+	assert.Equal(t, []int{50, 99}, latency.percentiles)
+
+	// the first matching override applies
+	metric := overrides.Lookup("my.metric")
+	require.NotNil(t, metric)
+	assert.Equal(t, []string{"max", "count"}, metric.aggregates)
+	assert.Empty(t, metric.percentiles)
+
+	assert.Nil(t, overrides.Lookup("other.metric"))
+
+	var noOverrides *HistogramOverrides
+	assert.Nil(t, noOverrides.Lookup("my.metric"))
+}
+
+func TestGetHistogramOverrides(t *testing.T) {
+	cfg := configmock.New(t)
+
+	overrides, err := GetHistogramOverrides(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, overrides)
+
+	cfg.SetWithoutSource("histogram_overrides", []interface{}{
+		map[string]interface{}{"match": "*.latency", "percentiles": []interface{}{0.99}},
+	})
+	overrides, err = GetHistogramOverrides(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []int{99}, overrides.Lookup("my.latency").percentiles)
+
+	cfg.SetWithoutSource("histogram_overrides", []interface{}{
+		map[string]interface{}{"match": "*.latency", "aggregates": []interface{}{"p99"}},
+	})
+	_, err = GetHistogramOverrides(cfg)
+	assert.Error(t, err)
+}
+
+func TestContextMetricsHistogramOverride(t *testing.T) {
+	overrides, err := NewHistogramOverrides([]HistogramOverrideConfig{
+		{Match: "*.latency", Aggregates: []string{"max"}, Percentiles: []float64{0.99}},
+	})
+	require.NoError(t, err)
+
+	metrics := MakeContextMetrics()
+	histogramConfig := overrides.Lookup("my.latency")
+
+	defaultAggregates = nil
+	defaultPercentiles = nil
+	c := setupConfig(t)
+	for contextKey, sample := range map[ckey.ContextKey]struct {
+		mtype           MetricType
+		histogramConfig *HistogramConfig
+	}{
+		1: {HistogramType, histogramConfig},
+		2: {HistogramType, nil},
+		3: {HistorateType, histogramConfig},
+	} {
+		metrics.AddSample(contextKey, &MetricSample{Mtype: sample.mtype, Value: 1}, 12340, 10, sample.histogramConfig, nil, c)
+		metrics.AddSample(contextKey, &MetricSample{Mtype: sample.mtype, Value: 6}, 12345, 10, sample.histogramConfig, nil, c)
+	}
+	series, errs := metrics.Flush(12351)
+	assert.Len(t, errs, 0)
+
+	suffixes := map[ckey.ContextKey][]string{}
+	for _, serie := range series {
+		suffixes[serie.ContextKey] = append(suffixes[serie.ContextKey], serie.NameSuffix)
+	}
+	assert.Equal(t, map[ckey.ContextKey][]string{
+		1: {".max", ".99percentile"},
+		2: {".max", ".median", ".avg", ".count", ".95percentile"},
+		3: {".max", ".99percentile"},
+	}, suffixes)
+}
@@ -26,6 +26,14 @@ func NewHistorate(interval int64, config pkgconfigmodel.Config) *Historate {
 	}
 }
 
+// newConfiguredHistorate returns a newly-initialized historate computing the
+// aggregates and percentiles of histogramConfig, or the default ones if it is nil.
+func newConfiguredHistorate(interval int64, histogramConfig *HistogramConfig, config pkgconfigmodel.Config) *Historate {
+	return &Historate{
+		histogram: *newConfiguredHistogram(interval, histogramConfig, config),
+	}
+}
+
 func (h *Historate) addSample(sample *MetricSample, timestamp float64) {
 	if h.previousTimestamp != 0 {
 		v := (sample.Value - h.previousSample) / (timestamp - h.previousTimestamp)
@@ -60,6 +60,10 @@ type Serie struct {
 	NoIndex        bool                 `json:"-"` // This is only used by api V2
 	Resources      []Resource           `json:"-"` // This is only used by api V2
 	Source         MetricSource         `json:"-"` // This is only used by api V2
+	// The type of the metric the serie was flushed from, and the timestamp of the last
+	// sample of the gauges, used to roll up the series.
+	SourceMType     MetricType `json:"-"`
+	SampleTimestamp float64    `json:"-"`
 }
 
 // SeriesAPIV2Enum returns the enumeration value for MetricPayload.MetricType in
@@ -26,7 +26,8 @@ type AgentConfig struct {
 
 // ConfigContent contains the configurations set by remote-config
 type ConfigContent struct {
-	LogLevel string `json:"log_level"`
+	LogLevel           string        `json:"log_level"`
+	HistogramOverrides []interface{} `json:"histogram_overrides,omitempty"`
 }
 
 type agentConfigData struct {
@@ -145,15 +146,24 @@ func MergeRCAgentConfig(applyStatus func(cfgPath string, status ApplyStatus), up
 	mergedConfig := ConfigContent{}
 	for i := len(orderFile.Config.Order) - 1; i >= 0; i-- {
 		if layer, found := parsedLayers[orderFile.Config.Order[i]]; found {
-			mergedConfig.LogLevel = layer.Config.Config.LogLevel
+			mergeLayer(&mergedConfig, layer.Config.Config)
 		}
 	}
 	// Same for internal config
 	for i := len(orderFile.Config.InternalOrder) - 1; i >= 0; i-- {
 		if layer, found := parsedLayers[orderFile.Config.InternalOrder[i]]; found {
-			mergedConfig.LogLevel = layer.Config.Config.LogLevel
+			mergeLayer(&mergedConfig, layer.Config.Config)
 		}
 	}
 
 	return mergedConfig, nil
 }
+
+// mergeLayer applies a layer with a higher priority to the merged configuration.
+// The histogram overrides of the layers which don't set any are ignored.
+func mergeLayer(mergedConfig *ConfigContent, layer ConfigContent) {
+	mergedConfig.LogLevel = layer.LogLevel
+	if layer.HistogramOverrides != nil {
+		mergedConfig.HistogramOverrides = layer.HistogramOverrides
+	}
+}
@@ -17,6 +17,20 @@ type SamplerEnvConfig struct {
 	PrioritySamplerTargetTPS *float64 `json:"priority_sampler_target_TPS"`
 	ErrorsSamplerTargetTPS   *float64 `json:"errors_sampler_target_TPS"`
 	RareSamplerEnabled       *bool    `json:"rare_sampler_enabled"`
+	LatencySamplerTargetTPS  *float64 `json:"latency_sampler_target_TPS"`
+	LatencySamplerPercentile *float64 `json:"latency_sampler_percentile"`
+	// TraceSamplingRules replaces the trace sampling rules of the agent when it is not nil.
+	TraceSamplingRules []TraceSamplingRule `json:"trace_sampling_rules"`
+}
+
+// TraceSamplingRule is a trace sampling rule applied by the agent
+type TraceSamplingRule struct {
+	Service    string            `json:"service"`
+	Name       string            `json:"name"`
+	Resource   string            `json:"resource"`
+	Tags       map[string]string `json:"tags"`
+	SampleRate float64           `json:"sample_rate"`
+	RateLimit  float64           `json:"rate_limit"`
 }
 
 // EnvAndConfig breaks down configuration by environment
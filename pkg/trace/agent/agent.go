@@ -52,6 +52,10 @@ const (
 	// probabilitySampling is the value for _dd.p.dm when the agent is configured to use the ProbabilitySampler.
 	probabilitySampling = "-9"
 
+	// agentRuleSampling is the value for _dd.p.dm when the trace is kept by a sampling rule of the agent,
+	// distinct from the "-3" set by the tracers when the trace is kept by one of their local rules.
+	agentRuleSampling = "-14"
+
 	// tagDecisionMaker specifies the sampling decision maker
 	tagDecisionMaker = "_dd.p.dm"
 )
@@ -89,8 +93,11 @@ type Agent struct {
 	ClientStatsAggregator *stats.ClientStatsAggregator
 	Blacklister           *filters.Blacklister
 	Replacer              *filters.Replacer
+	SpanRules             *filters.SpanRules
 	PrioritySampler       *sampler.PrioritySampler
+	RulesSampler          *sampler.RulesSampler
 	ErrorsSampler         *sampler.ErrorsSampler
+	LatencySampler        *sampler.LatencySampler
 	RareSampler           *sampler.RareSampler
 	NoPrioritySampler     *sampler.NoPrioritySampler
 	ProbabilisticSampler  *sampler.ProbabilisticSampler
@@ -151,8 +158,11 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig, telemetryCollector
 		ClientStatsAggregator: stats.NewClientStatsAggregator(conf, statsWriter, statsd),
 		Blacklister:           filters.NewBlacklister(conf.Ignore["resource"]),
 		Replacer:              filters.NewReplacer(conf.ReplaceTags),
+		SpanRules:             filters.NewSpanRules(conf.SpanRules, statsd),
 		PrioritySampler:       sampler.NewPrioritySampler(conf, dynConf, statsd),
+		RulesSampler:          sampler.NewRulesSampler(conf.TraceSamplingRules, statsd),
 		ErrorsSampler:         sampler.NewErrorsSampler(conf, statsd),
+		LatencySampler:        sampler.NewLatencySampler(conf, statsd),
 		RareSampler:           sampler.NewRareSampler(conf, statsd),
 		NoPrioritySampler:     sampler.NewNoPrioritySampler(conf, statsd),
 		ProbabilisticSampler:  sampler.NewProbabilisticSampler(conf, statsd),
@@ -166,9 +176,10 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig, telemetryCollector
 		Statsd:                statsd,
 		Timing:                timing,
 	}
+	agnt.PrioritySampler.SetRulesSampler(agnt.RulesSampler)
 	agnt.Receiver = api.NewHTTPReceiver(conf, dynConf, in, agnt, telemetryCollector, statsd, timing)
 	agnt.OTLPReceiver = api.NewOTLPReceiver(in, conf, statsd, timing)
-	agnt.RemoteConfigHandler = remoteconfighandler.New(conf, agnt.PrioritySampler, agnt.RareSampler, agnt.ErrorsSampler)
+	agnt.RemoteConfigHandler = remoteconfighandler.New(conf, agnt.PrioritySampler, agnt.RareSampler, agnt.ErrorsSampler, agnt.LatencySampler, agnt.RulesSampler)
 	agnt.TraceWriter = writer.NewTraceWriter(conf, agnt.PrioritySampler, agnt.ErrorsSampler, agnt.RareSampler, telemetryCollector, statsd, timing, comp)
 	return agnt
 }
@@ -182,10 +193,13 @@ func (a *Agent) Run() {
 		a.Concentrator,
 		a.ClientStatsAggregator,
 		a.PrioritySampler,
+		a.RulesSampler,
 		a.ErrorsSampler,
+		a.LatencySampler,
 		a.NoPrioritySampler,
 		a.ProbabilisticSampler,
 		a.EventProcessor,
+		a.SpanRules,
 		a.OTLPReceiver,
 		a.RemoteConfigHandler,
 		a.DebugServer,
@@ -267,11 +281,14 @@ func (a *Agent) loop() {
 		a.TraceWriter,
 		a.StatsWriter,
 		a.PrioritySampler,
+		a.RulesSampler,
 		a.ErrorsSampler,
+		a.LatencySampler,
 		a.NoPrioritySampler,
 		a.ProbabilisticSampler,
 		a.RareSampler,
 		a.EventProcessor,
+		a.SpanRules,
 		a.obfuscator,
 		a.DebugServer,
 	} {
@@ -344,6 +361,21 @@ func (a *Agent) Process(p *api.Payload) {
 			continue
 		}
 
+		if a.SpanRules != nil {
+			// Applied before the root is looked up as the rules may drop it.
+			chunk.Spans = a.SpanRules.Apply(chunk.Spans)
+			if dropped := tracen - int64(len(chunk.Spans)); dropped > 0 {
+				ts.SpansFiltered.Add(dropped)
+				tracen -= dropped
+			}
+			if len(chunk.Spans) == 0 {
+				log.Debugf("Trace rejected as all its spans were dropped by span rules.")
+				ts.TracesFiltered.Inc()
+				p.RemoveChunk(i)
+				continue
+			}
+		}
+
 		// Root span is used to carry some trace-level metadata, such as sampling rate and priority.
 		root := traceutil.GetRoot(chunk.Spans)
 		setChunkAttributes(chunk, root)
@@ -613,6 +645,36 @@ func isManualUserDrop(pt *traceutil.ProcessedTrace) bool {
 	return dm == manualSampling
 }
 
+// ruleSamplingEligible returns true if the sampling rules of the agent apply to the chunk, which is the
+// case when its priority was not set by the user and was not decided by the rules of the tracer,
+// including the OTLP traces sampled probabilistically.
+func ruleSamplingEligible(chunk *pb.TraceChunk) bool {
+	if priority, ok := sampler.GetSamplingPriority(chunk); ok && priority != sampler.PriorityAutoDrop && priority != sampler.PriorityAutoKeep {
+		return false
+	}
+	switch chunk.Tags[tagDecisionMaker] {
+	case "", "-0", "-1", "-2", probabilitySampling:
+		// no decision, or decided by the default mechanism, the agent rates or the probabilistic sampler
+		return true
+	}
+	return false
+}
+
+// applySamplingRules runs the RulesSampler on the chunk if it is eligible, and returns true
+// if a rule decided its priority.
+func (a *Agent) applySamplingRules(pt *traceutil.ProcessedTrace) bool {
+	if a.RulesSampler == nil || !ruleSamplingEligible(pt.TraceChunk) {
+		return false
+	}
+	if !a.RulesSampler.Sample(pt.TraceChunk, pt.Root, pt.TracerEnv) {
+		return false
+	}
+	if pt.TraceChunk.Priority > 0 {
+		pt.TraceChunk.Tags[tagDecisionMaker] = agentRuleSampling
+	}
+	return true
+}
+
 // traceSampling reports whether the chunk should be kept as a trace, setting "DroppedTrace" on the chunk
 func (a *Agent) traceSampling(now time.Time, ts *info.TagStats, pt *traceutil.ProcessedTrace) (keep bool, checkAnalyticsEvents bool) {
 	sampled, check := a.runSamplers(now, ts, *pt)
@@ -632,11 +694,12 @@ func (a *Agent) getAnalyzedEvents(pt *traceutil.ProcessedTrace, ts *info.TagStat
 // with the sampling rate.
 //
 // If the agent is set as Error Tracking Standalone, only the ErrorSampler is run (other samplers are bypassed).
-// Otherwise, the rare sampler is run first, catching all rare traces early. If the probabilistic sampler is
-// enabled, it is run on the trace, followed by the error sampler. Otherwise, If the trace has a
-// priority set, the sampling priority is used with the Priority Sampler. When there is no priority
-// set, the NoPrioritySampler is run. Finally, if the trace has not been sampled by the other
-// samplers, the error sampler is run.
+// Otherwise, the rare sampler is run first, catching all rare traces early. The sampling rules of the agent
+// then decide the priority of the eligible traces they match. If the probabilistic sampler is enabled, it
+// is run on the traces not matched by a rule, followed by the latency and error samplers. Otherwise, If
+// the trace has a priority set, the sampling priority is used with the Priority Sampler. When there is no
+// priority set, the NoPrioritySampler is run. Finally, if the trace has not been sampled by the other
+// samplers, the latency sampler is run on slow traces, then the error sampler.
 func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.ProcessedTrace) (keep bool, checkAnalyticsEvents bool) {
 	// ETS: chunks that don't contain errors (or spans with exception span events) are all dropped.
 	if a.conf.ErrorTrackingStandalone {
@@ -649,15 +712,24 @@ func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.Proce
 
 	// Run this early to make sure the signature gets counted by the RareSampler.
 	rare := a.RareSampler.Sample(now, pt.TraceChunk, pt.TracerEnv)
+	// Likewise, the LatencySampler learns the latency distributions from all traces.
+	slow := a.LatencySampler != nil && a.LatencySampler.Observe(now, pt.Root, pt.TracerEnv)
 
 	if a.conf.ProbabilisticSamplerEnabled {
 		if rare {
 			return true, true
 		}
-		if a.ProbabilisticSampler.Sample(pt.Root) {
+		if a.applySamplingRules(&pt) {
+			if pt.TraceChunk.Priority > 0 {
+				return true, true
+			}
+		} else if a.ProbabilisticSampler.Sample(pt.Root) {
 			pt.TraceChunk.Tags[tagDecisionMaker] = probabilitySampling
 			return true, true
 		}
+		if slow && a.LatencySampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv) {
+			return true, true
+		}
 		if traceContainsError(pt.TraceChunk.Spans, false) {
 			return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), true
 		}
@@ -687,6 +759,9 @@ func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.Proce
 		return true, true
 	}
 
+	if a.applySamplingRules(&pt) {
+		hasPriority = true
+	}
 	if hasPriority {
 		if a.PrioritySampler.Sample(now, pt.TraceChunk, pt.Root, pt.TracerEnv, pt.ClientDroppedP0sWeight) {
 			return true, true
@@ -695,6 +770,10 @@ func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.Proce
 		return true, true
 	}
 
+	if slow && a.LatencySampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv) {
+		return true, true
+	}
+
 	if traceContainsError(pt.TraceChunk.Spans, false) {
 		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), true
 	}
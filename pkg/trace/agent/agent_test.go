@@ -252,6 +252,45 @@ func TestProcess(t *testing.T) {
 		assert.EqualValues(2, want.SpansFiltered.Load())
 	})
 
+	t.Run("SpanRules", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.SpanRules = []*config.SpanRule{
+			{Name: "drop-cache", Match: []*config.SpanRuleCondition{{Key: "type", Value: "cache"}}, Action: config.SpanRuleDrop},
+			{Name: "set-team", Action: config.SpanRuleSet, Key: "team", Value: "storage"},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewTestAgent(ctx, cfg, telemetry.NewNoopCollector())
+		defer cancel()
+
+		now := time.Now()
+		root := &pb.Span{TraceID: 1, SpanID: 1, Service: "web", Name: "http.request", Resource: "GET /", Type: "web", Start: now.Add(-time.Second).UnixNano(), Duration: (500 * time.Millisecond).Nanoseconds()}
+		cache := &pb.Span{TraceID: 1, SpanID: 2, ParentID: 1, Service: "web", Name: "redis.command", Resource: "GET", Type: "cache", Start: now.Add(-time.Second).UnixNano(), Duration: (100 * time.Millisecond).Nanoseconds()}
+		child := &pb.Span{TraceID: 1, SpanID: 3, ParentID: 2, Service: "web", Name: "redis.dial", Resource: "dial", Type: "tcp", Start: now.Add(-time.Second).UnixNano(), Duration: (10 * time.Millisecond).Nanoseconds()}
+
+		want := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+		assert := assert.New(t)
+
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayloadWithChunk(testutil.TraceChunkWithSpans([]*pb.Span{root, cache, child})),
+			Source:        want,
+		})
+		assert.EqualValues(0, want.TracesFiltered.Load())
+		assert.EqualValues(1, want.SpansFiltered.Load())
+		assert.Equal(uint64(1), child.ParentID)
+		assert.Equal("storage", root.Meta["team"])
+		assert.Equal("storage", child.Meta["team"])
+		assert.NotContains(cache.Meta, "team")
+
+		// a trace whose spans are all dropped is filtered out
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayloadWithChunk(testutil.TraceChunkWithSpan(&pb.Span{TraceID: 2, SpanID: 1, Service: "web", Name: "redis.command", Resource: "GET", Type: "cache", Start: now.Add(-time.Second).UnixNano(), Duration: (100 * time.Millisecond).Nanoseconds()})),
+			Source:        want,
+		})
+		assert.EqualValues(1, want.TracesFiltered.Load())
+		assert.EqualValues(2, want.SpansFiltered.Load())
+	})
+
 	t.Run("Block-all", func(t *testing.T) {
 		cfg := config.New()
 		cfg.Endpoints[0].APIKey = "test"
@@ -1484,6 +1523,104 @@ func TestSample(t *testing.T) {
 	}
 }
 
+func TestSampleWithSamplingRules(t *testing.T) {
+	now := time.Now()
+	statsd := &statsd.NoOpClient{}
+	rules := []*config.TraceSamplingRule{
+		{Service: "serv1", Resource: "GET /health*", SampleRate: 0},
+		{Service: "serv1", SampleRate: 1},
+	}
+	for _, r := range rules {
+		require.NoError(t, r.Compile())
+	}
+	genTrace := func(resource, decisionMaker string, priority sampler.SamplingPriority) traceutil.ProcessedTrace {
+		root := &pb.Span{
+			Service:  "serv1",
+			Resource: resource,
+			TraceID:  1,
+			Start:    now.UnixNano(),
+			Duration: (100 * time.Millisecond).Nanoseconds(),
+			Metrics:  map[string]float64{"_top_level": 1},
+			Meta:     map[string]string{},
+		}
+		chunk := testutil.TraceChunkWithSpan(root)
+		if decisionMaker != "" {
+			chunk.Tags["_dd.p.dm"] = decisionMaker
+		}
+		chunk.Priority = int32(priority)
+		return traceutil.ProcessedTrace{TraceChunk: chunk, Root: root}
+	}
+	tests := map[string]struct {
+		trace         traceutil.ProcessedTrace
+		probabilistic bool
+		keep          bool
+		dm            string
+	}{
+		"autokeep-dropped-by-rule": {
+			trace: genTrace("GET /health", "-1", sampler.PriorityAutoKeep),
+			keep:  false,
+			dm:    "-1",
+		},
+		"autodrop-kept-by-rule": {
+			trace: genTrace("GET /users", "", sampler.PriorityAutoDrop),
+			keep:  true,
+			dm:    "-14",
+		},
+		"otlp-kept-by-rule": {
+			trace: genTrace("GET /users", "-9", sampler.PriorityAutoDrop),
+			keep:  true,
+			dm:    "-14",
+		},
+		"userkeep-not-eligible": {
+			trace: genTrace("GET /health", "-4", sampler.PriorityUserKeep),
+			keep:  true,
+			dm:    "-4",
+		},
+		"tracer-rule-not-eligible": {
+			trace: genTrace("GET /users", "-3", sampler.PriorityAutoDrop),
+			keep:  false,
+			dm:    "-3",
+		},
+		"probabilistic-dropped-by-rule": {
+			trace:         genTrace("GET /health", "", sampler.PriorityAutoKeep),
+			probabilistic: true,
+			keep:          false,
+			dm:            "",
+		},
+		"probabilistic-kept-by-rule": {
+			trace:         genTrace("GET /users", "", sampler.PriorityAutoDrop),
+			probabilistic: true,
+			keep:          true,
+			dm:            "-14",
+		},
+	}
+	for name, tt := range tests {
+		cfg := &config.AgentConfig{
+			TargetTPS:                              5,
+			ErrorTPS:                               1000,
+			Features:                               make(map[string]struct{}),
+			ProbabilisticSamplerEnabled:            tt.probabilistic,
+			ProbabilisticSamplerSamplingPercentage: 100,
+		}
+		a := &Agent{
+			NoPrioritySampler:    sampler.NewNoPrioritySampler(cfg, statsd),
+			ErrorsSampler:        sampler.NewErrorsSampler(cfg, statsd),
+			PrioritySampler:      sampler.NewPrioritySampler(cfg, &sampler.DynamicConfig{}, statsd),
+			RulesSampler:         sampler.NewRulesSampler(rules, statsd),
+			ProbabilisticSampler: sampler.NewProbabilisticSampler(cfg, statsd),
+			RareSampler:          sampler.NewRareSampler(config.New(), statsd),
+			EventProcessor:       newEventProcessor(cfg, statsd),
+			conf:                 cfg,
+		}
+		t.Run(name, func(t *testing.T) {
+			keep, _ := a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
+			assert.Equal(t, tt.keep, keep)
+			assert.Equal(t, !tt.keep, tt.trace.TraceChunk.DroppedTrace)
+			assert.Equal(t, tt.dm, tt.trace.TraceChunk.Tags["_dd.p.dm"])
+		})
+	}
+}
+
 func TestSampleManualUserDropNoAnalyticsEvents(t *testing.T) {
 	// This test exists to confirm previous behavior where we did not extract nor tag analytics events on
 	// user manual drop traces
@@ -127,4 +127,14 @@ const (
 	// Response: Service sampling rates (see description in v04).
 	//
 	V07 Version = "v0.7"
+
+	// zipkinV2 API
+	//
+	// Request: Zipkin v2 spans.
+	// 	Content-Type: application/json or application/x-protobuf
+	// 	Payload: A list of spans (https://zipkin.io/zipkin-api/#/default/post_spans)
+	//
+	// Response: 202 Accepted, with an empty body.
+	//
+	zipkinV2 Version = "zipkin_v2"
 )
@@ -0,0 +1,585 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/api/apiutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/api/internal/header"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/transform"
+)
+
+// zipkinNoServiceName is the service of the Zipkin spans without a local endpoint service name.
+const zipkinNoServiceName = "ZipkinNoServiceName"
+
+// zipkinSpan is a Zipkin v2 span, as defined in https://zipkin.io/zipkin-api/zipkin2-api.yaml.
+// The IDs are hex-encoded, as in the JSON encoding.
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      uint64             `json:"timestamp"` // microseconds
+	Duration       uint64             `json:"duration"`  // microseconds
+	Debug          bool               `json:"debug"`
+	Shared         bool               `json:"shared"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+}
+
+// zipkinEndpoint is the network context of a node in the service graph.
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+// address returns the IP address of the endpoint, if any.
+func (e *zipkinEndpoint) address() string {
+	if e.IPv6 != "" {
+		return e.IPv6
+	}
+	return e.IPv4
+}
+
+// zipkinAnnotation is an event which explains latency with a timestamp.
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"` // microseconds
+	Value     string `json:"value"`
+}
+
+// handleZipkinSpans handles the Zipkin v2 spans sent to /api/v2/spans, converting them
+// to trace chunks so that they are processed like the spans of the Datadog tracers.
+func (r *HTTPReceiver) handleZipkinSpans(v Version, w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	select {
+	// Wait for the semaphore to become available, allowing the handler to
+	// decode its payload.
+	case r.recvsem <- struct{}{}:
+	case <-time.After(time.Duration(r.conf.DecoderTimeout) * time.Millisecond):
+		// this payload can not be accepted
+		io.Copy(io.Discard, req.Body) //nolint:errcheck
+		w.WriteHeader(http.StatusTooManyRequests)
+		r.tagStats(v, req.Header, "").PayloadRefused.Inc()
+		return
+	}
+	defer func() {
+		// Signal the semaphore that we are done decoding, so another handler
+		// routine can take a turn decoding a payload.
+		<-r.recvsem
+	}()
+
+	start := time.Now()
+	spans, err := decodeZipkinRequest(req, r.conf.MaxRequestBytes)
+	var service string
+	if len(spans) > 0 && spans[0].LocalEndpoint != nil {
+		service = spans[0].LocalEndpoint.ServiceName
+	}
+	ts := r.tagStats(v, req.Header, service)
+	defer func(err error) {
+		tags := append(ts.AsTags(), fmt.Sprintf("success:%v", err == nil))
+		_ = r.statsd.Histogram("datadog.trace_agent.receiver.serve_traces_ms", float64(time.Since(start))/float64(time.Millisecond), tags, 1)
+	}(err)
+	var tp *pb.TracerPayload
+	if err == nil {
+		tp, err = r.zipkinTracerPayload(req, spans)
+	}
+	if err != nil {
+		httpDecodingError(err, []string{"handler:zipkin_spans", fmt.Sprintf("v:%s", v)}, w, r.statsd)
+		if err == apiutil.ErrLimitedReaderLimitReached {
+			ts.TracesDropped.PayloadTooLarge.Inc()
+		} else {
+			ts.TracesDropped.DecodingError.Inc()
+		}
+		log.Errorf("Cannot decode Zipkin spans payload: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	ts.TracesReceived.Add(int64(len(tp.Chunks)))
+	ts.TracesBytes.Add(req.Body.(*apiutil.LimitedReader).Count)
+	ts.PayloadAccepted.Inc()
+
+	if ctags := getContainerTags(r.conf.ContainerTags, tp.ContainerID); ctags != "" {
+		tp.Tags = map[string]string{tagContainersTags: ctags}
+	}
+	r.out <- &Payload{
+		Source:        ts,
+		TracerPayload: tp,
+	}
+}
+
+// decodeZipkinRequest decodes the JSON or Protobuf spans of the request, which may be
+// compressed with gzip. The size of the decompressed spans is limited to maxBytes.
+func decodeZipkinRequest(req *http.Request, maxBytes int64) ([]*zipkinSpan, error) {
+	body := io.Reader(req.Body)
+	if strings.EqualFold(req.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = apiutil.NewLimitedReader(io.NopCloser(gz), maxBytes)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, err
+	}
+	switch getMediaType(req) {
+	case "application/x-protobuf", "application/protobuf":
+		return decodeZipkinProto(buf.Bytes())
+	default:
+		var spans []*zipkinSpan
+		if err := json.Unmarshal(buf.Bytes(), &spans); err != nil {
+			return nil, err
+		}
+		return spans, nil
+	}
+}
+
+// zipkinTracerPayload converts the Zipkin spans of the request to a tracer payload,
+// grouping them in chunks by trace ID.
+func (r *HTTPReceiver) zipkinTracerPayload(req *http.Request, spans []*zipkinSpan) (*pb.TracerPayload, error) {
+	chunks := make(map[uint64]*pb.TraceChunk)
+	var order []uint64
+	var env string
+	// IDs of the server sides of the shared spans, by trace and ID of the client side
+	sharedIDs := make(map[zipkinSpanRef]uint64)
+	for _, in := range spans {
+		span, err := convertZipkinSpan(in)
+		if err != nil {
+			return nil, err
+		}
+		if in.Shared && in.Kind == "SERVER" {
+			sharedIDs[zipkinSpanRef{traceID: span.TraceID, spanID: span.ParentID}] = span.SpanID
+		}
+		chunk, ok := chunks[span.TraceID]
+		if !ok {
+			chunk = &pb.TraceChunk{
+				Priority: int32(sampler.PriorityNone),
+				Tags:     make(map[string]string),
+			}
+			chunks[span.TraceID] = chunk
+			order = append(order, span.TraceID)
+		}
+		if in.Debug {
+			// debug spans must be kept
+			chunk.Priority = int32(sampler.PriorityUserKeep)
+		}
+		if env == "" {
+			env = span.Meta["env"]
+		}
+		chunk.Spans = append(chunk.Spans, span)
+	}
+	if len(sharedIDs) > 0 {
+		remapZipkinSharedChildren(chunks, sharedIDs)
+	}
+	if env == "" {
+		env = r.conf.DefaultEnv
+	}
+	tp := &pb.TracerPayload{
+		Hostname:        r.conf.Hostname,
+		Env:             traceutil.NormalizeTag(env),
+		ContainerID:     r.containerIDProvider.GetContainerID(req.Context(), req.Header),
+		LanguageName:    req.Header.Get(header.Lang),
+		LanguageVersion: req.Header.Get(header.LangVersion),
+		TracerVersion:   req.Header.Get(header.TracerVersion),
+		Chunks:          make([]*pb.TraceChunk, 0, len(order)),
+	}
+	for _, id := range order {
+		tp.Chunks = append(tp.Chunks, chunks[id])
+	}
+	return tp, nil
+}
+
+// zipkinSpanRef references a span by its trace and span IDs.
+type zipkinSpanRef struct {
+	traceID uint64
+	spanID  uint64
+}
+
+// remapZipkinSharedChildren makes the spans referencing a shared span as their
+// parent children of its server side, whose ID is changed by the conversion. The
+// children on the server side are reported along with the shared span by the
+// server, so only the spans of the same payload are remapped.
+func remapZipkinSharedChildren(chunks map[uint64]*pb.TraceChunk, sharedIDs map[zipkinSpanRef]uint64) {
+	for traceID, chunk := range chunks {
+		for _, span := range chunk.Spans {
+			id, ok := sharedIDs[zipkinSpanRef{traceID: traceID, spanID: span.ParentID}]
+			if ok && span.SpanID != id {
+				span.ParentID = id
+			}
+		}
+	}
+}
+
+// zipkinSpanKinds maps the Zipkin span kinds to the OpenTelemetry ones, whose
+// conventions are shared with the OTLP conversion.
+var zipkinSpanKinds = map[string]ptrace.SpanKind{
+	"CLIENT":   ptrace.SpanKindClient,
+	"SERVER":   ptrace.SpanKindServer,
+	"PRODUCER": ptrace.SpanKindProducer,
+	"CONSUMER": ptrace.SpanKindConsumer,
+}
+
+// convertZipkinSpan converts the Zipkin span in to a Datadog span, following the
+// conventions of the OTLP conversion.
+func convertZipkinSpan(in *zipkinSpan) (*pb.Span, error) {
+	traceID, err := parseZipkinTraceID(in.TraceID)
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := parseZipkinSpanID(in.ID)
+	if err != nil {
+		return nil, err
+	}
+	var parentID uint64
+	if in.ParentID != "" {
+		if parentID, err = parseZipkinSpanID(in.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	span := &pb.Span{
+		TraceID:  traceutil.OTelTraceIDToUint64(traceID),
+		SpanID:   spanID,
+		ParentID: parentID,
+		Start:    int64(in.Timestamp) * int64(time.Microsecond),
+		Duration: int64(in.Duration) * int64(time.Microsecond),
+		Meta:     make(map[string]string, len(in.Tags)+4),
+		Metrics:  map[string]float64{},
+	}
+	if span.Start == 0 {
+		span.Start = time.Now().UnixNano()
+	}
+	if in.Shared && in.Kind == "SERVER" {
+		// the server side of a span shared with its client has the same ID,
+		// it is made a child of the client span instead
+		span.ParentID = span.SpanID
+		span.SpanID = zipkinSharedSpanID(span.SpanID)
+	}
+	if high := binary.BigEndian.Uint64(traceID[:8]); high != 0 {
+		transform.SetMetaOTLP(span, "_dd.p.tid", fmt.Sprintf("%016x", high))
+	}
+
+	for k, v := range in.Tags {
+		if k == "error" {
+			// Zipkin marks the errors with an "error" tag holding the message
+			span.Error = 1
+			if v != "" && v != "true" {
+				span.Meta["error.msg"] = v
+			}
+			continue
+		}
+		transform.SetMetaOTLP(span, k, v)
+	}
+	if in.LocalEndpoint != nil && in.LocalEndpoint.ServiceName != "" && span.Service == "" {
+		span.Service = in.LocalEndpoint.ServiceName
+	}
+	if e := in.RemoteEndpoint; e != nil {
+		if e.ServiceName != "" {
+			transform.SetMetaOTLP(span, "peer.service", e.ServiceName)
+		}
+		if addr := e.address(); addr != "" {
+			transform.SetMetaOTLP(span, "network.peer.address", addr)
+		}
+		if e.Port != 0 {
+			transform.SetMetaOTLP(span, "network.peer.port", strconv.Itoa(e.Port))
+		}
+	}
+	if len(in.Annotations) > 0 {
+		transform.SetMetaOTLP(span, "events", marshalZipkinAnnotations(in.Annotations))
+	}
+
+	spanKind := zipkinSpanKinds[in.Kind]
+	transform.SetMetaOTLP(span, "span.kind", traceutil.OTelSpanKindName(spanKind))
+	if _, ok := span.Meta["env"]; !ok {
+		if _, env := transform.GetFirstFromMap(span.Meta, "deployment.environment.name", "deployment.environment"); env != "" {
+			transform.SetMetaOTLP(span, "env", traceutil.NormalizeTag(env))
+		}
+	}
+	if span.Name == "" {
+		span.Name = "zipkin." + traceutil.OTelSpanKindName(spanKind)
+	}
+	if span.Service == "" {
+		span.Service = zipkinNoServiceName
+	}
+	if span.Resource == "" {
+		if res := resourceFromTags(span.Meta); res != "" {
+			span.Resource = res
+		} else if in.Name != "" {
+			span.Resource = in.Name
+		} else {
+			span.Resource = span.Name
+		}
+	}
+	if span.Type == "" {
+		span.Type = spanKind2Type(spanKind, span)
+	}
+	return span, nil
+}
+
+// parseZipkinTraceID parses a 64 or 128-bit hex-encoded trace ID.
+func parseZipkinTraceID(s string) ([16]byte, error) {
+	var id [16]byte
+	if len(s) == 0 || len(s) > 32 {
+		return id, fmt.Errorf("invalid Zipkin trace ID %q", s)
+	}
+	b, err := hex.DecodeString(strings.Repeat("0", 32-len(s)) + s)
+	if err != nil {
+		return id, fmt.Errorf("invalid Zipkin trace ID %q", s)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// parseZipkinSpanID parses a 64-bit hex-encoded span ID.
+func parseZipkinSpanID(s string) (uint64, error) {
+	if len(s) == 0 || len(s) > 16 {
+		return 0, fmt.Errorf("invalid Zipkin span ID %q", s)
+	}
+	id, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Zipkin span ID %q", s)
+	}
+	return id, nil
+}
+
+// zipkinSharedSpanID derives the ID of the server side of a shared span from
+// the ID of the client side.
+func zipkinSharedSpanID(id uint64) uint64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	h := fnv.New64a()
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// marshalZipkinAnnotations marshals the annotations into JSON, in the format of
+// the span events of the OTLP conversion.
+func marshalZipkinAnnotations(annotations []zipkinAnnotation) string {
+	type event struct {
+		TimeUnixNano uint64 `json:"time_unix_nano,omitempty"`
+		Name         string `json:"name,omitempty"`
+	}
+	events := make([]event, 0, len(annotations))
+	for _, a := range annotations {
+		events = append(events, event{TimeUnixNano: a.Timestamp * uint64(time.Microsecond), Name: a.Value})
+	}
+	b, err := json.Marshal(events)
+	if err != nil {
+		log.Errorf("Error marshalling Zipkin annotations: %v", err)
+		return "[]"
+	}
+	return string(b)
+}
+
+// Field numbers of the Zipkin v2 Protobuf messages, as defined in
+// https://github.com/openzipkin/zipkin-api/blob/master/zipkin.proto
+const (
+	zipkinProtoListSpans = 1
+
+	zipkinProtoSpanTraceID        = 1
+	zipkinProtoSpanParentID       = 2
+	zipkinProtoSpanID             = 3
+	zipkinProtoSpanKind           = 4
+	zipkinProtoSpanName           = 5
+	zipkinProtoSpanTimestamp      = 6
+	zipkinProtoSpanDuration       = 7
+	zipkinProtoSpanLocalEndpoint  = 8
+	zipkinProtoSpanRemoteEndpoint = 9
+	zipkinProtoSpanAnnotations    = 10
+	zipkinProtoSpanTags           = 11
+	zipkinProtoSpanDebug          = 12
+	zipkinProtoSpanShared         = 13
+
+	zipkinProtoEndpointServiceName = 1
+	zipkinProtoEndpointIPv4        = 2
+	zipkinProtoEndpointIPv6        = 3
+	zipkinProtoEndpointPort        = 4
+
+	zipkinProtoAnnotationTimestamp = 1
+	zipkinProtoAnnotationValue     = 2
+)
+
+// zipkinProtoKinds maps the values of the Protobuf Span.Kind enum to the JSON ones.
+var zipkinProtoKinds = map[uint64]string{
+	1: "CLIENT",
+	2: "SERVER",
+	3: "PRODUCER",
+	4: "CONSUMER",
+}
+
+var errZipkinProtoWireType = errors.New("unexpected Zipkin Protobuf wire type")
+
+// decodeZipkinProto decodes a Protobuf ListOfSpans message.
+func decodeZipkinProto(b []byte) ([]*zipkinSpan, error) {
+	var spans []*zipkinSpan
+	err := rangeProtoFields(b, func(num protowire.Number, typ protowire.Type, _ uint64, data []byte) error {
+		if num != zipkinProtoListSpans {
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return errZipkinProtoWireType
+		}
+		span, err := decodeZipkinProtoSpan(data)
+		if err != nil {
+			return err
+		}
+		spans = append(spans, span)
+		return nil
+	})
+	return spans, err
+}
+
+func decodeZipkinProtoSpan(b []byte) (*zipkinSpan, error) {
+	var span zipkinSpan
+	err := rangeProtoFields(b, func(num protowire.Number, _ protowire.Type, x uint64, data []byte) error {
+		var err error
+		switch num {
+		case zipkinProtoSpanTraceID:
+			span.TraceID = hex.EncodeToString(data)
+		case zipkinProtoSpanParentID:
+			span.ParentID = hex.EncodeToString(data)
+		case zipkinProtoSpanID:
+			span.ID = hex.EncodeToString(data)
+		case zipkinProtoSpanKind:
+			span.Kind = zipkinProtoKinds[x]
+		case zipkinProtoSpanName:
+			span.Name = string(data)
+		case zipkinProtoSpanTimestamp:
+			span.Timestamp = x
+		case zipkinProtoSpanDuration:
+			span.Duration = x
+		case zipkinProtoSpanLocalEndpoint:
+			span.LocalEndpoint, err = decodeZipkinProtoEndpoint(data)
+		case zipkinProtoSpanRemoteEndpoint:
+			span.RemoteEndpoint, err = decodeZipkinProtoEndpoint(data)
+		case zipkinProtoSpanAnnotations:
+			var a zipkinAnnotation
+			err = rangeProtoFields(data, func(num protowire.Number, _ protowire.Type, x uint64, data []byte) error {
+				switch num {
+				case zipkinProtoAnnotationTimestamp:
+					a.Timestamp = x
+				case zipkinProtoAnnotationValue:
+					a.Value = string(data)
+				}
+				return nil
+			})
+			span.Annotations = append(span.Annotations, a)
+		case zipkinProtoSpanTags:
+			var k, v string
+			err = rangeProtoFields(data, func(num protowire.Number, _ protowire.Type, _ uint64, data []byte) error {
+				switch num {
+				case 1:
+					k = string(data)
+				case 2:
+					v = string(data)
+				}
+				return nil
+			})
+			if span.Tags == nil {
+				span.Tags = make(map[string]string)
+			}
+			span.Tags[k] = v
+		case zipkinProtoSpanDebug:
+			span.Debug = x != 0
+		case zipkinProtoSpanShared:
+			span.Shared = x != 0
+		}
+		return err
+	})
+	return &span, err
+}
+
+func decodeZipkinProtoEndpoint(b []byte) (*zipkinEndpoint, error) {
+	var e zipkinEndpoint
+	err := rangeProtoFields(b, func(num protowire.Number, _ protowire.Type, x uint64, data []byte) error {
+		switch num {
+		case zipkinProtoEndpointServiceName:
+			e.ServiceName = string(data)
+		case zipkinProtoEndpointIPv4:
+			if len(data) == net.IPv4len {
+				e.IPv4 = net.IP(data).String()
+			}
+		case zipkinProtoEndpointIPv6:
+			if len(data) == net.IPv6len {
+				e.IPv6 = net.IP(data).String()
+			}
+		case zipkinProtoEndpointPort:
+			e.Port = int(x)
+		}
+		return nil
+	})
+	return &e, err
+}
+
+// rangeProtoFields calls f with each field of the Protobuf message b. The varint and
+// fixed-size values are passed as x, and the length-delimited ones as data.
+func rangeProtoFields(b []byte, f func(num protowire.Number, typ protowire.Type, x uint64, data []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var (
+			x    uint64
+			data []byte
+		)
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			x, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			x = uint64(v)
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := f(num, typ, x, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+const zipkinTestSpans = `[
+  {
+    "traceId": "5af7183fb1d4cf5f1a2b3c4d5e6f7a8b",
+    "id": "352bff9a74ca9ad2",
+    "name": "get /api/users",
+    "kind": "SERVER",
+    "timestamp": 1700000000000000,
+    "duration": 20000,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "10.0.0.1"},
+    "remoteEndpoint": {"ipv4": "10.0.0.2", "port": 53412},
+    "annotations": [{"timestamp": 1700000000005000, "value": "wr"}],
+    "tags": {"http.method": "GET", "http.route": "/api/users", "http.status_code": "200", "env": "prod"}
+  },
+  {
+    "traceId": "5af7183fb1d4cf5f1a2b3c4d5e6f7a8b",
+    "parentId": "352bff9a74ca9ad2",
+    "id": "4fe9e1c3f7b10a2d",
+    "name": "query",
+    "kind": "CLIENT",
+    "timestamp": 1700000000002000,
+    "duration": 10000,
+    "localEndpoint": {"serviceName": "frontend"},
+    "remoteEndpoint": {"serviceName": "mysql", "ipv6": "::1", "port": 3306},
+    "tags": {"db.system": "mysql", "error": "connection reset"}
+  },
+  {
+    "traceId": "0000000000000001",
+    "id": "0000000000000002",
+    "debug": true
+  }
+]`
+
+func TestConvertZipkinSpan(t *testing.T) {
+	var spans []*zipkinSpan
+	require.NoError(t, json.Unmarshal([]byte(zipkinTestSpans), &spans))
+
+	server, err := convertZipkinSpan(spans[0])
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x1a2b3c4d5e6f7a8b), server.TraceID)
+	assert.Equal(t, uint64(0x352bff9a74ca9ad2), server.SpanID)
+	assert.Equal(t, uint64(0), server.ParentID)
+	assert.Equal(t, int64(1700000000000000000), server.Start)
+	assert.Equal(t, int64(20000000), server.Duration)
+	assert.Equal(t, "frontend", server.Service)
+	assert.Equal(t, "zipkin.server", server.Name)
+	assert.Equal(t, "GET /api/users", server.Resource)
+	assert.Equal(t, "web", server.Type)
+	assert.Equal(t, int32(0), server.Error)
+	assert.Equal(t, map[string]string{
+		"_dd.p.tid":            "5af7183fb1d4cf5f",
+		"http.method":          "GET",
+		"http.route":           "/api/users",
+		"http.status_code":     "200",
+		"env":                  "prod",
+		"span.kind":            "server",
+		"network.peer.address": "10.0.0.2",
+		"network.peer.port":    "53412",
+		"events":               `[{"time_unix_nano":1700000000005000000,"name":"wr"}]`,
+	}, server.Meta)
+
+	client, err := convertZipkinSpan(spans[1])
+	require.NoError(t, err)
+	assert.Equal(t, server.SpanID, client.ParentID)
+	assert.Equal(t, "zipkin.client", client.Name)
+	assert.Equal(t, "query", client.Resource)
+	assert.Equal(t, "db", client.Type)
+	assert.Equal(t, int32(1), client.Error)
+	assert.Equal(t, "connection reset", client.Meta["error.msg"])
+	assert.Equal(t, "mysql", client.Meta["peer.service"])
+	assert.Equal(t, "::1", client.Meta["network.peer.address"])
+	assert.NotContains(t, client.Meta, "error")
+
+	minimal, err := convertZipkinSpan(spans[2])
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), minimal.TraceID)
+	assert.Equal(t, zipkinNoServiceName, minimal.Service)
+	assert.Equal(t, "zipkin.unspecified", minimal.Name)
+	assert.Equal(t, "zipkin.unspecified", minimal.Resource)
+	assert.Equal(t, "custom", minimal.Type)
+	assert.NotContains(t, minimal.Meta, "_dd.p.tid")
+
+	// the server side of a shared span is made a child of the client side
+	shared, err := convertZipkinSpan(&zipkinSpan{TraceID: "1", ID: "2", ParentID: "1", Kind: "SERVER", Shared: true})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), shared.ParentID)
+	assert.NotEqual(t, uint64(2), shared.SpanID)
+
+	for _, in := range []*zipkinSpan{
+		{TraceID: "", ID: "2"},
+		{TraceID: "xyz", ID: "2"},
+		{TraceID: "1", ID: "00000000000000000002"},
+		{TraceID: "1", ID: "2", ParentID: "z"},
+	} {
+		_, err := convertZipkinSpan(in)
+		assert.Error(t, err, "%+v", in)
+	}
+}
+
+func TestZipkinTracerPayloadSharedSpan(t *testing.T) {
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", nil)
+
+	tp, err := r.zipkinTracerPayload(req, []*zipkinSpan{
+		// the child of the server side may be reported before it
+		{TraceID: "1", ID: "3", ParentID: "2", Kind: "CLIENT"},
+		{TraceID: "1", ID: "2", ParentID: "1", Kind: "SERVER", Shared: true},
+		{TraceID: "1", ID: "2", ParentID: "1", Kind: "CLIENT"},
+		// the same span ID in another trace is left as is
+		{TraceID: "4", ID: "5", ParentID: "2"},
+	})
+	require.NoError(t, err)
+	require.Len(t, tp.Chunks, 2)
+	require.Len(t, tp.Chunks[0].Spans, 3)
+	child, server, client := tp.Chunks[0].Spans[0], tp.Chunks[0].Spans[1], tp.Chunks[0].Spans[2]
+
+	assert.Equal(t, uint64(2), client.SpanID)
+	assert.Equal(t, uint64(1), client.ParentID)
+	assert.Equal(t, zipkinSharedSpanID(2), server.SpanID)
+	assert.Equal(t, uint64(2), server.ParentID)
+	// the children of the shared span are children of its server side
+	assert.Equal(t, uint64(3), child.SpanID)
+	assert.Equal(t, server.SpanID, child.ParentID)
+
+	require.Len(t, tp.Chunks[1].Spans, 1)
+	assert.Equal(t, uint64(2), tp.Chunks[1].Spans[0].ParentID)
+}
+
+func appendZipkinProtoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func TestDecodeZipkinProto(t *testing.T) {
+	var endpoint []byte
+	endpoint = appendZipkinProtoBytes(endpoint, zipkinProtoEndpointServiceName, []byte("frontend"))
+	endpoint = appendZipkinProtoBytes(endpoint, zipkinProtoEndpointIPv4, []byte{10, 0, 0, 1})
+	endpoint = protowire.AppendTag(endpoint, zipkinProtoEndpointPort, protowire.VarintType)
+	endpoint = protowire.AppendVarint(endpoint, 8080)
+
+	var annotation []byte
+	annotation = protowire.AppendTag(annotation, zipkinProtoAnnotationTimestamp, protowire.Fixed64Type)
+	annotation = protowire.AppendFixed64(annotation, 1700000000005000)
+	annotation = appendZipkinProtoBytes(annotation, zipkinProtoAnnotationValue, []byte("wr"))
+
+	var tag []byte
+	tag = appendZipkinProtoBytes(tag, 1, []byte("http.method"))
+	tag = appendZipkinProtoBytes(tag, 2, []byte("GET"))
+
+	var span []byte
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanTraceID, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanParentID, []byte{0, 0, 0, 0, 0, 0, 0, 2})
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanID, []byte{0, 0, 0, 0, 0, 0, 0, 3})
+	span = protowire.AppendTag(span, zipkinProtoSpanKind, protowire.VarintType)
+	span = protowire.AppendVarint(span, 2)
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanName, []byte("get"))
+	span = protowire.AppendTag(span, zipkinProtoSpanTimestamp, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, 1700000000000000)
+	span = protowire.AppendTag(span, zipkinProtoSpanDuration, protowire.VarintType)
+	span = protowire.AppendVarint(span, 20000)
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanLocalEndpoint, endpoint)
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanAnnotations, annotation)
+	span = appendZipkinProtoBytes(span, zipkinProtoSpanTags, tag)
+	span = protowire.AppendTag(span, zipkinProtoSpanDebug, protowire.VarintType)
+	span = protowire.AppendVarint(span, 1)
+	// unknown fields are skipped
+	span = protowire.AppendTag(span, 42, protowire.Fixed32Type)
+	span = protowire.AppendFixed32(span, 42)
+
+	var list []byte
+	list = appendZipkinProtoBytes(list, zipkinProtoListSpans, span)
+	list = appendZipkinProtoBytes(list, zipkinProtoListSpans, nil)
+
+	spans, err := decodeZipkinProto(list)
+	require.NoError(t, err)
+	require.Len(t, spans, 2)
+	assert.Equal(t, &zipkinSpan{
+		TraceID:       "00000000000000000000000000000001",
+		ParentID:      "0000000000000002",
+		ID:            "0000000000000003",
+		Kind:          "SERVER",
+		Name:          "get",
+		Timestamp:     1700000000000000,
+		Duration:      20000,
+		Debug:         true,
+		LocalEndpoint: &zipkinEndpoint{ServiceName: "frontend", IPv4: "10.0.0.1", Port: 8080},
+		Annotations:   []zipkinAnnotation{{Timestamp: 1700000000005000, Value: "wr"}},
+		Tags:          map[string]string{"http.method": "GET"},
+	}, spans[0])
+	assert.Equal(t, &zipkinSpan{}, spans[1])
+
+	_, err = decodeZipkinProto(list[:len(list)-len(span)/2])
+	assert.Error(t, err)
+}
+
+func TestHandleZipkinSpans(t *testing.T) {
+	conf := newTestReceiverConfig()
+	conf.DefaultEnv = "none"
+	r := newTestReceiverFromConfig(conf)
+	handler := r.handleWithVersion(zipkinV2, r.handleZipkinSpans)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, err := gz.Write([]byte(zipkinTestSpans))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	p := <-r.out
+	assert.Equal(t, "prod", p.TracerPayload.Env)
+	require.Len(t, p.TracerPayload.Chunks, 2)
+	assert.Len(t, p.TracerPayload.Chunks[0].Spans, 2)
+	assert.Equal(t, int32(sampler.PriorityNone), p.TracerPayload.Chunks[0].Priority)
+	assert.Len(t, p.TracerPayload.Chunks[1].Spans, 1)
+	assert.Equal(t, int32(sampler.PriorityUserKeep), p.TracerPayload.Chunks[1].Priority)
+	assert.EqualValues(t, 2, p.Source.TracesReceived.Load())
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewReader([]byte(`[{"id":"1"}]`))))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v2/spans", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Empty(t, r.out)
+}
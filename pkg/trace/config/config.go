@@ -186,6 +186,70 @@ type ReplaceRule struct {
 	Repl string `mapstructure:"repl"`
 }
 
+// Span rule actions.
+const (
+	// SpanRuleDrop drops the matching spans, their children being re-parented.
+	SpanRuleDrop = "drop"
+	// SpanRuleDelete deletes the Keys attributes of the matching spans.
+	SpanRuleDelete = "delete"
+	// SpanRuleHash replaces the values of the Keys attributes of the matching spans by their hash.
+	SpanRuleHash = "hash"
+	// SpanRuleSet sets the Key attribute of the matching spans to Value.
+	SpanRuleSet = "set"
+	// SpanRuleRename renames the From attribute of the matching spans to To.
+	SpanRuleRename = "rename"
+)
+
+// SpanRule specifies a rule applied by the agent to each span it receives,
+// before computing the stats.
+type SpanRule struct {
+	// Name identifies the rule in the telemetry.
+	Name string `mapstructure:"name"`
+
+	// Match specifies the conditions which must all be met by a span for the rule
+	// to apply. A rule without conditions applies to all spans.
+	Match []*SpanRuleCondition `mapstructure:"match"`
+
+	// Action specifies what the rule does to the matching spans: one of SpanRuleDrop,
+	// SpanRuleDelete, SpanRuleHash, SpanRuleSet or SpanRuleRename.
+	Action string `mapstructure:"action"`
+
+	// Keys specifies the meta or metrics keys deleted or hashed by the rule.
+	Keys []string `mapstructure:"keys"`
+
+	// Key and Value specify the attribute set by the rule. Key is either "service",
+	// "name", "resource", "type" or a meta key.
+	Key   string `mapstructure:"key"`
+	Value string `mapstructure:"value"`
+
+	// From and To specify the meta or metrics key renamed by the rule.
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// SpanRuleCondition specifies a condition on an attribute of a span. The condition
+// is met if the attribute is set and, when specified, matches Value, Pattern, or
+// compares to Number using Op.
+type SpanRuleCondition struct {
+	// Key specifies the attribute: "service", "name", "resource", "type", or a meta
+	// or metrics key.
+	Key string `mapstructure:"key"`
+
+	// Value specifies the exact value of the attribute.
+	Value string `mapstructure:"value"`
+
+	// Pattern specifies a regexp pattern matching the value of the attribute. It must compile.
+	Pattern string `mapstructure:"pattern"`
+
+	// Re holds the compiled Pattern and is only used internally.
+	Re *regexp.Regexp `mapstructure:"-"`
+
+	// Op specifies the operator used to compare the numeric value of the attribute
+	// to Number: "==", "!=", "<", "<=", ">" or ">=".
+	Op     string  `mapstructure:"op"`
+	Number float64 `mapstructure:"number"`
+}
+
 // WriterConfig specifies configuration for an API writer.
 type WriterConfig struct {
 	// ConnectionLimit specifies the maximum number of concurrent outgoing
@@ -201,6 +265,25 @@ type WriterConfig struct {
 	FlushPeriodSeconds float64 `mapstructure:"flush_period_seconds"`
 }
 
+// PayloadSpillConfig specifies the on-disk queue of the trace and stats payloads
+// which could not be sent to the intake after all their retries.
+type PayloadSpillConfig struct {
+	// Enabled specifies whether the payloads are spilled to disk instead of
+	// being dropped.
+	Enabled bool
+
+	// Dir is the directory holding the spilled payloads.
+	Dir string
+
+	// MaxSize is the maximum total size, in bytes, of the spilled payloads. When
+	// it is reached, the oldest trace payloads are dropped first, to keep the
+	// stats payloads.
+	MaxSize int64
+
+	// MaxAge is the age after which a spilled payload is dropped.
+	MaxAge time.Duration
+}
+
 // FargateOrchestratorName is a Fargate orchestrator name.
 type FargateOrchestratorName string
 
@@ -315,6 +398,14 @@ type AgentConfig struct {
 	RareSamplerCooldownPeriod time.Duration
 	RareSamplerCardinality    int
 
+	// Latency Sampler configuration
+	LatencySamplerEnabled    bool
+	LatencySamplerTPS        float64
+	LatencySamplerPercentile float64 // in ]0, 100[
+
+	// TraceSamplingRules holds the trace sampling rules applied in order by the agent.
+	TraceSamplingRules []*TraceSamplingRule
+
 	// Probabilistic Sampler configuration
 	ProbabilisticSamplerEnabled            bool
 	ProbabilisticSamplerHashSeed           uint32
@@ -353,6 +444,9 @@ type AgentConfig struct {
 	// case, the sender will drop failed payloads when it is unable to enqueue
 	// them for another retry.
 	MaxSenderRetries int
+	// PayloadSpill configures the spill to disk of the payloads which could not
+	// be sent, replayed once the intake is reachable again.
+	PayloadSpill PayloadSpillConfig
 	// HTTP client used in writer connections. If nil, default client values will be used.
 	HTTPClientFunc func() *http.Client `json:"-"`
 
@@ -382,6 +476,10 @@ type AgentConfig struct {
 	// It maps tag keys to a set of replacements. Only supported in A6.
 	ReplaceTags []*ReplaceRule
 
+	// SpanRules holds the rules dropping or modifying the spans matching their
+	// conditions. They are applied in order to each span.
+	SpanRules []*SpanRule
+
 	// GlobalTags list metadata that will be added to all spans
 	GlobalTags map[string]string
 
@@ -509,6 +607,10 @@ func New() *AgentConfig {
 		RareSamplerCooldownPeriod: 5 * time.Minute,
 		RareSamplerCardinality:    200,
 
+		LatencySamplerEnabled:    false,
+		LatencySamplerTPS:        5,
+		LatencySamplerPercentile: 99,
+
 		ErrorTrackingStandalone: false,
 
 		ReceiverEnabled:        true,
@@ -523,6 +625,10 @@ func New() *AgentConfig {
 		TraceWriter:             new(WriterConfig),
 		ConnectionResetInterval: 0, // disabled
 		MaxSenderRetries:        4,
+		PayloadSpill: PayloadSpillConfig{
+			MaxSize: 512 * 1024 * 1024, // 512MB
+			MaxAge:  time.Hour,
+		},
 
 		StatsdHost:    "localhost",
 		StatsdPort:    8125,
@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TraceSamplingRule specifies a trace sampling rule applied by the agent to the
+// traces from the tracers which did not apply their own rules, and to the OTLP
+// traces. A rule matches a trace if its root span matches all the patterns of
+// the rule. The patterns are globs, "*" matching any sequence of characters and
+// "?" any single character. An empty pattern matches anything.
+type TraceSamplingRule struct {
+	// Service specifies the pattern of the service of the root span.
+	Service string `mapstructure:"service"`
+
+	// Name specifies the pattern of the operation name of the root span.
+	Name string `mapstructure:"name"`
+
+	// Resource specifies the pattern of the resource of the root span.
+	Resource string `mapstructure:"resource"`
+
+	// Tags specifies the patterns of the values of the tags of the root span, by key.
+	// The tags must be set, including when their pattern is empty.
+	Tags map[string]string `mapstructure:"tags"`
+
+	// SampleRate specifies the rate at which the matching traces are kept, between 0 and 1.
+	SampleRate float64 `mapstructure:"sample_rate"`
+
+	// RateLimit specifies the maximum number of matching traces kept per second.
+	// It is not limited if 0.
+	RateLimit float64 `mapstructure:"rate_limit"`
+
+	// ServiceRe, NameRe, ResourceRe and TagsRe hold the compiled patterns and are
+	// only used internally. They are nil for the empty patterns.
+	ServiceRe  *regexp.Regexp            `mapstructure:"-"`
+	NameRe     *regexp.Regexp            `mapstructure:"-"`
+	ResourceRe *regexp.Regexp            `mapstructure:"-"`
+	TagsRe     map[string]*regexp.Regexp `mapstructure:"-"`
+}
+
+// Compile validates the rule and compiles its patterns.
+func (r *TraceSamplingRule) Compile() error {
+	if r.SampleRate < 0 || r.SampleRate > 1 {
+		return fmt.Errorf("sample_rate %v must be between 0 and 1", r.SampleRate)
+	}
+	if r.RateLimit < 0 {
+		return fmt.Errorf("rate_limit %v must be positive", r.RateLimit)
+	}
+	r.ServiceRe = globRegexp(r.Service)
+	r.NameRe = globRegexp(r.Name)
+	r.ResourceRe = globRegexp(r.Resource)
+	r.TagsRe = nil
+	for k, v := range r.Tags {
+		if k == "" {
+			return errors.New("tags must have a key")
+		}
+		if r.TagsRe == nil {
+			r.TagsRe = make(map[string]*regexp.Regexp, len(r.Tags))
+		}
+		r.TagsRe[k] = globRegexp(v)
+	}
+	return nil
+}
+
+// globRegexp returns the regexp matching the glob pattern, or nil if it is empty.
+func globRegexp(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	var b strings.Builder
+	// the patterns match across lines, like resources holding SQL queries
+	b.WriteString("(?s)^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceSamplingRuleCompile(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		r := &TraceSamplingRule{
+			Service:    "web-*",
+			Resource:   "GET /users/?",
+			Tags:       map[string]string{"http.status_code": "5*", "team": ""},
+			SampleRate: 0.5,
+			RateLimit:  10,
+		}
+		require.NoError(t, r.Compile())
+		assert.Nil(t, r.NameRe)
+		assert.True(t, r.ServiceRe.MatchString("web-store"))
+		assert.False(t, r.ServiceRe.MatchString("api-web-store"))
+		assert.True(t, r.ResourceRe.MatchString("GET /users/1"))
+		assert.False(t, r.ResourceRe.MatchString("GET /users/12"))
+		assert.True(t, r.TagsRe["http.status_code"].MatchString("503"))
+		assert.Contains(t, r.TagsRe, "team")
+		assert.Nil(t, r.TagsRe["team"])
+	})
+
+	t.Run("literal", func(t *testing.T) {
+		r := &TraceSamplingRule{Resource: "SELECT (a.b) FROM t\nWHERE *"}
+		require.NoError(t, r.Compile())
+		assert.True(t, r.ResourceRe.MatchString("SELECT (a.b) FROM t\nWHERE id = 1"))
+		assert.False(t, r.ResourceRe.MatchString("SELECT (aab) FROM t\nWHERE id = 1"))
+	})
+
+	for _, r := range []*TraceSamplingRule{
+		{SampleRate: -0.1},
+		{SampleRate: 1.1},
+		{SampleRate: 1, RateLimit: -1},
+		{SampleRate: 1, Tags: map[string]string{"": "value"}},
+	} {
+		assert.Error(t, r.Compile(), "%+v", r)
+	}
+}
@@ -0,0 +1,316 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package filters
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// SpanRules is a filter which drops or modifies the spans matching its rules.
+// It counts the spans each rule matched and changed, and reports these counts
+// periodically once started.
+type SpanRules struct {
+	rules  []*spanRule
+	statsd statsd.ClientInterface
+
+	// start/stop synchronization
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// spanRule holds a span rule along with its counters.
+type spanRule struct {
+	*config.SpanRule
+	tags    []string
+	matched *atomic.Int64 // spans meeting the conditions of the rule
+	applied *atomic.Int64 // spans dropped or changed by the rule
+}
+
+// NewSpanRules returns a new SpanRules filter which will apply the given rules,
+// compiled beforehand, in order.
+func NewSpanRules(rules []*config.SpanRule, statsd statsd.ClientInterface) *SpanRules {
+	sr := &SpanRules{
+		rules:   make([]*spanRule, 0, len(rules)),
+		statsd:  statsd,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	for _, r := range rules {
+		sr.rules = append(sr.rules, &spanRule{
+			SpanRule: r,
+			tags:     []string{"rule:" + r.Name, "action:" + r.Action},
+			matched:  atomic.NewInt64(0),
+			applied:  atomic.NewInt64(0),
+		})
+	}
+	return sr
+}
+
+// Start starts up the routine periodically reporting the counters of the rules.
+func (f *SpanRules) Start() {
+	if len(f.rules) == 0 {
+		close(f.stopped)
+		return
+	}
+	go func() {
+		defer watchdog.LogOnPanic(f.statsd)
+		statsTicker := time.NewTicker(10 * time.Second)
+		defer statsTicker.Stop()
+		for {
+			select {
+			case <-statsTicker.C:
+				f.report()
+			case <-f.stop:
+				f.report()
+				close(f.stopped)
+				return
+			}
+		}
+	}()
+}
+
+// Stop shuts down the reporting routine, reporting the counters one last time.
+func (f *SpanRules) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+		<-f.stopped
+	})
+}
+
+func (f *SpanRules) report() {
+	for _, r := range f.rules {
+		_ = f.statsd.Count("datadog.trace_agent.span_rules.matched", r.matched.Swap(0), r.tags, 1)
+		_ = f.statsd.Count("datadog.trace_agent.span_rules.applied", r.applied.Swap(0), r.tags, 1)
+	}
+}
+
+// Apply applies the rules to the spans of the trace and returns the spans which
+// were kept. The children of the dropped spans are re-parented to the closest
+// kept ancestor, or made roots if there is none. The returned trace shares the
+// backing array of the given one.
+func (f *SpanRules) Apply(trace pb.Trace) pb.Trace {
+	if len(f.rules) == 0 {
+		return trace
+	}
+	var dropped map[uint64]uint64 // parent ID by dropped span ID
+	kept := trace[:0]
+	for _, s := range trace {
+		if f.applySpan(s) {
+			kept = append(kept, s)
+			continue
+		}
+		if dropped == nil {
+			dropped = make(map[uint64]uint64)
+		}
+		dropped[s.SpanID] = s.ParentID
+	}
+	if len(dropped) == 0 {
+		return kept
+	}
+	for _, s := range kept {
+		// bounded, in case the parent IDs of the dropped spans form a cycle
+		for i := 0; i < len(dropped); i++ {
+			parentID, ok := dropped[s.ParentID]
+			if !ok {
+				break
+			}
+			s.ParentID = parentID
+		}
+		if _, ok := dropped[s.ParentID]; ok {
+			s.ParentID = 0
+		}
+	}
+	// zero the tail so that the dropped spans can be garbage collected
+	for i := len(kept); i < len(trace); i++ {
+		trace[i] = nil
+	}
+	return kept
+}
+
+// applySpan applies the rules to the span s, returning false if it should be dropped.
+func (f *SpanRules) applySpan(s *pb.Span) bool {
+	for _, r := range f.rules {
+		if !r.matches(s) {
+			continue
+		}
+		r.matched.Inc()
+		if r.Action == config.SpanRuleDrop {
+			r.applied.Inc()
+			return false
+		}
+		if r.apply(s) {
+			r.applied.Inc()
+		}
+	}
+	return true
+}
+
+// matches returns true if the span s meets all the conditions of the rule.
+func (r *spanRule) matches(s *pb.Span) bool {
+	for _, c := range r.Match {
+		str, num, isNum, ok := spanAttribute(s, c.Key)
+		if !ok {
+			return false
+		}
+		if c.Value != "" && str != c.Value {
+			return false
+		}
+		if c.Re != nil && !c.Re.MatchString(str) {
+			return false
+		}
+		if c.Op != "" {
+			if !isNum {
+				var err error
+				if num, err = strconv.ParseFloat(str, 64); err != nil {
+					return false
+				}
+			}
+			if !compareNumber(num, c.Op, c.Number) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// apply applies the action of the rule to the span s, returning true if it changed it.
+func (r *spanRule) apply(s *pb.Span) bool {
+	changed := false
+	switch r.Action {
+	case config.SpanRuleDelete:
+		for _, k := range r.Keys {
+			if _, ok := s.Meta[k]; ok {
+				delete(s.Meta, k)
+				changed = true
+			}
+			if _, ok := s.Metrics[k]; ok {
+				delete(s.Metrics, k)
+				changed = true
+			}
+		}
+	case config.SpanRuleHash:
+		for _, k := range r.Keys {
+			if v, ok := s.Meta[k]; ok {
+				s.Meta[k] = hashValue(v)
+				changed = true
+			}
+			if v, ok := s.Metrics[k]; ok {
+				// the hash is not a number, it is moved to the meta
+				delete(s.Metrics, k)
+				setMeta(s, k, hashValue(strconv.FormatFloat(v, 'f', -1, 64)))
+				changed = true
+			}
+		}
+	case config.SpanRuleSet:
+		changed = setSpanAttribute(s, r.Key, r.Value)
+	case config.SpanRuleRename:
+		if v, ok := s.Meta[r.From]; ok {
+			delete(s.Meta, r.From)
+			setMeta(s, r.To, v)
+			changed = true
+		}
+		if v, ok := s.Metrics[r.From]; ok {
+			delete(s.Metrics, r.From)
+			s.Metrics[r.To] = v
+			changed = true
+		}
+	}
+	return changed
+}
+
+// spanAttribute returns the value of the attribute key of the span s, as a
+// string and, for the metrics, as a number. ok is false if it is not set.
+func spanAttribute(s *pb.Span, key string) (str string, num float64, isNum, ok bool) {
+	switch key {
+	case "service":
+		return s.Service, 0, false, true
+	case "name":
+		return s.Name, 0, false, true
+	case "resource":
+		return s.Resource, 0, false, true
+	case "type":
+		return s.Type, 0, false, true
+	}
+	if v, ok := s.Meta[key]; ok {
+		return v, 0, false, true
+	}
+	if v, ok := s.Metrics[key]; ok {
+		return strconv.FormatFloat(v, 'f', -1, 64), v, true, true
+	}
+	return "", 0, false, false
+}
+
+// setSpanAttribute sets the attribute key of the span s to v, returning true
+// if it changed.
+func setSpanAttribute(s *pb.Span, key, v string) bool {
+	var field *string
+	switch key {
+	case "service":
+		field = &s.Service
+	case "name":
+		field = &s.Name
+	case "resource":
+		field = &s.Resource
+	case "type":
+		field = &s.Type
+	default:
+		if old, ok := s.Meta[key]; ok && old == v {
+			return false
+		}
+		setMeta(s, key, v)
+		return true
+	}
+	if *field == v {
+		return false
+	}
+	*field = v
+	return true
+}
+
+func setMeta(s *pb.Span, key, v string) {
+	if s.Meta == nil {
+		s.Meta = make(map[string]string, 1)
+	}
+	s.Meta[key] = v
+}
+
+// hashValue returns the hexadecimal FNV-1a hash of v.
+func hashValue(v string) string {
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// compareNumber returns the result of the comparison of a and b with the operator op.
+func compareNumber(a float64, op string, b float64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package filters
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+func TestSpanRulesMatch(t *testing.T) {
+	span := &pb.Span{
+		Service:  "web",
+		Name:     "http.request",
+		Resource: "GET /health",
+		Type:     "web",
+		Meta:     map[string]string{"http.status_code": "503", "user.email": "a@b.c"},
+		Metrics:  map[string]float64{"db.row_count": 1500},
+	}
+	for _, tt := range []struct {
+		cond *config.SpanRuleCondition
+		want bool
+	}{
+		{&config.SpanRuleCondition{Key: "service", Value: "web"}, true},
+		{&config.SpanRuleCondition{Key: "service", Value: "we"}, false},
+		{&config.SpanRuleCondition{Key: "resource", Re: regexp.MustCompile("^GET /health")}, true},
+		{&config.SpanRuleCondition{Key: "name", Re: regexp.MustCompile("^grpc")}, false},
+		{&config.SpanRuleCondition{Key: "user.email"}, true},
+		{&config.SpanRuleCondition{Key: "user.id"}, false},
+		{&config.SpanRuleCondition{Key: "http.status_code", Op: ">=", Number: 500}, true},
+		{&config.SpanRuleCondition{Key: "http.status_code", Op: "<", Number: 500}, false},
+		{&config.SpanRuleCondition{Key: "user.email", Op: "==", Number: 0}, false},
+		{&config.SpanRuleCondition{Key: "db.row_count", Op: ">", Number: 1000}, true},
+		{&config.SpanRuleCondition{Key: "db.row_count", Op: "!=", Number: 1500}, false},
+		{&config.SpanRuleCondition{Key: "db.row_count", Value: "1500"}, true},
+		{&config.SpanRuleCondition{Key: "db.row_count", Re: regexp.MustCompile("^15")}, true},
+	} {
+		r := &spanRule{SpanRule: &config.SpanRule{Match: []*config.SpanRuleCondition{tt.cond}}}
+		assert.Equal(t, tt.want, r.matches(span), "%+v", tt.cond)
+	}
+
+	// all the conditions must be met
+	r := &spanRule{SpanRule: &config.SpanRule{Match: []*config.SpanRuleCondition{
+		{Key: "service", Value: "web"},
+		{Key: "db.row_count", Op: "<", Number: 1000},
+	}}}
+	assert.False(t, r.matches(span))
+}
+
+func TestSpanRulesApply(t *testing.T) {
+	f := NewSpanRules([]*config.SpanRule{
+		{Name: "drop-health", Match: []*config.SpanRuleCondition{{Key: "resource", Value: "GET /health"}}, Action: config.SpanRuleDrop},
+		{Name: "delete", Action: config.SpanRuleDelete, Keys: []string{"secret", "secret.count"}},
+		{Name: "hash", Action: config.SpanRuleHash, Keys: []string{"user.email", "user.id"}},
+		{Name: "set", Match: []*config.SpanRuleCondition{{Key: "service", Value: "web"}}, Action: config.SpanRuleSet, Key: "team", Value: "frontend"},
+		{Name: "set-service", Match: []*config.SpanRuleCondition{{Key: "type", Value: "db"}}, Action: config.SpanRuleSet, Key: "service", Value: "mysql"},
+		{Name: "rename", Action: config.SpanRuleRename, From: "usr.id", To: "user.name"},
+	}, &statsd.NoOpClient{})
+
+	trace := pb.Trace{
+		{SpanID: 1, Service: "web", Resource: "GET /users", Meta: map[string]string{"secret": "s", "user.email": "a@b.c"}},
+		{SpanID: 2, ParentID: 1, Service: "web", Resource: "GET /health", Meta: map[string]string{"team": "frontend"}},
+		{SpanID: 3, ParentID: 2, Service: "web", Type: "db", Metrics: map[string]float64{"user.id": 42, "secret.count": 1}},
+		{SpanID: 4, ParentID: 3, Service: "cache", Meta: map[string]string{"usr.id": "bob"}},
+	}
+	trace = f.Apply(trace)
+
+	assert.Equal(t, pb.Trace{
+		{SpanID: 1, Service: "web", Resource: "GET /users", Meta: map[string]string{"user.email": hashValue("a@b.c"), "team": "frontend"}},
+		{SpanID: 3, ParentID: 1, Service: "mysql", Type: "db", Metrics: map[string]float64{}, Meta: map[string]string{"user.id": hashValue("42"), "team": "frontend"}},
+		{SpanID: 4, ParentID: 3, Service: "cache", Meta: map[string]string{"user.name": "bob"}},
+	}, trace)
+
+	counts := make(map[string][2]int64)
+	for _, r := range f.rules {
+		counts[r.Name] = [2]int64{r.matched.Load(), r.applied.Load()}
+	}
+	assert.Equal(t, map[string][2]int64{
+		"drop-health": {1, 1},
+		"delete":      {3, 2},
+		"hash":        {3, 2},
+		"set":         {2, 2},
+		"set-service": {1, 1},
+		"rename":      {3, 1},
+	}, counts)
+
+	f.Start()
+	f.Stop()
+	for _, r := range f.rules {
+		assert.EqualValues(t, 0, r.matched.Load())
+	}
+}
+
+func TestSpanRulesReparent(t *testing.T) {
+	f := NewSpanRules([]*config.SpanRule{
+		{Name: "drop", Match: []*config.SpanRuleCondition{{Key: "name", Value: "internal"}}, Action: config.SpanRuleDrop},
+	}, &statsd.NoOpClient{})
+
+	t.Run("chain", func(t *testing.T) {
+		trace := f.Apply(pb.Trace{
+			{SpanID: 1, Name: "internal"},
+			{SpanID: 2, ParentID: 1, Name: "internal"},
+			{SpanID: 3, ParentID: 2, Name: "request"},
+			{SpanID: 4, ParentID: 3, Name: "internal"},
+			{SpanID: 5, ParentID: 4, Name: "query"},
+		})
+		assert.Equal(t, pb.Trace{
+			{SpanID: 3, ParentID: 0, Name: "request"},
+			{SpanID: 5, ParentID: 3, Name: "query"},
+		}, trace)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		trace := f.Apply(pb.Trace{
+			{SpanID: 1, ParentID: 2, Name: "internal"},
+			{SpanID: 2, ParentID: 1, Name: "internal"},
+			{SpanID: 3, ParentID: 1, Name: "request"},
+		})
+		assert.Equal(t, pb.Trace{{SpanID: 3, ParentID: 0, Name: "request"}}, trace)
+	})
+
+	t.Run("no-rules", func(t *testing.T) {
+		trace := pb.Trace{{SpanID: 1, Name: "internal"}}
+		assert.Equal(t, trace, NewSpanRules(nil, &statsd.NoOpClient{}).Apply(trace))
+	})
+}
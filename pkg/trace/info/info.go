@@ -86,9 +86,9 @@ const (
   --- Writer stats (1 min) ---
 
   Traces: {{.Status.TraceWriter.Payloads}} payloads, {{.Status.TraceWriter.Traces}} traces, {{if gt .Status.TraceWriter.Events.Load 0}}{{.Status.TraceWriter.Events.Load}} events, {{end}}{{.Status.TraceWriter.Bytes}} bytes
-  {{if gt .Status.TraceWriter.Errors.Load 0}}WARNING: Traces API errors (1 min): {{.Status.TraceWriter.Errors.Load}}{{end}}
+  {{if gt .Status.TraceWriter.Errors.Load 0}}WARNING: Traces API errors (1 min): {{.Status.TraceWriter.Errors.Load}}{{end}}{{if gt .Status.TraceWriter.SpilledPayloads.Load 0}} Spilled to disk: {{.Status.TraceWriter.SpilledPayloads.Load}} payloads, {{.Status.TraceWriter.SpilledBytes.Load}} bytes{{end}}
   Stats: {{.Status.StatsWriter.Payloads.Load}} payloads, {{.Status.StatsWriter.StatsBuckets.Load}} stats buckets, {{.Status.StatsWriter.Bytes.Load}} bytes
-  {{if gt .Status.StatsWriter.Errors.Load 0}}WARNING: Stats API errors (1 min): {{.Status.StatsWriter.Errors.Load}}{{end}}
+  {{if gt .Status.StatsWriter.Errors.Load 0}}WARNING: Stats API errors (1 min): {{.Status.StatsWriter.Errors.Load}}{{end}}{{if gt .Status.StatsWriter.SpilledPayloads.Load 0}} Spilled to disk: {{.Status.StatsWriter.SpilledPayloads.Load}} payloads, {{.Status.StatsWriter.SpilledBytes.Load}} bytes{{end}}
 `
 
 	notRunningTmplSrc = `{{.Banner}}
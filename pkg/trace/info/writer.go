@@ -26,6 +26,8 @@ type TraceWriterInfo struct {
 	Bytes             atomic.Int64
 	BytesUncompressed atomic.Int64
 	SingleMaxSize     atomic.Int64
+	SpilledPayloads   atomic.Int64
+	SpilledBytes      atomic.Int64
 }
 
 // StatsWriterInfo represents statistics from the stats writer.
@@ -34,14 +36,16 @@ type StatsWriterInfo struct {
 	// initialization of the type.  The atomic values _must_ occur first in the
 	// struct.
 
-	Payloads       atomic.Int64
-	ClientPayloads atomic.Int64
-	StatsBuckets   atomic.Int64
-	StatsEntries   atomic.Int64
-	Errors         atomic.Int64
-	Retries        atomic.Int64
-	Splits         atomic.Int64
-	Bytes          atomic.Int64
+	Payloads        atomic.Int64
+	ClientPayloads  atomic.Int64
+	StatsBuckets    atomic.Int64
+	StatsEntries    atomic.Int64
+	Errors          atomic.Int64
+	Retries         atomic.Int64
+	Splits          atomic.Int64
+	Bytes           atomic.Int64
+	SpilledPayloads atomic.Int64
+	SpilledBytes    atomic.Int64
 }
 
 // UpdateTraceWriterInfo updates internal trace writer stats
@@ -51,6 +55,14 @@ func UpdateTraceWriterInfo(tws TraceWriterInfo) {
 	traceWriterInfo = tws
 }
 
+// UpdateTraceWriterSpill updates the number of trace payloads spilled to disk and their size
+func UpdateTraceWriterSpill(payloads, bytes int64) {
+	infoMu.RLock()
+	defer infoMu.RUnlock()
+	traceWriterInfo.SpilledPayloads.Store(payloads)
+	traceWriterInfo.SpilledBytes.Store(bytes)
+}
+
 func publishTraceWriterInfo() interface{} {
 	infoMu.RLock()
 	defer infoMu.RUnlock()
@@ -69,6 +81,8 @@ func (twi TraceWriterInfo) MarshalJSON() ([]byte, error) {
 		"Bytes":             float64(twi.Bytes.Load()),
 		"BytesUncompressed": float64(twi.BytesUncompressed.Load()),
 		"SingleMaxSize":     float64(twi.SingleMaxSize.Load()),
+		"SpilledPayloads":   float64(twi.SpilledPayloads.Load()),
+		"SpilledBytes":      float64(twi.SpilledBytes.Load()),
 	}
 	return json.Marshal(asMap)
 }
@@ -80,6 +94,14 @@ func UpdateStatsWriterInfo(sws StatsWriterInfo) {
 	statsWriterInfo = sws
 }
 
+// UpdateStatsWriterSpill updates the number of stats payloads spilled to disk and their size
+func UpdateStatsWriterSpill(payloads, bytes int64) {
+	infoMu.RLock()
+	defer infoMu.RUnlock()
+	statsWriterInfo.SpilledPayloads.Store(payloads)
+	statsWriterInfo.SpilledBytes.Store(bytes)
+}
+
 func publishStatsWriterInfo() interface{} {
 	infoMu.RLock()
 	defer infoMu.RUnlock()
@@ -89,14 +111,16 @@ func publishStatsWriterInfo() interface{} {
 // MarshalJSON implements encoding/json.MarshalJSON.
 func (swi StatsWriterInfo) MarshalJSON() ([]byte, error) {
 	asMap := map[string]float64{
-		"Payloads":       float64(swi.Payloads.Load()),
-		"ClientPayloads": float64(swi.ClientPayloads.Load()),
-		"StatsBuckets":   float64(swi.StatsBuckets.Load()),
-		"StatsEntries":   float64(swi.StatsEntries.Load()),
-		"Errors":         float64(swi.Errors.Load()),
-		"Retries":        float64(swi.Retries.Load()),
-		"Splits":         float64(swi.Splits.Load()),
-		"Bytes":          float64(swi.Bytes.Load()),
+		"Payloads":        float64(swi.Payloads.Load()),
+		"ClientPayloads":  float64(swi.ClientPayloads.Load()),
+		"StatsBuckets":    float64(swi.StatsBuckets.Load()),
+		"StatsEntries":    float64(swi.StatsEntries.Load()),
+		"Errors":          float64(swi.Errors.Load()),
+		"Retries":         float64(swi.Retries.Load()),
+		"Splits":          float64(swi.Splits.Load()),
+		"Bytes":           float64(swi.Bytes.Load()),
+		"SpilledPayloads": float64(swi.SpilledPayloads.Load()),
+		"SpilledBytes":    float64(swi.SpilledBytes.Load()),
 	}
 	return json.Marshal(asMap)
 }
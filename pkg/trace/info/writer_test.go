@@ -21,6 +21,8 @@ func TestPublishTraceWriterInfo(t *testing.T) {
 		atom(7),
 		atom(8),
 		atom(9),
+		atom(10),
+		atom(11),
 	}
 
 	testExpvarPublish(t, publishTraceWriterInfo,
@@ -35,6 +37,8 @@ func TestPublishTraceWriterInfo(t *testing.T) {
 			"Bytes":             7.0,
 			"BytesUncompressed": 8.0,
 			"SingleMaxSize":     9.0,
+			"SpilledPayloads":   10.0,
+			"SpilledBytes":      11.0,
 		})
 }
 
@@ -49,19 +53,23 @@ func TestPublishStatsWriterInfo(t *testing.T) {
 		atom(6),
 		atom(7),
 		atom(8),
+		atom(9),
+		atom(10),
 	}
 
 	testExpvarPublish(t, publishStatsWriterInfo,
 		map[string]interface{}{
 			// all JSON numbers are floats, so the results come back as floats
-			"Payloads":       1.0,
-			"ClientPayloads": 2.0,
-			"StatsBuckets":   3.0,
-			"StatsEntries":   4.0,
-			"Errors":         5.0,
-			"Retries":        6.0,
-			"Splits":         7.0,
-			"Bytes":          8.0,
+			"Payloads":        1.0,
+			"ClientPayloads":  2.0,
+			"StatsBuckets":    3.0,
+			"StatsEntries":    4.0,
+			"Errors":          5.0,
+			"Retries":         6.0,
+			"Splits":          7.0,
+			"Bytes":           8.0,
+			"SpilledPayloads": 9.0,
+			"SpilledBytes":    10.0,
 		})
 }
 
@@ -6,6 +6,7 @@ package remoteconfighandler
 import (
 	reflect "reflect"
 
+	config "github.com/DataDog/datadog-agent/pkg/trace/config"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -113,3 +114,85 @@ func (mr *MockrareSamplerMockRecorder) SetEnabled(enabled interface{}) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEnabled", reflect.TypeOf((*MockrareSampler)(nil).SetEnabled), enabled)
 }
+
+// MocklatencySampler is a mock of latencySampler interface.
+type MocklatencySampler struct {
+	ctrl     *gomock.Controller
+	recorder *MocklatencySamplerMockRecorder
+}
+
+// MocklatencySamplerMockRecorder is the mock recorder for MocklatencySampler.
+type MocklatencySamplerMockRecorder struct {
+	mock *MocklatencySampler
+}
+
+// NewMocklatencySampler creates a new mock instance.
+func NewMocklatencySampler(ctrl *gomock.Controller) *MocklatencySampler {
+	mock := &MocklatencySampler{ctrl: ctrl}
+	mock.recorder = &MocklatencySamplerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocklatencySampler) EXPECT() *MocklatencySamplerMockRecorder {
+	return m.recorder
+}
+
+// UpdatePercentile mocks base method.
+func (m *MocklatencySampler) UpdatePercentile(percentile float64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdatePercentile", percentile)
+}
+
+// UpdatePercentile indicates an expected call of UpdatePercentile.
+func (mr *MocklatencySamplerMockRecorder) UpdatePercentile(percentile interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePercentile", reflect.TypeOf((*MocklatencySampler)(nil).UpdatePercentile), percentile)
+}
+
+// UpdateTargetTPS mocks base method.
+func (m *MocklatencySampler) UpdateTargetTPS(targetTPS float64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateTargetTPS", targetTPS)
+}
+
+// UpdateTargetTPS indicates an expected call of UpdateTargetTPS.
+func (mr *MocklatencySamplerMockRecorder) UpdateTargetTPS(targetTPS interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTargetTPS", reflect.TypeOf((*MocklatencySampler)(nil).UpdateTargetTPS), targetTPS)
+}
+
+// MockrulesSampler is a mock of rulesSampler interface.
+type MockrulesSampler struct {
+	ctrl     *gomock.Controller
+	recorder *MockrulesSamplerMockRecorder
+}
+
+// MockrulesSamplerMockRecorder is the mock recorder for MockrulesSampler.
+type MockrulesSamplerMockRecorder struct {
+	mock *MockrulesSampler
+}
+
+// NewMockrulesSampler creates a new mock instance.
+func NewMockrulesSampler(ctrl *gomock.Controller) *MockrulesSampler {
+	mock := &MockrulesSampler{ctrl: ctrl}
+	mock.recorder = &MockrulesSamplerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrulesSampler) EXPECT() *MockrulesSamplerMockRecorder {
+	return m.recorder
+}
+
+// UpdateRules mocks base method.
+func (m *MockrulesSampler) UpdateRules(rules []*config.TraceSamplingRule) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateRules", rules)
+}
+
+// UpdateRules indicates an expected call of UpdateRules.
+func (mr *MockrulesSamplerMockRecorder) UpdateRules(rules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRules", reflect.TypeOf((*MockrulesSampler)(nil).UpdateRules), rules)
+}
@@ -34,19 +34,30 @@ type rareSampler interface {
 	SetEnabled(enabled bool)
 }
 
+type latencySampler interface {
+	UpdateTargetTPS(targetTPS float64)
+	UpdatePercentile(percentile float64)
+}
+
+type rulesSampler interface {
+	UpdateRules(rules []*config.TraceSamplingRule)
+}
+
 // RemoteConfigHandler holds pointers to samplers that need to be updated when APM remote config changes
 type RemoteConfigHandler struct {
 	remoteClient                  config.RemoteClient
 	prioritySampler               prioritySampler
 	errorsSampler                 errorsSampler
 	rareSampler                   rareSampler
+	latencySampler                latencySampler
+	rulesSampler                  rulesSampler
 	agentConfig                   *config.AgentConfig
 	configState                   *state.AgentConfigState
 	configSetEndpointFormatString string
 }
 
 // New creates a new RemoteConfigHandler
-func New(conf *config.AgentConfig, prioritySampler prioritySampler, rareSampler rareSampler, errorsSampler errorsSampler) *RemoteConfigHandler {
+func New(conf *config.AgentConfig, prioritySampler prioritySampler, rareSampler rareSampler, errorsSampler errorsSampler, latencySampler latencySampler, rulesSampler rulesSampler) *RemoteConfigHandler {
 	if conf.RemoteConfigClient == nil {
 		return nil
 	}
@@ -62,6 +73,8 @@ func New(conf *config.AgentConfig, prioritySampler prioritySampler, rareSampler
 		prioritySampler: prioritySampler,
 		rareSampler:     rareSampler,
 		errorsSampler:   errorsSampler,
+		latencySampler:  latencySampler,
+		rulesSampler:    rulesSampler,
 		agentConfig:     conf,
 		configState: &state.AgentConfigState{
 			FallbackLogLevel: level.String(),
@@ -219,4 +232,54 @@ func (h *RemoteConfigHandler) updateSamplers(config apmsampling.SamplerConfig) {
 		rareSamplerEnabled = h.agentConfig.RareSamplerEnabled
 	}
 	h.rareSampler.SetEnabled(rareSamplerEnabled)
+
+	var latencySamplerTargetTPS float64
+	if confForEnv != nil && confForEnv.LatencySamplerTargetTPS != nil {
+		latencySamplerTargetTPS = *confForEnv.LatencySamplerTargetTPS
+	} else if config.AllEnvs.LatencySamplerTargetTPS != nil {
+		latencySamplerTargetTPS = *config.AllEnvs.LatencySamplerTargetTPS
+	} else {
+		latencySamplerTargetTPS = h.agentConfig.LatencySamplerTPS
+	}
+	h.latencySampler.UpdateTargetTPS(latencySamplerTargetTPS)
+
+	var latencySamplerPercentile float64
+	if confForEnv != nil && confForEnv.LatencySamplerPercentile != nil {
+		latencySamplerPercentile = *confForEnv.LatencySamplerPercentile
+	} else if config.AllEnvs.LatencySamplerPercentile != nil {
+		latencySamplerPercentile = *config.AllEnvs.LatencySamplerPercentile
+	} else {
+		latencySamplerPercentile = h.agentConfig.LatencySamplerPercentile
+	}
+	h.latencySampler.UpdatePercentile(latencySamplerPercentile)
+
+	traceSamplingRules := h.agentConfig.TraceSamplingRules
+	if confForEnv != nil && confForEnv.TraceSamplingRules != nil {
+		traceSamplingRules = compileTraceSamplingRules(confForEnv.TraceSamplingRules)
+	} else if config.AllEnvs.TraceSamplingRules != nil {
+		traceSamplingRules = compileTraceSamplingRules(config.AllEnvs.TraceSamplingRules)
+	}
+	h.rulesSampler.UpdateRules(traceSamplingRules)
+}
+
+// compileTraceSamplingRules returns the compiled trace sampling rules from remote config,
+// leaving out the invalid ones.
+func compileTraceSamplingRules(rules []apmsampling.TraceSamplingRule) []*config.TraceSamplingRule {
+	compiled := make([]*config.TraceSamplingRule, 0, len(rules))
+	for _, r := range rules {
+		rule := &config.TraceSamplingRule{
+			Service:    r.Service,
+			Name:       r.Name,
+			Resource:   r.Resource,
+			Tags:       r.Tags,
+			SampleRate: r.SampleRate,
+			RateLimit:  r.RateLimit,
+		}
+		if err := rule.Compile(); err != nil {
+			log.Errorf("ignoring invalid trace sampling rule from remote config: %s", err)
+			continue
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled
 }
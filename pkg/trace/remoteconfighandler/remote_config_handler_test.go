@@ -33,9 +33,11 @@ func TestStart(t *testing.T) {
 	prioritySampler := NewMockprioritySampler(ctrl)
 	errorsSampler := NewMockerrorsSampler(ctrl)
 	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
 	pkglog.SetupLogger(pkglog.Default(), "debug")
 
-	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler)
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
 
 	remoteClient.EXPECT().Subscribe(state.ProductAPMSampling, gomock.Any()).Times(1)
 	remoteClient.EXPECT().Subscribe(state.ProductAgentConfig, gomock.Any()).Times(1)
@@ -57,10 +59,12 @@ func TestPrioritySampler(t *testing.T) {
 	prioritySampler := NewMockprioritySampler(ctrl)
 	errorsSampler := NewMockerrorsSampler(ctrl)
 	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
 	pkglog.SetupLogger(pkglog.Default(), "debug")
 
-	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true}
-	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler)
+	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, LatencySamplerTPS: 41, LatencySamplerPercentile: 99}
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
 
 	payload := apmsampling.SamplerConfig{
 		AllEnvs: apmsampling.SamplerEnvConfig{
@@ -76,6 +80,9 @@ func TestPrioritySampler(t *testing.T) {
 	prioritySampler.EXPECT().UpdateTargetTPS(float64(42)).Times(1)
 	errorsSampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
 	rareSampler.EXPECT().SetEnabled(true).Times(1)
+	latencySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
+	latencySampler.EXPECT().UpdatePercentile(float64(99)).Times(1)
+	rulesSampler.EXPECT().UpdateRules(gomock.Nil()).Times(1)
 
 	h.onUpdate(map[string]state.RawConfig{"datadog/2/APM_SAMPLING/samplerconfig/config": config}, applyEmpty)
 
@@ -88,10 +95,12 @@ func TestErrorsSampler(t *testing.T) {
 	prioritySampler := NewMockprioritySampler(ctrl)
 	errorsSampler := NewMockerrorsSampler(ctrl)
 	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
 	pkglog.SetupLogger(pkglog.Default(), "debug")
 
-	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true}
-	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler)
+	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, LatencySamplerTPS: 41, LatencySamplerPercentile: 99}
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
 
 	payload := apmsampling.SamplerConfig{
 		AllEnvs: apmsampling.SamplerEnvConfig{
@@ -107,6 +116,9 @@ func TestErrorsSampler(t *testing.T) {
 	prioritySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
 	errorsSampler.EXPECT().UpdateTargetTPS(float64(42)).Times(1)
 	rareSampler.EXPECT().SetEnabled(true).Times(1)
+	latencySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
+	latencySampler.EXPECT().UpdatePercentile(float64(99)).Times(1)
+	rulesSampler.EXPECT().UpdateRules(gomock.Nil()).Times(1)
 
 	h.onUpdate(map[string]state.RawConfig{"datadog/2/APM_SAMPLING/samplerconfig/config": config}, applyEmpty)
 
@@ -119,10 +131,12 @@ func TestRareSampler(t *testing.T) {
 	prioritySampler := NewMockprioritySampler(ctrl)
 	errorsSampler := NewMockerrorsSampler(ctrl)
 	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
 	pkglog.SetupLogger(pkglog.Default(), "debug")
 
-	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true}
-	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler)
+	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, LatencySamplerTPS: 41, LatencySamplerPercentile: 99}
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
 
 	payload := apmsampling.SamplerConfig{
 		AllEnvs: apmsampling.SamplerEnvConfig{
@@ -138,28 +152,135 @@ func TestRareSampler(t *testing.T) {
 	prioritySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
 	errorsSampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
 	rareSampler.EXPECT().SetEnabled(false).Times(1)
+	latencySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
+	latencySampler.EXPECT().UpdatePercentile(float64(99)).Times(1)
+	rulesSampler.EXPECT().UpdateRules(gomock.Nil()).Times(1)
 
 	h.onUpdate(map[string]state.RawConfig{"datadog/2/APM_SAMPLING/samplerconfig/config": config}, applyEmpty)
 
 	ctrl.Finish()
 }
 
+func TestLatencySampler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	remoteClient := NewMockRemoteClient(ctrl)
+	prioritySampler := NewMockprioritySampler(ctrl)
+	errorsSampler := NewMockerrorsSampler(ctrl)
+	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
+	pkglog.SetupLogger(pkglog.Default(), "debug")
+
+	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, LatencySamplerTPS: 41, LatencySamplerPercentile: 99}
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
+
+	payload := apmsampling.SamplerConfig{
+		AllEnvs: apmsampling.SamplerEnvConfig{
+			LatencySamplerTargetTPS:  pointer.Ptr(42.0),
+			LatencySamplerPercentile: pointer.Ptr(95.0),
+		},
+	}
+
+	raw, _ := json.Marshal(payload)
+	config := state.RawConfig{
+		Config: raw,
+	}
+
+	prioritySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
+	errorsSampler.EXPECT().UpdateTargetTPS(float64(41)).Times(1)
+	rareSampler.EXPECT().SetEnabled(true).Times(1)
+	latencySampler.EXPECT().UpdateTargetTPS(float64(42)).Times(1)
+	latencySampler.EXPECT().UpdatePercentile(float64(95)).Times(1)
+	rulesSampler.EXPECT().UpdateRules(gomock.Nil()).Times(1)
+
+	h.onUpdate(map[string]state.RawConfig{"datadog/2/APM_SAMPLING/samplerconfig/config": config}, applyEmpty)
+
+	ctrl.Finish()
+}
+
+func TestTraceSamplingRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	remoteClient := NewMockRemoteClient(ctrl)
+	prioritySampler := NewMockprioritySampler(ctrl)
+	errorsSampler := NewMockerrorsSampler(ctrl)
+	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
+	pkglog.SetupLogger(pkglog.Default(), "debug")
+
+	agentRules := []*config.TraceSamplingRule{{Service: "agent-*", SampleRate: 0.1}}
+	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, LatencySamplerTPS: 41, LatencySamplerPercentile: 99, DefaultEnv: "agent-env", TraceSamplingRules: agentRules}
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
+
+	update := func(payload apmsampling.SamplerConfig) {
+		raw, _ := json.Marshal(payload)
+		h.onUpdate(map[string]state.RawConfig{"datadog/2/APM_SAMPLING/samplerconfig/config": {Config: raw}}, applyEmpty)
+	}
+	prioritySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(3)
+	errorsSampler.EXPECT().UpdateTargetTPS(float64(41)).Times(3)
+	rareSampler.EXPECT().SetEnabled(true).Times(3)
+	latencySampler.EXPECT().UpdateTargetTPS(float64(41)).Times(3)
+	latencySampler.EXPECT().UpdatePercentile(float64(99)).Times(3)
+
+	// the rules of the env take precedence, leaving out the invalid ones
+	var rules []*config.TraceSamplingRule
+	rulesSampler.EXPECT().UpdateRules(gomock.Any()).Do(func(r []*config.TraceSamplingRule) { rules = r }).Times(1)
+	update(apmsampling.SamplerConfig{
+		AllEnvs: apmsampling.SamplerEnvConfig{
+			TraceSamplingRules: []apmsampling.TraceSamplingRule{{Service: "all-envs", SampleRate: 0.5}},
+		},
+		ByEnv: []apmsampling.EnvAndConfig{{
+			Env: "agent-env",
+			Config: apmsampling.SamplerEnvConfig{
+				TraceSamplingRules: []apmsampling.TraceSamplingRule{
+					{Service: "web-*", Resource: "GET /health*", Tags: map[string]string{"http.status_code": "200"}, SampleRate: 0, RateLimit: 10},
+					{Service: "invalid", SampleRate: 2},
+				},
+			},
+		}},
+	})
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "web-*", rules[0].Service)
+	assert.True(t, rules[0].ServiceRe.MatchString("web-store"))
+	assert.True(t, rules[0].ResourceRe.MatchString("GET /healthz"))
+	assert.Equal(t, 10.0, rules[0].RateLimit)
+
+	// an empty list of rules from remote config removes the rules
+	rulesSampler.EXPECT().UpdateRules(gomock.Any()).Do(func(r []*config.TraceSamplingRule) { rules = r }).Times(1)
+	update(apmsampling.SamplerConfig{
+		AllEnvs: apmsampling.SamplerEnvConfig{
+			TraceSamplingRules: []apmsampling.TraceSamplingRule{},
+		},
+	})
+	assert.Empty(t, rules)
+
+	// the rules of the agent config apply when remote config has none
+	rulesSampler.EXPECT().UpdateRules(agentRules).Times(1)
+	update(apmsampling.SamplerConfig{})
+
+	ctrl.Finish()
+}
+
 func TestEnvPrecedence(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	remoteClient := NewMockRemoteClient(ctrl)
 	prioritySampler := NewMockprioritySampler(ctrl)
 	errorsSampler := NewMockerrorsSampler(ctrl)
 	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
 	pkglog.SetupLogger(pkglog.Default(), "debug")
 
-	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, DefaultEnv: "agent-env"}
-	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler)
+	agentConfig := config.AgentConfig{RemoteConfigClient: remoteClient, TargetTPS: 41, ErrorTPS: 41, RareSamplerEnabled: true, LatencySamplerTPS: 41, LatencySamplerPercentile: 99, DefaultEnv: "agent-env"}
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
 
 	payload := apmsampling.SamplerConfig{
 		AllEnvs: apmsampling.SamplerEnvConfig{
 			PrioritySamplerTargetTPS: pointer.Ptr(42.0),
 			ErrorsSamplerTargetTPS:   pointer.Ptr(42.0),
 			RareSamplerEnabled:       pointer.Ptr(true),
+			LatencySamplerTargetTPS:  pointer.Ptr(42.0),
+			LatencySamplerPercentile: pointer.Ptr(95.0),
 		},
 		ByEnv: []apmsampling.EnvAndConfig{{
 			Env: "agent-env",
@@ -167,6 +288,8 @@ func TestEnvPrecedence(t *testing.T) {
 				PrioritySamplerTargetTPS: pointer.Ptr(43.0),
 				ErrorsSamplerTargetTPS:   pointer.Ptr(43.0),
 				RareSamplerEnabled:       pointer.Ptr(false),
+				LatencySamplerTargetTPS:  pointer.Ptr(43.0),
+				LatencySamplerPercentile: pointer.Ptr(90.0),
 			},
 		}},
 	}
@@ -179,6 +302,9 @@ func TestEnvPrecedence(t *testing.T) {
 	prioritySampler.EXPECT().UpdateTargetTPS(float64(43)).Times(1)
 	errorsSampler.EXPECT().UpdateTargetTPS(float64(43)).Times(1)
 	rareSampler.EXPECT().SetEnabled(false).Times(1)
+	latencySampler.EXPECT().UpdateTargetTPS(float64(43)).Times(1)
+	latencySampler.EXPECT().UpdatePercentile(float64(90)).Times(1)
+	rulesSampler.EXPECT().UpdateRules(gomock.Nil()).Times(1)
 
 	h.onUpdate(map[string]state.RawConfig{"datadog/2/APM_SAMPLING/samplerconfig/config": config}, applyEmpty)
 
@@ -191,6 +317,8 @@ func TestLogLevel(t *testing.T) {
 	prioritySampler := NewMockprioritySampler(ctrl)
 	errorsSampler := NewMockerrorsSampler(ctrl)
 	rareSampler := NewMockrareSampler(ctrl)
+	latencySampler := NewMocklatencySampler(ctrl)
+	rulesSampler := NewMockrulesSampler(ctrl)
 
 	pkglog.SetupLogger(pkglog.Default(), "debug")
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -209,7 +337,7 @@ func TestLogLevel(t *testing.T) {
 			return "fakeToken"
 		},
 	}
-	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler)
+	h := New(&agentConfig, prioritySampler, rareSampler, errorsSampler, latencySampler, rulesSampler)
 
 	layer := state.RawConfig{Config: []byte(`{"name": "layer1", "config": {"log_level": "debug"}}`)}
 	configOrder := state.RawConfig{Config: []byte(`{"internal_order": ["layer1", "layer2"]}`)}
@@ -0,0 +1,171 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/sketches-go/ddsketch"
+)
+
+const (
+	latencyRateKey = "_dd.latency_sr"
+	// latencyWindow is the period over which the latency distributions are learnt.
+	// The thresholds used during a window are computed from the previous one.
+	latencyWindow = time.Minute
+	// latencyMinCount is the weighted number of traces a signature must have in a
+	// window for its threshold to be computed.
+	latencyMinCount = 50
+	// latencyMaxSignatures caps the number of latency distributions learnt.
+	latencyMaxSignatures = 1000
+	// latencyRelativeAccuracy is the relative accuracy of the latency percentiles.
+	latencyRelativeAccuracy = 0.01
+	// latencyMaxNumBins is the maximum number of bins of the latency sketches.
+	latencyMaxNumBins = 2048
+)
+
+// LatencySampler is dedicated to catching the traces which are slower than a
+// percentile of the latency of the other traces sharing the same signature,
+// made of the env and the service, name and resource of the root span. The
+// latency distributions are learnt from all the traces, then the slow traces
+// which were not kept by the other samplers are sampled within a target TPS
+// like the ErrorsSampler.
+type LatencySampler struct {
+	ScoreSampler
+	percentile *atomic.Float64
+
+	mu            sync.Mutex // guards the fields below
+	windowStart   time.Time
+	distributions map[Signature]*latencyDistribution
+}
+
+// latencyDistribution holds the latencies of the traces of a signature.
+type latencyDistribution struct {
+	// sketch holds the latencies of the current window.
+	sketch *ddsketch.DDSketch
+	// threshold is the latency percentile of the previous window, in nanoseconds.
+	// It is 0 if there were not enough traces to compute it.
+	threshold float64
+}
+
+// NewLatencySampler returns an initialized Sampler dedicated to slow traces.
+func NewLatencySampler(conf *config.AgentConfig, statsd statsd.ClientInterface) *LatencySampler {
+	s := newSampler(conf.ExtraSampleRate, conf.LatencySamplerTPS, []string{"sampler:latency"}, statsd)
+	return &LatencySampler{
+		ScoreSampler:  ScoreSampler{Sampler: s, samplingRateKey: latencyRateKey, disabled: !conf.LatencySamplerEnabled},
+		percentile:    atomic.NewFloat64(conf.LatencySamplerPercentile),
+		distributions: make(map[Signature]*latencyDistribution),
+	}
+}
+
+// Observe records the latency of the trace and tells if it is above the configured
+// percentile of its signature. It must be called for all traces, including the
+// ones kept by other samplers, so that the distributions are not biased.
+func (s *LatencySampler) Observe(now time.Time, root *pb.Span, env string) bool {
+	if s.disabled || root == nil {
+		return false
+	}
+	sig := latencySignature(root, env)
+	latency := float64(root.Duration)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate(now)
+	d, ok := s.distributions[sig]
+	if !ok {
+		if len(s.distributions) >= latencyMaxSignatures {
+			return false
+		}
+		sketch, err := ddsketch.LogCollapsingLowestDenseDDSketch(latencyRelativeAccuracy, latencyMaxNumBins)
+		if err != nil {
+			log.Errorf("Can't create the latency sketch: %v", err)
+			return false
+		}
+		d = &latencyDistribution{sketch: sketch}
+		s.distributions[sig] = d
+	}
+	if latency >= 0 {
+		_ = d.sketch.AddWithCount(latency, float64(weightRoot(root)))
+	}
+	return d.threshold > 0 && latency > d.threshold
+}
+
+// rotate starts a new window once the current one is over, computing the
+// thresholds from the latencies it holds. s.mu must be held.
+func (s *LatencySampler) rotate(now time.Time) {
+	if s.windowStart.IsZero() {
+		s.windowStart = now
+		return
+	}
+	if now.Sub(s.windowStart) < latencyWindow {
+		return
+	}
+	s.windowStart = now
+	q := s.percentile.Load() / 100
+	for sig, d := range s.distributions {
+		count := d.sketch.GetCount()
+		if count == 0 {
+			// no traffic on this signature, clean it up from the sampler
+			delete(s.distributions, sig)
+			continue
+		}
+		d.threshold = 0
+		if count >= latencyMinCount {
+			if v, err := d.sketch.GetValueAtQuantile(q); err == nil {
+				d.threshold = v
+			}
+		}
+		d.sketch.Clear()
+	}
+}
+
+// Sample counts a slow trace, as reported by Observe, and tells if it has to be kept.
+func (s *LatencySampler) Sample(now time.Time, trace pb.Trace, root *pb.Span, env string) bool {
+	if s.disabled || len(trace) == 0 {
+		return false
+	}
+	signature := s.shrink(latencySignature(root, env))
+	s.countWeightedSig(now, signature, weightRoot(root))
+	rate := s.getSignatureSampleRate(signature)
+	return s.applySampleRate(root, rate)
+}
+
+// UpdatePercentile updates the latency percentile above which traces are
+// considered slow. It applies from the next window.
+func (s *LatencySampler) UpdatePercentile(percentile float64) {
+	if percentile <= 0 || percentile >= 100 {
+		log.Errorf("Ignoring invalid latency sampler percentile %v, it must be between 0 and 100", percentile)
+		return
+	}
+	s.percentile.Store(percentile)
+}
+
+// GetPercentile returns the latency percentile above which traces are considered slow.
+func (s *LatencySampler) GetPercentile() float64 {
+	return s.percentile.Load()
+}
+
+// latencySignature returns the signature of the latency distribution of the
+// traces with the given root and env.
+func latencySignature(root *pb.Span, env string) Signature {
+	h := new32a()
+	h.Write([]byte(env))
+	h.WriteChar(',')
+	h.Write([]byte(root.Service))
+	h.WriteChar(',')
+	h.Write([]byte(root.Name))
+	h.WriteChar(',')
+	h.Write([]byte(root.Resource))
+	return Signature(h.Sum32())
+}
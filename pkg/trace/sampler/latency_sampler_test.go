@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+func getTestLatencySampler(tps float64) *LatencySampler {
+	conf := &config.AgentConfig{
+		ExtraSampleRate:          1,
+		LatencySamplerEnabled:    true,
+		LatencySamplerTPS:        tps,
+		LatencySamplerPercentile: 90,
+	}
+	return NewLatencySampler(conf, &statsd.NoOpClient{})
+}
+
+func getTestLatencyRoot(resource string, duration time.Duration) *pb.Span {
+	return &pb.Span{TraceID: randomTraceID(), SpanID: 1, Service: "mcnulty", Name: "http.request", Resource: resource, Duration: duration.Nanoseconds()}
+}
+
+// feedLatencies observes one trace per millisecond of latency from 1 to 100ms.
+func feedLatencies(s *LatencySampler, now time.Time, resource string) {
+	for i := 1; i <= 100; i++ {
+		s.Observe(now, getTestLatencyRoot(resource, time.Duration(i)*time.Millisecond), defaultEnv)
+	}
+}
+
+func TestLatencySamplerObserve(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestLatencySampler(10)
+	now := time.Now()
+
+	// no threshold is known during the first window
+	feedLatencies(s, now, "GET /")
+	assert.False(s.Observe(now, getTestLatencyRoot("GET /", time.Second), defaultEnv))
+
+	// the threshold is the 90th percentile of the previous window
+	now = now.Add(latencyWindow)
+	assert.False(s.Observe(now, getTestLatencyRoot("GET /", 80*time.Millisecond), defaultEnv))
+	assert.True(s.Observe(now, getTestLatencyRoot("GET /", 95*time.Millisecond), defaultEnv))
+	// the signatures have their own distributions
+	assert.False(s.Observe(now, getTestLatencyRoot("POST /", time.Second), defaultEnv))
+
+	// signatures without enough traces don't have a threshold
+	now = now.Add(latencyWindow)
+	assert.False(s.Observe(now, getTestLatencyRoot("GET /", time.Second), defaultEnv))
+	assert.False(s.Observe(now, getTestLatencyRoot("POST /", time.Second), defaultEnv))
+
+	// signatures without traffic are removed
+	s.Observe(now.Add(latencyWindow), getTestLatencyRoot("GET /", time.Second), defaultEnv)
+	s.Observe(now.Add(2*latencyWindow), getTestLatencyRoot("GET /", time.Second), defaultEnv)
+	assert.Len(s.distributions, 1)
+}
+
+func TestLatencySamplerPercentile(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestLatencySampler(10)
+	now := time.Now()
+
+	s.UpdatePercentile(50)
+	s.UpdatePercentile(100)
+	assert.Equal(50.0, s.GetPercentile())
+
+	feedLatencies(s, now, "GET /")
+	now = now.Add(latencyWindow)
+	assert.True(s.Observe(now, getTestLatencyRoot("GET /", 60*time.Millisecond), defaultEnv))
+}
+
+func TestLatencySamplerSample(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestLatencySampler(10)
+	now := time.Now()
+
+	root := getTestLatencyRoot("GET /", time.Second)
+	assert.True(s.Sample(now, pb.Trace{root}, root, defaultEnv))
+	assert.Equal(1.0, root.Metrics[latencyRateKey])
+
+	disabled := NewLatencySampler(&config.AgentConfig{LatencySamplerTPS: 10, LatencySamplerPercentile: 90}, &statsd.NoOpClient{})
+	feedLatencies(disabled, now, "GET /")
+	assert.False(disabled.Observe(now.Add(latencyWindow), root, defaultEnv))
+	assert.False(disabled.Sample(now, pb.Trace{root}, root, defaultEnv))
+}
@@ -45,7 +45,9 @@ type PrioritySampler struct {
 	// This struct is shared with the agent API which sends the rates in http responses to spans post requests
 	rateByService *RateByService
 	catalog       *serviceKeyCatalog
-	exit          chan struct{}
+	// rules holds the sampling rules of the agent, whose rates by service override the computed ones.
+	rules *RulesSampler
+	exit  chan struct{}
 }
 
 // NewPrioritySampler returns an initialized Sampler
@@ -60,6 +62,12 @@ func NewPrioritySampler(conf *config.AgentConfig, dynConf *DynamicConfig, statsd
 	return s
 }
 
+// SetRulesSampler sets the sampler holding the sampling rules of the agent, whose rules
+// matching on the service and env only override the rates returned to the tracers.
+func (s *PrioritySampler) SetRulesSampler(rules *RulesSampler) {
+	s.rules = rules
+}
+
 // Start runs and block on the Sampler main loop
 func (s *PrioritySampler) Start() {
 	go func() {
@@ -170,5 +178,7 @@ func (s *PrioritySampler) countSignature(now time.Time, root *pb.Span, signature
 // agents to pick the right service rate.
 func (s *PrioritySampler) ratesByService() map[ServiceSignature]float64 {
 	rates, defaultRate := s.sampler.getAllSignatureSampleRates()
-	return s.catalog.ratesByService(s.agentEnv, rates, defaultRate)
+	rbs := s.catalog.ratesByService(s.agentEnv, rates, defaultRate)
+	s.rules.applyServiceRates(rbs)
+	return rbs
 }
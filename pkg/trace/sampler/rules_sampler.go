@@ -0,0 +1,275 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+const (
+	// limitRateKey is the metric key holding the ratio of the traces the rate limiter of a
+	// sampling rule kept, among the traces it was applied to.
+	limitRateKey = "_dd.limit_psr"
+	// limiterWindow is the period over which the rate limiters of the rules count the traces.
+	limiterWindow = time.Second
+)
+
+// RulesSampler applies the trace sampling rules of the agent, which can be
+// updated through remote configuration. The first rule matching the root span
+// of a trace decides its sampling priority, replacing the decision of the tracer.
+// The rules matching on the service and env only are also reflected in the
+// rates the PrioritySampler returns to the tracers.
+type RulesSampler struct {
+	mu    sync.RWMutex // guards rules
+	rules []*samplingRule
+
+	statsd  statsd.ClientInterface
+	matched *atomic.Int64
+	kept    *atomic.Int64
+	tags    []string
+	exit    chan struct{}
+	stopped chan struct{}
+}
+
+// samplingRule holds a trace sampling rule along with its rate limiter.
+type samplingRule struct {
+	*config.TraceSamplingRule
+	limiter *ruleLimiter // nil if the rule is not rate limited
+}
+
+// ruleLimiter is the rate limiter of a sampling rule, which keeps track of the ratio of
+// the traces it allows over the current and the previous windows.
+type ruleLimiter struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	windowStart time.Time
+	seen        float64
+	allowed     float64
+	prevSeen    float64
+	prevAllowed float64
+}
+
+func newRuleLimiter(limit float64) *ruleLimiter {
+	return &ruleLimiter{limiter: rate.NewLimiter(rate.Limit(limit), int(math.Max(1, math.Ceil(limit))))}
+}
+
+// allow returns true if the trace is allowed by the limiter, along with the ratio of the
+// traces allowed over the current and the previous windows.
+func (l *ruleLimiter) allow(now time.Time) (bool, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elapsed := now.Sub(l.windowStart); elapsed >= limiterWindow {
+		if elapsed >= 2*limiterWindow {
+			// no trace was seen during the previous window
+			l.seen, l.allowed = 0, 0
+		}
+		l.prevSeen, l.prevAllowed = l.seen, l.allowed
+		l.seen, l.allowed = 0, 0
+		l.windowStart = now
+	}
+	allowed := l.limiter.AllowN(now, 1)
+	l.seen++
+	if allowed {
+		l.allowed++
+	}
+	return allowed, (l.allowed + l.prevAllowed) / (l.seen + l.prevSeen)
+}
+
+// NewRulesSampler returns a RulesSampler applying the given rules, compiled beforehand.
+func NewRulesSampler(rules []*config.TraceSamplingRule, statsd statsd.ClientInterface) *RulesSampler {
+	s := &RulesSampler{
+		statsd:  statsd,
+		matched: atomic.NewInt64(0),
+		kept:    atomic.NewInt64(0),
+		tags:    []string{"sampler:rules"},
+		exit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	s.UpdateRules(rules)
+	return s
+}
+
+// UpdateRules replaces the rules of the sampler with the given ones, compiled beforehand.
+func (s *RulesSampler) UpdateRules(rules []*config.TraceSamplingRule) {
+	samplingRules := make([]*samplingRule, 0, len(rules))
+	for _, r := range rules {
+		sr := &samplingRule{TraceSamplingRule: r}
+		if r.RateLimit > 0 {
+			sr.limiter = newRuleLimiter(r.RateLimit)
+		}
+		samplingRules = append(samplingRules, sr)
+	}
+	s.mu.Lock()
+	s.rules = samplingRules
+	s.mu.Unlock()
+}
+
+// Start starts up the routine periodically reporting the number of traces
+// the rules matched and kept.
+func (s *RulesSampler) Start() {
+	go func() {
+		defer watchdog.LogOnPanic(s.statsd)
+		statsTicker := time.NewTicker(10 * time.Second)
+		defer statsTicker.Stop()
+		for {
+			select {
+			case <-statsTicker.C:
+				s.report()
+			case <-s.exit:
+				close(s.stopped)
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the reporting routine.
+func (s *RulesSampler) Stop() {
+	close(s.exit)
+	<-s.stopped
+}
+
+func (s *RulesSampler) report() {
+	_ = s.statsd.Count("datadog.trace_agent.sampler.kept", s.kept.Swap(0), s.tags, 1)
+	_ = s.statsd.Count("datadog.trace_agent.sampler.seen", s.matched.Swap(0), s.tags, 1)
+}
+
+// Sample applies the first rule matching the root span of the chunk, setting the
+// priority of the chunk along with the rates applied on the root span. env is the
+// env of the tracer, used when the root span has no env tag. It returns false if
+// no rule matched, in which case the chunk is left untouched.
+//
+// The tracers may drop the traces exceeding the agent rate they applied, the rate of the
+// rule is then bounded by this rate: the traces are sampled by the same hash of their ID,
+// so the kept traces are the ones below both rates.
+func (s *RulesSampler) Sample(chunk *pb.TraceChunk, root *pb.Span, env string) bool {
+	s.mu.RLock()
+	rules := s.rules
+	s.mu.RUnlock()
+	for _, r := range rules {
+		if !r.matches(root, env) {
+			continue
+		}
+		s.matched.Inc()
+		sampleRate := r.SampleRate
+		if agentRate, ok := getMetric(root, agentRateKey); ok {
+			sampleRate = math.Min(sampleRate, agentRate)
+			// the rule rate, bounded by the agent rate, replaces it
+			delete(root.Metrics, agentRateKey)
+		}
+		keep := SampleByRate(root.TraceID, sampleRate)
+		setMetric(root, ruleRateKey, sampleRate)
+		if keep && r.limiter != nil {
+			var limitRate float64
+			keep, limitRate = r.limiter.allow(time.Now())
+			setMetric(root, limitRateKey, limitRate)
+		}
+		if keep {
+			s.kept.Inc()
+			chunk.Priority = int32(PriorityAutoKeep)
+		} else {
+			chunk.Priority = int32(PriorityAutoDrop)
+		}
+		return true
+	}
+	return false
+}
+
+// matches returns true if the root span matches all the patterns of the rule.
+func (r *samplingRule) matches(root *pb.Span, env string) bool {
+	if r.ServiceRe != nil && !r.ServiceRe.MatchString(root.Service) {
+		return false
+	}
+	if r.NameRe != nil && !r.NameRe.MatchString(root.Name) {
+		return false
+	}
+	if r.ResourceRe != nil && !r.ResourceRe.MatchString(root.Resource) {
+		return false
+	}
+	for k, re := range r.TagsRe {
+		v, ok := root.Meta[k]
+		if !ok {
+			if f, isMetric := root.Metrics[k]; isMetric {
+				v, ok = strconv.FormatFloat(f, 'f', -1, 64), true
+			} else if k == "env" && env != "" {
+				v, ok = env, true
+			}
+		}
+		if !ok || re != nil && !re.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceLevel returns true if the rule only matches on the service and env,
+// in which case it can be applied by the tracers through the rates by service.
+func (r *samplingRule) serviceLevel() bool {
+	if r.NameRe != nil || r.ResourceRe != nil || r.RateLimit > 0 {
+		return false
+	}
+	for k := range r.TagsRe {
+		if k != "env" {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesService returns true if the rule may match the root spans of the
+// service and env, the patterns on other attributes being unknown.
+func (r *samplingRule) matchesService(service, env string) bool {
+	if r.ServiceRe != nil && !r.ServiceRe.MatchString(service) {
+		return false
+	}
+	if re, ok := r.TagsRe["env"]; ok {
+		if env == "" || re != nil && !re.MatchString(env) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyServiceRates replaces the rates by service with the sample rates of the
+// rules matching on the service and env only, when they are the first rule
+// which may match the service and env. When this first rule also matches on
+// other attributes, it is applied by the agent and the rate is left as is, so
+// that the tracers don't drop the traces it would keep.
+func (s *RulesSampler) applyServiceRates(rates map[ServiceSignature]float64) {
+	if s == nil {
+		return
+	}
+	s.mu.RLock()
+	rules := s.rules
+	s.mu.RUnlock()
+	if len(rules) == 0 {
+		return
+	}
+	for sig := range rates {
+		for _, r := range rules {
+			if !r.matchesService(sig.Name, sig.Env) {
+				continue
+			}
+			if r.serviceLevel() {
+				rates[sig] = r.SampleRate
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+func getTestRulesSampler(t *testing.T, rules ...*config.TraceSamplingRule) *RulesSampler {
+	for _, r := range rules {
+		require.NoError(t, r.Compile())
+	}
+	return NewRulesSampler(rules, &statsd.NoOpClient{})
+}
+
+func getTestRulesChunk(service, name, resource string, meta map[string]string) (*pb.TraceChunk, *pb.Span) {
+	root := &pb.Span{TraceID: randomTraceID(), SpanID: 1, Service: service, Name: name, Resource: resource, Meta: meta, Metrics: map[string]float64{agentRateKey: 1}}
+	return &pb.TraceChunk{Priority: int32(PriorityAutoKeep), Spans: []*pb.Span{root}}, root
+}
+
+func TestRulesSamplerSample(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestRulesSampler(t,
+		&config.TraceSamplingRule{Service: "web-*", Resource: "GET /health*", SampleRate: 0},
+		&config.TraceSamplingRule{Name: "db.*", Tags: map[string]string{"env": "prod", "db.system": "postgres*"}, SampleRate: 1},
+		&config.TraceSamplingRule{Service: "web-*", Tags: map[string]string{"http.status_code": "5??"}, SampleRate: 1},
+	)
+
+	chunk, root := getTestRulesChunk("web-store", "http.request", "GET /healthz", nil)
+	assert.True(s.Sample(chunk, root, defaultEnv))
+	assert.Equal(int32(PriorityAutoDrop), chunk.Priority)
+	assert.Equal(0.0, root.Metrics[ruleRateKey])
+	assert.NotContains(root.Metrics, agentRateKey)
+
+	// the env of the tracer is used when the root span has no env tag
+	chunk, root = getTestRulesChunk("api", "db.query", "SELECT", map[string]string{"db.system": "postgresql"})
+	chunk.Priority = int32(PriorityAutoDrop)
+	assert.True(s.Sample(chunk, root, "prod"))
+	assert.Equal(int32(PriorityAutoKeep), chunk.Priority)
+	assert.Equal(1.0, root.Metrics[ruleRateKey])
+	chunk, root = getTestRulesChunk("api", "db.query", "SELECT", map[string]string{"db.system": "postgresql", "env": "staging"})
+	assert.False(s.Sample(chunk, root, "prod"))
+
+	// the metrics of the root span match the tags
+	chunk, root = getTestRulesChunk("web-store", "http.request", "GET /users", nil)
+	root.Metrics["http.status_code"] = 503
+	chunk.Priority = int32(PriorityAutoDrop)
+	assert.True(s.Sample(chunk, root, defaultEnv))
+	assert.Equal(int32(PriorityAutoKeep), chunk.Priority)
+
+	// the chunks not matching any rule are left untouched
+	chunk, root = getTestRulesChunk("web-store", "http.request", "GET /users", map[string]string{"http.status_code": "200"})
+	assert.False(s.Sample(chunk, root, defaultEnv))
+	assert.Equal(int32(PriorityAutoKeep), chunk.Priority)
+	assert.Equal(1.0, root.Metrics[agentRateKey])
+	assert.NotContains(root.Metrics, ruleRateKey)
+
+	// the rule rate is bounded by the agent rate applied by the tracer
+	chunk, root = getTestRulesChunk("web-store", "http.request", "GET /users", nil)
+	root.Metrics[agentRateKey] = 0.5
+	root.Metrics["http.status_code"] = 500
+	for SampleByRate(root.TraceID, 0.5) {
+		root.TraceID = randomTraceID()
+	}
+	assert.True(s.Sample(chunk, root, defaultEnv))
+	assert.Equal(int32(PriorityAutoDrop), chunk.Priority)
+	assert.Equal(0.5, root.Metrics[ruleRateKey])
+	assert.NotContains(root.Metrics, agentRateKey)
+
+	// the rules can be replaced
+	s.UpdateRules(nil)
+	chunk, root = getTestRulesChunk("web-store", "http.request", "GET /healthz", nil)
+	assert.False(s.Sample(chunk, root, defaultEnv))
+}
+
+func TestRulesSamplerRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestRulesSampler(t, &config.TraceSamplingRule{Service: "web", SampleRate: 1, RateLimit: 2})
+
+	var kept int
+	var limitRate float64
+	for i := 0; i < 10; i++ {
+		chunk, root := getTestRulesChunk("web", "http.request", "GET /", nil)
+		assert.True(s.Sample(chunk, root, defaultEnv))
+		limitRate = root.Metrics[limitRateKey]
+		assert.True(limitRate > 0 && limitRate <= 1, limitRate)
+		if chunk.Priority == int32(PriorityAutoKeep) {
+			kept++
+		}
+	}
+	assert.Equal(2, kept)
+	assert.Equal(0.2, limitRate)
+}
+
+func TestRuleLimiter(t *testing.T) {
+	assert := assert.New(t)
+	l := newRuleLimiter(2)
+	now := time.Now()
+
+	for i, want := range []struct {
+		allowed bool
+		rate    float64
+	}{{true, 1}, {true, 1}, {false, 2.0 / 3}, {false, 0.5}} {
+		allowed, rate := l.allow(now)
+		assert.Equal(want.allowed, allowed, i)
+		assert.Equal(want.rate, rate, i)
+	}
+
+	// the ratio covers the previous window
+	allowed, rate := l.allow(now.Add(limiterWindow))
+	assert.True(allowed)
+	assert.Equal(3.0/5, rate)
+
+	// the windows without any trace are not counted
+	allowed, rate = l.allow(now.Add(5 * limiterWindow))
+	assert.True(allowed)
+	assert.Equal(1.0, rate)
+}
+
+func TestRulesSamplerServiceRates(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestRulesSampler(t,
+		&config.TraceSamplingRule{Service: "web", Tags: map[string]string{"env": "prod"}, SampleRate: 0.1},
+		&config.TraceSamplingRule{Service: "api-*", SampleRate: 0.2},
+		// may match the root spans of any service, the rules below are applied by the agent
+		&config.TraceSamplingRule{Resource: "/checkout", SampleRate: 1},
+		&config.TraceSamplingRule{Service: "web", SampleRate: 0.01},
+		&config.TraceSamplingRule{Service: "db", SampleRate: 1, RateLimit: 10},
+	)
+	rates := map[ServiceSignature]float64{
+		{}:                            0.5,
+		{Name: "web", Env: "prod"}:    0.5,
+		{Name: "web", Env: "staging"}: 0.5,
+		{Name: "web"}:                 0.5,
+		{Name: "api-users", Env: ""}:  0.5,
+		{Name: "db", Env: "prod"}:     0.5,
+	}
+	s.applyServiceRates(rates)
+	assert.Equal(map[ServiceSignature]float64{
+		{}:                            0.5,
+		{Name: "web", Env: "prod"}:    0.1,
+		{Name: "web", Env: "staging"}: 0.5,
+		{Name: "web"}:                 0.5,
+		{Name: "api-users", Env: ""}:  0.2,
+		{Name: "db", Env: "prod"}:     0.5,
+	}, rates)
+
+	// the rates returned to the tracers reflect the rules
+	ps := getTestPrioritySampler()
+	ps.SetRulesSampler(s)
+	sig := ps.catalog.register(ServiceSignature{Name: "web", Env: "prod"})
+	ps.sampler.rates = map[Signature]float64{sig: 0.5}
+	assert.Equal(0.1, ps.ratesByService()[ServiceSignature{Name: "web", Env: "prod"}])
+}
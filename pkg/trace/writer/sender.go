@@ -30,8 +30,9 @@ import (
 )
 
 // newSenders returns a list of senders based on the given agent configuration, using climit
-// as the maximum number of concurrent outgoing connections, writing to path.
-func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, qsize int, telemetryCollector telemetry.TelemetryCollector, statsd statsd.ClientInterface) []*sender {
+// as the maximum number of concurrent outgoing connections, writing to path. When spill is
+// not nil, the payloads which can't be sent are stored in it as the given kind.
+func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, qsize int, spill *spillStore, kind spillKind, telemetryCollector telemetry.TelemetryCollector, statsd statsd.ClientInterface) []*sender {
 	if e := cfg.Endpoints; len(e) == 0 || e[0].Host == "" || e[0].APIKey == "" {
 		panic(errors.New("config was not properly validated"))
 	}
@@ -45,6 +46,12 @@ func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, q
 			log.Criticalf("Invalid host endpoint: %q", endpoint.Host)
 			os.Exit(1)
 		}
+		var queue *spillQueue
+		if spill != nil {
+			if queue, err = spill.queue(kind, url.Host, endpoint.APIKey); err != nil {
+				log.Errorf("Can't open the payload spill queue of %s, its %s payloads will not be spilled to disk: %v", url.Host, kind, err)
+			}
+		}
 		senders[i] = newSender(&senderConfig{
 			client:     cfg.NewHTTPClient(),
 			maxConns:   int(maxConns),
@@ -53,6 +60,7 @@ func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, q
 			url:        url,
 			apiKey:     endpoint.APIKey,
 			recorder:   r,
+			spill:      queue,
 			userAgent:  fmt.Sprintf("Datadog Trace Agent/%s/%s", cfg.AgentVersion, cfg.GitCommit),
 		}, statsd)
 	}
@@ -80,6 +88,9 @@ const (
 	// eventTypeDropped specifies that a payload had to be dropped to make room
 	// in the queue.
 	eventTypeDropped
+	// eventTypeSpilled specifies that a payload which could not be sent was
+	// stored on disk, to be replayed later.
+	eventTypeSpilled
 )
 
 var eventTypeStrings = map[eventType]string{
@@ -87,6 +98,7 @@ var eventTypeStrings = map[eventType]string{
 	eventTypeSent:     "eventTypeSent",
 	eventTypeRejected: "eventTypeRejected",
 	eventTypeDropped:  "eventTypeDropped",
+	eventTypeSpilled:  "eventTypeSpilled",
 }
 
 // String implements fmt.Stringer.
@@ -134,6 +146,9 @@ type senderConfig struct {
 	// recorder specifies the eventRecorder to use when reporting events occurring
 	// in the sender.
 	recorder eventRecorder
+	// spill specifies the on-disk queue storing the payloads which could not be
+	// sent, instead of dropping them. It is nil when spilling is disabled.
+	spill *spillQueue
 	// userAgent is the computed user agent we'll use when communicating with Datadog
 	userAgent string
 }
@@ -150,8 +165,15 @@ type sender struct {
 	mu     sync.RWMutex // guards closed
 	closed bool         // closed reports if the loop is stopped
 	statsd statsd.ClientInterface
+
+	replay     chan struct{} // triggers a replay of the spilled payloads
+	stopReplay chan struct{} // stops the replay loop
 }
 
+// spillReplayInterval specifies how often the spilled payloads are replayed
+// when no payload was sent successfully in the meantime.
+var spillReplayInterval = 10 * time.Second
+
 // newSender returns a new sender based on the given config cfg.
 func newSender(cfg *senderConfig, statsd statsd.ClientInterface) *sender {
 	s := sender{
@@ -160,10 +182,15 @@ func newSender(cfg *senderConfig, statsd statsd.ClientInterface) *sender {
 		inflight:   atomic.NewInt32(0),
 		maxRetries: int32(cfg.maxRetries),
 		statsd:     statsd,
+		replay:     make(chan struct{}, 1),
+		stopReplay: make(chan struct{}),
 	}
 	for i := 0; i < cfg.maxConns; i++ {
 		go s.loop()
 	}
+	if cfg.spill != nil {
+		go s.replayLoop()
+	}
 	return &s
 }
 
@@ -191,6 +218,7 @@ func (s *sender) Stop() {
 	s.closed = true
 	s.mu.Unlock()
 	close(s.queue)
+	close(s.stopReplay)
 }
 
 // WaitForInflight blocks until all in progress payloads are sent,
@@ -264,8 +292,8 @@ func (s *sender) sendOnce(p *payload) bool {
 		s.mu.RLock()
 		defer s.mu.RUnlock()
 		if s.closed {
-			s.releasePayload(p, eventTypeDropped, stats)
 			// sender is stopped
+			s.spillOrDrop(p, stats)
 			return true
 		}
 
@@ -276,15 +304,19 @@ func (s *sender) sendOnce(p *payload) bool {
 			log.Warnf("Retried payload %d times: %s", r, err.Error())
 		}
 		if p.retries.Load() >= s.maxRetries {
-			log.Warnf("Dropping Payload after %d retries, due to: %v.\n", p.retries.Load(), err)
+			if s.cfg.spill == nil {
+				log.Warnf("Dropping Payload after %d retries, due to: %v.\n", p.retries.Load(), err)
+			}
 			// queue is full; since this is the oldest payload, we drop it
-			s.releasePayload(p, eventTypeDropped, stats)
+			// or store it on disk
+			s.spillOrDrop(p, stats)
 			return true
 		}
 		s.recordEvent(eventTypeRetry, stats)
 		return false
 	case nil:
 		s.releasePayload(p, eventTypeSent, stats)
+		s.triggerReplay()
 	default:
 		// this is a fatal error, we have to drop this payload
 		log.Warnf("Dropping Payload due to non-retryable error: %v.\n", err)
@@ -293,6 +325,96 @@ func (s *sender) sendOnce(p *payload) bool {
 	return true
 }
 
+// spillOrDrop stores the payload p on disk when spilling is enabled, or drops it.
+func (s *sender) spillOrDrop(p *payload, stats *eventData) {
+	if s.cfg.spill != nil {
+		err := s.cfg.spill.push(p)
+		if err == nil {
+			s.releasePayload(p, eventTypeSpilled, stats)
+			return
+		}
+		log.Warnf("Dropping Payload which could not be spilled to disk: %v", err)
+	}
+	s.releasePayload(p, eventTypeDropped, stats)
+}
+
+// triggerReplay notifies the replay loop that the intake is reachable.
+func (s *sender) triggerReplay() {
+	if s.cfg.spill == nil {
+		return
+	}
+	select {
+	case s.replay <- struct{}{}:
+	default:
+	}
+}
+
+// replayLoop replays the spilled payloads periodically, and whenever a payload
+// is sent successfully.
+func (s *sender) replayLoop() {
+	tick := time.NewTicker(spillReplayInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		case <-tick.C:
+		case <-s.replay:
+		}
+		s.replaySpilled()
+	}
+}
+
+// replaySpilled sends the spilled payloads in the order they were stored, until
+// the queue is empty or the intake fails with a retriable error.
+func (s *sender) replaySpilled() {
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		default:
+		}
+		p, name, err := s.cfg.spill.peek()
+		if err != nil {
+			log.Warnf("Dropping unreadable spilled payload: %v", err)
+			s.cfg.spill.remove(name)
+			continue
+		}
+		if p == nil {
+			return
+		}
+		req, err := p.httpRequest(s.cfg.url)
+		if err != nil {
+			log.Errorf("http.Request: %s", err)
+			s.cfg.spill.remove(name)
+			ppool.Put(p)
+			continue
+		}
+		start := time.Now()
+		err = s.do(req)
+		stats := &eventData{
+			bytes:    p.body.Len(),
+			count:    1,
+			duration: time.Since(start),
+			err:      err,
+		}
+		ppool.Put(p)
+		switch err.(type) {
+		case *retriableError:
+			// keep the payload for the next replay
+			log.Tracef("Error replaying spilled payload: %v\n", err)
+			return
+		case nil:
+			s.cfg.spill.remove(name)
+			s.recordEvent(eventTypeSent, stats)
+		default:
+			log.Warnf("Dropping spilled Payload due to non-retryable error: %v.\n", err)
+			s.cfg.spill.remove(name)
+			s.recordEvent(eventTypeRejected, stats)
+		}
+	}
+}
+
 // waitForSenders blocks until all senders have sent their inflight payloads
 func waitForSenders(senders []*sender) {
 	var wg sync.WaitGroup
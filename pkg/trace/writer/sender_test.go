@@ -230,6 +230,67 @@ func TestSender(t *testing.T) {
 			assert.True(time.Since(start)-failed[i].duration < time.Second)
 		}
 	})
+
+	t.Run("spill", func(t *testing.T) {
+		assert := assert.New(t)
+		defer useBackoffDuration(0)()
+		var (
+			mu       sync.Mutex
+			down     = true
+			received []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			if down {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			received = append(received, string(body))
+		}))
+		defer server.Close()
+
+		var recorder mockRecorder
+		cfg := testSenderConfig(server.URL)
+		cfg.recorder = &recorder
+		cfg.maxConns = 1
+		cfg.maxRetries = 1
+		store := &spillStore{dir: t.TempDir(), maxSize: 1024 * 1024, maxAge: time.Hour}
+		queue, err := store.queue(spillKindTraces, cfg.url.Host, testAPIKey)
+		assert.NoError(err)
+		cfg.spill = queue
+		s := newSender(cfg, statsd)
+
+		for _, body := range []string{"1", "2", "3"} {
+			p := newPayload(map[string]string{"Content-Type": "text/plain"})
+			p.body.WriteString(body)
+			s.Push(p)
+		}
+		s.WaitForInflight()
+		assert.Len(recorder.data(eventTypeSpilled), 3)
+		assert.Empty(recorder.data(eventTypeDropped))
+		payloads, _ := store.depth(spillKindTraces)
+		assert.EqualValues(3, payloads)
+
+		// the intake recovers, the first payload sent triggers the replay
+		mu.Lock()
+		down = false
+		mu.Unlock()
+		p := newPayload(nil)
+		p.body.WriteString("4")
+		s.Push(p)
+		assert.Eventually(func() bool {
+			payloads, _ := store.depth(spillKindTraces)
+			return payloads == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		s.Stop()
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal([]string{"4", "1", "2", "3"}, received)
+		assert.Len(recorder.data(eventTypeSent), 4)
+	})
 }
 
 func TestPayload(t *testing.T) {
@@ -284,8 +345,8 @@ func useBackoffDuration(d time.Duration) func() {
 
 // mockRecorder is a mock eventRecorder which records all calls to recordEvent.
 type mockRecorder struct {
-	mu                             sync.RWMutex
-	retry, sent, dropped, rejected []*eventData
+	mu                                      sync.RWMutex
+	retry, sent, dropped, rejected, spilled []*eventData
 }
 
 // data returns all call data for the given eventType.
@@ -301,6 +362,8 @@ func (r *mockRecorder) data(t eventType) []*eventData {
 		return r.dropped
 	case eventTypeRejected:
 		return r.rejected
+	case eventTypeSpilled:
+		return r.spilled
 	default:
 		panic("unknown event")
 	}
@@ -319,5 +382,7 @@ func (r *mockRecorder) recordEvent(t eventType, data *eventData) {
 		r.dropped = append(r.dropped, data)
 	case eventTypeRejected:
 		r.rejected = append(r.rejected, data)
+	case eventTypeSpilled:
+		r.spilled = append(r.spilled, data)
 	}
 }
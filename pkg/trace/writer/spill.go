@@ -0,0 +1,372 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+)
+
+const spillExtension = ".payload"
+
+// errSpillFull is returned when a payload does not fit in the spill directory,
+// even after evicting the payloads it has priority over.
+var errSpillFull = errors.New("the payload spill directory is full")
+
+// spillKind specifies the kind of payloads stored in a spill queue.
+type spillKind int
+
+const (
+	// spillKindTraces specifies the payloads of the trace writer.
+	spillKindTraces spillKind = iota
+	// spillKindStats specifies the payloads of the stats writer. They have
+	// priority over the traces when the spill directory is full.
+	spillKindStats
+)
+
+// String implements fmt.Stringer.
+func (k spillKind) String() string {
+	if k == spillKindStats {
+		return "stats"
+	}
+	return "traces"
+}
+
+// spillHeader holds the fields of a payload stored along with its body.
+type spillHeader struct {
+	Headers map[string]string `json:"headers"`
+}
+
+// spillFile is a payload stored in a spill queue.
+type spillFile struct {
+	name    string
+	size    int64
+	created time.Time
+}
+
+// spillStore is the on-disk storage of the payloads which could not be sent to
+// the intake. It is shared by the trace and stats writers so that the size
+// limit applies to both, the stats payloads evicting the trace payloads first
+// when it is reached.
+type spillStore struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu     sync.Mutex // guards the fields below and the queues
+	size   int64
+	queues []*spillQueue
+}
+
+var (
+	spillStoresMu sync.Mutex
+	spillStores   = make(map[string]*spillStore) // by directory
+)
+
+// getSpillStore returns the spill store configured in cfg, or nil if spilling
+// is disabled. Writers configured with the same directory share the same store.
+func getSpillStore(cfg *config.AgentConfig) *spillStore {
+	if !cfg.PayloadSpill.Enabled || cfg.PayloadSpill.Dir == "" {
+		return nil
+	}
+	spillStoresMu.Lock()
+	defer spillStoresMu.Unlock()
+	if s, ok := spillStores[cfg.PayloadSpill.Dir]; ok {
+		return s
+	}
+	if err := os.MkdirAll(cfg.PayloadSpill.Dir, 0700); err != nil {
+		log.Errorf("Can't create the payload spill directory, payloads will not be spilled to disk: %v", err)
+		return nil
+	}
+	s := &spillStore{
+		dir:     cfg.PayloadSpill.Dir,
+		maxSize: cfg.PayloadSpill.MaxSize,
+		maxAge:  cfg.PayloadSpill.MaxAge,
+	}
+	spillStores[s.dir] = s
+	return s
+}
+
+// queue returns the spill queue of the payloads of the given kind sent to an
+// endpoint, reloading the payloads stored by a previous run.
+func (s *spillStore) queue(kind spillKind, host, apiKey string) (*spillQueue, error) {
+	// the API key is hashed to tell apart the endpoints sharing the same host
+	h := fnv.New32a()
+	h.Write([]byte(apiKey))
+	path := filepath.Join(s.dir, kind.String(), fmt.Sprintf("%s-%08x", sanitizeSpillHost(host), h.Sum32()))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.queues {
+		if q.path == path {
+			return q, nil
+		}
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	q := &spillQueue{store: s, kind: kind, path: path}
+	if err := q.reload(); err != nil {
+		return nil, err
+	}
+	s.queues = append(s.queues, q)
+	return q, nil
+}
+
+// sanitizeSpillHost returns host with the characters which are not safe in a
+// file name replaced.
+func sanitizeSpillHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// depth returns the number of payloads of the given kind and their size.
+func (s *spillStore) depth(kind spillKind) (payloads, size int64) {
+	if s == nil {
+		return 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.queues {
+		if q.kind != kind {
+			continue
+		}
+		payloads += int64(len(q.files))
+		for _, f := range q.files {
+			size += f.size
+		}
+	}
+	return payloads, size
+}
+
+// expire removes the payloads older than the maximum age. s.mu must be held.
+func (s *spillStore) expire(now time.Time) {
+	if s.maxAge <= 0 {
+		return
+	}
+	for _, q := range s.queues {
+		n := 0
+		for len(q.files) > 0 && now.Sub(q.files[0].created) > s.maxAge {
+			q.removeOldest()
+			n++
+		}
+		if n > 0 {
+			log.Warnf("Dropped %d %s payloads older than %s from the payload spill directory", n, q.kind, s.maxAge)
+		}
+	}
+}
+
+// evictFor removes the oldest payload which a payload of the given kind has
+// priority over, returning false if there is none. s.mu must be held.
+func (s *spillStore) evictFor(kind spillKind) bool {
+	victim := s.oldest(spillKindTraces)
+	if victim == nil && kind == spillKindStats {
+		victim = s.oldest(spillKindStats)
+	}
+	if victim == nil {
+		return false
+	}
+	log.Debugf("Payload spill directory full, dropping the oldest %s payload", victim.kind)
+	victim.removeOldest()
+	return true
+}
+
+// oldest returns the queue of the given kind holding the oldest payload, or nil
+// if they are all empty. s.mu must be held.
+func (s *spillStore) oldest(kind spillKind) *spillQueue {
+	var oldest *spillQueue
+	for _, q := range s.queues {
+		if q.kind != kind || len(q.files) == 0 {
+			continue
+		}
+		if oldest == nil || q.files[0].created.Before(oldest.files[0].created) {
+			oldest = q
+		}
+	}
+	return oldest
+}
+
+// spillQueue is a FIFO queue of the payloads of a sender stored on disk, one
+// file per payload. Files are named after a sequence number so that the
+// payloads are replayed in the order they were stored, including after a restart.
+type spillQueue struct {
+	store  *spillStore
+	kind   spillKind
+	path   string
+	files  []spillFile // guarded by store.mu
+	nextID uint64      // guarded by store.mu
+}
+
+func (q *spillQueue) reload() error {
+	entries, err := os.ReadDir(q.path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if strings.HasSuffix(name, ".tmp") {
+			// a payload which was being stored when the agent stopped
+			os.Remove(filepath.Join(q.path, name))
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, spillExtension), 10, 64)
+		if err != nil || filepath.Ext(name) != spillExtension {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			log.Warnf("Can't get the size of the spilled payload %s: %v", name, err)
+			continue
+		}
+		q.files = append(q.files, spillFile{name: name, size: info.Size(), created: info.ModTime()})
+		q.store.size += info.Size()
+		if id >= q.nextID {
+			q.nextID = id + 1
+		}
+	}
+	// the names are zero-padded, the lexical order is the sequence order
+	sort.Slice(q.files, func(i, j int) bool { return q.files[i].name < q.files[j].name })
+	if len(q.files) > 0 {
+		log.Infof("Reloaded %d spilled %s payloads from %s", len(q.files), q.kind, q.path)
+	}
+	return nil
+}
+
+// push stores the payload p at the end of the queue, evicting older payloads
+// if needed. It returns errSpillFull if the payload does not fit.
+func (q *spillQueue) push(p *payload) (err error) {
+	header, err := json.Marshal(spillHeader{Headers: p.headers})
+	if err != nil {
+		return err
+	}
+	size := int64(len(header) + 1 + p.body.Len())
+
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	q.store.expire(time.Now())
+	if size > q.store.maxSize {
+		return errSpillFull
+	}
+	for q.store.size+size > q.store.maxSize {
+		if !q.store.evictFor(q.kind) {
+			return errSpillFull
+		}
+	}
+
+	name := fmt.Sprintf("%020d%s", q.nextID, spillExtension)
+	f, err := os.CreateTemp(q.path, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmpName)
+		}
+	}()
+	if _, err = f.Write(append(header, '\n')); err != nil {
+		return err
+	}
+	if _, err = f.Write(p.body.Bytes()); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, filepath.Join(q.path, name)); err != nil {
+		return err
+	}
+
+	q.nextID++
+	q.store.size += size
+	q.files = append(q.files, spillFile{name: name, size: size, created: time.Now()})
+	return nil
+}
+
+// peek returns the oldest payload of the queue along with its name, without
+// removing it. The returned payload is nil if the queue is empty. The name is
+// also returned along with the error of an unreadable payload, so that it can
+// be removed.
+func (q *spillQueue) peek() (*payload, string, error) {
+	q.store.mu.Lock()
+	q.store.expire(time.Now())
+	if len(q.files) == 0 {
+		q.store.mu.Unlock()
+		return nil, "", nil
+	}
+	name := q.files[0].name
+	q.store.mu.Unlock()
+
+	content, err := os.ReadFile(filepath.Join(q.path, name))
+	if err != nil {
+		return nil, name, err
+	}
+	i := bytes.IndexByte(content, '\n')
+	if i < 0 {
+		return nil, name, fmt.Errorf("invalid spilled payload %s", name)
+	}
+	var header spillHeader
+	if err := json.Unmarshal(content[:i], &header); err != nil {
+		return nil, name, fmt.Errorf("invalid spilled payload %s: %v", name, err)
+	}
+	p := newPayload(header.Headers)
+	p.body.Write(content[i+1:])
+	return p, name, nil
+}
+
+// remove removes the payload with the given name, if it was not evicted
+// or expired in the meantime.
+func (q *spillQueue) remove(name string) {
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	for i, f := range q.files {
+		if f.name == name {
+			q.files = append(q.files[:i], q.files[i+1:]...)
+			q.store.size -= f.size
+			q.removeFile(f.name)
+			return
+		}
+	}
+}
+
+// removeOldest removes the oldest payload of the queue. q.store.mu must be held.
+func (q *spillQueue) removeOldest() {
+	if len(q.files) == 0 {
+		return
+	}
+	f := q.files[0]
+	q.files = q.files[1:]
+	q.store.size -= f.size
+	q.removeFile(f.name)
+}
+
+func (q *spillQueue) removeFile(name string) {
+	path := filepath.Join(q.path, name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Can't remove the spilled payload %s: %v", path, err)
+	}
+}
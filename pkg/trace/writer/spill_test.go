@@ -0,0 +1,152 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+)
+
+// spillTestPayload returns a payload taking 100 bytes once spilled.
+func spillTestPayload(c byte) *payload {
+	p := newPayload(nil)
+	// the header takes len(`{"headers":null}`)+1 bytes
+	p.body.WriteString(strings.Repeat(string(c), 100-17))
+	return p
+}
+
+func peekBody(t *testing.T, q *spillQueue) (string, string) {
+	p, name, err := q.peek()
+	require.NoError(t, err)
+	if p == nil {
+		return "", ""
+	}
+	return p.body.String()[:1], name
+}
+
+func TestGetSpillStore(t *testing.T) {
+	cfg := config.New()
+	assert.Nil(t, getSpillStore(cfg))
+
+	cfg.PayloadSpill.Enabled = true
+	cfg.PayloadSpill.Dir = t.TempDir()
+	s := getSpillStore(cfg)
+	require.NotNil(t, s)
+	assert.Same(t, s, getSpillStore(cfg))
+}
+
+func TestSpillQueue(t *testing.T) {
+	dir := t.TempDir()
+	store := &spillStore{dir: dir, maxSize: 1000, maxAge: time.Hour}
+	q, err := store.queue(spillKindTraces, "trace.agent.datadoghq.com:443", "key")
+	require.NoError(t, err)
+
+	p := newPayload(map[string]string{"Content-Type": "application/msgpack"})
+	p.body.WriteString("a")
+	require.NoError(t, q.push(p))
+	require.NoError(t, q.push(spillTestPayload('b')))
+	require.NoError(t, q.push(spillTestPayload('c')))
+
+	p, name, err := q.peek()
+	require.NoError(t, err)
+	assert.Equal(t, "a", p.body.String())
+	assert.Equal(t, map[string]string{"Content-Type": "application/msgpack"}, p.headers)
+	q.remove(name)
+	// removing a payload twice is a no-op
+	q.remove(name)
+	payloads, size := store.depth(spillKindTraces)
+	assert.EqualValues(t, 2, payloads)
+	assert.EqualValues(t, 200, size)
+
+	// a payload being written when the agent stopped is discarded on reload
+	require.NoError(t, os.WriteFile(filepath.Join(q.path, "00000000000000000003.payload.123.tmp"), []byte("x"), 0600))
+	store = &spillStore{dir: dir, maxSize: 1000, maxAge: time.Hour}
+	q, err = store.queue(spillKindTraces, "trace.agent.datadoghq.com:443", "key")
+	require.NoError(t, err)
+	assert.EqualValues(t, 200, store.size)
+	require.NoError(t, q.push(spillTestPayload('d')))
+
+	var bodies []string
+	for {
+		body, name := peekBody(t, q)
+		if name == "" {
+			break
+		}
+		bodies = append(bodies, body)
+		q.remove(name)
+	}
+	assert.Equal(t, []string{"b", "c", "d"}, bodies)
+	assert.EqualValues(t, 0, store.size)
+
+	entries, err := os.ReadDir(q.path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpillStorePriority(t *testing.T) {
+	store := &spillStore{dir: t.TempDir(), maxSize: 300, maxAge: time.Hour}
+	traces, err := store.queue(spillKindTraces, "trace.agent.datadoghq.com", "key")
+	require.NoError(t, err)
+	stats, err := store.queue(spillKindStats, "trace.agent.datadoghq.com", "key")
+	require.NoError(t, err)
+
+	require.NoError(t, traces.push(spillTestPayload('a')))
+	require.NoError(t, traces.push(spillTestPayload('b')))
+	require.NoError(t, stats.push(spillTestPayload('1')))
+
+	// the traces evict the oldest traces
+	require.NoError(t, traces.push(spillTestPayload('c')))
+	body, _ := peekBody(t, traces)
+	assert.Equal(t, "b", body)
+
+	// the stats evict the traces first
+	require.NoError(t, stats.push(spillTestPayload('2')))
+	require.NoError(t, stats.push(spillTestPayload('3')))
+	payloads, _ := store.depth(spillKindTraces)
+	assert.EqualValues(t, 0, payloads)
+
+	// the traces can't evict the stats
+	assert.Equal(t, errSpillFull, traces.push(spillTestPayload('d')))
+
+	// the stats evict the oldest stats once there are no traces left
+	require.NoError(t, stats.push(spillTestPayload('4')))
+	body, _ = peekBody(t, stats)
+	assert.Equal(t, "2", body)
+	payloads, size := store.depth(spillKindStats)
+	assert.EqualValues(t, 3, payloads)
+	assert.EqualValues(t, 300, size)
+
+	// a payload larger than the store is never spilled
+	p := newPayload(nil)
+	p.body.WriteString(strings.Repeat("x", 300))
+	assert.Equal(t, errSpillFull, stats.push(p))
+}
+
+func TestSpillStoreExpire(t *testing.T) {
+	store := &spillStore{dir: t.TempDir(), maxSize: 1000, maxAge: time.Minute}
+	q, err := store.queue(spillKindStats, "trace.agent.datadoghq.com", "key")
+	require.NoError(t, err)
+
+	require.NoError(t, q.push(spillTestPayload('a')))
+	require.NoError(t, q.push(spillTestPayload('b')))
+	store.mu.Lock()
+	q.files[0].created = time.Now().Add(-2 * time.Minute)
+	store.mu.Unlock()
+
+	body, _ := peekBody(t, q)
+	assert.Equal(t, "b", body)
+	payloads, size := store.depth(spillKindStats)
+	assert.EqualValues(t, 1, payloads)
+	assert.EqualValues(t, 100, size)
+}
@@ -44,6 +44,7 @@ const (
 // This implements the stats.Writer interface.
 type DatadogStatsWriter struct {
 	senders []*sender
+	spill   *spillStore
 	stop    chan struct{}
 	stats   *info.StatsWriterInfo
 	conf    *config.AgentConfig
@@ -68,6 +69,7 @@ func NewStatsWriter(
 ) *DatadogStatsWriter {
 	sw := &DatadogStatsWriter{
 		stats:     &info.StatsWriterInfo{},
+		spill:     getSpillStore(cfg),
 		stop:      make(chan struct{}),
 		flushChan: make(chan chan struct{}),
 		syncMode:  cfg.SynchronousFlushing,
@@ -92,7 +94,7 @@ func NewStatsWriter(
 		qsize = int(math.Max(1, maxmem/payloadSize))
 	}
 	log.Debugf("Stats writer initialized (climit=%d qsize=%d)", climit, qsize)
-	sw.senders = newSenders(cfg, sw, pathStats, climit, qsize, telemetryCollector, statsd)
+	sw.senders = newSenders(cfg, sw, pathStats, climit, qsize, sw.spill, spillKindStats, telemetryCollector, statsd)
 	return sw
 }
 
@@ -364,6 +366,12 @@ func (w *DatadogStatsWriter) report() {
 	_ = w.statsd.Count("datadog.trace_agent.stats_writer.retries", w.stats.Retries.Swap(0), nil, 1)
 	_ = w.statsd.Count("datadog.trace_agent.stats_writer.splits", w.stats.Splits.Swap(0), nil, 1)
 	_ = w.statsd.Count("datadog.trace_agent.stats_writer.errors", w.stats.Errors.Swap(0), nil, 1)
+	if w.spill != nil {
+		payloads, size := w.spill.depth(spillKindStats)
+		_ = w.statsd.Gauge("datadog.trace_agent.stats_writer.spill.payloads", float64(payloads), nil, 1)
+		_ = w.statsd.Gauge("datadog.trace_agent.stats_writer.spill.bytes", float64(size), nil, 1)
+		info.UpdateStatsWriterSpill(payloads, size)
+	}
 }
 
 // recordEvent implements eventRecorder.
@@ -391,5 +399,10 @@ func (w *DatadogStatsWriter) recordEvent(t eventType, data *eventData) {
 		w.easylog.Warn("Stats writer queue full. Payload dropped (%.2fKB).", float64(data.bytes)/1024)
 		_ = w.statsd.Count("datadog.trace_agent.stats_writer.dropped", 1, nil, 1)
 		_ = w.statsd.Count("datadog.trace_agent.stats_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpilled:
+		log.Debugf("Stats payload spilled to disk (%.2fKB).", float64(data.bytes)/1024)
+		_ = w.statsd.Count("datadog.trace_agent.stats_writer.spilled", 1, nil, 1)
+		_ = w.statsd.Count("datadog.trace_agent.stats_writer.spilled_bytes", int64(data.bytes), nil, 1)
 	}
 }
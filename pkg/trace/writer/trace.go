@@ -62,6 +62,7 @@ type TraceWriter struct {
 	hostname     string
 	env          string
 	senders      []*sender
+	spill        *spillStore
 	stop         chan struct{}
 	stats        *info.TraceWriterInfo
 	wg           sync.WaitGroup // waits flusher + reporter + compressor
@@ -102,6 +103,7 @@ func NewTraceWriter(
 		hostname:           cfg.Hostname,
 		env:                cfg.DefaultEnv,
 		stats:              &info.TraceWriterInfo{},
+		spill:              getSpillStore(cfg),
 		stop:               make(chan struct{}),
 		flushChan:          make(chan chan struct{}),
 		syncMode:           cfg.SynchronousFlushing,
@@ -128,7 +130,7 @@ func NewTraceWriter(
 
 	qsize := 1
 	log.Infof("Trace writer initialized (climit=%d qsize=%d compression=%s)", climit, qsize, compressor.Encoding())
-	tw.senders = newSenders(cfg, tw, pathTraces, climit, qsize, telemetryCollector, statsd)
+	tw.senders = newSenders(cfg, tw, pathTraces, climit, qsize, tw.spill, spillKindTraces, telemetryCollector, statsd)
 	tw.wg.Add(1)
 	go tw.timeFlush()
 	tw.wg.Add(1)
@@ -326,6 +328,12 @@ func (w *TraceWriter) report() {
 	_ = w.statsd.Count("datadog.trace_agent.trace_writer.traces", w.stats.Traces.Swap(0), nil, 1)
 	_ = w.statsd.Count("datadog.trace_agent.trace_writer.events", w.stats.Events.Swap(0), nil, 1)
 	_ = w.statsd.Count("datadog.trace_agent.trace_writer.spans", w.stats.Spans.Swap(0), nil, 1)
+	if w.spill != nil {
+		payloads, size := w.spill.depth(spillKindTraces)
+		_ = w.statsd.Gauge("datadog.trace_agent.trace_writer.spill.payloads", float64(payloads), nil, 1)
+		_ = w.statsd.Gauge("datadog.trace_agent.trace_writer.spill.bytes", float64(size), nil, 1)
+		info.UpdateTraceWriterSpill(payloads, size)
+	}
 }
 
 var _ eventRecorder = (*TraceWriter)(nil)
@@ -358,5 +366,10 @@ func (w *TraceWriter) recordEvent(t eventType, data *eventData) {
 		w.easylog.Warn("Trace Payload dropped (%.2fKB).", float64(data.bytes)/1024)
 		_ = w.statsd.Count("datadog.trace_agent.trace_writer.dropped", 1, nil, 1)
 		_ = w.statsd.Count("datadog.trace_agent.trace_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpilled:
+		log.Debugf("Trace payload spilled to disk (%.2fKB).", float64(data.bytes)/1024)
+		_ = w.statsd.Count("datadog.trace_agent.trace_writer.spilled", 1, nil, 1)
+		_ = w.statsd.Count("datadog.trace_agent.trace_writer.spilled_bytes", int64(data.bytes), nil, 1)
 	}
 }